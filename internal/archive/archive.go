@@ -5,18 +5,18 @@ package archive
 import (
 	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 
 	"github.com/le-vlad/pgbranch/internal/postgres"
 	"github.com/le-vlad/pgbranch/pkg/config"
 )
 
 // Archive represents a pgbranch snapshot archive.
-// Archive format is a gzipped tar containing:
+// Archive format is a gzip or zstd compressed tar containing:
 //   - manifest.json: metadata about the snapshot
 //   - dump.pgc: pg_dump custom format file
 type Archive struct {
@@ -28,16 +28,45 @@ type Archive struct {
 type CreateOptions struct {
 	Description string
 	CreatedBy   string
+	// Compression is the codec used when writing the archive. Empty means gzip.
+	Compression string
+	// SchemaOnly dumps only the schema, leaving every table empty.
+	SchemaOnly bool
+	// ExcludeTables lists tables, or pg_dump table patterns, to leave out
+	// of the archive entirely.
+	ExcludeTables []string
+}
+
+// RestoreOptions contains optional parameters for restoring an archive.
+type RestoreOptions struct {
+	// Jobs is the number of parallel pg_restore workers. Values above 1
+	// write the dump to a temporary file first.
+	Jobs int
 }
 
 // Create creates a new archive from the specified snapshot database.
 func Create(ctx context.Context, cfg *config.Config, branchName, snapshotDBName string, opts *CreateOptions) (*Archive, error) {
+	dumpOpts := &postgres.DumpOptions{}
+	dumpMode := DumpModeFull
+	if opts != nil {
+		dumpOpts.SchemaOnly = opts.SchemaOnly
+		dumpOpts.ExcludeTables = opts.ExcludeTables
+		if opts.SchemaOnly {
+			dumpMode = DumpModeSchemaOnly
+		}
+	}
+
 	client := postgres.NewClient(cfg)
+	defer client.Close()
 
 	pgDumpVersion, _ := postgres.GetPgDumpVersion()
+	serverVersion, _ := client.ServerVersion(ctx)
 
 	var dumpBuf bytes.Buffer
-	if err := client.DumpSnapshotToWriter(ctx, snapshotDBName, &dumpBuf); err != nil {
+	if err := client.DumpDatabase(ctx, snapshotDBName, &dumpBuf, dumpOpts); err != nil {
+		if versionErr := client.CheckPgDumpVersion(ctx); versionErr != nil {
+			return nil, fmt.Errorf("failed to dump database: %w (%v)", err, versionErr)
+		}
 		return nil, fmt.Errorf("failed to dump database: %w", err)
 	}
 
@@ -50,12 +79,20 @@ func Create(ctx context.Context, cfg *config.Config, branchName, snapshotDBName
 
 	manifest := NewManifest(branchName, cfg.Database)
 	manifest.PgDumpVersion = pgDumpVersion
+	if serverVersion > 0 {
+		manifest.PgVersion = strconv.Itoa(serverVersion)
+	}
 	manifest.DumpChecksum = checksum
 	manifest.DumpSize = size
+	manifest.DumpMode = dumpMode
 
 	if opts != nil {
 		manifest.Description = opts.Description
+		manifest.ExcludedTables = opts.ExcludeTables
 		manifest.CreatedBy = opts.CreatedBy
+		if opts.Compression != "" {
+			manifest.Compression = opts.Compression
+		}
 	}
 
 	return &Archive{
@@ -64,13 +101,31 @@ func Create(ctx context.Context, cfg *config.Config, branchName, snapshotDBName
 	}, nil
 }
 
-// WriteTo writes the archive to the given writer in gzipped tar format.
+// WriteTo writes the archive to the given writer as a compressed tar, using
+// the manifest's compression codec at its default level.
 func (a *Archive) WriteTo(w io.Writer) (int64, error) {
-	gzw := gzip.NewWriter(w)
-	defer gzw.Close()
+	return a.WriteToLevel(w, DefaultCompressionLevel)
+}
 
-	tw := tar.NewWriter(gzw)
-	defer tw.Close()
+// WriteToLevel writes the archive to the given writer as a compressed tar,
+// using the manifest's compression codec at the given level.
+func (a *Archive) WriteToLevel(w io.Writer, level int) (int64, error) {
+	cw, err := newCompressor(w, a.Manifest.Compression, level)
+	if err != nil {
+		return 0, err
+	}
+	tw := tar.NewWriter(cw)
+
+	// The tar trailer and the compressor's footer are only written on
+	// Close, so on success both are closed below and their errors
+	// returned; these closes are for the early returns.
+	closed := false
+	defer func() {
+		if !closed {
+			tw.Close()
+			cw.Close()
+		}
+	}()
 
 	manifestData, err := a.Manifest.ToJSON()
 	if err != nil {
@@ -85,9 +140,31 @@ func (a *Archive) WriteTo(w io.Writer) (int64, error) {
 		return 0, fmt.Errorf("failed to write dump to archive: %w", err)
 	}
 
+	closed = true
+	if err := tw.Close(); err != nil {
+		cw.Close()
+		return 0, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := cw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish compressed archive: %w", err)
+	}
+
 	return int64(len(manifestData) + len(a.DumpData)), nil
 }
 
+// Reader returns the archive as a compressed tar stream, as written by
+// WriteToLevel, without holding the compressed archive in memory: it is
+// compressed by a goroutine as the stream is read. Closing the reader
+// before reaching the end stops the goroutine.
+func (a *Archive) Reader(level int) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := a.WriteToLevel(pw, level)
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
 // writeToTar writes a single file entry to the tar archive.
 func writeToTar(tw *tar.Writer, name string, data []byte) error {
 	header := &tar.Header{
@@ -105,14 +182,16 @@ func writeToTar(tw *tar.Writer, name string, data []byte) error {
 }
 
 // ReadFrom reads an archive from the given reader.
+// The compression codec is detected from the stream itself, so archives
+// written before the manifest recorded a codec are read as gzip.
 func ReadFrom(r io.Reader) (*Archive, error) {
-	gzr, err := gzip.NewReader(r)
+	dr, codec, err := newDecompressor(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		return nil, err
 	}
-	defer gzr.Close()
+	defer dr.Close()
 
-	tr := tar.NewReader(gzr)
+	tr := tar.NewReader(dr)
 
 	var manifest *Manifest
 	var dumpData []byte
@@ -156,6 +235,10 @@ func ReadFrom(r io.Reader) (*Archive, error) {
 		return nil, fmt.Errorf("invalid manifest: %w", err)
 	}
 
+	if manifest.CompressionCodec() != codec {
+		return nil, fmt.Errorf("compression mismatch: manifest says %s, archive is %s", manifest.CompressionCodec(), codec)
+	}
+
 	checksum, size, err := ComputeChecksum(bytes.NewReader(dumpData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify checksum: %w", err)
@@ -176,10 +259,16 @@ func ReadFrom(r io.Reader) (*Archive, error) {
 }
 
 // Restore restores the archive to the specified snapshot database.
-func (a *Archive) Restore(ctx context.Context, cfg *config.Config, snapshotDBName string) error {
+func (a *Archive) Restore(ctx context.Context, cfg *config.Config, snapshotDBName string, opts *RestoreOptions) error {
 	client := postgres.NewClient(cfg)
+	defer client.Close()
+
+	var restoreOpts *postgres.RestoreOptions
+	if opts != nil {
+		restoreOpts = &postgres.RestoreOptions{Jobs: opts.Jobs}
+	}
 
-	if err := client.RestoreSnapshotFromReader(ctx, snapshotDBName, bytes.NewReader(a.DumpData)); err != nil {
+	if err := client.RestoreSnapshotFromReader(ctx, snapshotDBName, bytes.NewReader(a.DumpData), restoreOpts); err != nil {
 		return fmt.Errorf("failed to restore snapshot: %w", err)
 	}
 
@@ -192,9 +281,11 @@ func (a *Archive) SaveToFile(path string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	defer f.Close()
 
 	_, err = a.WriteTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	return err
 }
 
@@ -2,6 +2,8 @@ package archive
 
 import (
 	"bytes"
+	"compress/gzip"
+	"io"
 	"os"
 	"strings"
 	"testing"
@@ -79,6 +81,38 @@ func TestManifestValidate(t *testing.T) {
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "size")
 	})
+
+	t.Run("unsupported dump mode", func(t *testing.T) {
+		m := validManifest()
+		m.DumpMode = "partial"
+		err := m.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dump mode")
+	})
+}
+
+func TestManifestDumpMode(t *testing.T) {
+	m := NewManifest("feature-1", "mydb")
+	assert.Equal(t, DumpModeFull, m.Mode(), "manifests without a dump mode are full archives")
+	assert.False(t, m.IsPartial())
+
+	m.DumpMode = DumpModeSchemaOnly
+	assert.Equal(t, DumpModeSchemaOnly, m.Mode())
+	assert.True(t, m.IsPartial())
+}
+
+func TestManifestPgMajorVersion(t *testing.T) {
+	m := NewManifest("main", "mydb")
+	assert.Equal(t, 0, m.PgMajorVersion())
+
+	m.PgVersion = "160004"
+	assert.Equal(t, 16, m.PgMajorVersion())
+
+	m.PgVersion = "90624"
+	assert.Equal(t, 9, m.PgMajorVersion())
+
+	m.PgVersion = "PostgreSQL 16.4"
+	assert.Equal(t, 0, m.PgMajorVersion())
 }
 
 func TestManifestJSONRoundTrip(t *testing.T) {
@@ -88,6 +122,8 @@ func TestManifestJSONRoundTrip(t *testing.T) {
 	m.Description = "test snapshot"
 	m.CreatedBy = "tester"
 	m.PgDumpVersion = "16.1"
+	m.DumpMode = DumpModeSchemaOnly
+	m.ExcludedTables = []string{"audit_log", "public.cache_*"}
 
 	data, err := m.ToJSON()
 	require.NoError(t, err)
@@ -104,6 +140,8 @@ func TestManifestJSONRoundTrip(t *testing.T) {
 	assert.Equal(t, m.Description, parsed.Description)
 	assert.Equal(t, m.CreatedBy, parsed.CreatedBy)
 	assert.Equal(t, m.PgDumpVersion, parsed.PgDumpVersion)
+	assert.Equal(t, m.DumpMode, parsed.DumpMode)
+	assert.Equal(t, m.ExcludedTables, parsed.ExcludedTables)
 }
 
 func TestComputeChecksum(t *testing.T) {
@@ -137,6 +175,30 @@ func TestVerifyChecksum(t *testing.T) {
 	})
 }
 
+func TestArchiveReader(t *testing.T) {
+	dumpData := []byte("fake pg_dump output")
+	m := NewManifest("feature-1", "mydb")
+	a := &Archive{Manifest: m, DumpData: dumpData}
+
+	var want bytes.Buffer
+	_, err := a.WriteToLevel(&want, DefaultCompressionLevel)
+	require.NoError(t, err)
+
+	r := a.Reader(DefaultCompressionLevel)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, want.Bytes(), got)
+
+	// Closing early stops the writer instead of blocking it.
+	r = a.Reader(DefaultCompressionLevel)
+	require.NoError(t, r.Close())
+
+	a.Manifest.Compression = "lz4"
+	_, err = io.ReadAll(a.Reader(DefaultCompressionLevel))
+	assert.Error(t, err)
+}
+
 func TestArchiveWriteToReadFromRoundTrip(t *testing.T) {
 	dumpData := []byte("fake pg_dump output")
 	checksum, size, err := ComputeChecksum(bytes.NewReader(dumpData))
@@ -166,6 +228,129 @@ func TestArchiveWriteToReadFromRoundTrip(t *testing.T) {
 	assert.Equal(t, original.DumpData, restored.DumpData)
 }
 
+func TestArchiveWriteToLevel(t *testing.T) {
+	dumpData := bytes.Repeat([]byte("fake pg_dump output "), 1000)
+	checksum, size, err := ComputeChecksum(bytes.NewReader(dumpData))
+	require.NoError(t, err)
+
+	m := NewManifest("feature-1", "mydb")
+	m.DumpChecksum = checksum
+	m.DumpSize = size
+
+	a := &Archive{Manifest: m, DumpData: dumpData}
+
+	var stored, best bytes.Buffer
+	_, err = a.WriteToLevel(&stored, gzip.NoCompression)
+	require.NoError(t, err)
+	_, err = a.WriteToLevel(&best, gzip.BestCompression)
+	require.NoError(t, err)
+
+	assert.Less(t, best.Len(), stored.Len())
+
+	restored, err := ReadFrom(&best)
+	require.NoError(t, err)
+	assert.Equal(t, dumpData, restored.DumpData)
+
+	_, err = a.WriteToLevel(&bytes.Buffer{}, 10)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid gzip compression level")
+}
+
+// failingWriter fails every write, like a full disk or a broken pipe.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestArchiveWriteToLevelReportsFinalFlush(t *testing.T) {
+	m := NewManifest("feature-1", "mydb")
+	m.DumpChecksum = "abc123"
+	m.DumpSize = 4
+
+	for _, codec := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(codec, func(t *testing.T) {
+			m.Compression = codec
+			a := &Archive{Manifest: m, DumpData: []byte("data")}
+
+			// Small archives stay buffered in the compressor until it is
+			// closed, so the write only fails there.
+			_, err := a.WriteToLevel(failingWriter{}, DefaultCompressionLevel)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, io.ErrClosedPipe)
+		})
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly, DefaultCompressionLevel, 0, 1, 9} {
+		assert.NoError(t, ValidateCompression(CompressionGzip, level), "gzip level %d", level)
+	}
+	for _, level := range []int{-3, 10, 100} {
+		assert.Error(t, ValidateCompression(CompressionGzip, level), "gzip level %d", level)
+	}
+	for _, level := range []int{DefaultCompressionLevel, 1, 3, 22} {
+		assert.NoError(t, ValidateCompression(CompressionZstd, level), "zstd level %d", level)
+	}
+	for _, level := range []int{0, 23} {
+		assert.Error(t, ValidateCompression(CompressionZstd, level), "zstd level %d", level)
+	}
+	assert.NoError(t, ValidateCompression("", DefaultCompressionLevel))
+	assert.Error(t, ValidateCompression("lz4", DefaultCompressionLevel))
+}
+
+func TestArchiveZstdRoundTrip(t *testing.T) {
+	dumpData := bytes.Repeat([]byte("fake pg_dump output "), 1000)
+	checksum, size, err := ComputeChecksum(bytes.NewReader(dumpData))
+	require.NoError(t, err)
+
+	m := NewManifest("feature-1", "mydb")
+	m.DumpChecksum = checksum
+	m.DumpSize = size
+	m.Compression = CompressionZstd
+
+	a := &Archive{Manifest: m, DumpData: dumpData}
+
+	var buf bytes.Buffer
+	_, err = a.WriteToLevel(&buf, 19)
+	require.NoError(t, err)
+	assert.Equal(t, zstdMagic, buf.Bytes()[:4])
+
+	restored, err := ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, CompressionZstd, restored.Manifest.Compression)
+	assert.Equal(t, dumpData, restored.DumpData)
+}
+
+func TestReadFromLegacyGzipArchive(t *testing.T) {
+	dumpData := []byte("fake pg_dump output")
+	checksum, size, err := ComputeChecksum(bytes.NewReader(dumpData))
+	require.NoError(t, err)
+
+	// Archives written before the compression field existed have no codec
+	// in their manifest.
+	m := NewManifest("feature-1", "mydb")
+	m.DumpChecksum = checksum
+	m.DumpSize = size
+	m.Compression = ""
+
+	var buf bytes.Buffer
+	_, err = (&Archive{Manifest: m, DumpData: dumpData}).WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, gzipMagic, buf.Bytes()[:2])
+
+	restored, err := ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, CompressionGzip, restored.Manifest.CompressionCodec())
+	assert.Equal(t, dumpData, restored.DumpData)
+}
+
+func TestReadFromUnknownCompression(t *testing.T) {
+	_, err := ReadFrom(strings.NewReader("not an archive"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unrecognized archive compression")
+}
+
 func TestArchiveSize(t *testing.T) {
 	m := NewManifest("feature-1", "mydb")
 	m.DumpSize = 12345
@@ -0,0 +1,94 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionGzip compresses the archive with gzip. Archives written
+	// before the compression field existed are always gzip.
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses the archive with zstd.
+	CompressionZstd = "zstd"
+
+	// DefaultCompressionLevel selects the codec's default level.
+	DefaultCompressionLevel = -1
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ValidateCompression checks that codec is a supported compression codec and
+// that level is valid for it. An empty codec means gzip. Gzip accepts
+// -2 (Huffman only) through 9; zstd accepts 1 through 22. Both accept -1 for
+// the codec's default level.
+func ValidateCompression(codec string, level int) error {
+	switch codec {
+	case "", CompressionGzip:
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return fmt.Errorf("invalid gzip compression level %d: must be between %d and %d (-1 for default)",
+				level, gzip.HuffmanOnly, gzip.BestCompression)
+		}
+	case CompressionZstd:
+		if level != DefaultCompressionLevel && (level < 1 || level > 22) {
+			return fmt.Errorf("invalid zstd compression level %d: must be between 1 and 22 (-1 for default)", level)
+		}
+	default:
+		return fmt.Errorf("unsupported compression %q: must be %q or %q", codec, CompressionGzip, CompressionZstd)
+	}
+	return nil
+}
+
+// newCompressor wraps w with a writer for the given codec and level.
+func newCompressor(w io.Writer, codec string, level int) (io.WriteCloser, error) {
+	if err := ValidateCompression(codec, level); err != nil {
+		return nil, err
+	}
+
+	switch codec {
+	case CompressionZstd:
+		opts := []zstd.EOption{}
+		if level != DefaultCompressionLevel {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	default:
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+// newDecompressor detects the codec of r from its magic bytes and returns a
+// reader for the decompressed stream along with the detected codec.
+func newDecompressor(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && len(magic) < len(gzipMagic) {
+		return nil, "", fmt.Errorf("failed to read archive header: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		return gzr, CompressionGzip, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), CompressionZstd, nil
+	default:
+		return nil, "", fmt.Errorf("unrecognized archive compression")
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
+
+	"github.com/le-vlad/pgbranch/internal/postgres"
 )
 
 const (
@@ -18,6 +21,14 @@ const (
 	CurrentVersion = 1
 )
 
+const (
+	// DumpModeFull archives contain both schema and data. Archives written
+	// before the dump mode field existed are always full.
+	DumpModeFull = "full"
+	// DumpModeSchemaOnly archives contain the schema with every table empty.
+	DumpModeSchemaOnly = "schema-only"
+)
+
 // Manifest contains metadata about a snapshot archive.
 type Manifest struct {
 	Version int `json:"version"`
@@ -30,6 +41,8 @@ type Manifest struct {
 
 	CreatedBy string `json:"created_by,omitempty"`
 
+	// PgVersion is the server_version_num of the server the archive was
+	// dumped from.
 	PgVersion string `json:"pg_version,omitempty"`
 
 	PgDumpVersion string `json:"pg_dump_version,omitempty"`
@@ -41,16 +54,56 @@ type Manifest struct {
 	Parent string `json:"parent,omitempty"`
 
 	Description string `json:"description,omitempty"`
+
+	Compression string `json:"compression,omitempty"`
+
+	DumpMode string `json:"dump_mode,omitempty"`
+
+	ExcludedTables []string `json:"excluded_tables,omitempty"`
 }
 
 // NewManifest creates a new manifest with the given branch and database names.
 func NewManifest(branch, database string) *Manifest {
 	return &Manifest{
-		Version:   CurrentVersion,
-		Branch:    branch,
-		Database:  database,
-		CreatedAt: time.Now().UTC(),
+		Version:     CurrentVersion,
+		Branch:      branch,
+		Database:    database,
+		CreatedAt:   time.Now().UTC(),
+		Compression: CompressionGzip,
+	}
+}
+
+// PgMajorVersion returns the major version of the server the archive was
+// dumped from, or 0 when it is unknown.
+func (m *Manifest) PgMajorVersion() int {
+	version, err := strconv.Atoi(m.PgVersion)
+	if err != nil || version <= 0 {
+		return 0
 	}
+	return postgres.MajorVersion(version)
+}
+
+// CompressionCodec returns the archive's compression codec. Manifests
+// written before the field existed describe gzip archives.
+func (m *Manifest) CompressionCodec() string {
+	if m.Compression == "" {
+		return CompressionGzip
+	}
+	return m.Compression
+}
+
+// Mode returns the archive's dump mode. Manifests written before the field
+// existed describe full archives.
+func (m *Manifest) Mode() string {
+	if m.DumpMode == "" {
+		return DumpModeFull
+	}
+	return m.DumpMode
+}
+
+// IsPartial reports whether the archive lacks the data.
+func (m *Manifest) IsPartial() bool {
+	return m.Mode() != DumpModeFull
 }
 
 // Validate checks that all required manifest fields are set and valid.
@@ -73,6 +126,16 @@ func (m *Manifest) Validate() error {
 	if m.DumpSize == 0 {
 		return fmt.Errorf("dump size is required")
 	}
+	switch m.CompressionCodec() {
+	case CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("unsupported compression %q", m.Compression)
+	}
+	switch m.Mode() {
+	case DumpModeFull, DumpModeSchemaOnly:
+	default:
+		return fmt.Errorf("unsupported dump mode %q", m.DumpMode)
+	}
 	return nil
 }
 
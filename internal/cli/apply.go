@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/schema"
+)
+
+func newApplyCmd() *cobra.Command {
+	var (
+		force  bool
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "apply <branch> <file.sql>",
+		Short: "Run a SQL file against a branch",
+		Long: `Run the statements of a SQL file, such as one written by
+'merge --migration-file', against a branch's snapshot.
+
+The statements of a file without BEGIN or COMMIT run in one transaction:
+if any fails, none of them is kept, and the failing statement is shown
+with its line in the file. In a file with BEGIN and COMMIT, the statements
+between them run in one transaction, and each statement outside them runs
+on its own, so statements that can't run inside a transaction, such as the
+ALTER TYPE ... ADD VALUE that merge writes before BEGIN for old servers,
+work there. A failure then keeps the statements that ran before it.
+Of a goose migration file, only the statements before '-- +goose Down'
+run.
+
+When the branch is checked out, the file runs against the working database
+first and then against the branch's snapshot, as merge does, so the two
+don't diverge. If it fails on the snapshot, the working database keeps its
+changes; run 'pgbranch update' to save them to the snapshot.
+
+Examples:
+  pgbranch apply main migrations/20240101120000_merge_feature-auth.sql
+  pgbranch apply main fix.sql --dry-run
+  pgbranch apply main fix.sql -f`,
+		Args: cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return completeBranches(1)(cmd, args, toComplete)
+			}
+			if len(args) == 1 {
+				return []string{"sql"}, cobra.ShellCompDirectiveFilterFileExt
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			branchName, path := args[0], args[1]
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read SQL file: %w", err)
+			}
+
+			batches := schema.SplitBatches(schema.SplitStatements(string(data)))
+			var count int
+			for _, batch := range batches {
+				count += len(batch.Statements)
+			}
+			if count == 0 {
+				return fmt.Errorf("no SQL statements in '%s'", path)
+			}
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			if !brancher.Metadata.BranchExists(branchName) {
+				return brancher.BranchNotFoundError(branchName)
+			}
+
+			if dryRun {
+				fmt.Printf("Would run %d statement(s) from '%s' on '%s':\n\n", count, path, branchName)
+				for _, batch := range batches {
+					// Show where transactions start and end when not
+					// everything runs in one.
+					wrap := batch.Transactional && len(batches) > 1
+					if wrap {
+						fmt.Print("BEGIN;\n\n")
+					}
+					for _, stmt := range batch.Statements {
+						fmt.Printf("%s;\n\n", stmt.SQL)
+					}
+					if wrap {
+						fmt.Print("COMMIT;\n\n")
+					}
+				}
+				return nil
+			}
+
+			if !force && !confirmPrompt(fmt.Sprintf("Run %d statement(s) from '%s' on '%s'?", count, path, branchName)) {
+				fmt.Println("Apply cancelled.")
+				return nil
+			}
+
+			databases, err := brancher.MergeDatabases(branchName)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			printConnection(brancher.Config)
+
+			yellow := color.New(color.FgYellow).SprintFunc()
+			red := color.New(color.FgRed).SprintFunc()
+
+			for i, dbName := range databases {
+				if dbName == brancher.Config.Database {
+					infof("Applying '%s' to working database '%s' ('%s' is checked out)...\n", path, dbName, branchName)
+				} else {
+					infof("Applying '%s' to '%s'...\n", path, branchName)
+				}
+
+				start := time.Now()
+				kept, err := applyBatches(ctx, brancher.Config.ConnectionURLForDB(dbName), batches)
+				verbosef("Applying to '%s' took %s\n", dbName, time.Since(start).Round(time.Millisecond))
+				if err != nil {
+					var stmtErr *schema.StatementError
+					if errors.As(err, &stmtErr) {
+						if kept == 0 {
+							fmt.Printf("\n%s Statement at %s:%d failed; no changes were kept:\n\n",
+								red("✗"), path, stmtErr.Statement.Line)
+						} else {
+							fmt.Printf("\n%s Statement at %s:%d failed; the %d statement(s) committed before it were kept:\n\n",
+								red("✗"), path, stmtErr.Statement.Line, kept)
+						}
+						fmt.Printf("  %s\n\n", stmtErr.Statement.SQL)
+						fmt.Printf("  Error: %v\n", stmtErr.Err)
+					}
+					if i > 0 {
+						fmt.Printf("\n%s The working database '%s' was changed, but the snapshot of '%s' was not",
+							yellow("⚠"), databases[0], branchName)
+						if kept > 0 {
+							fmt.Print(" fully")
+						}
+						fmt.Printf(".\n  Run 'pgbranch update %s' to save the working database to the snapshot.\n", branchName)
+						return fmt.Errorf("failed to apply '%s' to the snapshot of '%s', which no longer matches the working database: %w",
+							path, branchName, err)
+					}
+					return fmt.Errorf("failed to apply '%s' to '%s': %w", path, branchName, err)
+				}
+			}
+
+			if err := brancher.RecordSchema(ctx, branchName); err != nil {
+				fmt.Printf("%s Could not record the new schema of '%s': %v\n", yellow("⚠"), branchName, err)
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Applied %d statement(s) from '%s' to '%s'\n", green("✓"), count, path, branchName)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip the confirmation prompt")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the statements without running them")
+	cmd.MarkFlagsMutuallyExclusive("force", "dry-run")
+
+	return cmd
+}
+
+func applyBatches(ctx context.Context, connURL string, batches []schema.Batch) (int, error) {
+	conn, err := pgx.Connect(ctx, connURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	return schema.ApplyBatches(ctx, conn, batches)
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/le-vlad/pgbranch/internal/archive"
+	"github.com/spf13/cobra"
+)
+
+func newArchiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Inspect archive files",
+		Long: `Inspect branch archive files written by 'pgbranch export' or stored on a remote.
+
+These commands never connect to a database.`,
+	}
+
+	cmd.AddCommand(newArchiveVerifyCmd())
+
+	return cmd
+}
+
+func newArchiveVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify <file>",
+		Short: "Check an archive file's integrity",
+		Long: `Read an archive file, check that its manifest is valid and that the dump
+matches the recorded checksum and size, and print the manifest.
+
+Exits with a non-zero status if the archive is corrupt, so it can be used
+in CI before deploying an archive.
+
+Examples:
+  pgbranch archive verify main.pgbranch
+  pgbranch archive verify /shared/snapshots/main.pgbranch`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			}
+			defer f.Close()
+
+			arch, err := archive.ReadFrom(f)
+			if err != nil {
+				return fmt.Errorf("archive '%s' is invalid: %w", path, err)
+			}
+
+			m := arch.Manifest
+			dim := color.New(color.Faint).SprintFunc()
+
+			fmt.Printf("Branch:          %s\n", m.Branch)
+			fmt.Printf("Database:        %s\n", m.Database)
+			if major := m.PgMajorVersion(); major > 0 {
+				fmt.Printf("PostgreSQL:      %d\n", major)
+			}
+			fmt.Printf("Created:         %s\n", m.CreatedAt.Format("2006-01-02 15:04:05"))
+			if m.Description != "" {
+				fmt.Printf("Description:     %s\n", m.Description)
+			}
+			if m.PgDumpVersion != "" {
+				fmt.Printf("pg_dump version: %s\n", m.PgDumpVersion)
+			}
+			fmt.Printf("Dump size:       %s\n", formatSize(m.DumpSize))
+			fmt.Printf("Compression:     %s\n", m.CompressionCodec())
+			fmt.Printf("Contents:        %s\n", m.Mode())
+			if len(m.ExcludedTables) > 0 {
+				fmt.Printf("Excluded tables: %v\n", m.ExcludedTables)
+			}
+			fmt.Printf("Checksum:        %s\n", dim(m.DumpChecksum))
+			fmt.Println()
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Archive is valid (checksum OK)\n", green("✓"))
+
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -1,12 +1,31 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/postgres"
+	"github.com/le-vlad/pgbranch/internal/remote"
+	"github.com/le-vlad/pgbranch/internal/storage"
+)
+
+var (
+	branchFrom       string
+	branchFromRemote string
+	branchRemote     string
+	branchAll        bool
+	branchForce      bool
+	branchAllowNewer bool
+	branchMessage    string
+	branchLabel      string
+
+	branchOutput outputFlags
 )
 
 var branchCmd = &cobra.Command{
@@ -16,57 +35,504 @@ var branchCmd = &cobra.Command{
 
 Without arguments, lists all branches.
 With a name argument, creates a new branch from the current database state.
+Use --from to branch off another branch's snapshot instead, or
+--from-remote to download a branch from a remote and restore it under the
+new name, like 'pgbranch pull <remote-branch> --as <name>'.
+Use -m to give the new branch a description, shown by 'pgbranch branch'
+and 'pgbranch log'; 'pgbranch branch describe' changes it later.
+Use -a to also list branches on remotes, as recorded by 'pgbranch fetch'.
+Use --json to list local branches as JSON, including their snapshot sizes.
+Use --label to list only branches with that label; 'pgbranch branch
+label' adds and removes labels.
+
+When PostgreSQL runs on this machine, creating a branch is refused if
+its disk doesn't have room for the copy. Use --force to create it anyway.
+Use --allow-newer-version to restore a remote archive created on a newer
+PostgreSQL major version.
 
 Examples:
-  pgbranch branch           # List all branches
-  pgbranch branch main      # Create branch 'main'
-  pgbranch branch feature-x # Create branch 'feature-x'`,
+  pgbranch branch                                       # List all branches
+  pgbranch branch -a                                    # List local and remote branches
+  pgbranch branch --json                                # List branches as JSON
+  pgbranch branch --label wip                           # List branches labeled 'wip'
+  pgbranch branch main                                  # Create branch 'main'
+  pgbranch branch feature-x                             # Create branch 'feature-x'
+  pgbranch branch feature-x -m "Login rework"           # Create it with a description
+  pgbranch branch feature-y --from main                 # Create 'feature-y' from 'main'
+  pgbranch branch staging --from-remote main            # From 'main' on the default remote
+  pgbranch branch staging --from-remote main -r backup  # From 'main' on 'backup'`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runBranch,
 }
 
+func init() {
+	branchCmd.Flags().StringVar(&branchFrom, "from", "", "Create the branch from an existing branch's snapshot")
+	branchCmd.Flags().BoolVarP(&branchAll, "all", "a", false, "List local branches and remote-only branches from the last fetch")
+	branchCmd.Flags().StringVar(&branchFromRemote, "from-remote", "", "Create the branch from a branch on a remote")
+	branchCmd.Flags().StringVarP(&branchRemote, "remote", "r", "", "Remote to use with --from-remote (default: use default remote)")
+	branchCmd.Flags().BoolVarP(&branchForce, "force", "f", false, "Create the branch even if the server looks short on disk space")
+	branchCmd.Flags().BoolVar(&branchAllowNewer, "allow-newer-version", false, "With --from-remote, restore archives created on a newer PostgreSQL major version")
+	branchCmd.Flags().StringVarP(&branchMessage, "message", "m", "", "Description of the new branch")
+	branchCmd.Flags().StringVarP(&branchLabel, "label", "l", "", "Only list branches with this label")
+	branchOutput.register(branchCmd)
+	branchCmd.MarkFlagsMutuallyExclusive("from", "from-remote")
+	branchCmd.RegisterFlagCompletionFunc("from", completeBranchFlag)
+	branchCmd.RegisterFlagCompletionFunc("from-remote", completeRemoteBranchFlag)
+
+	branchCmd.AddCommand(newBranchDescribeCmd())
+	branchCmd.AddCommand(newBranchLabelCmd())
+	branchCmd.AddCommand(newBranchProtectCmd(true))
+	branchCmd.AddCommand(newBranchProtectCmd(false))
+}
+
 func runBranch(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	brancher, err := core.NewBrancher()
 	if err != nil {
 		return err
 	}
+	defer brancher.Close()
+
+	jsonOutput, err := branchOutput.isJSON()
+	if err != nil {
+		return err
+	}
+
+	if branchRemote != "" && branchFromRemote == "" {
+		return fmt.Errorf("--remote can only be used with --from-remote")
+	}
 
 	if len(args) == 0 {
+		if branchFrom != "" {
+			return fmt.Errorf("--from requires a branch name")
+		}
+		if branchFromRemote != "" {
+			return fmt.Errorf("--from-remote requires a branch name")
+		}
+		if branchMessage != "" {
+			return fmt.Errorf("--message requires a branch name")
+		}
+		if jsonOutput {
+			if branchAll {
+				return fmt.Errorf("--all cannot be used with JSON output")
+			}
+			return printJSON(branchesJSON(ctx, brancher, filterByLabel(brancher.ListBranches(), branchLabel), false))
+		}
 		return listBranches(brancher)
 	}
 
+	if branchLabel != "" {
+		return fmt.Errorf("--label can only be used when listing branches")
+	}
+
+	if jsonOutput {
+		return fmt.Errorf("JSON output is only available when listing branches")
+	}
+
+	if branchAll {
+		return fmt.Errorf("--all cannot be used when creating a branch")
+	}
+
 	name := args[0]
-	return createBranch(brancher, name)
+	if branchFromRemote != "" {
+		err = createBranchFromRemote(ctx, brancher, name)
+	} else {
+		err = createBranch(ctx, brancher, name)
+	}
+	if err != nil || branchMessage == "" {
+		return err
+	}
+	return brancher.SetDescription(name, branchMessage)
+}
+
+func newBranchLabelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "label",
+		Short: "Add or remove branch labels",
+		Long: `Add or remove labels, short tags for organizing branches.
+
+List the branches with a label with 'pgbranch branch --label <label>'.
+Branches labeled 'keep' are never considered stale, so 'pgbranch prune'
+leaves them alone.
+
+Examples:
+  pgbranch branch label add feature-x wip
+  pgbranch branch label add main keep
+  pgbranch branch label remove feature-x wip`,
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:               "add <name> <label>",
+			Short:             "Add a label to a branch",
+			Args:              cobra.ExactArgs(2),
+			ValidArgsFunction: completeBranches(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				brancher, err := core.NewBrancher()
+				if err != nil {
+					return err
+				}
+				defer brancher.Close()
+
+				if err := brancher.AddLabel(args[0], args[1]); err != nil {
+					return err
+				}
+
+				green := color.New(color.FgGreen).SprintFunc()
+				infof("%s Labeled branch '%s' with '%s'\n", green("✓"), args[0], args[1])
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:               "remove <name> <label>",
+			Aliases:           []string{"rm"},
+			Short:             "Remove a label from a branch",
+			Args:              cobra.ExactArgs(2),
+			ValidArgsFunction: completeBranches(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				brancher, err := core.NewBrancher()
+				if err != nil {
+					return err
+				}
+				defer brancher.Close()
+
+				if err := brancher.RemoveLabel(args[0], args[1]); err != nil {
+					return err
+				}
+
+				green := color.New(color.FgGreen).SprintFunc()
+				infof("%s Removed label '%s' from branch '%s'\n", green("✓"), args[1], args[0])
+				return nil
+			},
+		},
+	)
+
+	return cmd
+}
+
+// newBranchProtectCmd returns 'branch protect' or, when protect is false,
+// 'branch unprotect'.
+func newBranchProtectCmd(protect bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "protect <name>",
+		Short: "Protect a branch from deletion and pruning",
+		Long: `Protect a branch from deletion and pruning. 'pgbranch delete' refuses
+to delete a protected branch unless --force is given, and 'pgbranch prune'
+always keeps it.
+
+Example:
+  pgbranch branch protect main`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeBranches(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			if err := brancher.SetProtected(name, protect); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			if protect {
+				infof("%s Protected branch '%s'\n", green("✓"), name)
+			} else {
+				infof("%s Removed protection from branch '%s'\n", green("✓"), name)
+			}
+
+			return nil
+		},
+	}
+
+	if !protect {
+		cmd.Use = "unprotect <name>"
+		cmd.Short = "Allow a protected branch to be deleted and pruned again"
+		cmd.Long = `Remove the protection added by 'pgbranch branch protect'.
+
+Example:
+  pgbranch branch unprotect main`
+	}
+
+	return cmd
+}
+
+func newBranchDescribeCmd() *cobra.Command {
+	var message string
+
+	cmd := &cobra.Command{
+		Use:   "describe <name> -m <description>",
+		Short: "Set a branch's description",
+		Long: `Set the description of a branch, shown by 'pgbranch branch' and
+'pgbranch log'. Pass an empty description to remove it.
+
+Examples:
+  pgbranch branch describe feature-x -m "Login rework, needs seed data"
+  pgbranch branch describe feature-x -m ""`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeBranches(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			if err := brancher.SetDescription(name, message); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			if message == "" {
+				infof("%s Removed the description of branch '%s'\n", green("✓"), name)
+			} else {
+				infof("%s Updated the description of branch '%s'\n", green("✓"), name)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&message, "message", "m", "", "Description of the branch")
+	cmd.MarkFlagRequired("message")
+
+	return cmd
 }
 
 func listBranches(b *core.Brancher) error {
-	branches := b.ListBranches()
+	branches := filterByLabel(b.ListBranches(), branchLabel)
+
+	// Remote branches have no labels, so a label filter leaves them out.
+	var remoteOnly []string
+	if branchAll && branchLabel == "" {
+		remoteOnly = remoteOnlyBranches(b)
+	}
 
-	if len(branches) == 0 {
+	if branchLabel != "" && len(branches) == 0 {
+		fmt.Printf("No branches labeled '%s'.\n", branchLabel)
+		return nil
+	}
+
+	if len(branches) == 0 && len(remoteOnly) == 0 {
 		fmt.Println("No branches yet. Create one with: pgbranch branch <name>")
 		return nil
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
 
+	width := 0
 	for _, info := range branches {
+		if (info.Branch.Description != "" || len(info.Branch.Labels) > 0 || info.Branch.Protected) && len(info.Name) > width {
+			width = len(info.Name)
+		}
+	}
+
+	for _, info := range branches {
+		var details []string
+		if info.Branch.Protected {
+			details = append(details, yellow("(protected)"))
+		}
+		if len(info.Branch.Labels) > 0 {
+			details = append(details, cyan(formatLabels(info.Branch.Labels)))
+		}
+		if info.Branch.Description != "" {
+			details = append(details, dim(info.Branch.Description))
+		}
+		var suffix string
+		if len(details) > 0 {
+			suffix = strings.Repeat(" ", width-len(info.Name)+2) + strings.Join(details, " ")
+		}
 		if info.IsCurrent {
-			fmt.Printf("* %s\n", green(info.Name))
+			fmt.Printf("* %s%s\n", green(info.Name), suffix)
 		} else {
-			fmt.Printf("  %s\n", info.Name)
+			fmt.Printf("  %s%s\n", info.Name, suffix)
+		}
+	}
+
+	for _, name := range remoteOnly {
+		fmt.Printf("  %s\n", red(name))
+	}
+
+	return nil
+}
+
+// filterByLabel returns the branches that have label, or all of them if
+// label is empty.
+func filterByLabel(branches []core.BranchInfo, label string) []core.BranchInfo {
+	if label == "" {
+		return branches
+	}
+	var result []core.BranchInfo
+	for _, info := range branches {
+		if info.Branch.HasLabel(label) {
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// formatLabels formats branch labels for listings, as "[keep, wip]".
+func formatLabels(labels []string) string {
+	return "[" + strings.Join(labels, ", ") + "]"
+}
+
+// remoteOnlyBranches returns tracked remote branches that have no local
+// branch of the same name, formatted as remotes/<remote>/<branch>.
+func remoteOnlyBranches(b *core.Brancher) []string {
+	remoteNames := make([]string, 0, len(b.Metadata.RemoteTracking))
+	for name := range b.Metadata.RemoteTracking {
+		remoteNames = append(remoteNames, name)
+	}
+	sort.Strings(remoteNames)
+
+	var result []string
+	for _, remoteName := range remoteNames {
+		tracking := b.Metadata.RemoteTracking[remoteName]
+		for _, ref := range tracking.Branches {
+			if b.Metadata.BranchExists(ref.Name) {
+				continue
+			}
+			result = append(result, fmt.Sprintf("remotes/%s/%s", remoteName, ref.Name))
 		}
 	}
 
+	return result
+}
+
+func createBranch(ctx context.Context, b *core.Brancher, name string) error {
+	green := color.New(color.FgGreen).SprintFunc()
+
+	if branchFrom != "" {
+		return createBranchFrom(ctx, b, name, branchFrom, branchForce)
+	}
+
+	if err := checkDiskSpace(ctx, b, b.Config.Database, branchForce); err != nil {
+		return err
+	}
+
+	if err := b.CreateBranch(ctx, name); err != nil {
+		return err
+	}
+
+	infof("%s Created branch '%s'\n", green("✓"), name)
+
 	return nil
 }
 
-func createBranch(b *core.Brancher, name string) error {
-	if err := b.CreateBranch(name); err != nil {
+// createBranchFrom creates branch name as a copy of the existing branch
+// source's snapshot.
+func createBranchFrom(ctx context.Context, b *core.Brancher, name, source string, force bool) error {
+	sourceBranch, ok := b.Metadata.GetBranch(source)
+	if !ok {
+		return storage.NewBranchNotFoundError(source)
+	}
+	if err := checkDiskSpace(ctx, b, sourceBranch.Snapshot, force); err != nil {
+		return err
+	}
+	if err := b.CreateBranchFrom(ctx, name, source); err != nil {
 		return err
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Printf("%s Created branch '%s'\n", green("✓"), name)
+	infof("%s Created branch '%s' from '%s'\n", green("✓"), name, source)
+
+	return nil
+}
+
+// createBranchFromRemote downloads branchFromRemote from a remote and
+// restores it as the new root branch name, recording where it came from.
+func createBranchFromRemote(ctx context.Context, b *core.Brancher, name string) error {
+	if err := postgres.CheckTools(); err != nil {
+		return err
+	}
+
+	if err := b.ValidateBranchName(name); err != nil {
+		return err
+	}
+
+	if b.Metadata.BranchExists(name) {
+		return storage.NewBranchExistsError(name)
+	}
+
+	remoteCfg, err := b.Config.GetRemote(branchRemote)
+	if err != nil {
+		return err
+	}
+
+	r, err := remote.New(&remote.Config{
+		Name:    remoteCfg.Name,
+		Type:    remoteCfg.Type,
+		URL:     remoteCfg.URL,
+		Options: remoteCfg.Options,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create remote: %w", err)
+	}
+
+	exists, err := r.Exists(ctx, branchFromRemote)
+	if err != nil {
+		return fmt.Errorf("failed to check remote: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("branch '%s' not found on remote '%s'", branchFromRemote, remoteCfg.Name)
+	}
+
+	source := remoteSource(ctx, r, remoteCfg.Name, branchFromRemote)
+
+	printConnection(b.Config)
+	infof("Pulling '%s' from remote '%s'...\n", branchFromRemote, remoteCfg.Name)
+
+	arch, err := downloadArchive(ctx, r, branchFromRemote)
+	if err != nil {
+		return err
+	}
+
+	printArchiveInfo(arch, name)
+
+	if err := checkServerVersion(ctx, b, arch, branchAllowNewer); err != nil {
+		return err
+	}
+
+	if err := restoreArchiveAsBranch(ctx, b, arch, name, source, 1); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	infof("%s Created branch '%s' from '%s' on remote '%s'\n", green("✓"), name, branchFromRemote, remoteCfg.Name)
+
+	return nil
+}
+
+// checkDiskSpace refuses to copy dbName when the server's disk doesn't have
+// room for it, unless force is set. When the check itself fails, the copy
+// goes ahead; the reason is only shown with --verbose.
+func checkDiskSpace(ctx context.Context, b *core.Brancher, dbName string, force bool) error {
+	space, err := b.CheckDiskSpace(ctx, dbName)
+	if err != nil {
+		verbosef("Skipping disk space check: %v\n", err)
+		return nil
+	}
+	if space == nil || space.Sufficient() {
+		return nil
+	}
+
+	if !force {
+		return fmt.Errorf("not enough disk space to copy '%s': it needs about %s, only %s is free in %s. Use --force to copy it anyway",
+			dbName, formatSize(space.Required), formatSize(int64(space.Available)), space.Path)
+	}
 
+	yellow := color.New(color.FgYellow).SprintFunc()
+	fmt.Printf("%s Copying '%s' needs about %s, only %s is free in %s\n",
+		yellow("⚠"), dbName, formatSize(space.Required), formatSize(int64(space.Available)), space.Path)
 	return nil
 }
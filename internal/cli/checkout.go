@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -10,7 +12,13 @@ import (
 )
 
 func showStaleWarning(brancher *core.Brancher) {
-	staleBranches := brancher.GetStaleBranches(core.DefaultStaleDays)
+	if quietMode {
+		return
+	}
+
+	// Count only the branches prune would offer to remove.
+	staleBranches := brancher.GetStaleBranches(core.DefaultStaleDays,
+		core.StaleOptions{ExcludeCurrent: true, ExcludeProtected: true})
 	if len(staleBranches) == 0 {
 		return
 	}
@@ -27,10 +35,15 @@ func showStaleWarning(brancher *core.Brancher) {
 	fmt.Printf("  Run '%s' to clean up stale database clones.\n", orange("pgbranch prune"))
 }
 
-var autoCreateBranch bool
+var (
+	autoCreateBranch bool
+	checkoutDryRun   bool
+	checkoutReset    bool
+	checkoutNoSave   bool
+)
 
 var checkoutCmd = &cobra.Command{
-	Use:   "checkout <branch>",
+	Use:   "checkout [branch]",
 	Short: "Switch to a different branch",
 	Long: `Switch to a different branch by restoring its snapshot.
 
@@ -39,27 +52,70 @@ This will:
 2. Drop the current database
 3. Restore the target branch's snapshot
 
+Without a branch name, lists the branches with their last checkout
+times and asks which one to switch to.
+
 Use -b to create a new branch and switch to it.
+Use --dry-run to show what would happen without changing any databases.
+Use --no-save to skip step 1 for a faster switch. Any changes made on the
+current branch since it was last saved are then lost.
+
+Checking out the current branch with --reset (or --force) restores its
+snapshot without saving first, discarding all changes to the working
+database since the branch was last saved, like 'pgbranch reset -f'.
 
 Example:
+  pgbranch checkout
   pgbranch checkout main
   pgbranch checkout feature-x
-  pgbranch checkout -b new-feature`,
-	Args: cobra.ExactArgs(1),
-	RunE: runCheckout,
+  pgbranch checkout -b new-feature
+  pgbranch checkout feature-x --dry-run
+  pgbranch checkout main --no-save
+  pgbranch checkout main --reset`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeBranches(1),
+	RunE:              runCheckout,
 }
 
 func init() {
 	checkoutCmd.Flags().BoolVarP(&autoCreateBranch, "branch", "b", false, "Create a new branch and switch to it")
+	checkoutCmd.Flags().BoolVar(&checkoutDryRun, "dry-run", false, "Show what checkout would do without making changes")
+	checkoutCmd.Flags().BoolVar(&checkoutReset, "reset", false, "When on the branch already, discard working database changes")
+	checkoutCmd.Flags().BoolVarP(&checkoutReset, "force", "f", false, "Same as --reset")
+	checkoutCmd.Flags().BoolVar(&checkoutNoSave, "no-save", false, "Don't save the current branch first; its unsaved changes are lost")
 }
 
 func runCheckout(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	brancher, err := core.NewBrancher()
 	if err != nil {
 		return err
 	}
+	defer brancher.Close()
 
-	name := args[0]
+	var name string
+	if len(args) == 1 {
+		name = args[0]
+	} else {
+		if autoCreateBranch {
+			return fmt.Errorf("a branch name is required with -b")
+		}
+		name, err = pickBranch(brancher)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Println("Checkout cancelled.")
+			return nil
+		}
+	}
+
+	if checkoutDryRun {
+		return runCheckoutDryRun(brancher, name)
+	}
+
+	printConnection(brancher.Config)
 
 	if autoCreateBranch {
 		if brancher.Metadata.BranchExists(name) {
@@ -67,33 +123,179 @@ func runCheckout(cmd *cobra.Command, args []string) error {
 		}
 
 		yellow := color.New(color.FgYellow).SprintFunc()
-		fmt.Printf("%s Creating branch '%s'...\n", yellow("→"), name)
+		infof("%s Creating branch '%s'...\n", yellow("→"), name)
 
-		if err := brancher.CreateBranch(name); err != nil {
+		err := timed("Creating the branch", func() error {
+			return brancher.CreateBranch(ctx, name)
+		})
+		if err != nil {
 			return err
 		}
 	}
 
 	if brancher.CurrentBranch() == name {
-		fmt.Printf("Already on branch '%s'\n", name)
-		return nil
+		return runCheckoutCurrent(ctx, brancher, name)
 	}
 
 	yellow := color.New(color.FgYellow).SprintFunc()
 	currentBranch := brancher.CurrentBranch()
 	if currentBranch != "" {
-		fmt.Printf("%s Saving branch '%s'...\n", yellow("→"), currentBranch)
+		if checkoutNoSave {
+			fmt.Printf("%s Discarding unsaved changes to branch '%s'\n", yellow("⚠"), currentBranch)
+		} else {
+			infof("%s Saving branch '%s'...\n", yellow("→"), currentBranch)
+		}
 	}
-	fmt.Printf("%s Switching to branch '%s'...\n", yellow("→"), name)
+	infof("%s Switching to branch '%s'...\n", yellow("→"), name)
 
-	if err := brancher.Checkout(name); err != nil {
+	err = timed("Checkout", func() error {
+		return brancher.Checkout(ctx, name, !checkoutNoSave)
+	})
+	if err != nil {
 		return err
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Printf("%s Switched to branch '%s'\n", green("✓"), name)
+	infof("%s Switched to branch '%s'\n", green("✓"), name)
 
 	showStaleWarning(brancher)
 
 	return nil
 }
+
+// pickBranch lists the branches as a numbered list and asks which one to
+// check out. It returns an empty name if nothing was picked.
+func pickBranch(brancher *core.Brancher) (string, error) {
+	branches := brancher.ListBranches()
+	if len(branches) == 0 {
+		return "", fmt.Errorf("no branches yet. Create one with: pgbranch branch <name>")
+	}
+
+	cyan := color.New(color.FgCyan).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	for i, info := range branches {
+		var lastAccess string
+		if info.Branch.LastCheckoutAt.IsZero() {
+			lastAccess = "never checked out"
+		} else {
+			lastAccess = fmt.Sprintf("last checkout: %s", info.Branch.LastCheckoutAt.Format("2006-01-02 15:04"))
+		}
+
+		currentMarker := ""
+		if info.IsCurrent {
+			currentMarker = cyan(" (current)")
+		}
+
+		fmt.Printf("  %d. %s%s\n", i+1, info.Name, currentMarker)
+		fmt.Printf("     %s\n", dim(lastAccess))
+	}
+	fmt.Println()
+
+	fmt.Println("Enter the number of the branch to check out, or press Enter to cancel:")
+	fmt.Print("> ")
+
+	input, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", nil
+	}
+
+	var num int
+	if _, err := fmt.Sscanf(input, "%d", &num); err != nil {
+		return "", fmt.Errorf("invalid number: %s", input)
+	}
+	if num < 1 || num > len(branches) {
+		return "", fmt.Errorf("number out of range: %d", num)
+	}
+
+	return branches[num-1].Name, nil
+}
+
+// runCheckoutCurrent handles checking out the branch that is already
+// current, which only does something with --reset.
+func runCheckoutCurrent(ctx context.Context, brancher *core.Brancher, name string) error {
+	if !checkoutReset {
+		infof("Already on branch '%s'\n", name)
+		infof("  Use 'pgbranch checkout %s --reset' to discard changes since the branch was last saved.\n", name)
+		return nil
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	infof("%s Resetting to branch '%s'...\n", yellow("→"), name)
+
+	if err := timed("Reset", func() error { return brancher.Reset(ctx) }); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	infof("%s Reset '%s' to branch '%s'\n", green("✓"), brancher.Config.Database, name)
+
+	return nil
+}
+
+func runCheckoutDryRun(brancher *core.Brancher, name string) error {
+	yellow := color.New(color.FgYellow).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	if autoCreateBranch {
+		if brancher.Metadata.BranchExists(name) {
+			return fmt.Errorf("fatal: a branch named '%s' already exists", name)
+		}
+		if err := brancher.ValidateBranchName(name); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s Would create branch '%s' from the current database\n", yellow("→"), name)
+		if current := brancher.CurrentBranch(); current != "" {
+			fmt.Printf("%s Would save branch '%s'\n", yellow("→"), current)
+		}
+		fmt.Printf("%s Would switch to branch '%s'\n", yellow("→"), name)
+		fmt.Println(dim("Dry run: no changes were made."))
+		return nil
+	}
+
+	plan, err := brancher.CheckoutPlan(name)
+	if err != nil {
+		return err
+	}
+
+	if plan.AlreadyOn {
+		if !checkoutReset {
+			fmt.Printf("Already on branch '%s'\n", name)
+			return nil
+		}
+		fmt.Printf("%s Would reset to branch '%s', discarding unsaved changes\n", yellow("→"), name)
+		fmt.Printf("    Snapshot:      %s\n", plan.Snapshot)
+		fmt.Println(dim("Dry run: no changes were made."))
+		return nil
+	}
+
+	if plan.SaveBranch != "" {
+		if checkoutNoSave {
+			fmt.Printf("%s Would discard unsaved changes to branch '%s'\n", yellow("⚠"), plan.SaveBranch)
+		} else {
+			fmt.Printf("%s Would save branch '%s'\n", yellow("→"), plan.SaveBranch)
+		}
+	}
+	fmt.Printf("%s Would switch to branch '%s'\n", yellow("→"), name)
+	fmt.Printf("    Snapshot:      %s\n", plan.Snapshot)
+	if plan.LastCheckoutAt.IsZero() {
+		fmt.Printf("    Last checkout: %s\n", dim("never"))
+	} else {
+		fmt.Printf("    Last checkout: %s\n", plan.LastCheckoutAt.Format("2006-01-02 15:04:05"))
+	}
+
+	if plan.IsStale {
+		warn := color.New(color.FgYellow, color.Bold).SprintFunc()
+		fmt.Printf("%s Branch '%s' is stale (not accessed in %d+ days)\n", warn("!"), name, core.DefaultStaleDays)
+	}
+
+	fmt.Println(dim("Dry run: no changes were made."))
+
+	return nil
+}
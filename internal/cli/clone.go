@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/postgres"
+	"github.com/le-vlad/pgbranch/internal/remote"
+	"github.com/le-vlad/pgbranch/internal/storage"
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+func newCloneCmd() *cobra.Command {
+	var (
+		database        string
+		host            string
+		port            int
+		user            string
+		password        string
+		sslMode         string
+		sslRootCert     string
+		noStorePass     bool
+		remoteName      string
+		skipCredentials bool
+		jobs            int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clone <url>",
+		Short: "Initialize pgbranch from a remote and pull all its branches",
+		Long: `Initialize pgbranch in the current directory, add the remote at <url>
+as 'origin', and pull every branch on it as a local branch.
+
+Branches that fail to pull are reported at the end; the ones that
+succeeded are kept. Retry the others with 'pgbranch pull'.
+
+Examples:
+  pgbranch clone /shared/snapshots -d myapp_dev
+  pgbranch clone s3://my-bucket/pgbranch -d myapp_dev -H localhost -U postgres
+  pgbranch clone gs://my-bucket/pgbranch -d myapp_dev --no-credentials`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if config.IsInitialized() {
+				return fmt.Errorf("pgbranch already initialized in this directory")
+			}
+
+			if err := config.ValidateSSLMode(sslMode); err != nil {
+				return err
+			}
+
+			if jobs < 1 {
+				return fmt.Errorf("--jobs must be at least 1")
+			}
+
+			if err := postgres.CheckTools(); err != nil {
+				return err
+			}
+
+			remoteCfg, err := remote.ParseURL(remoteName, args[0])
+			if err != nil {
+				return fmt.Errorf("invalid remote URL: %w", err)
+			}
+
+			if !skipCredentials {
+				if err := promptRemoteCredentials(remoteCfg); err != nil {
+					return err
+				}
+			}
+
+			r, err := remote.New(remoteCfg)
+			if err != nil {
+				return fmt.Errorf("failed to create remote: %w", err)
+			}
+
+			ctx := cmd.Context()
+
+			// List before initializing, so an unreachable remote leaves
+			// the directory untouched.
+			remoteBranches, err := r.List(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list remote branches: %w", err)
+			}
+
+			cfg := config.DefaultConfig()
+			cfg.Database = database
+			cfg.Host = host
+			cfg.Port = port
+			cfg.User = user
+			if !noStorePass {
+				cfg.Password = password
+			}
+			cfg.SSLMode = sslMode
+			cfg.SSLRootCert = sslRootCert
+
+			if err := cfg.AddRemote(&config.RemoteConfig{
+				Name:    remoteCfg.Name,
+				Type:    remoteCfg.Type,
+				URL:     remoteCfg.URL,
+				Options: remoteCfg.Options,
+			}); err != nil {
+				return err
+			}
+
+			if err := core.InitializeWithConfig(cfg); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			yellow := color.New(color.FgYellow).SprintFunc()
+			red := color.New(color.FgRed).SprintFunc()
+
+			infof("%s Initialized pgbranch for database '%s' with remote '%s' (%s)\n",
+				green("✓"), database, remoteCfg.Name, remoteCfg.Type)
+
+			if len(remoteBranches) == 0 {
+				fmt.Printf("%s Remote '%s' has no branches\n", yellow("⚠"), remoteCfg.Name)
+				return nil
+			}
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			names := make([]string, 0, len(remoteBranches))
+			modTimes := make(map[string]time.Time, len(remoteBranches))
+			for _, rb := range remoteBranches {
+				names = append(names, rb.Name)
+				modTimes[rb.Name] = rb.ModTime
+			}
+			sort.Strings(names)
+
+			var pulled []string
+			failed := make(map[string]error)
+
+			for _, name := range names {
+				infof("\nPulling '%s'...\n", name)
+				source := &storage.RemoteSource{
+					Remote:    remoteCfg.Name,
+					Branch:    name,
+					PulledAt:  time.Now(),
+					UpdatedAt: modTimes[name],
+				}
+				if err := cloneBranch(ctx, brancher, r, source, jobs); err != nil {
+					fmt.Printf("%s %v\n", red("✗"), err)
+					failed[name] = err
+					continue
+				}
+				pulled = append(pulled, name)
+			}
+
+			infof("\n%s Pulled %d of %d branch(es)\n", green("✓"), len(pulled), len(names))
+			for _, name := range pulled {
+				infof("    %s\n", name)
+			}
+
+			if len(failed) > 0 {
+				fmt.Printf("%s Failed to pull %d branch(es):\n", red("✗"), len(failed))
+				for _, name := range names {
+					if err, ok := failed[name]; ok {
+						fmt.Printf("    %s: %v\n", name, err)
+					}
+				}
+				return fmt.Errorf("failed to pull %d of %d branches", len(failed), len(names))
+			}
+
+			infof("\nNext steps:\n")
+			infof("  pgbranch checkout %s    # Restore a branch into '%s'\n", names[0], database)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&database, "database", "d", "", "Database name (required)")
+	cmd.Flags().StringVarP(&host, "host", "H", "localhost", "PostgreSQL host")
+	cmd.Flags().IntVarP(&port, "port", "p", 5432, "PostgreSQL port")
+	cmd.Flags().StringVarP(&user, "user", "U", "postgres", "PostgreSQL user")
+	cmd.Flags().StringVarP(&password, "password", "W", "", "PostgreSQL password")
+	cmd.Flags().StringVar(&sslMode, "sslmode", config.DefaultSSLMode, "SSL mode (disable, allow, prefer, require, verify-ca, verify-full)")
+	cmd.Flags().StringVar(&sslRootCert, "sslrootcert", "", "CA certificate used to verify the server (verify-ca, verify-full)")
+	cmd.Flags().BoolVar(&noStorePass, "no-store-password", false, "Don't save a password; read it from PGPASSWORD or ~/.pgpass at connection time")
+	cmd.Flags().StringVarP(&remoteName, "origin", "o", "origin", "Name to give the remote")
+	cmd.Flags().BoolVar(&skipCredentials, "no-credentials", false, "Skip credential prompts")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 1, "Number of parallel pg_restore jobs (uses a temporary file when above 1)")
+	cmd.MarkFlagRequired("database")
+	cmd.MarkFlagsMutuallyExclusive("password", "no-store-password")
+
+	return cmd
+}
+
+// cloneBranch pulls source.Branch from r and restores it as a local branch
+// of the same name.
+func cloneBranch(ctx context.Context, brancher *core.Brancher, r remote.Remote, source *storage.RemoteSource, jobs int) error {
+	branchName := source.Branch
+	if err := brancher.ValidateBranchName(branchName); err != nil {
+		return err
+	}
+
+	arch, err := downloadArchive(ctx, r, branchName)
+	if err != nil {
+		return err
+	}
+
+	printArchiveInfo(arch, branchName)
+
+	if err := checkServerVersion(ctx, brancher, arch, false); err != nil {
+		return err
+	}
+
+	return restoreArchiveAsBranch(ctx, brancher, arch, branchName, source, jobs)
+}
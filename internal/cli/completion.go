@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/storage"
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+// completeBranches returns a ValidArgsFunction that completes local branch
+// names for the first maxArgs arguments, leaving out names already given.
+// It only reads metadata, so it works without a database connection.
+func completeBranches(maxArgs int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= maxArgs {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		meta, ok := loadMetadataForCompletion()
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		return filterCompletions(meta.ListBranches(), args, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeBranchFlag completes local branch names for flags that take a
+// branch, such as 'branch --from'.
+func completeBranchFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	meta, ok := loadMetadataForCompletion()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(meta.ListBranches(), nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRemoteBranches completes the names of branches on remotes, as
+// recorded by the last 'pgbranch fetch', for the first argument.
+func completeRemoteBranches(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	meta, ok := loadMetadataForCompletion()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(remoteBranchNames(meta), args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRemoteBranchFlag completes the names of branches on remotes for
+// flags that take one, such as 'branch --from-remote'.
+func completeRemoteBranchFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	meta, ok := loadMetadataForCompletion()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(remoteBranchNames(meta), nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// remoteBranchNames returns the names of branches on all remotes, as
+// recorded by the last 'pgbranch fetch', without duplicates.
+func remoteBranchNames(meta *storage.Metadata) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, tracking := range meta.RemoteTracking {
+		for _, ref := range tracking.Branches {
+			if !seen[ref.Name] {
+				seen[ref.Name] = true
+				names = append(names, ref.Name)
+			}
+		}
+	}
+	return names
+}
+
+// completeSnapshots completes the names of saved snapshots for the first
+// argument.
+func completeSnapshots(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	meta, ok := loadMetadataForCompletion()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names := make([]string, 0, len(meta.Snapshots))
+	for name := range meta.Snapshots {
+		names = append(names, name)
+	}
+	return filterCompletions(names, args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func loadMetadataForCompletion() (*storage.Metadata, bool) {
+	if !config.IsInitialized() {
+		return nil, false
+	}
+	meta, err := storage.LoadMetadata()
+	if err != nil {
+		return nil, false
+	}
+	return meta, true
+}
+
+// filterCompletions returns the names starting with toComplete that are not
+// among args, sorted.
+func filterCompletions(names, args []string, toComplete string) []string {
+	given := make(map[string]bool, len(args))
+	for _, arg := range args {
+		given[arg] = true
+	}
+
+	var result []string
+	for _, name := range names {
+		if !given[name] && strings.HasPrefix(name, toComplete) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
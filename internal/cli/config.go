@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View and edit pgbranch settings",
+		Long: `View and edit the settings saved in .pgbranch/config.json.
+
+Supported keys: database, host, port, user, sslmode, default_remote
+
+Examples:
+  pgbranch config list
+  pgbranch config get host
+  pgbranch config set host db.internal
+  pgbranch config set sslmode require`,
+	}
+
+	cmd.AddCommand(
+		newConfigGetCmd(),
+		newConfigSetCmd(),
+		newConfigListCmd(),
+	)
+
+	return cmd
+}
+
+func newConfigGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			value, err := cfg.Get(args[0])
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(value)
+			return nil
+		},
+	}
+}
+
+func newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Change a setting",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, value := args[0], args[1]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := cfg.Set(key, value); err != nil {
+				return err
+			}
+
+			if err := cfg.Validate(); err != nil {
+				return fmt.Errorf("invalid config: %w", err)
+			}
+
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Set %s to '%s'\n", green("✓"), key, value)
+			return nil
+		},
+	}
+}
+
+func newConfigListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all settings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			for _, key := range config.Keys {
+				value, err := cfg.Get(key)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s=%s\n", key, value)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+var copyForce bool
+
+var copyCmd = &cobra.Command{
+	Use:     "copy <source> <new>",
+	Aliases: []string{"cp"},
+	Short:   "Create a branch from another branch",
+	Long: `Create a new branch from an existing branch's snapshot.
+
+This is the same as 'pgbranch branch <new> --from <source>'. The working
+database and the current branch are left as they are.
+
+Example:
+  pgbranch copy main feature-y
+  pgbranch cp feature-x feature-x-backup`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeBranches(1),
+	RunE:              runCopy,
+}
+
+func init() {
+	copyCmd.Flags().BoolVarP(&copyForce, "force", "f", false, "Create the branch even if the server looks short on disk space")
+}
+
+func runCopy(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	brancher, err := core.NewBrancher()
+	if err != nil {
+		return err
+	}
+	defer brancher.Close()
+
+	return createBranchFrom(ctx, brancher, args[1], args[0], copyForce)
+}
@@ -1,8 +1,6 @@
 package cli
 
 import (
-	"fmt"
-
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
@@ -17,33 +15,38 @@ var deleteCmd = &cobra.Command{
 	Short:   "Delete a branch",
 	Long: `Delete a branch and its snapshot.
 
-Cannot delete the current branch unless --force is used.
+Cannot delete the current branch or a protected branch unless --force
+is used.
 
 Example:
   pgbranch delete feature-x
   pgbranch delete main --force`,
-	Args: cobra.ExactArgs(1),
-	RunE: runDelete,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranches(1),
+	RunE:              runDelete,
 }
 
 func init() {
-	deleteCmd.Flags().BoolVarP(&deleteForce, "force", "f", false, "Force delete even if current branch")
+	deleteCmd.Flags().BoolVarP(&deleteForce, "force", "f", false, "Force delete even if current or protected branch")
 }
 
 func runDelete(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	brancher, err := core.NewBrancher()
 	if err != nil {
 		return err
 	}
+	defer brancher.Close()
 
 	name := args[0]
 
-	if err := brancher.DeleteBranch(name, deleteForce); err != nil {
+	if err := brancher.DeleteBranch(ctx, name, deleteForce); err != nil {
 		return err
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Printf("%s Deleted branch '%s'\n", green("✓"), name)
+	infof("%s Deleted branch '%s'\n", green("✓"), name)
 
 	return nil
 }
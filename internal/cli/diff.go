@@ -3,19 +3,29 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/jackc/pgx/v5"
 	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/postgres"
+	"github.com/le-vlad/pgbranch/internal/remote"
 	"github.com/le-vlad/pgbranch/internal/schema"
+	"github.com/le-vlad/pgbranch/pkg/config"
 	"github.com/spf13/cobra"
 )
 
 func newDiffCmd() *cobra.Command {
 	var (
-		statOnly bool
-		showSQL  bool
+		statOnly   bool
+		showSQL    bool
+		jsonOutput bool
+		dataStat   bool
+		renames    []string
+		extract    extractFlags
 	)
 
 	cmd := &cobra.Command{
@@ -25,6 +35,24 @@ func newDiffCmd() *cobra.Command {
 
 If only one branch is specified, it compares against the current working database.
 
+Either branch can be a branch on a remote, written remote:<remote>/<branch>
+or remote:<branch> for the default remote. Its archive is downloaded and
+restored into a temporary database, which is dropped afterwards. Use this
+to see what a pull would change.
+
+With --data-stat, also counts the rows of every table present on both
+sides and lists the tables whose row counts differ. Counting scans each
+table, so it can take a while on large databases.
+
+Objects in the schemas of common extensions (cron, pglogical, tiger,
+tiger_data, topology) are left out. Use --exclude-schema and
+--exclude-table to leave out more, such as a migrations bookkeeping table,
+and --no-default-excludes to include the extension schemas.
+
+A renamed column shows up as a dropped and an added column. When a table
+has exactly one of each with the same definition, diff points it out; pass
+--rename table.old=new to show it as a rename instead.
+
 Examples:
   # Compare two branches
   pgbranch diff main feature-auth
@@ -32,77 +60,124 @@ Examples:
   # Compare a branch against current working database
   pgbranch diff main
 
+  # See what pulling 'main' from origin would change
+  pgbranch diff main remote:origin/main
+
   # Show summary only
   pgbranch diff main feature-auth --stat
 
   # Show SQL statements to migrate
-  pgbranch diff main feature-auth --sql`,
-		Args: cobra.RangeArgs(1, 2),
+  pgbranch diff main feature-auth --sql
+
+  # Also compare row counts
+  pgbranch diff main feature-auth --data-stat
+
+  # Show users.name → users.full_name as a rename, not a drop and an add
+  pgbranch diff main feature-auth --sql --rename users.name=full_name
+
+  # Ignore the migrations table and a scratch schema
+  pgbranch diff main feature-auth --exclude-table schema_migrations --exclude-schema scratch
+
+  # Machine-readable output for CI
+  pgbranch diff main feature-auth --json`,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeBranches(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			brancher, err := core.NewBrancher()
 			if err != nil {
 				return err
 			}
+			defer brancher.Close()
+
+			ctx := cmd.Context()
+
+			if dataStat && showSQL {
+				return fmt.Errorf("--data-stat cannot be used with --sql")
+			}
+
+			extractOpts, err := extract.options()
+			if err != nil {
+				return err
+			}
 
-			ctx := context.Background()
+			// Keep download progress out of the JSON.
+			if jsonOutput {
+				quietMode = true
+			}
 
-			var fromDB, toDB string
-			var fromName, toName string
+			fromName := args[0]
+			fromDB, cleanup, err := resolveDiffRef(ctx, brancher, fromName, "diff_from")
+			if err != nil {
+				return err
+			}
+			defer cleanup()
 
+			var toDB, toName string
 			if len(args) == 1 {
-				branchName := args[0]
-				branch, ok := brancher.Metadata.GetBranch(branchName)
-				if !ok {
-					return fmt.Errorf("branch '%s' does not exist", branchName)
-				}
-				fromDB = branch.Snapshot
-				fromName = branchName
 				toDB = brancher.Config.Database
 				toName = "(working)"
 			} else {
-				branch1Name := args[0]
-				branch2Name := args[1]
-
-				branch1, ok := brancher.Metadata.GetBranch(branch1Name)
-				if !ok {
-					return fmt.Errorf("branch '%s' does not exist", branch1Name)
+				toName = args[1]
+				toDB, cleanup, err = resolveDiffRef(ctx, brancher, toName, "diff_to")
+				if err != nil {
+					return err
 				}
-				branch2, ok := brancher.Metadata.GetBranch(branch2Name)
-				if !ok {
-					return fmt.Errorf("branch '%s' does not exist", branch2Name)
-				}
-
-				fromDB = branch1.Snapshot
-				fromName = branch1Name
-				toDB = branch2.Snapshot
-				toName = branch2Name
+				defer cleanup()
 			}
 
-			fromSchema, err := extractSchemaFromDB(ctx, brancher, fromDB)
+			fromSchema, err := extractSchemaFromDB(ctx, brancher, fromDB, extractOpts)
 			if err != nil {
 				return fmt.Errorf("failed to extract schema from '%s': %w", fromName, err)
 			}
 
-			toSchema, err := extractSchemaFromDB(ctx, brancher, toDB)
+			toSchema, err := extractSchemaFromDB(ctx, brancher, toDB, extractOpts)
 			if err != nil {
 				return fmt.Errorf("failed to extract schema from '%s': %w", toName, err)
 			}
 
 			changeSet := schema.Diff(fromSchema, toSchema)
+			if err := applyRenames(changeSet, renames); err != nil {
+				return err
+			}
 
-			if changeSet.IsEmpty() {
-				fmt.Printf("No schema differences between '%s' and '%s'\n", fromName, toName)
-				return nil
+			var rowCounts []tableRowCount
+			if dataStat {
+				rowCounts, err = compareRowCounts(ctx, brancher, fromDB, toDB, fromSchema, toSchema)
+				if err != nil {
+					return err
+				}
 			}
 
-			fmt.Printf("Comparing '%s' → '%s'\n\n", fromName, toName)
+			if jsonOutput {
+				if !dataStat {
+					data, err := changeSet.ToJSON()
+					if err != nil {
+						return fmt.Errorf("failed to serialize diff: %w", err)
+					}
+					fmt.Println(string(data))
+					return nil
+				}
+				return printJSON(diffJSON{ChangeSetJSON: changeSet.JSON(), RowCounts: rowCounts})
+			}
 
-			if statOnly {
-				printDiffStat(changeSet)
-			} else if showSQL {
-				printDiffSQL(changeSet)
+			if changeSet.IsEmpty() {
+				fmt.Printf("No schema differences between '%s' and '%s'\n", fromName, toName)
 			} else {
-				printDiffFull(changeSet)
+				fmt.Printf("Comparing '%s' → '%s'\n\n", fromName, toName)
+
+				if statOnly {
+					printDiffStat(os.Stdout, changeSet)
+				} else if showSQL {
+					printDiffSQL(os.Stdout, changeSet)
+				} else {
+					printDiffFull(os.Stdout, changeSet)
+				}
+				printRenameHints(os.Stdout, changeSet.RenameCandidates())
+			}
+
+			if dataStat {
+				fmt.Println()
+				printRowCounts(os.Stdout, rowCounts)
 			}
 
 			return nil
@@ -111,11 +186,161 @@ Examples:
 
 	cmd.Flags().BoolVar(&statOnly, "stat", false, "Show summary statistics only")
 	cmd.Flags().BoolVar(&showSQL, "sql", false, "Show SQL statements to apply changes")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output changes as JSON")
+	cmd.Flags().BoolVar(&dataStat, "data-stat", false, "Also compare row counts of tables on both sides (scans every table)")
+	cmd.Flags().StringArrayVar(&renames, "rename", nil, "Treat a dropped and an added column as a rename, as table.old=new (repeatable)")
+	extract.register(cmd)
 
 	return cmd
 }
 
-func extractSchemaFromDB(ctx context.Context, brancher *core.Brancher, dbName string) (*schema.Schema, error) {
+// remoteRefPrefix marks a diff argument as a branch on a remote.
+const remoteRefPrefix = "remote:"
+
+// resolveDiffRef returns the database holding the schema of ref, a local
+// branch name or a remote:<remote>/<branch> reference. A remote branch is
+// restored into a new temporary database named for purpose, which cleanup
+// drops; for local branches cleanup does nothing.
+func resolveDiffRef(ctx context.Context, brancher *core.Brancher, ref, purpose string) (string, func(), error) {
+	noop := func() {}
+
+	if !strings.HasPrefix(ref, remoteRefPrefix) {
+		branch, ok := brancher.Metadata.GetBranch(ref)
+		if !ok {
+			return "", noop, brancher.BranchNotFoundError(ref)
+		}
+		return branch.Snapshot, noop, nil
+	}
+
+	remoteName, branchName := parseRemoteRef(brancher.Config, strings.TrimPrefix(ref, remoteRefPrefix))
+	if branchName == "" {
+		return "", noop, fmt.Errorf("invalid remote reference '%s': expected remote:<remote>/<branch>", ref)
+	}
+
+	if err := postgres.CheckTools(); err != nil {
+		return "", noop, err
+	}
+
+	remoteCfg, err := brancher.Config.GetRemote(remoteName)
+	if err != nil {
+		return "", noop, err
+	}
+
+	r, err := remote.New(&remote.Config{
+		Name:    remoteCfg.Name,
+		Type:    remoteCfg.Type,
+		URL:     remoteCfg.URL,
+		Options: remoteCfg.Options,
+	})
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create remote: %w", err)
+	}
+
+	exists, err := r.Exists(ctx, branchName)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to check remote: %w", err)
+	}
+	if !exists {
+		return "", noop, fmt.Errorf("branch '%s' not found on remote '%s'", branchName, remoteCfg.Name)
+	}
+
+	infof("Downloading '%s' from remote '%s'...\n", branchName, remoteCfg.Name)
+
+	arch, err := downloadArchive(ctx, r, branchName)
+	if err != nil {
+		return "", noop, err
+	}
+
+	// The name is unique to this run, so concurrent diffs don't drop each
+	// other's databases; restoring fails rather than reusing an existing one.
+	tempDB := brancher.NewTempDBName(purpose)
+
+	cleanup := func() {
+		brancher.Client.DropDatabaseByName(context.WithoutCancel(ctx), tempDB)
+	}
+
+	err = timed("Restoring", func() error {
+		return arch.Restore(ctx, brancher.Config, tempDB, nil)
+	})
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to restore '%s' from remote '%s': %w", branchName, remoteCfg.Name, err)
+	}
+
+	return tempDB, cleanup, nil
+}
+
+// parseRemoteRef splits <remote>/<branch> into its parts. Branch names may
+// contain '/', so when the first part isn't a configured remote the whole
+// of ref is a branch on the default remote.
+func parseRemoteRef(cfg *config.Config, ref string) (remoteName, branchName string) {
+	if name, branch, ok := strings.Cut(ref, "/"); ok {
+		if _, exists := cfg.Remotes[name]; exists {
+			return name, branch
+		}
+	}
+	return "", ref
+}
+
+// extractFlags holds the flags shared by diff and merge that leave objects
+// out of the extracted schemas.
+type extractFlags struct {
+	excludeSchemas    []string
+	excludeTables     []string
+	noDefaultExcludes bool
+}
+
+func (f *extractFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&f.excludeSchemas, "exclude-schema", nil, "Leave out the objects in these schemas (repeatable, globs allowed)")
+	cmd.Flags().StringSliceVar(&f.excludeTables, "exclude-table", nil, "Leave out these tables, as name or schema.name (repeatable, globs allowed)")
+	cmd.Flags().BoolVar(&f.noDefaultExcludes, "no-default-excludes", false, "Include the schemas of common extensions, left out by default")
+}
+
+// options returns the extract options selected by the flags.
+func (f *extractFlags) options() (schema.ExtractOptions, error) {
+	opts := schema.DefaultExtractOptions()
+	if f.noDefaultExcludes {
+		opts = schema.ExtractOptions{}
+	}
+	opts.ExcludeSchemas = append(opts.ExcludeSchemas, f.excludeSchemas...)
+	opts.ExcludeTables = append(opts.ExcludeTables, f.excludeTables...)
+	if err := opts.Validate(); err != nil {
+		return schema.ExtractOptions{}, err
+	}
+	return opts, nil
+}
+
+// applyRenames turns the column drops and adds named by --rename flags,
+// written table.old=new, into renames.
+func applyRenames(cs *schema.ChangeSet, renames []string) error {
+	for _, r := range renames {
+		column, newName, ok := strings.Cut(r, "=")
+		if !ok || !strings.Contains(column, ".") || newName == "" {
+			return fmt.Errorf("invalid --rename %q: expected table.old=new", r)
+		}
+		if err := cs.RenameColumn(column, newName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printRenameHints lists dropped and added columns that look like renames,
+// with the --rename flag that treats them as such.
+func printRenameHints(w io.Writer, candidates []schema.RenameCandidate) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	fmt.Fprintf(w, "\n%s These dropped and added columns have the same definition and may be renames:\n", yellow("!"))
+	for _, c := range candidates {
+		fmt.Fprintf(w, "  %s  %s\n", c, dim(fmt.Sprintf("--rename %s.%s=%s", c.TableName, c.OldName, c.NewName)))
+	}
+}
+
+func extractSchemaFromDB(ctx context.Context, brancher *core.Brancher, dbName string, opts schema.ExtractOptions) (*schema.Schema, error) {
 	connURL := brancher.Config.ConnectionURLForDB(dbName)
 	conn, err := pgx.Connect(ctx, connURL)
 	if err != nil {
@@ -123,10 +348,121 @@ func extractSchemaFromDB(ctx context.Context, brancher *core.Brancher, dbName st
 	}
 	defer conn.Close(ctx)
 
-	return schema.ExtractFromConnection(ctx, conn, dbName)
+	return schema.ExtractFromConnection(ctx, conn, dbName, opts)
+}
+
+// tableRowCount is the number of rows in a table on each side of a diff.
+type tableRowCount struct {
+	Table string `json:"table"`
+	From  int64  `json:"from"`
+	To    int64  `json:"to"`
+}
+
+// Delta returns how many rows the table gained from one side to the other.
+func (c tableRowCount) Delta() int64 {
+	return c.To - c.From
+}
+
+// diffJSON is the output of 'diff --json --data-stat': the change set with
+// the row counts alongside.
+type diffJSON struct {
+	schema.ChangeSetJSON
+	RowCounts []tableRowCount `json:"row_counts"`
+}
+
+// compareRowCounts counts the rows of every table present in both schemas,
+// on fromDB and toDB, sorted by table name.
+func compareRowCounts(ctx context.Context, brancher *core.Brancher, fromDB, toDB string, fromSchema, toSchema *schema.Schema) ([]tableRowCount, error) {
+	var tables []*schema.Table
+	for name, table := range fromSchema.Tables {
+		if _, ok := toSchema.Tables[name]; ok {
+			tables = append(tables, table)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].FullName() < tables[j].FullName()
+	})
+
+	fromCounts, err := countRows(ctx, brancher, fromDB, tables)
+	if err != nil {
+		return nil, err
+	}
+	toCounts, err := countRows(ctx, brancher, toDB, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]tableRowCount, 0, len(tables))
+	for i, table := range tables {
+		result = append(result, tableRowCount{
+			Table: table.FullName(),
+			From:  fromCounts[i],
+			To:    toCounts[i],
+		})
+	}
+	return result, nil
+}
+
+// countRows returns the number of rows in each of tables on dbName.
+func countRows(ctx context.Context, brancher *core.Brancher, dbName string, tables []*schema.Table) ([]int64, error) {
+	conn, err := pgx.Connect(ctx, brancher.Config.ConnectionURLForDB(dbName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	counts := make([]int64, len(tables))
+	for i, table := range tables {
+		tableSchema := table.Schema
+		if tableSchema == "" {
+			tableSchema = "public"
+		}
+		query := "SELECT count(*) FROM " + pgx.Identifier{tableSchema, table.Name}.Sanitize()
+		if err := conn.QueryRow(ctx, query).Scan(&counts[i]); err != nil {
+			return nil, fmt.Errorf("failed to count rows in '%s': %w", table.FullName(), err)
+		}
+	}
+	return counts, nil
 }
 
-func printDiffStat(cs *schema.ChangeSet) {
+func printRowCounts(w io.Writer, counts []tableRowCount) {
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	var changed []tableRowCount
+	width := 0
+	for _, c := range counts {
+		if c.Delta() == 0 {
+			continue
+		}
+		changed = append(changed, c)
+		if len(c.Table) > width {
+			width = len(c.Table)
+		}
+	}
+
+	if len(changed) == 0 {
+		fmt.Fprintf(w, "Row counts: %d table(s) compared, no differences\n", len(counts))
+		return
+	}
+
+	fmt.Fprintf(w, "Row counts:\n")
+	for _, c := range changed {
+		delta := fmt.Sprintf("%+d", c.Delta())
+		if c.Delta() > 0 {
+			delta = green(delta)
+		} else {
+			delta = red(delta)
+		}
+		fmt.Fprintf(w, "  %-*s  %d → %d  %s\n", width, c.Table, c.From, c.To, delta)
+	}
+	if same := len(counts) - len(changed); same > 0 {
+		fmt.Fprintf(w, "  %s\n", dim(fmt.Sprintf("%d other table(s) have the same row count", same)))
+	}
+}
+
+func printDiffStat(w io.Writer, cs *schema.ChangeSet) {
 	summary := cs.Summary()
 
 	green := color.New(color.FgGreen).SprintFunc()
@@ -137,60 +473,75 @@ func printDiffStat(cs *schema.ChangeSet) {
 
 	for changeType, count := range summary {
 		switch changeType {
-		case schema.ChangeCreateTable, schema.ChangeAddColumn, schema.ChangeCreateIndex,
+		case schema.ChangeCreateSchema, schema.ChangeCreateTable, schema.ChangeAddColumn, schema.ChangeCreateIndex,
 			schema.ChangeAddConstraint, schema.ChangeCreateEnum, schema.ChangeAddEnumValue,
-			schema.ChangeCreateFunction:
+			schema.ChangeCreateSequence, schema.ChangeCreateFunction, schema.ChangeCreateView:
 			additions += count
-		case schema.ChangeDropTable, schema.ChangeDropColumn, schema.ChangeDropIndex,
-			schema.ChangeDropConstraint, schema.ChangeDropEnum, schema.ChangeDropFunction:
+		case schema.ChangeDropSchema, schema.ChangeDropTable, schema.ChangeDropColumn, schema.ChangeDropIndex,
+			schema.ChangeDropConstraint, schema.ChangeDropEnum, schema.ChangeDropEnumValue, schema.ChangeDropSequence,
+			schema.ChangeDropFunction, schema.ChangeDropView:
 			deletions += count
-		case schema.ChangeAlterColumn, schema.ChangeReplaceFunction:
+		case schema.ChangeAlterColumn, schema.ChangeRenameColumn, schema.ChangeReorderEnum, schema.ChangeAlterSequence,
+			schema.ChangeReplaceFunction, schema.ChangeReplaceView, schema.ChangeComment:
 			modifications += count
 		}
 	}
 
-	fmt.Printf("Summary:\n")
+	fmt.Fprintf(w, "Summary:\n")
 	if additions > 0 {
-		fmt.Printf("  %s %d addition(s)\n", green("+"), additions)
+		fmt.Fprintf(w, "  %s %d addition(s)\n", green("+"), additions)
 	}
 	if deletions > 0 {
-		fmt.Printf("  %s %d deletion(s)\n", red("-"), deletions)
+		fmt.Fprintf(w, "  %s %d deletion(s)\n", red("-"), deletions)
 	}
 	if modifications > 0 {
-		fmt.Printf("  %s %d modification(s)\n", yellow("~"), modifications)
+		fmt.Fprintf(w, "  %s %d modification(s)\n", yellow("~"), modifications)
 	}
 
 	if cs.HasDestructive() {
-		fmt.Printf("\n  %s %d destructive change(s)\n",
+		fmt.Fprintf(w, "\n  %s %d destructive change(s)\n",
 			red("⚠"), cs.DestructiveCount())
 	}
 }
 
-func printDiffFull(cs *schema.ChangeSet) {
+func printDiffFull(w io.Writer, cs *schema.ChangeSet) {
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
 	yellow := color.New(color.FgYellow).SprintFunc()
 
-	tableCreates := cs.ByType(schema.ChangeCreateTable)
-	tableDrops := cs.ByType(schema.ChangeDropTable)
+	schemaCreates := cs.SortedByType(schema.ChangeCreateSchema)
+	schemaDrops := cs.SortedByType(schema.ChangeDropSchema)
+
+	if len(schemaCreates) > 0 || len(schemaDrops) > 0 {
+		for _, c := range schemaCreates {
+			fmt.Fprintf(w, "%s SCHEMA %s\n", green("+"), c.ObjectName())
+		}
+		for _, c := range schemaDrops {
+			fmt.Fprintf(w, "%s SCHEMA %s\n", red("-"), c.ObjectName())
+		}
+		fmt.Fprintln(w)
+	}
+
+	tableCreates := cs.SortedByType(schema.ChangeCreateTable)
+	tableDrops := cs.SortedByType(schema.ChangeDropTable)
 
 	for _, c := range tableCreates {
 		change := c.(*schema.CreateTableChange)
-		fmt.Printf("%s TABLE %s\n", green("+"), change.Table.FullName())
+		fmt.Fprintf(w, "%s TABLE %s\n", green("+"), change.Table.FullName())
 		for _, col := range change.Table.SortedColumns() {
 			nullable := ""
 			if !col.IsNullable {
 				nullable = " NOT NULL"
 			}
-			fmt.Printf("    %s %s%s\n", col.Name, col.FullType(), nullable)
+			fmt.Fprintf(w, "    %s %s%s\n", col.Name, col.FullType(), nullable)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
 	for _, c := range tableDrops {
 		change := c.(*schema.DropTableChange)
-		fmt.Printf("%s TABLE %s %s\n", red("-"), change.Table.FullName(), red("⚠ DESTRUCTIVE"))
-		fmt.Println()
+		fmt.Fprintf(w, "%s TABLE %s %s\n", red("-"), change.Table.FullName(), red("⚠ DESTRUCTIVE"))
+		fmt.Fprintln(w)
 	}
 
 	columnChanges := make(map[string][]schema.Change)
@@ -202,31 +553,49 @@ func printDiffFull(cs *schema.ChangeSet) {
 			columnChanges[change.TableName] = append(columnChanges[change.TableName], c)
 		case *schema.AlterColumnChange:
 			columnChanges[change.TableName] = append(columnChanges[change.TableName], c)
+		case *schema.RenameColumnChange:
+			columnChanges[change.TableName] = append(columnChanges[change.TableName], c)
 		}
 	}
 
-	for tableName, changes := range columnChanges {
-		fmt.Printf("%s TABLE %s\n", yellow("~"), tableName)
+	// Tables are keyed by qualified name, so public.events and
+	// analytics.events are listed separately.
+	tableNames := make([]string, 0, len(columnChanges))
+	for tableName := range columnChanges {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		// Changes within a table share the table prefix in their object
+		// name, so this orders them by column name.
+		changes := columnChanges[tableName]
+		sort.SliceStable(changes, func(i, j int) bool {
+			return changes[i].ObjectName() < changes[j].ObjectName()
+		})
+		fmt.Fprintf(w, "%s TABLE %s\n", yellow("~"), tableName)
 		for _, c := range changes {
 			switch change := c.(type) {
 			case *schema.AddColumnChange:
-				fmt.Printf("  %s COLUMN %s %s\n", green("+"), change.Column.Name, change.Column.FullType())
+				fmt.Fprintf(w, "  %s COLUMN %s %s\n", green("+"), change.Column.Name, change.Column.FullType())
 			case *schema.DropColumnChange:
-				fmt.Printf("  %s COLUMN %s %s\n", red("-"), change.Column.Name, red("⚠ DESTRUCTIVE"))
+				fmt.Fprintf(w, "  %s COLUMN %s %s\n", red("-"), change.Column.Name, red("⚠ DESTRUCTIVE"))
 			case *schema.AlterColumnChange:
 				destructive := ""
 				if change.IsDestructive() {
 					destructive = " " + red("⚠ DESTRUCTIVE")
 				}
-				fmt.Printf("  %s COLUMN %s: %s%s\n", yellow("~"), change.ColumnName,
+				fmt.Fprintf(w, "  %s COLUMN %s: %s%s\n", yellow("~"), change.ColumnName,
 					formatAlteration(&change.Alteration), destructive)
+			case *schema.RenameColumnChange:
+				fmt.Fprintf(w, "  %s COLUMN %s → %s [renamed]\n", yellow("~"), change.OldName, change.Column.Name)
 			}
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
-	indexCreates := cs.ByType(schema.ChangeCreateIndex)
-	indexDrops := cs.ByType(schema.ChangeDropIndex)
+	indexCreates := cs.SortedByType(schema.ChangeCreateIndex)
+	indexDrops := cs.SortedByType(schema.ChangeDropIndex)
 
 	if len(indexCreates) > 0 || len(indexDrops) > 0 {
 		for _, c := range indexCreates {
@@ -235,24 +604,24 @@ func printDiffFull(cs *schema.ChangeSet) {
 			if change.Index.IsUnique {
 				unique = "UNIQUE "
 			}
-			fmt.Printf("%s %sINDEX %s on %s(%s)\n",
+			fmt.Fprintf(w, "%s %sINDEX %s on %s(%s)\n",
 				green("+"), unique, change.Index.Name,
 				change.Index.TableName, strings.Join(change.Index.Columns, ", "))
 		}
 		for _, c := range indexDrops {
 			change := c.(*schema.DropIndexChange)
-			fmt.Printf("%s INDEX %s\n", red("-"), change.Index.Name)
+			fmt.Fprintf(w, "%s INDEX %s\n", red("-"), change.Index.Name)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
-	constraintCreates := cs.ByType(schema.ChangeAddConstraint)
-	constraintDrops := cs.ByType(schema.ChangeDropConstraint)
+	constraintCreates := cs.SortedByType(schema.ChangeAddConstraint)
+	constraintDrops := cs.SortedByType(schema.ChangeDropConstraint)
 
 	if len(constraintCreates) > 0 || len(constraintDrops) > 0 {
 		for _, c := range constraintCreates {
 			change := c.(*schema.AddConstraintChange)
-			fmt.Printf("%s CONSTRAINT %s (%s) on %s\n",
+			fmt.Fprintf(w, "%s CONSTRAINT %s (%s) on %s\n",
 				green("+"), change.Constraint.Name, change.Constraint.Type, change.TableName)
 		}
 		for _, c := range constraintDrops {
@@ -261,62 +630,128 @@ func printDiffFull(cs *schema.ChangeSet) {
 			if change.IsDestructive() {
 				destructive = " " + red("⚠ DESTRUCTIVE")
 			}
-			fmt.Printf("%s CONSTRAINT %s (%s)%s\n",
+			fmt.Fprintf(w, "%s CONSTRAINT %s (%s)%s\n",
 				red("-"), change.Constraint.Name, change.Constraint.Type, destructive)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
-	enumCreates := cs.ByType(schema.ChangeCreateEnum)
-	enumDrops := cs.ByType(schema.ChangeDropEnum)
-	enumValueAdds := cs.ByType(schema.ChangeAddEnumValue)
+	enumCreates := cs.SortedByType(schema.ChangeCreateEnum)
+	enumDrops := cs.SortedByType(schema.ChangeDropEnum)
+	enumValueAdds := cs.SortedByType(schema.ChangeAddEnumValue)
+	enumValueDrops := cs.SortedByType(schema.ChangeDropEnumValue)
+	enumReorders := cs.SortedByType(schema.ChangeReorderEnum)
 
-	if len(enumCreates) > 0 || len(enumDrops) > 0 || len(enumValueAdds) > 0 {
+	if len(enumCreates) > 0 || len(enumDrops) > 0 || len(enumValueAdds) > 0 || len(enumValueDrops) > 0 || len(enumReorders) > 0 {
 		for _, c := range enumCreates {
 			change := c.(*schema.CreateEnumChange)
-			fmt.Printf("%s ENUM %s (%s)\n",
+			fmt.Fprintf(w, "%s ENUM %s (%s)\n",
 				green("+"), change.Enum.FullName(), strings.Join(change.Enum.Values, ", "))
 		}
 		for _, c := range enumDrops {
 			change := c.(*schema.DropEnumChange)
-			fmt.Printf("%s ENUM %s %s\n", red("-"), change.Enum.FullName(), red("⚠ DESTRUCTIVE"))
+			fmt.Fprintf(w, "%s ENUM %s %s\n", red("-"), change.Enum.FullName(), red("⚠ DESTRUCTIVE"))
 		}
 		for _, c := range enumValueAdds {
 			change := c.(*schema.AddEnumValueChange)
-			fmt.Printf("%s ENUM VALUE '%s' to %s\n", green("+"), change.Value, change.EnumName)
+			fmt.Fprintf(w, "%s ENUM VALUE '%s' to %s\n", green("+"), change.Value, change.EnumName)
+		}
+		for _, c := range enumValueDrops {
+			change := c.(*schema.DropEnumValueChange)
+			for _, v := range change.Values() {
+				fmt.Fprintf(w, "%s ENUM VALUE '%s' from %s %s\n", red("-"), v, change.ObjectName(), red("⚠ DESTRUCTIVE"))
+			}
 		}
-		fmt.Println()
+		for _, c := range enumReorders {
+			change := c.(*schema.ReorderEnumChange)
+			fmt.Fprintf(w, "%s ENUM %s (%s) [reordered]\n",
+				yellow("~"), change.ObjectName(), strings.Join(change.NewEnum.Values, ", "))
+		}
+		fmt.Fprintln(w)
 	}
 
-	funcCreates := cs.ByType(schema.ChangeCreateFunction)
-	funcDrops := cs.ByType(schema.ChangeDropFunction)
-	funcReplaces := cs.ByType(schema.ChangeReplaceFunction)
+	seqCreates := cs.SortedByType(schema.ChangeCreateSequence)
+	seqDrops := cs.SortedByType(schema.ChangeDropSequence)
+	seqAlters := cs.SortedByType(schema.ChangeAlterSequence)
+
+	if len(seqCreates) > 0 || len(seqDrops) > 0 || len(seqAlters) > 0 {
+		for _, c := range seqCreates {
+			change := c.(*schema.CreateSequenceChange)
+			fmt.Fprintf(w, "%s SEQUENCE %s (%s)\n", green("+"), change.Sequence.FullName(), change.Sequence.DataType)
+		}
+		for _, c := range seqDrops {
+			fmt.Fprintf(w, "%s SEQUENCE %s\n", red("-"), c.ObjectName())
+		}
+		for _, c := range seqAlters {
+			fmt.Fprintf(w, "%s %s\n", yellow("~"), c.Description())
+		}
+		fmt.Fprintln(w)
+	}
+
+	funcCreates := cs.SortedByType(schema.ChangeCreateFunction)
+	funcDrops := cs.SortedByType(schema.ChangeDropFunction)
+	funcReplaces := cs.SortedByType(schema.ChangeReplaceFunction)
 
 	if len(funcCreates) > 0 || len(funcDrops) > 0 || len(funcReplaces) > 0 {
 		for _, c := range funcCreates {
 			change := c.(*schema.CreateFunctionChange)
-			fmt.Printf("%s FUNCTION %s\n", green("+"), change.Function.Signature())
+			fmt.Fprintf(w, "%s FUNCTION %s\n", green("+"), change.Function.Signature())
 		}
 		for _, c := range funcDrops {
 			change := c.(*schema.DropFunctionChange)
-			fmt.Printf("%s FUNCTION %s\n", red("-"), change.Function.Signature())
+			fmt.Fprintf(w, "%s FUNCTION %s\n", red("-"), change.Function.Signature())
 		}
 		for _, c := range funcReplaces {
 			change := c.(*schema.ReplaceFunctionChange)
-			fmt.Printf("%s FUNCTION %s [body changed]\n", yellow("~"), change.NewFunction.Signature())
+			fmt.Fprintf(w, "%s FUNCTION %s [body changed]\n", yellow("~"), change.NewFunction.Signature())
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
-	printDiffStat(cs)
+	viewCreates := cs.SortedByType(schema.ChangeCreateView)
+	viewDrops := cs.SortedByType(schema.ChangeDropView)
+	viewReplaces := cs.SortedByType(schema.ChangeReplaceView)
+
+	if len(viewCreates) > 0 || len(viewDrops) > 0 || len(viewReplaces) > 0 {
+		for _, c := range viewCreates {
+			fmt.Fprintf(w, "%s %s\n", green("+"), viewLabel(c.(*schema.CreateViewChange).View))
+		}
+		for _, c := range viewDrops {
+			fmt.Fprintf(w, "%s %s\n", red("-"), viewLabel(c.(*schema.DropViewChange).View))
+		}
+		for _, c := range viewReplaces {
+			fmt.Fprintf(w, "%s %s [definition changed]\n", yellow("~"), viewLabel(c.(*schema.ReplaceViewChange).NewView))
+		}
+		fmt.Fprintln(w)
+	}
+
+	commentChanges := cs.SortedByType(schema.ChangeComment)
+
+	if len(commentChanges) > 0 {
+		for _, c := range commentChanges {
+			change := c.(*schema.CommentChange)
+			kind := "COLUMN"
+			if change.ColumnName == "" {
+				kind = "TABLE"
+			}
+			if change.NewComment == "" {
+				fmt.Fprintf(w, "%s COMMENT ON %s %s\n", red("-"), kind, change.ObjectName())
+			} else {
+				fmt.Fprintf(w, "%s COMMENT ON %s %s: %q\n", yellow("~"), kind, change.ObjectName(), change.NewComment)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	printDiffStat(w, cs)
 }
 
-func printDiffSQL(cs *schema.ChangeSet) {
+func printDiffSQL(w io.Writer, cs *schema.ChangeSet) {
 	gen := schema.NewSQLGenerator()
 	statements := gen.Generate(cs)
 
 	for _, stmt := range statements {
-		fmt.Println(stmt)
+		fmt.Fprintln(w, stmt)
 	}
 }
 
@@ -326,6 +761,20 @@ func formatAlteration(alt *schema.ColumnAlteration) string {
 	if alt.TypeChanged {
 		parts = append(parts, fmt.Sprintf("type %s → %s", alt.OldType, alt.NewType))
 	}
+	if alt.GeneratedChanged {
+		if alt.NewGenerated == "" {
+			parts = append(parts, "drop expression")
+		} else {
+			parts = append(parts, fmt.Sprintf("generated always as (%s) stored", alt.NewGenerated))
+		}
+	}
+	if alt.CollationChanged {
+		if alt.NewCollation == "" {
+			parts = append(parts, "default collation")
+		} else {
+			parts = append(parts, fmt.Sprintf("collate \"%s\"", alt.NewCollation))
+		}
+	}
 	if alt.NullableChanged {
 		if alt.NewNullable {
 			parts = append(parts, "nullable")
@@ -340,10 +789,24 @@ func formatAlteration(alt *schema.ColumnAlteration) string {
 			parts = append(parts, fmt.Sprintf("default %s", *alt.NewDefault))
 		}
 	}
+	if alt.IdentityChanged {
+		if alt.NewIdentity == "" {
+			parts = append(parts, "drop identity")
+		} else {
+			parts = append(parts, fmt.Sprintf("generated %s as identity", strings.ToLower(alt.NewIdentity)))
+		}
+	}
 
 	return strings.Join(parts, ", ")
 }
 
+func viewLabel(v *schema.View) string {
+	if v.Materialized {
+		return "MATERIALIZED VIEW " + v.FullName()
+	}
+	return "VIEW " + v.FullName()
+}
+
 func init() {
 	rootCmd.AddCommand(newDiffCmd())
 }
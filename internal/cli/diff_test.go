@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/le-vlad/pgbranch/internal/schema"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testChangeSet returns a change set touching most sections of the full
+// diff, added in an order that differs from the rendered one.
+func testChangeSet() *schema.ChangeSet {
+	defaultNow := "now()"
+
+	logs := schema.NewTable("logs", "public")
+	logs.Columns["message"] = &schema.Column{Name: "message", DataType: "text", IsNullable: true, Position: 2}
+	logs.Columns["id"] = &schema.Column{Name: "id", DataType: "bigint", Position: 1}
+
+	cs := schema.NewChangeSet()
+	cs.Add(&schema.AddColumnChange{TableName: "users", Column: &schema.Column{Name: "nickname", DataType: "text", IsNullable: true}})
+	cs.Add(&schema.CreateTableChange{Table: logs})
+	cs.Add(&schema.DropColumnChange{TableName: "orders", Column: &schema.Column{Name: "legacy_total"}})
+	cs.Add(&schema.AddColumnChange{TableName: "users", Column: &schema.Column{Name: "email", DataType: "text", IsNullable: true}})
+	cs.Add(&schema.AlterColumnChange{
+		TableName:  "users",
+		ColumnName: "created_at",
+		Alteration: schema.ColumnAlteration{DefaultChanged: true, NewDefault: &defaultNow},
+	})
+	cs.Add(&schema.AlterColumnChange{
+		TableName:  "users",
+		ColumnName: "name",
+		Alteration: schema.ColumnAlteration{CollationChanged: true, NewCollation: "C"},
+	})
+	cs.Add(&schema.AlterColumnChange{
+		TableName:  "orders",
+		ColumnName: "total",
+		Alteration: schema.ColumnAlteration{GeneratedChanged: true, NewGenerated: "price * quantity"},
+	})
+	cs.Add(&schema.CreateIndexChange{Index: &schema.Index{Name: "idx_users_email", TableName: "users", Columns: []string{"email"}, IsUnique: true}})
+	cs.Add(&schema.CreateIndexChange{Index: &schema.Index{Name: "idx_logs_id", TableName: "logs", Columns: []string{"id"}}})
+	cs.Add(&schema.AddEnumValueChange{EnumName: "status", Value: "archived"})
+	cs.Add(&schema.CreateEnumChange{Enum: &schema.Enum{Name: "mood", Schema: "public", Values: []string{"happy", "sad"}}})
+	return cs
+}
+
+func TestPrintDiffFull(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	var buf bytes.Buffer
+	printDiffFull(&buf, testChangeSet())
+
+	golden := filepath.Join("testdata", "diff_full.golden")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0644))
+	}
+
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), buf.String())
+}
+
+func TestPrintRowCounts(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	var buf bytes.Buffer
+	printRowCounts(&buf, []tableRowCount{
+		{Table: "public.logs", From: 10, To: 250},
+		{Table: "public.orders", From: 42, To: 42},
+		{Table: "public.users", From: 7, To: 5},
+	})
+
+	golden := filepath.Join("testdata", "diff_data_stat.golden")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0644))
+	}
+
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), buf.String())
+}
+
+func TestPrintRenameHints(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	var buf bytes.Buffer
+	printRenameHints(&buf, nil)
+	assert.Empty(t, buf.String())
+
+	printRenameHints(&buf, []schema.RenameCandidate{{TableName: "users", OldName: "mail", NewName: "email"}})
+	assert.Equal(t, "\n! These dropped and added columns have the same definition and may be renames:\n"+
+		"  users.mail → email  --rename users.mail=email\n", buf.String())
+}
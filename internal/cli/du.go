@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+func newDuCmd() *cobra.Command {
+	var days int
+
+	cmd := &cobra.Command{
+		Use:   "du",
+		Short: "Show disk usage of branch snapshots",
+		Long: `Show how much disk space each branch's snapshot database takes on
+the server, largest first, with the total at the bottom.
+
+Branches not checked out in --days days (default: 7) are flagged as
+stale; 'pgbranch prune' can remove them.
+
+Examples:
+  pgbranch du
+  pgbranch du -d 14`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			branches := brancher.ListBranches()
+			if len(branches) == 0 {
+				fmt.Println("No branches yet. Create one with: pgbranch branch <name>")
+				return nil
+			}
+
+			stale := make(map[string]bool)
+			staleOpts := core.StaleOptions{ExcludeCurrent: true, ExcludeProtected: true}
+			for _, info := range brancher.GetStaleBranches(days, staleOpts) {
+				stale[info.Name] = true
+			}
+
+			type usage struct {
+				info  core.BranchInfo
+				size  int64
+				known bool
+			}
+
+			var (
+				rows                []usage
+				totalSize           int64
+				staleSize           int64
+				staleCount, missing int
+			)
+			for _, info := range branches {
+				u := usage{info: info}
+				if size, err := brancher.Client.DatabaseSize(ctx, info.Branch.Snapshot); err == nil {
+					u.size = size
+					u.known = true
+					totalSize += size
+					if stale[info.Name] {
+						staleSize += size
+					}
+				} else {
+					missing++
+				}
+				if stale[info.Name] {
+					staleCount++
+				}
+				rows = append(rows, u)
+			}
+
+			// Largest first; snapshots whose size couldn't be read go last.
+			sort.SliceStable(rows, func(i, j int) bool {
+				if rows[i].known != rows[j].known {
+					return rows[i].known
+				}
+				return rows[i].size > rows[j].size
+			})
+
+			green := color.New(color.FgGreen).SprintFunc()
+			yellow := color.New(color.FgYellow).SprintFunc()
+			dim := color.New(color.Faint).SprintFunc()
+
+			sizes := make([]string, len(rows))
+			width := len(formatSize(totalSize))
+			for i, u := range rows {
+				if u.known {
+					sizes[i] = formatSize(u.size)
+				} else {
+					sizes[i] = "unknown"
+				}
+				if len(sizes[i]) > width {
+					width = len(sizes[i])
+				}
+			}
+
+			for i, u := range rows {
+				size := fmt.Sprintf("%*s", width, sizes[i])
+				if !u.known {
+					size = dim(size)
+				}
+
+				name := u.info.Name
+				if u.info.IsCurrent {
+					name = green(name)
+				}
+
+				var marker string
+				if stale[u.info.Name] {
+					marker = yellow(fmt.Sprintf("  stale, %d days", u.info.Branch.DaysSinceLastAccess()))
+				}
+
+				fmt.Printf("  %s  %s%s\n", size, name, marker)
+			}
+
+			fmt.Println()
+			fmt.Printf("  %*s  total (%d branch(es))\n", width, formatSize(totalSize), len(rows))
+
+			if staleCount > 0 {
+				fmt.Printf("\n%s %d stale branch(es) use %s. Run 'pgbranch prune -d %d' to remove them.\n",
+					yellow("!"), staleCount, formatSize(staleSize), days)
+			}
+			if missing > 0 {
+				fmt.Printf("%s Could not read the size of %d snapshot database(s); they may be missing from the server.\n",
+					yellow("⚠"), missing)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&days, "days", "d", core.DefaultStaleDays, "Days after which a branch is considered stale")
+
+	return cmd
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/le-vlad/pgbranch/internal/archive"
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/postgres"
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd() *cobra.Command {
+	var (
+		force       bool
+		description string
+		compression int
+		codec       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export <branch> <file>",
+		Short: "Export a branch to an archive file",
+		Long: `Export a local branch snapshot to an archive file, without a remote.
+
+The file uses the same format as archives pushed to a remote and can be
+loaded with 'pgbranch import'.
+
+Examples:
+  pgbranch export main main.pgbranch
+  pgbranch export main main.pgbranch --description "Seed data for QA"
+  pgbranch export main main.pgbranch --codec zstd`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			branchName, path := args[0], args[1]
+
+			if err := archive.ValidateCompression(codec, compression); err != nil {
+				return err
+			}
+
+			if err := postgres.CheckTools(); err != nil {
+				return err
+			}
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			branch, ok := brancher.Metadata.GetBranch(branchName)
+			if !ok {
+				return fmt.Errorf("branch '%s' does not exist locally", branchName)
+			}
+
+			warnPgDumpVersion(ctx, brancher)
+
+			if _, err := os.Stat(path); err == nil && !force {
+				return fmt.Errorf("file '%s' already exists. Use --force to overwrite", path)
+			}
+
+			infof("Creating archive for branch '%s'...\n", branchName)
+
+			opts := &archive.CreateOptions{
+				Description: description,
+				Compression: codec,
+			}
+
+			arch, err := archive.Create(ctx, brancher.Config, branchName, branch.Snapshot, opts)
+			if err != nil {
+				return fmt.Errorf("failed to create archive: %w", err)
+			}
+
+			f, err := os.Create(path)
+			if err != nil {
+				return fmt.Errorf("failed to create file: %w", err)
+			}
+
+			_, err = arch.WriteToLevel(f, compression)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return fmt.Errorf("failed to write archive: %w", err)
+			}
+
+			// WriteToLevel counts the uncompressed payload; report the size
+			// of the file that was written instead.
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("failed to stat archive: %w", err)
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Exported '%s' to %s (%s)\n", green("✓"), branchName, path, formatSize(info.Size()))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the file if it exists")
+	cmd.Flags().StringVarP(&description, "description", "d", "", "Description for this snapshot")
+	cmd.Flags().IntVar(&compression, "compression", archive.DefaultCompressionLevel, "Compression level (gzip: 0-9, zstd: 1-22, -1 for default)")
+	cmd.Flags().StringVar(&codec, "codec", archive.CompressionGzip, "Compression codec (gzip or zstd)")
+
+	return cmd
+}
+
+func newImportCmd() *cobra.Command {
+	var (
+		localName  string
+		force      bool
+		allowNewer bool
+		jobs       int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Create a branch from an archive file",
+		Long: `Create a local branch from an archive file written by 'pgbranch export'
+or downloaded from a remote.
+
+The branch is named after the branch the archive was created from unless
+--as is given.
+
+Examples:
+  pgbranch import main.pgbranch
+  pgbranch import main.pgbranch --as main-from-alice
+  pgbranch import main.pgbranch --force`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			path := args[0]
+
+			if jobs < 1 {
+				return fmt.Errorf("--jobs must be at least 1")
+			}
+
+			if err := postgres.CheckTools(); err != nil {
+				return err
+			}
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			}
+			defer f.Close()
+
+			var size int64
+			if info, err := f.Stat(); err == nil {
+				size = info.Size()
+			}
+
+			progress := newProgressReader(f, "Reading", size)
+			arch, err := archive.ReadFrom(progress)
+			progress.Finish()
+			if err != nil {
+				return fmt.Errorf("failed to read archive: %w", err)
+			}
+
+			targetName := arch.Manifest.Branch
+			if localName != "" {
+				targetName = localName
+			}
+
+			if err := brancher.ValidateBranchName(targetName); err != nil {
+				return err
+			}
+
+			if err := checkOverwrite(brancher, targetName, force); err != nil {
+				return err
+			}
+
+			printArchiveInfo(arch, targetName)
+
+			if err := checkServerVersion(ctx, brancher, arch, allowNewer); err != nil {
+				return err
+			}
+
+			if err := restoreArchiveAsBranch(ctx, brancher, arch, targetName, nil, jobs); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Imported '%s' from %s\n", green("✓"), targetName, path)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&localName, "as", "", "Local branch name (default: branch name stored in the archive)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force overwrite if local branch exists")
+	cmd.Flags().BoolVar(&allowNewer, "allow-newer-version", false, "Restore archives created on a newer PostgreSQL major version")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 1, "Number of parallel pg_restore jobs (uses a temporary file when above 1)")
+
+	return cmd
+}
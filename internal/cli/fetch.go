@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/remote"
+	"github.com/le-vlad/pgbranch/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func newFetchCmd() *cobra.Command {
+	var remoteName string
+
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch the branch list from a remote",
+		Long: `Fetch the list of branches available on a remote without downloading
+any snapshot data.
+
+The result is stored in local metadata, so 'pgbranch branch -a' can show
+remote branches without contacting the remote again.
+
+Examples:
+  # Fetch from default remote
+  pgbranch fetch
+
+  # Fetch from a specific remote
+  pgbranch fetch --remote origin`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			remoteCfg, err := brancher.Config.GetRemote(remoteName)
+			if err != nil {
+				return err
+			}
+
+			remoteConfig := &remote.Config{
+				Name:    remoteCfg.Name,
+				Type:    remoteCfg.Type,
+				URL:     remoteCfg.URL,
+				Options: remoteCfg.Options,
+			}
+
+			r, err := remote.New(remoteConfig)
+			if err != nil {
+				return fmt.Errorf("failed to create remote: %w", err)
+			}
+
+			branches, err := r.List(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to list remote branches: %w", err)
+			}
+
+			sort.Slice(branches, func(i, j int) bool {
+				return branches[i].Name < branches[j].Name
+			})
+
+			previous := make(map[string]bool)
+			if tracking, ok := brancher.Metadata.GetRemoteBranches(remoteCfg.Name); ok {
+				for _, b := range tracking.Branches {
+					previous[b.Name] = true
+				}
+			}
+
+			refs := make([]storage.RemoteBranchRef, 0, len(branches))
+			for _, b := range branches {
+				refs = append(refs, storage.RemoteBranchRef{
+					Name:    b.Name,
+					Size:    b.Size,
+					ModTime: b.ModTime,
+				})
+			}
+
+			err = storage.LockedUpdate(func(m *storage.Metadata) error {
+				m.SetRemoteBranches(remoteCfg.Name, refs)
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to save metadata: %w", err)
+			}
+
+			fmt.Printf("From remote '%s'\n", remoteCfg.Name)
+			if len(branches) == 0 {
+				fmt.Println("  No branches on remote")
+				return nil
+			}
+
+			for _, b := range branches {
+				marker := " "
+				if !previous[b.Name] {
+					marker = "*"
+				}
+				fmt.Printf(" %s %s\t%s\t%s\n", marker, b.Name, formatSize(b.Size), b.ModTime.Format("2006-01-02 15:04"))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&remoteName, "remote", "r", "", "Remote name (default: use default remote)")
+
+	return cmd
+}
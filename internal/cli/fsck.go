@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/storage"
+)
+
+var fsckRepair bool
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check branch metadata for broken parent references",
+	Long: `Check branch metadata for parents that no longer exist and for
+parent cycles, which can be left behind by manual edits to metadata.json.
+
+With --repair, the parent of each affected branch is cleared so that it
+becomes a root branch. For a cycle, only the first branch of the cycle by
+name is changed.
+
+Examples:
+  pgbranch fsck
+  pgbranch fsck --repair`,
+	Args: cobra.NoArgs,
+	RunE: runFsck,
+}
+
+func init() {
+	fsckCmd.Flags().BoolVar(&fsckRepair, "repair", false, "Clear invalid parent references")
+}
+
+func runFsck(cmd *cobra.Command, args []string) error {
+	brancher, err := core.NewBrancher()
+	if err != nil {
+		return err
+	}
+	defer brancher.Close()
+
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	problems := brancher.Metadata.CheckParents()
+	if len(problems) == 0 {
+		infof("%s Branch metadata is consistent\n", green("✓"))
+		return nil
+	}
+
+	if !fsckRepair {
+		for _, p := range problems {
+			fmt.Printf("%s %s\n", red("✗"), p)
+		}
+		return fmt.Errorf("found %d problem(s) in branch metadata. Run 'pgbranch fsck --repair' to fix them", len(problems))
+	}
+
+	var repaired []storage.ParentProblem
+	err = storage.LockedUpdate(func(m *storage.Metadata) error {
+		repaired = m.RepairParents()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	for _, p := range repaired {
+		infof("%s %s: cleared parent of '%s'\n", green("✓"), p, p.Branch)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+var gcForce bool
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove snapshot databases that no branch refers to",
+	Long: `Find databases on the server that are named like snapshots of the
+configured database but don't belong to any branch or saved snapshot,
+and offer to drop them.
+
+These are left behind when pgbranch is interrupted while creating, updating
+or deleting a branch, and take up disk space on the server.
+
+Use --force (-y) to drop them without confirmation.
+
+Examples:
+  pgbranch gc
+  pgbranch gc -y`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+func init() {
+	gcCmd.Flags().BoolVarP(&gcForce, "force", "y", false, "Drop orphaned databases without confirmation")
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	brancher, err := core.NewBrancher()
+	if err != nil {
+		return err
+	}
+	defer brancher.Close()
+
+	orphaned, err := brancher.OrphanedSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	if len(orphaned) == 0 {
+		infof("%s No orphaned snapshot databases found.\n", green("✓"))
+		return nil
+	}
+
+	fmt.Printf("%s Found %d orphaned snapshot database(s):\n\n", yellow("!"), len(orphaned))
+
+	var totalSize int64
+	for _, name := range orphaned {
+		if size, err := brancher.Client.DatabaseSize(ctx, name); err == nil {
+			totalSize += size
+			fmt.Printf("  %s %s\n", name, dim(formatSize(size)))
+		} else {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	fmt.Println()
+
+	if !gcForce {
+		fmt.Printf("%s This will permanently drop %d database(s) (%s).\n",
+			red("!"), len(orphaned), formatSize(totalSize))
+		fmt.Print("Continue? [y/N]: ")
+
+		response, _ := stdinReader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+
+		if response != "y" && response != "yes" {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	dropped, errors := brancher.DropDatabases(ctx, orphaned)
+
+	for _, name := range dropped {
+		infof("%s Dropped '%s'\n", green("✓"), name)
+	}
+
+	for _, err := range errors {
+		fmt.Printf("%s %v\n", red("✗"), err)
+	}
+
+	return nil
+}
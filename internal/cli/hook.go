@@ -130,7 +130,7 @@ func runHookInstall(cmd *cobra.Command, args []string) error {
 		}
 
 		if string(content) == postCheckoutHook {
-			fmt.Println("pgbranch hook is already installed")
+			infof("pgbranch hook is already installed\n")
 			return nil
 		}
 
@@ -146,10 +146,9 @@ func runHookInstall(cmd *cobra.Command, args []string) error {
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Printf("%s Git hook installed successfully!\n", green("✓"))
-	fmt.Println()
-	fmt.Println("Now when you run 'git checkout <branch>', pgbranch will")
-	fmt.Println("automatically switch to the matching database branch if it exists.")
+	infof("%s Git hook installed successfully!\n", green("✓"))
+	infof("\nNow when you run 'git checkout <branch>', pgbranch will\n")
+	infof("automatically switch to the matching database branch if it exists.\n")
 
 	return nil
 }
@@ -183,7 +182,7 @@ func runHookUninstall(cmd *cobra.Command, args []string) error {
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Printf("%s Git hook uninstalled successfully\n", green("✓"))
+	infof("%s Git hook uninstalled successfully\n", green("✓"))
 
 	return nil
 }
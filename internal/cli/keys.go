@@ -56,11 +56,11 @@ unreadable. Use --force to regenerate anyway.`,
 
 			green := color.New(color.FgGreen).SprintFunc()
 			if force && credentials.KeyExists() {
-				fmt.Printf("%s Regenerated encryption key at: %s\n", green("✓"), keyPath)
+				infof("%s Regenerated encryption key at: %s\n", green("✓"), keyPath)
 				yellow := color.New(color.FgYellow).SprintFunc()
 				fmt.Printf("%s Existing encrypted credentials will need to be re-entered\n", yellow("!"))
 			} else {
-				fmt.Printf("%s Generated encryption key at: %s\n", green("✓"), keyPath)
+				infof("%s Generated encryption key at: %s\n", green("✓"), keyPath)
 			}
 
 			return nil
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -9,33 +10,70 @@ import (
 	"github.com/le-vlad/pgbranch/internal/core"
 )
 
+var (
+	logGraph  bool
+	logOutput outputFlags
+)
+
 var logCmd = &cobra.Command{
 	Use:   "log",
 	Short: "Show branch history",
-	Long: `Show all branches with their creation time and parent branch.
+	Long: `Show all branches with their creation time, parent branch, update
+history and the on-disk size of their snapshot database.
+
+With --graph, branches are drawn as a tree of parent/child relationships
+instead. With --json, they are printed as a JSON array.
 
 Example:
-  pgbranch log`,
+  pgbranch log
+  pgbranch log --graph
+  pgbranch log --json`,
 	RunE: runLog,
 }
 
+func init() {
+	logCmd.Flags().BoolVar(&logGraph, "graph", false, "Show branches as a tree based on their parents")
+	logOutput.register(logCmd)
+}
+
 func runLog(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	brancher, err := core.NewBrancher()
 	if err != nil {
 		return err
 	}
+	defer brancher.Close()
+
+	jsonOutput, err := logOutput.isJSON()
+	if err != nil {
+		return err
+	}
+	if jsonOutput && logGraph {
+		return fmt.Errorf("--graph cannot be used with JSON output")
+	}
 
 	branches := brancher.ListBranches()
 
+	if jsonOutput {
+		return printJSON(branchesJSON(ctx, brancher, branches, true))
+	}
+
 	if len(branches) == 0 {
 		fmt.Println("No branches yet.")
 		return nil
 	}
 
+	if logGraph {
+		printBranchGraph(brancher.BranchTree())
+		return nil
+	}
+
 	green := color.New(color.FgGreen).SprintFunc()
 	yellow := color.New(color.FgYellow).SprintFunc()
 	dim := color.New(color.Faint).SprintFunc()
 
+	var totalSize int64
 	for _, info := range branches {
 		var prefix string
 		var name string
@@ -47,18 +85,105 @@ func runLog(cmd *cobra.Command, args []string) error {
 			name = info.Name
 		}
 
+		if info.Branch.Protected {
+			name += yellow(" (protected)")
+		}
+
 		fmt.Printf("%s%s\n", prefix, name)
 
+		if info.Branch.Description != "" {
+			fmt.Printf("    %s\n", info.Branch.Description)
+		}
+
 		fmt.Printf("    Created:  %s\n", dim(info.Branch.CreatedAt.Format("2006-01-02 15:04:05")))
 
 		if info.Branch.Parent != "" {
 			fmt.Printf("    Parent:   %s\n", yellow(info.Branch.Parent))
 		}
 
+		if len(info.Branch.Labels) > 0 {
+			fmt.Printf("    Labels:   %s\n", strings.Join(info.Branch.Labels, ", "))
+		}
+
+		if source := info.Branch.Source; source != nil {
+			details := "pulled " + source.PulledAt.Format("2006-01-02 15:04:05")
+			if !source.UpdatedAt.IsZero() {
+				details += ", remote updated " + source.UpdatedAt.Format("2006-01-02 15:04:05")
+			}
+			var behind string
+			if _, ok := brancher.Metadata.RemoteUpdate(info.Branch); ok {
+				behind = yellow(" behind remote")
+			}
+			fmt.Printf("    Source:   %s/%s %s%s\n", source.Remote, source.Branch, dim("("+details+")"), behind)
+		}
+
 		fmt.Printf("    Snapshot: %s\n", dim(info.Branch.Snapshot))
 
+		if history := info.Branch.UpdateHistory; len(history) > 0 {
+			fmt.Printf("    Updates:\n")
+			for i := len(history) - 1; i >= 0; i-- {
+				entry := history[i]
+				message := entry.Message
+				if message == "" {
+					message = dim("(no message)")
+				}
+				fmt.Printf("      %s  %s\n", dim(entry.Time.Format("2006-01-02 15:04:05")), message)
+			}
+		}
+
+		if size, err := brancher.Client.DatabaseSize(ctx, info.Branch.Snapshot); err == nil {
+			totalSize += size
+			fmt.Printf("    Size:     %s\n", formatSize(size))
+		} else {
+			fmt.Printf("    Size:     %s\n", dim("unknown"))
+		}
+
 		fmt.Println()
 	}
 
+	fmt.Printf("Total snapshot size: %s\n", formatSize(totalSize))
+
 	return nil
 }
+
+func printBranchGraph(roots []*core.BranchNode) {
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	var orphaned []*core.BranchNode
+	for _, root := range roots {
+		printBranchNode(root, "", "")
+		if root.Orphaned {
+			orphaned = append(orphaned, root)
+		}
+	}
+
+	if len(orphaned) > 0 {
+		fmt.Println()
+		for _, node := range orphaned {
+			fmt.Printf("%s '%s' is shown as a root: its parent '%s' no longer exists\n",
+				yellow("⚠"), node.Name, node.Branch.Parent)
+		}
+	}
+}
+
+// printBranchNode prints node after prefix, then its children below it with
+// childPrefix drawing the tree lines of the levels above.
+func printBranchNode(node *core.BranchNode, prefix, childPrefix string) {
+	green := color.New(color.FgGreen).SprintFunc()
+
+	marker := "  "
+	name := node.Name
+	if node.IsCurrent {
+		marker = "* "
+		name = green(name)
+	}
+	fmt.Printf("%s%s%s\n", marker, prefix, name)
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			printBranchNode(child, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			printBranchNode(child, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,14 +16,24 @@ import (
 	"github.com/le-vlad/pgbranch/internal/core"
 	"github.com/le-vlad/pgbranch/internal/schema"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 func newMergeCmd() *cobra.Command {
 	var (
-		dryRun        bool
-		migrationFile bool
-		migrationDir  string
-		force         bool
+		dryRun           bool
+		migrationFile    bool
+		migrationDir     string
+		format           string
+		force            bool
+		allowConflicts   bool
+		threeWay         bool
+		safe             bool
+		allowEnumDrop    bool
+		allowEnumReorder bool
+		usings           []string
+		renames          []string
+		extract          extractFlags
 	)
 
 	cmd := &cobra.Command{
@@ -35,8 +47,29 @@ shows the changes, and applies them to the target branch snapshot.
 The merge will:
 1. Show all schema changes that will be applied
 2. Warn about destructive changes (DROP TABLE, DROP COLUMN, etc.)
-3. Require confirmation for destructive changes
-4. Apply the changes to the target branch snapshot
+3. Report conflicts that DDL cannot resolve cleanly (column type mismatches,
+   reused constraint names, differently ordered enum values) and abort
+   unless --allow-conflicts is given
+4. Require confirmation for destructive changes
+5. Apply the changes to the target branch snapshot, and to the working
+   database first if the target branch is checked out
+
+With --three-way, both branches are diffed against their common ancestor
+(found by following each branch's parent) and only the changes made on the
+source branch are applied. Objects changed differently on both sides are
+reported as conflicts and left untouched; the merge aborts unless
+--allow-conflicts is given. The ancestor is compared in its current state, so changes saved to
+it after the branches were created count as changes on both sides.
+
+A renamed column shows up as a dropped and an added column, and merging
+it that way loses the column's data. When a table has exactly one dropped
+and one added column with the same definition, merge asks whether to
+rename the column instead; --rename table.old=new answers up front, and
+with --force the columns are dropped and added as diffed.
+
+Objects left out with --exclude-schema and --exclude-table are not merged.
+As with diff, the schemas of common extensions are left out unless
+--no-default-excludes is given.
 
 Examples:
   # Merge feature branch into main
@@ -45,56 +78,194 @@ Examples:
   # Preview changes without applying (dry run)
   pgbranch merge feature-auth main --dry-run
 
-  # Generate a migration file instead of applying
+  # Generate up and down migration files instead of applying
   pgbranch merge feature-auth main --migration-file
 
+  # Write the migration in golang-migrate's or goose's file layout
+  pgbranch merge feature-auth main --migration-file --format golang-migrate
+  pgbranch merge feature-auth main --migration-file --format goose
+
+  # Add NOT NULL columns in steps that avoid long table locks
+  pgbranch merge feature-auth main --migration-file --safe
+
+  # Convert a column with a custom expression instead of a plain cast
+  pgbranch merge feature-auth main --using "orders.total=NULLIF(total, '')::numeric"
+
+  # Rename users.name to full_name instead of dropping and adding it
+  pgbranch merge feature-auth main --rename users.name=full_name
+
+  # Also drop enum values removed on the feature branch
+  pgbranch merge feature-auth main --allow-enum-value-drop
+
+  # Also reorder enum values to match the feature branch
+  pgbranch merge feature-auth main --allow-enum-reorder --allow-conflicts
+
+  # Apply only the changes made on the feature branch
+  pgbranch merge feature-auth main --three-way
+
+  # Merge even though the branches conflict (destructive changes are
+  # still confirmed)
+  pgbranch merge feature-auth main --allow-conflicts
+
   # Force merge without confirmation prompts
   pgbranch merge feature-auth main --force`,
-		Args: cobra.ExactArgs(2),
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeBranches(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			sourceBranch := args[0]
 			targetBranch := args[1]
 
+			if safe && !migrationFile {
+				return fmt.Errorf("--safe can only be used with --migration-file")
+			}
+			if cmd.Flags().Changed("format") && !migrationFile {
+				return fmt.Errorf("--format can only be used with --migration-file")
+			}
+			if !slices.Contains(migrationFormats, format) {
+				return fmt.Errorf("invalid migration format '%s' (use %s)", format, strings.Join(migrationFormats, ", "))
+			}
+
+			extractOpts, err := extract.options()
+			if err != nil {
+				return err
+			}
+
 			brancher, err := core.NewBrancher()
 			if err != nil {
 				return err
 			}
+			defer brancher.Close()
 
 			source, ok := brancher.Metadata.GetBranch(sourceBranch)
 			if !ok {
-				return fmt.Errorf("source branch '%s' does not exist", sourceBranch)
+				return branchNotFound(brancher, "source", sourceBranch)
 			}
 
 			target, ok := brancher.Metadata.GetBranch(targetBranch)
 			if !ok {
-				return fmt.Errorf("target branch '%s' does not exist", targetBranch)
+				return branchNotFound(brancher, "target", targetBranch)
 			}
 
-			ctx := context.Background()
+			ctx := cmd.Context()
 
-			fmt.Printf("Extracting schema from '%s'...\n", sourceBranch)
-			sourceSchema, err := extractSchemaFromDB(ctx, brancher, source.Snapshot)
+			printConnection(brancher.Config)
+
+			infof("Extracting schema from '%s'...\n", sourceBranch)
+			sourceSchema, err := extractSchemaFromDB(ctx, brancher, source.Snapshot, extractOpts)
 			if err != nil {
 				return fmt.Errorf("failed to extract source schema: %w", err)
 			}
 
-			fmt.Printf("Extracting schema from '%s'...\n", targetBranch)
-			targetSchema, err := extractSchemaFromDB(ctx, brancher, target.Snapshot)
+			infof("Extracting schema from '%s'...\n", targetBranch)
+			targetSchema, err := extractSchemaFromDB(ctx, brancher, target.Snapshot, extractOpts)
 			if err != nil {
 				return fmt.Errorf("failed to extract target schema: %w", err)
 			}
 
-			changeSet := schema.Diff(targetSchema, sourceSchema)
+			var changeSet *schema.ChangeSet
+			var conflicts []schema.MergeConflict
+			var schemaConflicts []schema.Conflict
 
-			if changeSet.IsEmpty() {
-				fmt.Printf("\nNo schema differences between '%s' and '%s'\n", sourceBranch, targetBranch)
+			if threeWay {
+				baseName, err := brancher.MergeBase(sourceBranch, targetBranch)
+				if err != nil {
+					return err
+				}
+				base, _ := brancher.Metadata.GetBranch(baseName)
+
+				infof("Extracting schema from common ancestor '%s'...\n", baseName)
+				baseSchema, err := extractSchemaFromDB(ctx, brancher, base.Snapshot, extractOpts)
+				if err != nil {
+					return fmt.Errorf("failed to extract ancestor schema: %w", err)
+				}
+
+				changeSet, conflicts = schema.ThreeWayDiff(baseSchema, sourceSchema, targetSchema)
+			} else {
+				changeSet = schema.Diff(targetSchema, sourceSchema)
+				schemaConflicts = schema.DetectConflicts(sourceSchema, targetSchema)
+			}
+
+			for _, u := range usings {
+				column, expr, ok := strings.Cut(u, "=")
+				if !ok || column == "" || expr == "" {
+					return fmt.Errorf("invalid --using %q: expected table.column=expression", u)
+				}
+				if err := changeSet.SetUsing(column, expr); err != nil {
+					return err
+				}
+			}
+
+			if err := applyRenames(changeSet, renames); err != nil {
+				return err
+			}
+			// With --force or --dry-run, or when the answers don't come
+			// from a terminal, nothing is asked, so the columns stay a
+			// drop and an add; point them out instead.
+			candidates := changeSet.RenameCandidates()
+			if force || dryRun || !stdinIsTerminal() {
+				printRenameHints(os.Stdout, candidates)
+				candidates = nil
+			}
+			for _, c := range candidates {
+				prompt := fmt.Sprintf("Column %s.%s was dropped and %s added with the same definition. Rename it instead?",
+					c.TableName, c.OldName, c.NewName)
+				if !confirmPrompt(prompt) {
+					continue
+				}
+				if err := changeSet.RenameColumn(c.TableName+"."+c.OldName, c.NewName); err != nil {
+					return err
+				}
+			}
+
+			if changeSet.IsEmpty() && len(conflicts) == 0 {
+				infof("\nNo schema differences between '%s' and '%s'\n", sourceBranch, targetBranch)
 				return nil
 			}
 
 			changeSet = schema.OrderChanges(changeSet)
 
-			fmt.Printf("\nChanges to merge from '%s' → '%s':\n\n", sourceBranch, targetBranch)
-			printDiffFull(changeSet)
+			// Dropping or reordering enum values recreates the type and
+			// rewrites every table using it, so it is left out unless
+			// asked for.
+			var skippedEnumDrops, skippedEnumReorders []schema.Change
+			if !allowEnumDrop {
+				skippedEnumDrops = changeSet.Remove(schema.ChangeDropEnumValue)
+			}
+			if !allowEnumReorder {
+				skippedEnumReorders = changeSet.Remove(schema.ChangeReorderEnum)
+			}
+
+			if changeSet.IsEmpty() {
+				infof("\nNo non-conflicting changes to merge from '%s' → '%s'\n", sourceBranch, targetBranch)
+			} else if !quietMode {
+				fmt.Printf("\nChanges to merge from '%s' → '%s':\n\n", sourceBranch, targetBranch)
+				printDiffFull(os.Stdout, changeSet)
+			}
+
+			if len(skippedEnumDrops) > 0 {
+				yellow := color.New(color.FgYellow).SprintFunc()
+				fmt.Printf("\n%s Not merged, since dropping enum values recreates the type and locks the tables using it:\n", yellow("!"))
+				for _, c := range skippedEnumDrops {
+					fmt.Printf("  • %s\n", c.Description())
+				}
+				fmt.Printf("  Pass --allow-enum-value-drop to merge them.\n")
+			}
+			if len(skippedEnumReorders) > 0 {
+				yellow := color.New(color.FgYellow).SprintFunc()
+				fmt.Printf("\n%s Not merged, since reordering enum values recreates the type and locks the tables using it:\n", yellow("!"))
+				for _, c := range skippedEnumReorders {
+					fmt.Printf("  • %s\n", c.Description())
+				}
+				fmt.Printf("  Pass --allow-enum-reorder to merge them.\n")
+			}
+
+			if len(conflicts) > 0 {
+				printMergeConflicts(conflicts)
+			}
+
+			if len(schemaConflicts) > 0 {
+				printSchemaConflicts(schemaConflicts)
+			}
 
 			warnings, errs := schema.ValidateChanges(changeSet)
 
@@ -116,12 +287,33 @@ Examples:
 
 			if dryRun {
 				fmt.Printf("\n--- Dry Run: SQL that would be executed ---\n\n")
-				printDiffSQL(changeSet)
+				printDiffSQL(os.Stdout, changeSet)
+				return nil
+			}
+
+			if len(conflicts) > 0 && !allowConflicts {
+				return fmt.Errorf("merge has %d conflict(s); resolve them or use --allow-conflicts to merge the non-conflicting changes", len(conflicts))
+			}
+
+			if len(schemaConflicts) > 0 && !allowConflicts {
+				return fmt.Errorf("merge has %d conflict(s); resolve them or use --allow-conflicts to apply anyway", len(schemaConflicts))
+			}
+
+			if changeSet.IsEmpty() {
 				return nil
 			}
 
 			if migrationFile {
-				return writeMigrationFile(changeSet, sourceBranch, targetBranch, migrationDir)
+				gen := schema.NewSQLGenerator()
+				gen.SafeMode = safe
+				// The migration is meant for the server the branches mirror.
+				// Without its version, the generator assumes an old one.
+				if version, err := brancher.Client.ServerVersion(ctx); err == nil {
+					gen.TargetVersion = version
+				} else {
+					verbosef("Could not read the server version: %v\n", err)
+				}
+				return writeMigrationFile(gen, changeSet, sourceBranch, targetBranch, migrationDir, format)
 			}
 
 			if changeSet.HasDestructive() && !force {
@@ -140,52 +332,147 @@ Examples:
 				}
 			}
 
-			fmt.Printf("\nApplying changes to '%s'...\n", targetBranch)
-
-			targetConnURL := brancher.Config.ConnectionURLForDB(target.Snapshot)
-			conn, err := pgx.Connect(ctx, targetConnURL)
+			databases, err := brancher.MergeDatabases(targetBranch)
 			if err != nil {
-				return fmt.Errorf("failed to connect to target: %w", err)
+				return err
 			}
-			defer conn.Close(ctx)
 
-			applier := schema.NewApplier(conn)
-			result, err := applier.Apply(ctx, changeSet)
-			if err != nil {
-				red := color.New(color.FgRed).SprintFunc()
-				fmt.Printf("\n%s Merge failed: %v\n", red("✗"), err)
-				if len(result.Failed) > 0 {
-					fmt.Printf("\nFailed change:\n")
-					for _, f := range result.Failed {
-						fmt.Printf("  • %s\n", f.Change.Description())
-						fmt.Printf("    SQL: %s\n", f.SQL)
-						fmt.Printf("    Error: %v\n", f.Error)
+			if verboseMode {
+				verbosef("\nSQL to execute:\n")
+				gen := schema.NewSQLGenerator()
+				for _, stmt := range gen.Generate(changeSet) {
+					verbosef("%s\n", stmt)
+				}
+			}
+
+			var applied int
+			for _, dbName := range databases {
+				if dbName == brancher.Config.Database {
+					infof("\nApplying changes to working database '%s' ('%s' is checked out)...\n", dbName, targetBranch)
+				} else {
+					infof("\nApplying changes to '%s'...\n", targetBranch)
+				}
+
+				start := time.Now()
+				result, err := applyMergeChanges(ctx, brancher.Config.ConnectionURLForDB(dbName), changeSet)
+				verbosef("Applying to '%s' took %s\n", dbName, time.Since(start).Round(time.Millisecond))
+				if err != nil {
+					if applied > 0 {
+						yellow := color.New(color.FgYellow).SprintFunc()
+						fmt.Printf("\n%s The working database was updated but the snapshot of '%s' was not.\n",
+							yellow("⚠"), targetBranch)
 					}
+					return err
 				}
-				return err
+				applied = len(result.Applied)
+			}
+
+			if err := brancher.RecordSchema(ctx, targetBranch); err != nil {
+				yellow := color.New(color.FgYellow).SprintFunc()
+				fmt.Printf("\n%s Could not record the new schema of '%s': %v\n", yellow("⚠"), targetBranch, err)
 			}
 
 			green := color.New(color.FgGreen).SprintFunc()
-			fmt.Printf("\n%s Successfully merged %d change(s) from '%s' into '%s'\n",
-				green("✓"), len(result.Applied), sourceBranch, targetBranch)
+			infof("\n%s Successfully merged %d change(s) from '%s' into '%s'\n",
+				green("✓"), applied, sourceBranch, targetBranch)
 
 			return nil
 		},
 	}
 
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show SQL without applying changes")
-	cmd.Flags().BoolVar(&migrationFile, "migration-file", false, "Generate a migration file instead of applying")
+	cmd.Flags().BoolVar(&migrationFile, "migration-file", false, "Generate up and down migration files instead of applying")
 	cmd.Flags().StringVar(&migrationDir, "migration-dir", "migrations", "Directory for migration files")
+	cmd.Flags().StringVar(&format, "format", "raw", "Migration file format: raw, golang-migrate or goose (with --migration-file)")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(migrationFormats, cobra.ShellCompDirectiveNoFileComp))
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompts")
+	cmd.Flags().BoolVar(&allowConflicts, "allow-conflicts", false, "Merge despite conflicts; destructive changes are still confirmed unless --force")
+	cmd.Flags().BoolVar(&threeWay, "three-way", false, "Merge only source's changes since the common ancestor")
+	cmd.Flags().StringArrayVar(&usings, "using", nil, "USING expression for a column type change, as table.column=expression (repeatable)")
+	cmd.Flags().StringArrayVar(&renames, "rename", nil, "Rename a column instead of dropping and adding it, as table.old=new (repeatable)")
+	cmd.Flags().BoolVar(&safe, "safe", false, "Add NOT NULL columns as nullable, backfill, then set NOT NULL (with --migration-file)")
+	cmd.Flags().BoolVar(&allowEnumDrop, "allow-enum-value-drop", false, "Drop enum values removed on source by recreating the type (rewrites and locks tables using it)")
+	cmd.Flags().BoolVar(&allowEnumReorder, "allow-enum-reorder", false, "Reorder enum values to match source by recreating the type (rewrites and locks tables using it)")
+	extract.register(cmd)
 
 	return cmd
 }
 
-func confirmPrompt(message string) bool {
-	reader := bufio.NewReader(os.Stdin)
+// applyMergeChanges applies cs to the database at connURL in a single
+// transaction, printing the failing change if it cannot be applied.
+func applyMergeChanges(ctx context.Context, connURL string, cs *schema.ChangeSet) (*schema.ApplyResult, error) {
+	conn, err := pgx.Connect(ctx, connURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to target: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	applier := schema.NewApplier(conn)
+	result, err := applier.Apply(ctx, cs)
+	if err != nil {
+		red := color.New(color.FgRed).SprintFunc()
+		fmt.Printf("\n%s Merge failed: %v\n", red("✗"), err)
+		if result != nil && len(result.Failed) > 0 {
+			fmt.Printf("\nFailed change:\n")
+			for _, f := range result.Failed {
+				fmt.Printf("  • %s\n", f.Change.Description())
+				fmt.Printf("    SQL: %s\n", f.SQL)
+				fmt.Printf("    Error: %v\n", f.Error)
+			}
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func printMergeConflicts(conflicts []schema.MergeConflict) {
+	red := color.New(color.FgRed).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	fmt.Printf("\n%s Conflicts (changed on both branches, not merged):\n", red("✗"))
+	for _, c := range conflicts {
+		fmt.Printf("  • %s\n", c.Object)
+		for _, sc := range c.Source {
+			fmt.Printf("      %s %s\n", dim("source:"), sc.Description())
+		}
+		for _, tc := range c.Target {
+			fmt.Printf("      %s %s\n", dim("target:"), tc.Description())
+		}
+	}
+}
+
+func printSchemaConflicts(conflicts []schema.Conflict) {
+	red := color.New(color.FgRed).SprintFunc()
+
+	fmt.Printf("\n%s Conflicts:\n", red("✗"))
+	for _, c := range conflicts {
+		fmt.Printf("  • %s\n", c)
+	}
+}
+
+// branchNotFound returns the error for a missing source or target branch,
+// suggesting similar branch names.
+func branchNotFound(brancher *core.Brancher, role, name string) error {
+	if hint := core.DidYouMean(brancher.SimilarBranches(name)); hint != "" {
+		return fmt.Errorf("%s branch '%s' does not exist. %s", role, name, hint)
+	}
+	return fmt.Errorf("%s branch '%s' does not exist", role, name)
+}
+
+// stdinReader is shared by every prompt, so that input one prompt reads
+// ahead, such as piped answers, is left for the next.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// stdinIsTerminal reports whether prompts are answered at a terminal rather
+// than read from a pipe or file.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
 
+func confirmPrompt(message string) bool {
 	fmt.Printf("%s [y/N]: ", message)
-	response, err := reader.ReadString('\n')
+	response, err := stdinReader.ReadString('\n')
 	if err != nil {
 		return false
 	}
@@ -194,7 +481,17 @@ func confirmPrompt(message string) bool {
 	return response == "y" || response == "yes"
 }
 
-func writeMigrationFile(cs *schema.ChangeSet, source, target, dir string) error {
+// migrationFormats are the layouts --format can write migration files in.
+var migrationFormats = []string{"raw", "golang-migrate", "goose"}
+
+// migrationOutput is one file written by writeMigrationFile.
+type migrationOutput struct {
+	label   string
+	path    string
+	content string
+}
+
+func writeMigrationFile(gen *schema.SQLGenerator, cs *schema.ChangeSet, source, target, dir, format string) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create migrations directory: %w", err)
 	}
@@ -202,23 +499,126 @@ func writeMigrationFile(cs *schema.ChangeSet, source, target, dir string) error
 	timestamp := time.Now().Format("20060102150405")
 	safeName := strings.ReplaceAll(source, "/", "_")
 	safeName = strings.ReplaceAll(safeName, " ", "_")
-	filename := fmt.Sprintf("%s_merge_%s.sql", timestamp, safeName)
-	filepath := filepath.Join(dir, filename)
+	name := "merge_" + safeName
 
-	gen := schema.NewSQLGenerator()
 	description := fmt.Sprintf("Merge %s → %s", source, target)
-	content := gen.GenerateMigrationFile(cs, description)
 
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write migration file: %w", err)
+	var outputs []migrationOutput
+	switch format {
+	case "golang-migrate":
+		version, err := nextMigrationVersion(dir)
+		if err != nil {
+			return err
+		}
+
+		// golang-migrate runs a file as one multi-statement query, which is
+		// a transaction block of its own, so each statement that can't run
+		// in one gets a migration to itself, ahead of the rest, and each
+		// statement that must run after the transaction commits gets one
+		// after it. Those have nothing to undo; the column they fill in is
+		// dropped by the down migration before them. Down migrations add
+		// columns back in a single step instead, since a backfill after
+		// their transaction would need down migrations of its own.
+		split := *gen
+		split.SeparateAfterCommit = true
+		down := *gen
+		down.SafeMode = false
+
+		type part struct{ up, down string }
+		var parts []part
+		outside, inside := gen.SplitTransactional(cs)
+		for _, change := range outside.Changes {
+			single := schema.NewChangeSet()
+			single.Add(change)
+			parts = append(parts, part{
+				split.GenerateMigrationFile(single, description),
+				down.GenerateDownMigrationFile(single, description),
+			})
+		}
+		if !inside.IsEmpty() {
+			parts = append(parts, part{
+				split.GenerateMigrationFile(inside, description),
+				down.GenerateDownMigrationFile(inside, description),
+			})
+		}
+		for _, stmt := range gen.AfterCommit(inside) {
+			parts = append(parts, part{
+				split.GenerateAfterCommitMigrationFile(stmt, description),
+				down.GenerateDownMigrationFile(schema.NewChangeSet(), description),
+			})
+		}
+
+		for i, p := range parts {
+			basePath := filepath.Join(dir, fmt.Sprintf("%06d_%s", version+uint64(i), name))
+			if len(parts) > 1 {
+				basePath += fmt.Sprintf("_%d", i+1)
+			}
+			outputs = append(outputs,
+				migrationOutput{"Migration file", basePath + ".up.sql", p.up},
+				migrationOutput{"Down migration file", basePath + ".down.sql", p.down},
+			)
+		}
+	case "goose":
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.sql", timestamp, name))
+		outputs = []migrationOutput{
+			{"Migration file", path, gen.GenerateGooseMigrationFile(cs, description)},
+		}
+	default:
+		basePath := filepath.Join(dir, fmt.Sprintf("%s_%s", timestamp, name))
+		outputs = []migrationOutput{
+			{"Migration file", basePath + ".sql", gen.GenerateMigrationFile(cs, description)},
+			{"Down migration file", basePath + ".down.sql", gen.GenerateDownMigrationFile(cs, description)},
+		}
+	}
+
+	for _, out := range outputs {
+		if err := os.WriteFile(out.path, []byte(out.content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", strings.ToLower(out.label), err)
+		}
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Printf("\n%s Migration file created: %s\n", green("✓"), filepath)
+	infof("\n")
+	for _, out := range outputs {
+		infof("%s %s created: %s\n", green("✓"), out.label, out.path)
+	}
+
+	if _, notes := cs.Inverse(); len(notes) > 0 {
+		yellow := color.New(color.FgYellow).SprintFunc()
+		fmt.Printf("%s The down migration cannot fully undo %d change(s); see the IRREVERSIBLE notes in it.\n",
+			yellow("⚠"), len(notes))
+	}
 
 	return nil
 }
 
+// nextMigrationVersion returns the version after the highest one among the
+// golang-migrate files in dir, whose names start with the version number
+// followed by an underscore.
+func nextMigrationVersion(dir string) (uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	var highest uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".up.sql") || strings.HasSuffix(name, ".down.sql")) {
+			continue
+		}
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			continue
+		}
+		if version, err := strconv.ParseUint(prefix, 10, 64); err == nil && version > highest {
+			highest = version
+		}
+	}
+
+	return highest + 1, nil
+}
+
 func init() {
 	rootCmd.AddCommand(newMergeCmd())
 }
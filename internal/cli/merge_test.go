@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/le-vlad/pgbranch/internal/schema"
+)
+
+func TestNextMigrationVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	version, err := nextMigrationVersion(dir)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), version)
+
+	for _, name := range []string{
+		"000001_create_users.up.sql",
+		"000001_create_users.down.sql",
+		"000007_add_email.up.sql",
+		"000042_notes.sql",
+		"README.md",
+		"latest_merge.up.sql",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	version, err = nextMigrationVersion(dir)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), version)
+}
+
+func TestWriteMigrationFileGolangMigrate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000004_init.up.sql"), nil, 0644))
+
+	cs := schema.NewChangeSet()
+	cs.Add(&schema.AddEnumValueChange{EnumName: "status", Value: "archived"})
+	cs.Add(&schema.AddColumnChange{TableName: "users", Column: &schema.Column{Name: "email", DataType: "text", IsNullable: true}})
+
+	gen := schema.NewSQLGenerator()
+	gen.TargetVersion = 110000
+	require.NoError(t, writeMigrationFile(gen, cs, "feature", "main", dir, "golang-migrate"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{
+		"000004_init.up.sql",
+		"000005_merge_feature_1.down.sql",
+		"000005_merge_feature_1.up.sql",
+		"000006_merge_feature_2.down.sql",
+		"000006_merge_feature_2.up.sql",
+	}, names)
+
+	up, err := os.ReadFile(filepath.Join(dir, "000005_merge_feature_1.up.sql"))
+	require.NoError(t, err)
+	assert.Contains(t, string(up), "ADD VALUE 'archived'")
+	assert.NotContains(t, string(up), "BEGIN;")
+}
+
+func TestWriteMigrationFileGolangMigrateSafe(t *testing.T) {
+	dir := t.TempDir()
+
+	active := "true"
+	cs := schema.NewChangeSet()
+	cs.Add(&schema.AddColumnChange{TableName: "users", Column: &schema.Column{Name: "active", DataType: "boolean", DefaultValue: &active}})
+
+	gen := schema.NewSQLGenerator()
+	gen.SafeMode = true
+	require.NoError(t, writeMigrationFile(gen, cs, "feature", "main", dir, "golang-migrate"))
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	up := read("000001_merge_feature_1.up.sql")
+	assert.Contains(t, up, "ALTER TABLE users ALTER COLUMN active SET DEFAULT true;")
+	assert.NotContains(t, up, "SET NOT NULL")
+
+	backfill := read("000002_merge_feature_2.up.sql")
+	assert.Contains(t, backfill, "UPDATE users SET active = DEFAULT WHERE active IS NULL;")
+	assert.NotContains(t, backfill, "BEGIN;")
+
+	assert.Contains(t, read("000003_merge_feature_3.up.sql"), "ALTER TABLE users ALTER COLUMN active SET NOT NULL;")
+
+	down := read("000001_merge_feature_1.down.sql")
+	assert.Contains(t, down, "DROP COLUMN active")
+}
@@ -1,10 +1,7 @@
 package cli
 
 import (
-	"context"
 	"fmt"
-	"os/signal"
-	"syscall"
 
 	"github.com/le-vlad/pgbranch/internal/migrate"
 	"github.com/spf13/cobra"
@@ -55,11 +52,8 @@ Requirements:
 				mode = migrate.RunSnapshotOnly
 			}
 
-			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-			defer stop()
-
 			migrator := migrate.NewMigrator(cfg, keepSlot, mode)
-			return migrator.Run(ctx)
+			return migrator.Run(cmd.Context())
 		},
 	}
 
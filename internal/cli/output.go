@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/storage"
+)
+
+// outputFlags holds the --json and --output flags shared by commands that
+// can print machine-readable output.
+type outputFlags struct {
+	json   bool
+	format string
+}
+
+func (o *outputFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.json, "json", false, "Output as JSON (same as --output json)")
+	cmd.Flags().StringVarP(&o.format, "output", "o", "text", "Output format (text, json)")
+	cmd.MarkFlagsMutuallyExclusive("json", "output")
+}
+
+// isJSON reports whether JSON output was requested, and rejects unknown
+// --output formats.
+func (o *outputFlags) isJSON() (bool, error) {
+	switch o.format {
+	case "text", "":
+		return o.json, nil
+	case "json":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid output format '%s' (use text or json)", o.format)
+	}
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// branchJSON is the JSON form of a branch printed by 'branch --json' and
+// 'log --json'.
+type branchJSON struct {
+	Name           string                `json:"name"`
+	Parent         string                `json:"parent,omitempty"`
+	Description    string                `json:"description,omitempty"`
+	Labels         []string              `json:"labels,omitempty"`
+	Protected      bool                  `json:"protected,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	LastCheckoutAt *time.Time            `json:"last_checkout_at,omitempty"`
+	Snapshot       string                `json:"snapshot"`
+	IsCurrent      bool                  `json:"is_current"`
+	Size           *int64                `json:"size,omitempty"`
+	Source         *storage.RemoteSource `json:"source,omitempty"`
+	UpdateHistory  []storage.UpdateEntry `json:"update_history,omitempty"`
+}
+
+// branchesJSON converts branches to their JSON form. Sizes that can't be
+// read, for example because the snapshot database is missing, are left out.
+func branchesJSON(ctx context.Context, b *core.Brancher, branches []core.BranchInfo, withHistory bool) []branchJSON {
+	result := make([]branchJSON, 0, len(branches))
+	for _, info := range branches {
+		entry := branchJSON{
+			Name:        info.Name,
+			Parent:      info.Branch.Parent,
+			Description: info.Branch.Description,
+			Labels:      info.Branch.Labels,
+			Protected:   info.Branch.Protected,
+			CreatedAt:   info.Branch.CreatedAt,
+			Snapshot:    info.Branch.Snapshot,
+			IsCurrent:   info.IsCurrent,
+			Source:      info.Branch.Source,
+		}
+		if !info.Branch.LastCheckoutAt.IsZero() {
+			lastCheckout := info.Branch.LastCheckoutAt
+			entry.LastCheckoutAt = &lastCheckout
+		}
+		if size, err := b.Client.DatabaseSize(ctx, info.Branch.Snapshot); err == nil {
+			entry.Size = &size
+		}
+		if withHistory {
+			entry.UpdateHistory = info.Branch.UpdateHistory
+		}
+		result = append(result, entry)
+	}
+	return result
+}
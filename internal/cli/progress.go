@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const progressBarWidth = 30
+
+// progressReader wraps a reader and draws a progress bar on stdout as data
+// is read from it. Nothing is drawn when stdout is not a terminal, or with
+// --quiet.
+type progressReader struct {
+	reader   io.Reader
+	label    string
+	total    int64
+	read     int64
+	enabled  bool
+	lastDraw time.Time
+}
+
+func newProgressReader(r io.Reader, label string, total int64) *progressReader {
+	return &progressReader{
+		reader:  r,
+		label:   label,
+		total:   total,
+		enabled: term.IsTerminal(int(os.Stdout.Fd())) && !quietMode,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.read += int64(n)
+
+	if p.enabled && time.Since(p.lastDraw) >= 100*time.Millisecond {
+		p.draw()
+	}
+
+	return n, err
+}
+
+// Finish draws the final state of the bar and moves to a new line.
+func (p *progressReader) Finish() {
+	if !p.enabled {
+		return
+	}
+	p.draw()
+	fmt.Println()
+}
+
+func (p *progressReader) draw() {
+	p.lastDraw = time.Now()
+
+	if p.total <= 0 {
+		fmt.Printf("\r%s %s\033[K", p.label, formatSize(p.read))
+		return
+	}
+
+	ratio := float64(p.read) / float64(p.total)
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * progressBarWidth)
+
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	fmt.Printf("\r%s [%s] %3d%% (%s / %s)\033[K",
+		p.label, bar, int(ratio*100), formatSize(p.read), formatSize(p.total))
+}
@@ -1,9 +1,7 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/fatih/color"
@@ -13,8 +11,13 @@ import (
 )
 
 var (
-	pruneDays  int
-	pruneForce bool
+	pruneDays         int
+	pruneForce        bool
+	pruneLabel        string
+	pruneExcludeLabel []string
+	pruneProtected    bool
+	pruneDryRun       bool
+	pruneByActivity   bool
 )
 
 var pruneCmd = &cobra.Command{
@@ -26,32 +29,74 @@ By default, shows an interactive list of stale branches where you can
 deselect branches you want to keep.
 
 Use --force (-y) to skip interactive mode and prune all stale branches.
+The current branch and protected branches (see 'pgbranch branch protect')
+are not listed. With --include-protected, protected branches are listed
+but flagged and always kept; delete them with 'pgbranch delete --force'.
 Use --days (-d) to customize the stale threshold (default: 7 days).
+Use --by-activity to also keep branches whose snapshot database has
+clients connected to it, even if it hasn't been checked out recently.
+Use --dry-run to only list the stale branches with their snapshot sizes
+and the total that pruning would free, without prompting or deleting
+anything, for example from a cron job.
+Use --label to only prune stale branches with a label, and
+--exclude-label to leave branches with a label alone. Branches labeled
+'keep' are never pruned.
 
 Examples:
   pgbranch prune              # Interactive mode
   pgbranch prune -y           # Prune all stale branches without confirmation
   pgbranch prune -d 14        # Consider branches stale after 14 days
-  pgbranch prune -d 14 -y     # Prune all branches older than 14 days`,
+  pgbranch prune -d 14 -y     # Prune all branches older than 14 days
+  pgbranch prune --dry-run    # Report stale branches and their sizes only
+  pgbranch prune --by-activity  # Keep branches that clients are connected to
+  pgbranch prune --label wip  # Only prune stale branches labeled 'wip'
+  pgbranch prune --exclude-label demo  # Keep branches labeled 'demo'`,
 	RunE: runPrune,
 }
 
 func init() {
 	pruneCmd.Flags().IntVarP(&pruneDays, "days", "d", core.DefaultStaleDays, "Days after which a branch is considered stale")
 	pruneCmd.Flags().BoolVarP(&pruneForce, "force", "y", false, "Skip interactive mode and prune all stale branches")
+	pruneCmd.Flags().StringVarP(&pruneLabel, "label", "l", "", "Only prune branches with this label")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "List stale branches and reclaimable space without deleting or prompting")
+	pruneCmd.MarkFlagsMutuallyExclusive("dry-run", "force")
+	pruneCmd.Flags().BoolVar(&pruneByActivity, "by-activity", false, "Don't consider branches stale while clients are connected to their snapshot")
+	pruneCmd.Flags().BoolVar(&pruneProtected, "include-protected", false, "List stale protected branches too (they are still kept)")
+	pruneCmd.Flags().StringSliceVar(&pruneExcludeLabel, "exclude-label", nil, "Never prune branches with this label (repeatable)")
 }
 
 func runPrune(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	brancher, err := core.NewBrancher()
 	if err != nil {
 		return err
 	}
+	defer brancher.Close()
+
+	staleOpts := core.StaleOptions{ExcludeCurrent: true, ExcludeProtected: !pruneProtected}
 
-	staleBranches := brancher.GetStaleBranches(pruneDays)
+	var inUse int
+	if pruneByActivity {
+		activity, err := brancher.SnapshotActivity(ctx)
+		if err != nil {
+			return err
+		}
+		before := len(brancher.GetStaleBranches(pruneDays, staleOpts))
+		staleOpts.LastActivity = activity
+		inUse = before - len(brancher.GetStaleBranches(pruneDays, staleOpts))
+	}
+
+	staleBranches := excludeLabels(filterByLabel(brancher.GetStaleBranches(pruneDays, staleOpts), pruneLabel), pruneExcludeLabel)
+
+	if inUse > 0 {
+		dim := color.New(color.Faint).SprintFunc()
+		infof("%s\n", dim(fmt.Sprintf("Keeping %d stale branch(es) with clients connected to their snapshot.", inUse)))
+	}
 
 	if len(staleBranches) == 0 {
 		green := color.New(color.FgGreen).SprintFunc()
-		fmt.Printf("%s No stale branches found (threshold: %d days).\n", green("✓"), pruneDays)
+		infof("%s No stale branches found (threshold: %d days).\n", green("✓"), pruneDays)
 		return nil
 	}
 
@@ -62,6 +107,7 @@ func runPrune(cmd *cobra.Command, args []string) error {
 	fmt.Printf("%s Found %d stale branch(es) (not accessed in %d+ days):\n\n",
 		yellow("!"), len(staleBranches), pruneDays)
 
+	var reclaimable int64
 	for i, info := range staleBranches {
 		days := info.Branch.DaysSinceLastAccess()
 		var lastAccess string
@@ -75,20 +121,44 @@ func runPrune(cmd *cobra.Command, args []string) error {
 		if info.IsCurrent {
 			currentMarker = cyan(" (current)")
 		}
+		if info.Branch.Protected {
+			currentMarker += yellow(" (protected)")
+		}
+
+		details := []string{
+			fmt.Sprintf("created: %s", info.Branch.CreatedAt.Format("2006-01-02")),
+			lastAccess,
+			fmt.Sprintf("%d days ago", days),
+		}
+		if pruneDryRun {
+			if size, err := brancher.Client.DatabaseSize(ctx, info.Branch.Snapshot); err == nil {
+				details = append(details, formatSize(size))
+				if !info.Branch.Protected {
+					reclaimable += size
+				}
+			} else {
+				details = append(details, "size unknown")
+			}
+		}
 
 		fmt.Printf("  %d. %s%s\n", i+1, info.Name, currentMarker)
-		fmt.Printf("     %s | %s | %s\n",
-			dim(fmt.Sprintf("created: %s", info.Branch.CreatedAt.Format("2006-01-02"))),
-			dim(lastAccess),
-			dim(fmt.Sprintf("%d days ago", days)),
-		)
+		fmt.Printf("     %s\n", dim(strings.Join(details, " | ")))
 	}
 	fmt.Println()
 
+	if pruneDryRun {
+		fmt.Printf("Pruning would free about %s. Dry run: no branches were deleted.\n", formatSize(reclaimable))
+		return nil
+	}
+
 	var toPrune []string
 
 	if pruneForce {
 		for _, info := range staleBranches {
+			if info.Branch.Protected {
+				fmt.Printf("%s Keeping protected branch '%s'\n", yellow("!"), info.Name)
+				continue
+			}
 			toPrune = append(toPrune, info.Name)
 		}
 	} else {
@@ -109,8 +179,7 @@ func runPrune(cmd *cobra.Command, args []string) error {
 			red("!"), len(toPrune))
 		fmt.Print("Continue? [y/N]: ")
 
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
+		response, _ := stdinReader.ReadString('\n')
 		response = strings.TrimSpace(strings.ToLower(response))
 
 		if response != "y" && response != "yes" {
@@ -119,14 +188,14 @@ func runPrune(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	fmt.Println()
-	deleted, errors := brancher.PruneBranches(toPrune)
+	infof("\n")
+	deleted, errors := brancher.PruneBranches(ctx, toPrune, false)
 
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
 
 	for _, name := range deleted {
-		fmt.Printf("%s Deleted branch '%s'\n", green("✓"), name)
+		infof("%s Deleted branch '%s'\n", green("✓"), name)
 	}
 
 	for _, err := range errors {
@@ -134,12 +203,33 @@ func runPrune(cmd *cobra.Command, args []string) error {
 	}
 
 	if len(deleted) > 0 {
-		fmt.Printf("\n%s Pruned %d branch(es).\n", green("✓"), len(deleted))
+		infof("\n%s Pruned %d branch(es).\n", green("✓"), len(deleted))
 	}
 
 	return nil
 }
 
+// excludeLabels returns the branches that have none of labels.
+func excludeLabels(branches []core.BranchInfo, labels []string) []core.BranchInfo {
+	if len(labels) == 0 {
+		return branches
+	}
+	var result []core.BranchInfo
+	for _, info := range branches {
+		excluded := false
+		for _, label := range labels {
+			if info.Branch.HasLabel(label) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
 func interactiveSelect(branches []core.BranchInfo) ([]string, error) {
 	if len(branches) == 0 {
 		return nil, nil
@@ -148,27 +238,28 @@ func interactiveSelect(branches []core.BranchInfo) ([]string, error) {
 	cyan := color.New(color.FgCyan).SprintFunc()
 	dim := color.New(color.Faint).SprintFunc()
 
+	// Protected branches are always kept.
+	keepSet := make(map[int]bool)
+	for i, info := range branches {
+		if info.Branch.Protected {
+			keepSet[i+1] = true
+		}
+	}
+
 	fmt.Println("Enter branch numbers to KEEP (comma-separated), or press Enter to prune all:")
 	fmt.Printf("  %s\n", dim("Example: 1,3 (keeps branches 1 and 3, prunes the rest)"))
+	if len(keepSet) > 0 {
+		fmt.Printf("  %s\n", dim("Protected branches are kept either way."))
+	}
 	fmt.Print("> ")
 
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
+	input, err := stdinReader.ReadString('\n')
 	if err != nil {
 		return nil, fmt.Errorf("failed to read input: %w", err)
 	}
 
 	input = strings.TrimSpace(input)
 
-	if input == "" {
-		result := make([]string, len(branches))
-		for i, info := range branches {
-			result[i] = info.Name
-		}
-		return result, nil
-	}
-
-	keepSet := make(map[int]bool)
 	parts := strings.Split(input, ",")
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
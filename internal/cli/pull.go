@@ -3,9 +3,12 @@ package cli
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/le-vlad/pgbranch/internal/archive"
 	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/postgres"
 	"github.com/le-vlad/pgbranch/internal/remote"
 	"github.com/le-vlad/pgbranch/internal/storage"
 	"github.com/spf13/cobra"
@@ -16,6 +19,8 @@ func newPullCmd() *cobra.Command {
 		remoteName string
 		localName  string
 		force      bool
+		allowNewer bool
+		jobs       int
 	)
 
 	cmd := &cobra.Command{
@@ -37,8 +42,16 @@ Examples:
   pgbranch pull main --as main-backup
 
   # Force overwrite if local branch exists
-  pgbranch pull main --force`,
-		Args: cobra.ExactArgs(1),
+  pgbranch pull main --force
+
+  # Try restoring an archive from a newer PostgreSQL major version
+  pgbranch pull main --allow-newer-version
+
+  # Restore with 4 parallel pg_restore workers. The dump is written to a
+  # temporary file first, which needs as much free disk space as the dump.
+  pgbranch pull main --jobs 4`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeRemoteBranches,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			branchName := args[0]
 			targetName := branchName
@@ -46,13 +59,26 @@ Examples:
 				targetName = localName
 			}
 
+			if err := postgres.CheckTools(); err != nil {
+				return err
+			}
+
 			brancher, err := core.NewBrancher()
 			if err != nil {
 				return err
 			}
+			defer brancher.Close()
 
-			if brancher.Metadata.BranchExists(targetName) && !force {
-				return fmt.Errorf("branch '%s' already exists locally. Use --force to overwrite or --as to use a different name", targetName)
+			if err := brancher.ValidateBranchName(targetName); err != nil {
+				return err
+			}
+
+			if jobs < 1 {
+				return fmt.Errorf("--jobs must be at least 1")
+			}
+
+			if err := checkOverwrite(brancher, targetName, force); err != nil {
+				return err
 			}
 
 			remoteCfg, err := brancher.Config.GetRemote(remoteName)
@@ -72,7 +98,7 @@ Examples:
 				return fmt.Errorf("failed to create remote: %w", err)
 			}
 
-			ctx := context.Background()
+			ctx := cmd.Context()
 
 			exists, err := r.Exists(ctx, branchName)
 			if err != nil {
@@ -83,58 +109,31 @@ Examples:
 				return fmt.Errorf("branch '%s' not found on remote '%s'", branchName, remoteCfg.Name)
 			}
 
-			fmt.Printf("Pulling '%s' from remote '%s'...\n", branchName, remoteCfg.Name)
+			source := remoteSource(ctx, r, remoteCfg.Name, branchName)
 
-			reader, size, err := r.Pull(ctx, branchName)
-			if err != nil {
-				return fmt.Errorf("failed to pull from remote: %w", err)
-			}
-			defer reader.Close()
+			printConnection(brancher.Config)
+			infof("Pulling '%s' from remote '%s'...\n", branchName, remoteCfg.Name)
 
-			fmt.Printf("Downloaded %s, verifying...\n", formatSize(size))
-
-			arch, err := archive.ReadFrom(reader)
+			arch, err := downloadArchive(ctx, r, branchName)
 			if err != nil {
-				return fmt.Errorf("failed to read archive: %w", err)
-			}
-
-			fmt.Printf("Archive verified (checksum OK)\n")
-			fmt.Printf("  Branch: %s\n", arch.Manifest.Branch)
-			fmt.Printf("  Created: %s\n", arch.Manifest.CreatedAt.Format("2006-01-02 15:04:05"))
-			if arch.Manifest.Description != "" {
-				fmt.Printf("  Description: %s\n", arch.Manifest.Description)
-			}
-			if arch.Manifest.PgDumpVersion != "" {
-				fmt.Printf("  pg_dump version: %s\n", arch.Manifest.PgDumpVersion)
-			}
-
-			if brancher.Metadata.BranchExists(targetName) && force {
-				fmt.Printf("Removing existing local branch '%s'...\n", targetName)
-				if err := brancher.DeleteBranch(targetName, true); err != nil {
-					return fmt.Errorf("failed to delete existing branch: %w", err)
-				}
+				return err
 			}
 
-			snapshotDBName := storage.SnapshotDBName(brancher.Config.Database, targetName)
-
-			fmt.Printf("Restoring to local snapshot...\n")
+			printArchiveInfo(arch, targetName)
 
-			if err := arch.Restore(ctx, brancher.Config, snapshotDBName); err != nil {
-				return fmt.Errorf("failed to restore snapshot: %w", err)
+			if err := checkServerVersion(ctx, brancher, arch, allowNewer); err != nil {
+				return err
 			}
 
-			brancher.Metadata.AddBranch(targetName, "", snapshotDBName)
-
-			if err := brancher.Metadata.Save(); err != nil {
-				brancher.Client.DeleteSnapshot(snapshotDBName)
-				return fmt.Errorf("failed to save metadata: %w", err)
+			if err := restoreArchiveAsBranch(ctx, brancher, arch, targetName, source, jobs); err != nil {
+				return err
 			}
 
-			fmt.Printf("Successfully pulled '%s'", branchName)
 			if targetName != branchName {
-				fmt.Printf(" as '%s'", targetName)
+				infof("Successfully pulled '%s' as '%s'\n", branchName, targetName)
+			} else {
+				infof("Successfully pulled '%s'\n", branchName)
 			}
-			fmt.Println()
 
 			return nil
 		},
@@ -143,6 +142,183 @@ Examples:
 	cmd.Flags().StringVarP(&remoteName, "remote", "r", "", "Remote name (default: use default remote)")
 	cmd.Flags().StringVar(&localName, "as", "", "Local branch name (default: same as remote branch)")
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force overwrite if local branch exists")
+	cmd.Flags().BoolVar(&allowNewer, "allow-newer-version", false, "Restore archives created on a newer PostgreSQL major version")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 1, "Number of parallel pg_restore jobs (uses a temporary file when above 1)")
 
 	return cmd
 }
+
+// remoteSource returns the provenance to record for branchName pulled from
+// r, the remote called remoteName. Call it before downloading, so that a
+// push landing during the download later shows the branch as behind. The
+// remote's modification time is left zero if the remote can't be listed.
+func remoteSource(ctx context.Context, r remote.Remote, remoteName, branchName string) *storage.RemoteSource {
+	source := &storage.RemoteSource{Remote: remoteName, Branch: branchName, PulledAt: time.Now()}
+
+	branches, err := r.List(ctx)
+	if err != nil {
+		return source
+	}
+	for _, b := range branches {
+		if b.Name == branchName {
+			source.UpdatedAt = b.ModTime
+			break
+		}
+	}
+	return source
+}
+
+// downloadArchive pulls branchName from r and reads it as an archive,
+// showing download progress.
+func downloadArchive(ctx context.Context, r remote.Remote, branchName string) (*archive.Archive, error) {
+	reader, size, err := r.Pull(ctx, branchName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull from remote: %w", err)
+	}
+	defer reader.Close()
+
+	progress := newProgressReader(reader, "Downloading", size)
+	var arch *archive.Archive
+	err = timed("Downloading", func() error {
+		var err error
+		arch, err = archive.ReadFrom(progress)
+		return err
+	})
+	progress.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	infof("Downloaded %s\n", formatSize(progress.read))
+
+	return arch, nil
+}
+
+// printArchiveInfo prints an archive's manifest along with warnings about
+// what restoring it as targetName will leave out.
+func printArchiveInfo(arch *archive.Archive, targetName string) {
+	infof("Archive verified (checksum OK)\n")
+	infof("  Branch: %s\n", arch.Manifest.Branch)
+	infof("  Created: %s\n", arch.Manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+	if arch.Manifest.Description != "" {
+		infof("  Description: %s\n", arch.Manifest.Description)
+	}
+	if arch.Manifest.PgDumpVersion != "" {
+		infof("  pg_dump version: %s\n", arch.Manifest.PgDumpVersion)
+	}
+
+	if arch.Manifest.Mode() == archive.DumpModeSchemaOnly {
+		infof("  Contents: schema only\n")
+		yellow := color.New(color.FgYellow).SprintFunc()
+		fmt.Printf("%s This archive was created with --schema-only; all tables in '%s' will be empty.\n", yellow("⚠"), targetName)
+	}
+
+	if len(arch.Manifest.ExcludedTables) > 0 {
+		yellow := color.New(color.FgYellow).SprintFunc()
+		fmt.Printf("%s These tables were excluded when the archive was created and will be missing from '%s':\n", yellow("⚠"), targetName)
+		for _, table := range arch.Manifest.ExcludedTables {
+			fmt.Printf("    %s\n", table)
+		}
+	}
+}
+
+// checkServerVersion refuses to restore an archive dumped from a newer major
+// PostgreSQL version than the local server, since pg_restore usually fails
+// on such dumps. With allowNewer, it only prints a warning.
+func checkServerVersion(ctx context.Context, brancher *core.Brancher, arch *archive.Archive, allowNewer bool) error {
+	archiveMajor := arch.Manifest.PgMajorVersion()
+	if archiveMajor == 0 {
+		return nil
+	}
+
+	serverVersion, err := brancher.Client.ServerVersion(ctx)
+	if err != nil {
+		return err
+	}
+	serverMajor := postgres.MajorVersion(serverVersion)
+
+	if serverMajor >= archiveMajor {
+		return nil
+	}
+
+	if !allowNewer {
+		return fmt.Errorf("archive was created on PostgreSQL %d but the local server is PostgreSQL %d; restoring to an older major version usually fails. Use --allow-newer-version to try anyway",
+			archiveMajor, serverMajor)
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	fmt.Printf("%s Archive was created on PostgreSQL %d but the local server is PostgreSQL %d; the restore may fail.\n",
+		yellow("⚠"), archiveMajor, serverMajor)
+	return nil
+}
+
+// checkOverwrite refuses to restore over the existing local branch
+// targetName unless force is set. Protected branches are refused even with
+// force, until they are unprotected.
+func checkOverwrite(brancher *core.Brancher, targetName string, force bool) error {
+	branch, ok := brancher.Metadata.GetBranch(targetName)
+	if !ok {
+		return nil
+	}
+
+	if !force {
+		return fmt.Errorf("branch '%s' already exists locally. Use --force to overwrite or --as to use a different name", targetName)
+	}
+
+	if branch.Protected {
+		return fmt.Errorf("%w '%s'. Run 'pgbranch branch unprotect %s' to overwrite it",
+			core.ErrProtectedBranch, targetName, targetName)
+	}
+
+	return nil
+}
+
+// restoreArchiveAsBranch restores arch and registers it as the root branch
+// targetName. An existing branch of that name is replaced: the archive is
+// restored into a temporary database first and only swapped in once the
+// restore succeeded, so a failure leaves the local branch as it was. source
+// is the remote branch arch was pulled from, or nil for archives imported
+// from a file.
+func restoreArchiveAsBranch(ctx context.Context, brancher *core.Brancher, arch *archive.Archive, targetName string, source *storage.RemoteSource, jobs int) error {
+	replace := brancher.Metadata.BranchExists(targetName)
+
+	snapshotDBName := brancher.SnapshotDBName(targetName)
+	if replace {
+		snapshotDBName = brancher.NewTempDBName("restore")
+	}
+
+	infof("Restoring to local snapshot...\n")
+
+	err := timed("Restoring", func() error {
+		return arch.Restore(ctx, brancher.Config, snapshotDBName, &archive.RestoreOptions{Jobs: jobs})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	if replace {
+		infof("Replacing local branch '%s'...\n", targetName)
+		err = brancher.ReplaceBranch(ctx, targetName, snapshotDBName, source)
+	} else {
+		err = brancher.RegisterBranch(targetName, "", snapshotDBName, source)
+	}
+	if err != nil {
+		brancher.Client.DeleteSnapshot(context.WithoutCancel(ctx), snapshotDBName)
+		if replace {
+			return err
+		}
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	// Without a recorded schema, verify reports the branch as
+	// unverifiable rather than failing the restore.
+	brancher.RecordSchema(ctx, targetName)
+
+	if replace && brancher.Metadata.CurrentBranch == targetName {
+		yellow := color.New(color.FgYellow).SprintFunc()
+		fmt.Printf("%s '%s' is checked out; the working database still holds its old data. Run 'pgbranch reset' to load the restored snapshot.\n",
+			yellow("⚠"), targetName)
+	}
+
+	return nil
+}
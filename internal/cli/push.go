@@ -1,13 +1,18 @@
 package cli
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/le-vlad/pgbranch/internal/archive"
 	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/postgres"
 	"github.com/le-vlad/pgbranch/internal/remote"
+	"github.com/le-vlad/pgbranch/internal/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -16,10 +21,16 @@ func newPushCmd() *cobra.Command {
 		remoteName  string
 		force       bool
 		description string
+		compression int
+		codec       string
+		schemaOnly  bool
+		excludes    []string
+		all         bool
+		parallel    int
 	)
 
 	cmd := &cobra.Command{
-		Use:   "push <branch>",
+		Use:   "push [branch]",
 		Short: "Push a branch to a remote",
 		Long: `Push a local branch snapshot to a remote storage backend.
 
@@ -37,21 +48,50 @@ Examples:
   pgbranch push main --force
 
   # Add a description
-  pgbranch push main --description "Initial schema with seed data"`,
-		Args: cobra.ExactArgs(1),
+  pgbranch push main --description "Initial schema with seed data"
+
+  # Trade CPU for a smaller archive (0 = none, 1 = fastest, 9 = best)
+  pgbranch push main --compression 9
+
+  # Compress with zstd instead of gzip
+  pgbranch push main --codec zstd
+
+  # Share the schema without any table data
+  pgbranch push main --schema-only
+
+  # Leave out large or ephemeral tables
+  pgbranch push main --exclude-table audit_log --exclude-table cache_entries
+
+  # Push every local branch that is not on the remote yet, 4 at a time
+  pgbranch push --all --parallel 4`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeBranches(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			branchName := args[0]
+			if all && len(args) > 0 {
+				return fmt.Errorf("cannot name a branch with --all")
+			}
+			if !all && len(args) == 0 {
+				return fmt.Errorf("branch name required (or use --all)")
+			}
 
-			brancher, err := core.NewBrancher()
-			if err != nil {
+			if err := archive.ValidateCompression(codec, compression); err != nil {
 				return err
 			}
 
-			branch, ok := brancher.Metadata.GetBranch(branchName)
-			if !ok {
-				return fmt.Errorf("branch '%s' does not exist locally", branchName)
+			if parallel < 1 {
+				return fmt.Errorf("--parallel must be at least 1")
+			}
+
+			if err := postgres.CheckTools(); err != nil {
+				return err
 			}
 
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
 			remoteCfg, err := brancher.Config.GetRemote(remoteName)
 			if err != nil {
 				return err
@@ -69,44 +109,43 @@ Examples:
 				return fmt.Errorf("failed to create remote: %w", err)
 			}
 
-			ctx := context.Background()
+			ctx := cmd.Context()
 
-			exists, err := r.Exists(ctx, branchName)
-			if err != nil {
-				return fmt.Errorf("failed to check remote: %w", err)
+			printConnection(brancher.Config)
+			warnPgDumpVersion(ctx, brancher)
+
+			opts := &archive.CreateOptions{
+				Description:   description,
+				Compression:   codec,
+				SchemaOnly:    schemaOnly,
+				ExcludeTables: excludes,
 			}
 
-			if exists && !force {
-				return fmt.Errorf("branch '%s' already exists on remote '%s'. Use --force to overwrite", branchName, remoteCfg.Name)
+			if all {
+				return pushAllBranches(ctx, brancher, r, remoteCfg.Name, opts, compression, force, parallel)
 			}
 
-			fmt.Printf("Creating archive for branch '%s'...\n", branchName)
+			branchName := args[0]
 
-			opts := &archive.CreateOptions{
-				Description: description,
+			branch, ok := brancher.Metadata.GetBranch(branchName)
+			if !ok {
+				return fmt.Errorf("branch '%s' does not exist locally", branchName)
 			}
 
-			arch, err := archive.Create(ctx, brancher.Config, branchName, branch.Snapshot, opts)
+			exists, err := r.Exists(ctx, branchName)
 			if err != nil {
-				return fmt.Errorf("failed to create archive: %w", err)
+				return fmt.Errorf("failed to check remote: %w", err)
 			}
 
-			fmt.Printf("Archive size: %s\n", formatSize(arch.Size()))
-
-			var buf bytes.Buffer
-			_, err = arch.WriteTo(&buf)
-			if err != nil {
-				return fmt.Errorf("failed to write archive: %w", err)
+			if exists && !force {
+				return fmt.Errorf("branch '%s' already exists on remote '%s'. Use --force to overwrite", branchName, remoteCfg.Name)
 			}
 
-			fmt.Printf("Pushing to remote '%s'...\n", remoteCfg.Name)
-
-			err = r.Push(ctx, branchName, &buf, int64(buf.Len()))
-			if err != nil {
-				return fmt.Errorf("failed to push to remote: %w", err)
+			if err := pushBranch(ctx, brancher, r, remoteCfg.Name, branch, opts, compression, true); err != nil {
+				return err
 			}
 
-			fmt.Printf("Successfully pushed '%s' to '%s'\n", branchName, remoteCfg.Name)
+			infof("Successfully pushed '%s' to '%s'\n", branchName, remoteCfg.Name)
 
 			return nil
 		},
@@ -115,6 +154,165 @@ Examples:
 	cmd.Flags().StringVarP(&remoteName, "remote", "r", "", "Remote name (default: use default remote)")
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force overwrite if branch exists on remote")
 	cmd.Flags().StringVarP(&description, "description", "d", "", "Description for this snapshot")
+	cmd.Flags().IntVar(&compression, "compression", archive.DefaultCompressionLevel, "Compression level (gzip: 0-9, zstd: 1-22, -1 for default)")
+	cmd.Flags().StringVar(&codec, "codec", archive.CompressionGzip, "Compression codec (gzip or zstd)")
+	cmd.Flags().BoolVar(&schemaOnly, "schema-only", false, "Push only the schema, without table data")
+	cmd.Flags().StringArrayVar(&excludes, "exclude-table", nil, "Leave a table out of the archive (repeatable, accepts pg_dump patterns)")
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Push every local branch")
+	cmd.Flags().IntVar(&parallel, "parallel", 2, "Number of branches to push at once with --all")
 
 	return cmd
 }
+
+// warnPgDumpVersion warns when the local pg_dump is older than the server,
+// in which case the dump is about to fail.
+func warnPgDumpVersion(ctx context.Context, brancher *core.Brancher) {
+	if err := brancher.Client.CheckPgDumpVersion(ctx); err != nil {
+		yellow := color.New(color.FgYellow).SprintFunc()
+		fmt.Printf("%s %v\n", yellow("⚠"), err)
+	}
+}
+
+// pushBranch builds an archive of branch's snapshot and uploads it to r.
+// With verbose set, it reports each step and draws an upload progress bar;
+// pushAllBranches turns this off since several branches upload at once.
+func pushBranch(ctx context.Context, brancher *core.Brancher, r remote.Remote, remoteName string, branch *storage.Branch, opts *archive.CreateOptions, compression int, verbose bool) error {
+	if verbose {
+		infof("Creating archive for branch '%s'...\n", branch.Name)
+	}
+
+	start := time.Now()
+	arch, err := archive.Create(ctx, brancher.Config, branch.Name, branch.Snapshot, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	if verbose {
+		infof("Archive size: %s\n", formatSize(arch.Size()))
+		verbosef("Creating the archive took %s\n", time.Since(start).Round(time.Millisecond))
+	}
+
+	// The archive is compressed while it uploads, so its compressed size
+	// isn't known up front.
+	body := arch.Reader(compression)
+	defer body.Close()
+
+	if !verbose {
+		if err := r.Push(ctx, branch.Name, body, -1); err != nil {
+			return fmt.Errorf("failed to push to remote: %w", err)
+		}
+		return nil
+	}
+
+	infof("Pushing to remote '%s'...\n", remoteName)
+
+	progress := newProgressReader(body, "Uploading", -1)
+	err = timed("Uploading", func() error {
+		return r.Push(ctx, branch.Name, progress, -1)
+	})
+	progress.Finish()
+	if err != nil {
+		return fmt.Errorf("failed to push to remote: %w", err)
+	}
+
+	return nil
+}
+
+// pushAllBranches pushes every local branch to r using up to parallel
+// workers. Branches already on the remote are skipped unless force is set.
+// It prints a line per branch as it finishes and a summary at the end, and
+// returns an error if any branch failed.
+func pushAllBranches(ctx context.Context, brancher *core.Brancher, r remote.Remote, remoteName string, opts *archive.CreateOptions, compression int, force bool, parallel int) error {
+	green := color.New(color.FgGreen).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	names := brancher.Metadata.ListBranches()
+	if len(names) == 0 {
+		infof("No branches to push.\n")
+		return nil
+	}
+	sort.Strings(names)
+
+	onRemote := make(map[string]bool)
+	if !force {
+		remoteBranches, err := r.List(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list remote branches: %w", err)
+		}
+		for _, rb := range remoteBranches {
+			onRemote[rb.Name] = true
+		}
+	}
+
+	var toPush []*storage.Branch
+	var skipped []string
+	for _, name := range names {
+		if onRemote[name] {
+			skipped = append(skipped, name)
+			continue
+		}
+		branch, _ := brancher.Metadata.GetBranch(name)
+		toPush = append(toPush, branch)
+	}
+
+	for _, name := range skipped {
+		infof("%s %s (already on '%s')\n", yellow("→"), name, remoteName)
+	}
+
+	if len(toPush) > 0 {
+		infof("Pushing %d branch(es) to '%s'...\n", len(toPush), remoteName)
+	}
+
+	type pushResult struct {
+		branch string
+		err    error
+	}
+
+	queue := make(chan *storage.Branch)
+	results := make(chan pushResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallel && i < len(toPush); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for branch := range queue {
+				err := pushBranch(ctx, brancher, r, remoteName, branch, opts, compression, false)
+				results <- pushResult{branch: branch.Name, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, branch := range toPush {
+			queue <- branch
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	pushed := 0
+	failed := make(map[string]error)
+	for res := range results {
+		if res.err != nil {
+			fmt.Printf("%s %s: %v\n", red("✗"), res.branch, res.err)
+			failed[res.branch] = res.err
+			continue
+		}
+		infof("%s %s\n", green("✓"), res.branch)
+		pushed++
+	}
+
+	infof("\nPushed %d, skipped %d, failed %d\n", pushed, len(skipped), len(failed))
+	if len(skipped) > 0 {
+		infof("Use --force to overwrite branches that are already on the remote.\n")
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to push %d of %d branches", len(failed), len(toPush))
+	}
+
+	return nil
+}
@@ -1,12 +1,13 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"sort"
 
+	"github.com/fatih/color"
 	"github.com/le-vlad/pgbranch/internal/credentials"
 	"github.com/le-vlad/pgbranch/internal/remote"
+	"github.com/le-vlad/pgbranch/internal/storage"
 	"github.com/le-vlad/pgbranch/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -31,6 +32,7 @@ Supported remote types:
 		newRemoteLsRemoteCmd(),
 		newRemoteSetDefaultCmd(),
 		newRemoteDeleteBranchCmd(),
+		newRemoteTestCmd(),
 	)
 
 	return cmd
@@ -72,52 +74,10 @@ Examples:
 				return fmt.Errorf("invalid remote URL: %w", err)
 			}
 
-			if credentials.RequiresCredentials(remoteCfg.Type) && !skipCredentials {
-				if err := ensureEncryptionKey(); err != nil {
+			if !skipCredentials {
+				if err := promptRemoteCredentials(remoteCfg); err != nil {
 					return err
 				}
-
-				creds, err := credentials.PromptForCredentials(remoteCfg.Type)
-				if err != nil {
-					return fmt.Errorf("failed to get credentials: %w", err)
-				}
-
-				if len(creds) > 0 {
-					save, err := credentials.ConfirmSaveCredentials()
-					if err != nil {
-						return err
-					}
-
-					if save {
-						if remoteCfg.Type == "gcs" {
-							if creds["service_account"] != "" {
-								remoteCfg.Options["service_account"] = creds["service_account"]
-							}
-						} else {
-							store, err := credentials.NewStore()
-							if err != nil {
-								return fmt.Errorf("failed to create credential store: %w", err)
-							}
-
-							remoteCreds := &credentials.RemoteCredentials{
-								AccessKey: creds["access_key"],
-								SecretKey: creds["secret_key"],
-							}
-
-							encAccess, encSecret, err := store.EncryptCredentials(remoteCreds)
-							if err != nil {
-								return fmt.Errorf("failed to encrypt credentials: %w", err)
-							}
-
-							if encAccess != "" {
-								remoteCfg.Options["encrypted_access_key"] = encAccess
-							}
-							if encSecret != "" {
-								remoteCfg.Options["encrypted_secret_key"] = encSecret
-							}
-						}
-					}
-				}
 			}
 
 			configRemote := &config.RemoteConfig{
@@ -135,9 +95,9 @@ Examples:
 				return fmt.Errorf("failed to save config: %w", err)
 			}
 
-			fmt.Printf("Added remote '%s' (%s)\n", name, remoteCfg.Type)
+			infof("Added remote '%s' (%s)\n", name, remoteCfg.Type)
 			if cfg.DefaultRemote == name {
-				fmt.Printf("Set '%s' as default remote\n", name)
+				infof("Set '%s' as default remote\n", name)
 			}
 
 			return nil
@@ -149,6 +109,67 @@ Examples:
 	return cmd
 }
 
+// promptRemoteCredentials asks for the credentials a remote of remoteCfg's
+// type needs and, if the user agrees, stores them in remoteCfg.Options,
+// encrypting access keys with the local encryption key.
+func promptRemoteCredentials(remoteCfg *remote.Config) error {
+	if !credentials.RequiresCredentials(remoteCfg.Type) {
+		return nil
+	}
+
+	if err := ensureEncryptionKey(); err != nil {
+		return err
+	}
+
+	creds, err := credentials.PromptForCredentials(remoteCfg.Type)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	if len(creds) == 0 {
+		return nil
+	}
+
+	save, err := credentials.ConfirmSaveCredentials()
+	if err != nil {
+		return err
+	}
+	if !save {
+		return nil
+	}
+
+	if remoteCfg.Type == "gcs" {
+		if creds["service_account"] != "" {
+			remoteCfg.Options["service_account"] = creds["service_account"]
+		}
+		return nil
+	}
+
+	store, err := credentials.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to create credential store: %w", err)
+	}
+
+	remoteCreds := &credentials.RemoteCredentials{
+		AccessKey: creds["access_key"],
+		SecretKey: creds["secret_key"],
+	}
+
+	encAccess, encSecret, err := store.EncryptCredentials(remoteCreds)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+
+	if encAccess != "" {
+		remoteCfg.Options["encrypted_access_key"] = encAccess
+	}
+	if encSecret != "" {
+		remoteCfg.Options["encrypted_secret_key"] = encSecret
+	}
+
+	return nil
+}
+
 func ensureEncryptionKey() error {
 	if credentials.KeyExists() {
 		return nil
@@ -159,14 +180,14 @@ func ensureEncryptionKey() error {
 		return err
 	}
 
-	fmt.Println("No encryption key found. Generating one...")
+	infof("No encryption key found. Generating one...\n")
 	_, _, err = credentials.EnsureKey()
 	if err != nil {
 		return fmt.Errorf("failed to generate encryption key: %w", err)
 	}
 
-	fmt.Printf("Encryption key saved to: %s\n", keyPath)
-	fmt.Println("This key is used to encrypt credentials stored in project configs.")
+	infof("Encryption key saved to: %s\n", keyPath)
+	infof("This key is used to encrypt credentials stored in project configs.\n")
 	return nil
 }
 
@@ -192,7 +213,15 @@ func newRemoteRemoveCmd() *cobra.Command {
 				return fmt.Errorf("failed to save config: %w", err)
 			}
 
-			fmt.Printf("Removed remote '%s'\n", name)
+			err = storage.LockedUpdate(func(m *storage.Metadata) error {
+				m.RemoveRemoteTracking(name)
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to save metadata: %w", err)
+			}
+
+			infof("Removed remote '%s'\n", name)
 			return nil
 		},
 	}
@@ -266,7 +295,7 @@ func newRemoteLsRemoteCmd() *cobra.Command {
 				return fmt.Errorf("failed to create remote: %w", err)
 			}
 
-			branches, err := r.List(context.Background())
+			branches, err := r.List(cmd.Context())
 			if err != nil {
 				return fmt.Errorf("failed to list remote branches: %w", err)
 			}
@@ -315,7 +344,65 @@ func newRemoteSetDefaultCmd() *cobra.Command {
 				return fmt.Errorf("failed to save config: %w", err)
 			}
 
-			fmt.Printf("Set '%s' as default remote\n", name)
+			infof("Set '%s' as default remote\n", name)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newRemoteTestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [name]",
+		Short: "Check that a remote is reachable and writable",
+		Long: `Check that a remote is reachable and writable by writing and removing a
+small probe object, without building an archive.
+
+Without a name, the default remote is tested.
+
+Examples:
+  pgbranch remote test
+  pgbranch remote test origin`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var name string
+			if len(args) > 0 {
+				name = args[0]
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			remoteCfg, err := cfg.GetRemote(name)
+			if err != nil {
+				return err
+			}
+
+			remoteConfig := &remote.Config{
+				Name:    remoteCfg.Name,
+				Type:    remoteCfg.Type,
+				URL:     remoteCfg.URL,
+				Options: remoteCfg.Options,
+			}
+
+			r, err := remote.New(remoteConfig)
+			if err != nil {
+				return fmt.Errorf("failed to create remote: %w", err)
+			}
+
+			infof("Testing remote '%s' (%s %s)...\n", remoteCfg.Name, remoteCfg.Type, remoteCfg.URL)
+
+			if err := r.Test(cmd.Context()); err != nil {
+				red := color.New(color.FgRed).SprintFunc()
+				fmt.Printf("%s Remote '%s' is not usable\n", red("✗"), remoteCfg.Name)
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Remote '%s' is reachable and writable\n", green("✓"), remoteCfg.Name)
 			return nil
 		},
 	}
@@ -374,13 +461,13 @@ func newRemoteDeleteBranchCmd() *cobra.Command {
 				return fmt.Errorf("failed to create remote: %w", err)
 			}
 
-			ctx := context.Background()
+			ctx := cmd.Context()
 
 			if err := r.Delete(ctx, branchName); err != nil {
 				return fmt.Errorf("failed to delete from remote: %w", err)
 			}
 
-			fmt.Printf("Deleted '%s' from remote '%s'\n", branchName, remoteCfg.Name)
+			infof("Deleted '%s' from remote '%s'\n", branchName, remoteCfg.Name)
 			return nil
 		},
 	}
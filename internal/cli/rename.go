@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+var renameCmd = &cobra.Command{
+	Use:     "rename <old> <new>",
+	Aliases: []string{"mv"},
+	Short:   "Rename a branch",
+	Long: `Rename a branch and its snapshot database.
+
+Branches created from the renamed branch keep pointing at it as their
+parent. Renaming the current branch leaves it checked out.
+
+Example:
+  pgbranch rename featur-x feature-x`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeBranches(1),
+	RunE:              runRename,
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	brancher, err := core.NewBrancher()
+	if err != nil {
+		return err
+	}
+	defer brancher.Close()
+
+	oldName, newName := args[0], args[1]
+
+	if err := brancher.Rename(ctx, oldName, newName); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	infof("%s Renamed branch '%s' to '%s'\n", green("✓"), oldName, newName)
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+func newResetCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Discard changes to the working database",
+		Long: `Restore the current branch's snapshot into the working database,
+discarding every change made since the branch was last saved.
+
+Unlike checkout, the working database is not saved first, so the
+changes are lost for good. You are asked to confirm unless --force
+is given.
+
+Examples:
+  pgbranch reset
+  pgbranch reset -f`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			current := brancher.CurrentBranch()
+			if current == "" {
+				return fmt.Errorf("no current branch to reset to")
+			}
+
+			if !force {
+				message := fmt.Sprintf("Discard all changes to '%s' since branch '%s' was last saved?",
+					brancher.Config.Database, current)
+				if !confirmPrompt(message) {
+					fmt.Println("Reset cancelled.")
+					return nil
+				}
+			}
+
+			yellow := color.New(color.FgYellow).SprintFunc()
+			infof("%s Restoring branch '%s'...\n", yellow("→"), current)
+
+			if err := timed("Reset", func() error { return brancher.Reset(ctx) }); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Reset '%s' to branch '%s'\n", green("✓"), brancher.Config.Database, current)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip the confirmation prompt")
+
+	return cmd
+}
@@ -1,11 +1,18 @@
 package cli
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+var noColor bool
+
 var rootCmd = &cobra.Command{
 	Use:   "pgbranch",
 	Short: "Git-style branching for PostgreSQL databases",
@@ -25,16 +32,56 @@ Example workflow:
 Share snapshots with your team:
   pgbranch remote add origin /shared/snapshots
   pgbranch push main
-  pgbranch pull main`,
+  pgbranch pull main
+
+Colored output is turned off with --no-color, by setting the NO_COLOR
+environment variable, or when output isn't a terminal.
+
+Use --quiet to print only warnings and errors, for scripts that only
+check the exit code, or --verbose to also see connection details, SQL
+statements and timings.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if noColor || os.Getenv("NO_COLOR") != "" {
+			color.NoColor = true
+		}
+		if quietMode && verboseMode {
+			return fmt.Errorf("--quiet and --verbose cannot be used together")
+		}
+		return nil
+	},
 }
 
+// Execute runs the root command. The first Ctrl-C or SIGTERM cancels the
+// context passed to commands, which stop the database operation in progress
+// and clean up after it; a second one exits immediately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			fmt.Fprintln(os.Stderr, "\nInterrupted, cleaning up. Press Ctrl-C again to exit immediately.")
+			signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
+	rootCmd.Version = buildVersion()
+
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().BoolVarP(&quietMode, "quiet", "q", false, "Print only warnings and errors")
+	rootCmd.PersistentFlags().BoolVarP(&verboseMode, "verbose", "v", false, "Also print connection details, SQL statements and timings")
+
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(branchCmd)
 	rootCmd.AddCommand(checkoutCmd)
@@ -43,11 +90,29 @@ func init() {
 	rootCmd.AddCommand(logCmd)
 	rootCmd.AddCommand(hookCmd)
 	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(gcCmd)
 	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(copyCmd)
+	rootCmd.AddCommand(squashCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(fsckCmd)
+	rootCmd.AddCommand(upgradeCmd)
 
 	rootCmd.AddCommand(newRemoteCmd())
 	rootCmd.AddCommand(newPushCmd())
 	rootCmd.AddCommand(newPullCmd())
+	rootCmd.AddCommand(newCloneCmd())
+	rootCmd.AddCommand(newFetchCmd())
+	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(newImportCmd())
+	rootCmd.AddCommand(newArchiveCmd())
 	rootCmd.AddCommand(newKeysCmd())
 	rootCmd.AddCommand(newMigrateCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newResetCmd())
+	rootCmd.AddCommand(newDuCmd())
+	rootCmd.AddCommand(newVersionCmd())
+	rootCmd.AddCommand(newSnapshotCmd())
+	rootCmd.AddCommand(newApplyCmd())
 }
@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/storage"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save and restore named checkpoints of the working database",
+		Long: `Save and restore named checkpoints of the working database.
+
+Snapshots are lightweight checkpoints kept apart from branches: they have
+no parent or history, don't change the current branch, and can only be
+restored over the working database. Take one before a risky operation
+and restore it if the operation goes wrong.
+
+Like branches, snapshots are stored as PostgreSQL template databases.
+
+Examples:
+  pgbranch snapshot create pre-experiment
+  pgbranch snapshot list
+  pgbranch snapshot restore pre-experiment
+  pgbranch snapshot delete pre-experiment`,
+	}
+
+	cmd.AddCommand(
+		newSnapshotCreateCmd(),
+		newSnapshotListCmd(),
+		newSnapshotRestoreCmd(),
+		newSnapshotDeleteCmd(),
+	)
+
+	return cmd
+}
+
+func newSnapshotCreateCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Save the working database as a snapshot",
+		Long: `Save a copy of the working database as a snapshot called <name>.
+
+The current branch is left untouched.
+
+Example:
+  pgbranch snapshot create pre-experiment`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			name := args[0]
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			if err := brancher.ValidateSnapshotName(name); err != nil {
+				return err
+			}
+
+			if err := checkDiskSpace(ctx, brancher, brancher.Config.Database, force); err != nil {
+				return err
+			}
+
+			yellow := color.New(color.FgYellow).SprintFunc()
+			infof("%s Saving '%s' as snapshot '%s'...\n", yellow("→"), brancher.Config.Database, name)
+
+			if err := timed("Snapshot", func() error { return brancher.CreateSavedSnapshot(ctx, name) }); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Created snapshot '%s'\n", green("✓"), name)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Create the snapshot even if the server looks short on disk space")
+
+	return cmd
+}
+
+// snapshotJSON is the JSON form of a saved snapshot printed by
+// 'snapshot list --json'.
+type snapshotJSON struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Branch    string    `json:"branch,omitempty"`
+	Database  string    `json:"database"`
+	Size      *int64    `json:"size,omitempty"`
+}
+
+func newSnapshotListCmd() *cobra.Command {
+	var output outputFlags
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List saved snapshots",
+		Long: `List saved snapshots, oldest first, with the branch that was checked
+out when each was taken and its size on the server.
+
+Examples:
+  pgbranch snapshot list
+  pgbranch snapshot list --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			asJSON, err := output.isJSON()
+			if err != nil {
+				return err
+			}
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			snapshots := brancher.ListSavedSnapshots()
+
+			if asJSON {
+				result := make([]snapshotJSON, 0, len(snapshots))
+				for _, s := range snapshots {
+					entry := snapshotJSON{
+						Name:      s.Name,
+						CreatedAt: s.CreatedAt,
+						Branch:    s.Branch,
+						Database:  s.Database,
+					}
+					if size, err := brancher.Client.DatabaseSize(ctx, s.Database); err == nil {
+						entry.Size = &size
+					}
+					result = append(result, entry)
+				}
+				return printJSON(result)
+			}
+
+			if len(snapshots) == 0 {
+				fmt.Println("No snapshots yet. Create one with: pgbranch snapshot create <name>")
+				return nil
+			}
+
+			dim := color.New(color.Faint).SprintFunc()
+
+			for _, s := range snapshots {
+				details := s.CreatedAt.Format("2006-01-02 15:04:05")
+				if s.Branch != "" {
+					details += ", on " + s.Branch
+				}
+				if size, err := brancher.Client.DatabaseSize(ctx, s.Database); err == nil {
+					details += ", " + formatSize(size)
+				}
+				fmt.Printf("  %s %s\n", s.Name, dim("("+details+")"))
+			}
+
+			return nil
+		},
+	}
+
+	output.register(cmd)
+
+	return cmd
+}
+
+func newSnapshotRestoreCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Replace the working database with a snapshot",
+		Long: `Replace the working database with a copy of the snapshot called
+<name>. The snapshot is kept, so it can be restored again.
+
+Changes made to the working database since it was last saved to a branch
+or snapshot are lost. You are asked to confirm unless --force is given.
+The current branch is not changed.
+
+Examples:
+  pgbranch snapshot restore pre-experiment
+  pgbranch snapshot restore pre-experiment -f`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSnapshots,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			name := args[0]
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			if _, ok := brancher.Metadata.GetSnapshot(name); !ok {
+				return storage.NewSnapshotNotFoundError(name)
+			}
+
+			if !force {
+				message := fmt.Sprintf("Replace '%s' with snapshot '%s'? Unsaved changes will be lost.",
+					brancher.Config.Database, name)
+				if !confirmPrompt(message) {
+					fmt.Println("Restore cancelled.")
+					return nil
+				}
+			}
+
+			yellow := color.New(color.FgYellow).SprintFunc()
+			infof("%s Restoring snapshot '%s'...\n", yellow("→"), name)
+
+			if err := timed("Restore", func() error { return brancher.RestoreSavedSnapshot(ctx, name) }); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Restored '%s' from snapshot '%s'\n", green("✓"), brancher.Config.Database, name)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip the confirmation prompt")
+
+	return cmd
+}
+
+func newSnapshotDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "delete <name>",
+		Aliases: []string{"rm"},
+		Short:   "Delete a saved snapshot",
+		Long: `Delete the snapshot called <name> and its database.
+
+Example:
+  pgbranch snapshot delete pre-experiment`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSnapshots,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			name := args[0]
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			if err := brancher.DeleteSavedSnapshot(ctx, name); err != nil {
+				return err
+			}
+
+			green := color.New(color.FgGreen).SprintFunc()
+			infof("%s Deleted snapshot '%s'\n", green("✓"), name)
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+var squashCmd = &cobra.Command{
+	Use:   "squash <name>",
+	Short: "Make a branch a root branch with a fresh snapshot",
+	Long: `Rebuild a branch's snapshot database from its current contents and
+clear its parent, so it no longer belongs to a chain of branches.
+
+The data in the snapshot is unchanged. Branches created from the squashed
+branch keep it as their parent. This is useful before pushing a clean
+branch to a remote.
+
+Example:
+  pgbranch squash feature-x`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranches(1),
+	RunE:              runSquash,
+}
+
+func runSquash(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	brancher, err := core.NewBrancher()
+	if err != nil {
+		return err
+	}
+	defer brancher.Close()
+
+	name := args[0]
+
+	if err := brancher.SquashBranch(ctx, name); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	infof("%s Squashed branch '%s'\n", green("✓"), name)
+
+	return nil
+}
@@ -1,12 +1,14 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/schema"
 	"github.com/le-vlad/pgbranch/pkg/config"
 )
 
@@ -15,16 +17,27 @@ var statusCmd = &cobra.Command{
 	Short: "Show current branch and status",
 	Long: `Show the current branch and repository status.
 
+Branches pulled from a remote that has been pushed to since are listed as
+behind, based on the branch list saved by the last 'pgbranch fetch'.
+
+With --verbose, also compares the schema of the working database with
+the current branch's snapshot and lists changes that have not been saved
+with 'pgbranch update'.
+
 Example:
-  pgbranch status`,
+  pgbranch status
+  pgbranch status -v`,
 	RunE: runStatus,
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	brancher, err := core.NewBrancher()
 	if err != nil {
 		return err
 	}
+	defer brancher.Close()
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -49,5 +62,79 @@ func runStatus(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Branches:  %d\n", branchCount)
 
+	if branchCount > 0 {
+		var totalSize int64
+		for _, info := range brancher.ListBranches() {
+			if size, err := brancher.Client.DatabaseSize(ctx, info.Branch.Snapshot); err == nil {
+				totalSize += size
+			}
+		}
+		fmt.Printf("Disk:      %s\n", formatSize(totalSize))
+	}
+
+	printBehindRemote(brancher)
+
+	if verboseMode && currentBranch != "" {
+		fmt.Println()
+		return printUncommittedChanges(ctx, brancher)
+	}
+
+	return nil
+}
+
+// printBehindRemote lists branches pulled from a remote whose remote copy
+// has been pushed to since, according to the last 'pgbranch fetch'.
+func printBehindRemote(brancher *core.Brancher) {
+	yellow := color.New(color.FgYellow).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	printed := false
+	for _, info := range brancher.ListBranches() {
+		ref, ok := brancher.Metadata.RemoteUpdate(info.Branch)
+		if !ok {
+			continue
+		}
+		if !printed {
+			fmt.Println()
+			fmt.Printf("%s Behind their remote, as of the last fetch:\n", yellow("!"))
+			printed = true
+		}
+
+		source := info.Branch.Source
+		pullCmd := fmt.Sprintf("pgbranch pull %s -r %s --force", source.Branch, source.Remote)
+		if info.Name != source.Branch {
+			pullCmd = fmt.Sprintf("pgbranch pull %s --as %s -r %s --force", source.Branch, info.Name, source.Remote)
+		}
+		fmt.Printf("    %s  %s/%s updated %s\n", info.Name, source.Remote, source.Branch, ref.ModTime.Format("2006-01-02 15:04:05"))
+		fmt.Printf("      %s\n", dim(pullCmd))
+	}
+}
+
+func printUncommittedChanges(ctx context.Context, brancher *core.Brancher) error {
+	changes, err := brancher.UncommittedChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	if changes.IsEmpty() {
+		fmt.Println("No schema changes since the branch snapshot")
+		return nil
+	}
+
+	red := color.New(color.FgRed).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	destructive := changes.DestructiveCount()
+	fmt.Printf("Schema changes not in branch snapshot: %d addition(s), %d destructive\n",
+		len(changes.Changes)-destructive, destructive)
+	for _, c := range schema.OrderChanges(changes).Changes {
+		if c.IsDestructive() {
+			fmt.Printf("  %s %s\n", red("⚠"), c.Description())
+		} else {
+			fmt.Printf("    %s\n", c.Description())
+		}
+	}
+	fmt.Printf("\n%s\n", dim("Run 'pgbranch update' to save them to the branch"))
+
 	return nil
 }
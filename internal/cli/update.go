@@ -9,6 +9,11 @@ import (
 	"github.com/le-vlad/pgbranch/internal/core"
 )
 
+var (
+	updateMessage string
+	updateForce   bool
+)
+
 var updateCmd = &cobra.Command{
 	Use:   "update [branch]",
 	Short: "Update a branch snapshot with current database state",
@@ -18,19 +23,34 @@ Without arguments, updates the current branch.
 With a name argument, updates the specified branch.
 
 This is useful when you want to actualize a snapshot without switching branches.
+Each update is recorded in the branch's history, shown by 'pgbranch log'.
+
+When PostgreSQL runs on this machine, the update is refused if its disk
+doesn't have room for the new snapshot, which is written before the old
+one is dropped. Use --force to update anyway.
 
 Examples:
-  pgbranch update           # Update current branch
-  pgbranch update main      # Update 'main' branch`,
-	Args: cobra.MaximumNArgs(1),
-	RunE: runUpdate,
+  pgbranch update                          # Update current branch
+  pgbranch update main                     # Update 'main' branch
+  pgbranch update -m "Add invoices table"  # Record a message with the update`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeBranches(1),
+	RunE:              runUpdate,
+}
+
+func init() {
+	updateCmd.Flags().StringVarP(&updateMessage, "message", "m", "", "Message recorded in the branch's update history")
+	updateCmd.Flags().BoolVarP(&updateForce, "force", "f", false, "Update even if the server looks short on disk space")
 }
 
 func runUpdate(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	brancher, err := core.NewBrancher()
 	if err != nil {
 		return err
 	}
+	defer brancher.Close()
 
 	var name string
 	if len(args) == 0 {
@@ -42,15 +62,19 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		name = args[0]
 	}
 
+	if err := checkDiskSpace(ctx, brancher, brancher.Config.Database, updateForce); err != nil {
+		return err
+	}
+
 	yellow := color.New(color.FgYellow).SprintFunc()
-	fmt.Printf("%s Updating branch '%s'...\n", yellow("→"), name)
+	infof("%s Updating branch '%s'...\n", yellow("→"), name)
 
-	if err := brancher.UpdateBranch(name); err != nil {
+	if err := brancher.UpdateBranch(ctx, name, updateMessage); err != nil {
 		return err
 	}
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Printf("%s Updated branch '%s' with current database state\n", green("✓"), name)
+	infof("%s Updated branch '%s' with current database state\n", green("✓"), name)
 
 	return nil
 }
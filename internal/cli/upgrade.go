@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Rename snapshots to the project-namespaced naming scheme",
+	Long: `Rename the snapshot databases of all branches to include this project's ID.
+
+Projects initialized by older versions of pgbranch name snapshots
+{database}_pgbranch_{branch}, so two projects using the same database name
+on one server can overwrite each other's snapshots. New projects include a
+short project ID in the name instead. upgrade assigns this project an ID if
+it has none and renames existing snapshots to match.
+
+Example:
+  pgbranch upgrade`,
+	Args: cobra.NoArgs,
+	RunE: runUpgrade,
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	brancher, err := core.NewBrancher()
+	if err != nil {
+		return err
+	}
+	defer brancher.Close()
+
+	green := color.New(color.FgGreen).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	renamed, err := brancher.UpgradeSnapshotNames(ctx)
+	for _, r := range renamed {
+		infof("%s %s: %s -> %s\n", green("✓"), r.Branch, dim(r.From), r.To)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(renamed) == 0 {
+		infof("%s All snapshots already use project ID %s\n", green("✓"), brancher.Config.ProjectID)
+		return nil
+	}
+
+	infof("\n%s Renamed %d snapshot(s) for project ID %s\n", green("✓"), len(renamed), brancher.Config.ProjectID)
+	return nil
+}
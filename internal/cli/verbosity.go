@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+// Set by the global --quiet and --verbose flags.
+var (
+	quietMode   bool
+	verboseMode bool
+)
+
+// infof prints progress and success messages. They are left out with
+// --quiet; warnings, errors and prompts are printed with fmt directly so
+// they always show.
+func infof(format string, a ...any) {
+	if quietMode {
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// verbosef prints details that are only shown with --verbose, such as SQL
+// statements and timings.
+func verbosef(format string, a ...any) {
+	if !verboseMode {
+		return
+	}
+	dim := color.New(color.Faint).SprintFunc()
+	fmt.Print(dim(fmt.Sprintf(format, a...)))
+}
+
+// printConnection shows the server and database a command works on, with
+// --verbose.
+func printConnection(cfg *config.Config) {
+	verbosef("Server: %s@%s:%d, database '%s', sslmode %s\n",
+		cfg.User, cfg.Host, cfg.Port, cfg.Database, cfg.SSLMode)
+}
+
+// timed runs fn and, with --verbose, prints how long step took.
+func timed(step string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	verbosef("%s took %s\n", step, time.Since(start).Round(time.Millisecond))
+	return err
+}
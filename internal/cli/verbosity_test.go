@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/testutil"
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+// runCommand runs pgbranch with args and returns what it printed to stdout.
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	rootCmd.SetArgs(args)
+	runErr := rootCmd.Execute()
+	os.Stdout = stdout
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, runErr)
+
+	return string(out)
+}
+
+func TestQuietSuppressesSuccessOutput(t *testing.T) {
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := config.DefaultConfig()
+	cfg.Database = "testdb"
+	require.NoError(t, core.InitializeWithConfig(cfg))
+
+	t.Cleanup(func() { quietMode = false })
+
+	out := runCommand(t, "config", "set", "host", "db.internal")
+	assert.Contains(t, out, "Set host to 'db.internal'")
+
+	out = runCommand(t, "config", "set", "port", "5433", "--quiet")
+	assert.Empty(t, out)
+
+	saved, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 5433, saved.Port)
+}
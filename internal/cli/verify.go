@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+)
+
+var verifyRecord bool
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <branch>",
+	Short: "Check a branch snapshot for out-of-band schema changes",
+	Long: `Check whether a branch's snapshot database was modified outside pgbranch.
+
+pgbranch records a fingerprint of each snapshot's schema when the branch is
+created, updated or merged into. verify re-extracts the snapshot's schema and
+compares it with that fingerprint, listing what changed when they differ.
+
+Use --record to accept the snapshot's current schema as its expected state,
+for example after an intentional manual change or for branches created
+before fingerprints were recorded.
+
+Examples:
+  pgbranch verify main
+  pgbranch verify main --record`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranches(1),
+	RunE:              runVerify,
+}
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifyRecord, "record", false, "Record the snapshot's current schema as expected")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	brancher, err := core.NewBrancher()
+	if err != nil {
+		return err
+	}
+	defer brancher.Close()
+
+	name := args[0]
+	green := color.New(color.FgGreen).SprintFunc()
+
+	if verifyRecord {
+		if err := brancher.RecordSchema(ctx, name); err != nil {
+			return err
+		}
+		infof("%s Recorded schema of branch '%s'\n", green("✓"), name)
+		return nil
+	}
+
+	result, err := brancher.VerifyBranch(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !result.Drifted() {
+		infof("%s Branch '%s' matches its recorded schema\n", green("✓"), name)
+		return nil
+	}
+
+	red := color.New(color.FgRed).SprintFunc()
+	fmt.Printf("%s Branch '%s' has drifted from its recorded schema\n", red("✗"), name)
+
+	if result.Changes != nil {
+		fmt.Printf("\nChanges since the schema was recorded:\n\n")
+		printDiffFull(os.Stdout, result.Changes)
+	} else {
+		fmt.Println("The recorded schema is not available, so the changes cannot be listed.")
+	}
+
+	return fmt.Errorf("snapshot of branch '%s' was modified outside pgbranch", name)
+}
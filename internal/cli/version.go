@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/le-vlad/pgbranch/internal/core"
+	"github.com/le-vlad/pgbranch/internal/postgres"
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+// version is set at build time with
+//
+//	go build -ldflags "-X github.com/le-vlad/pgbranch/internal/cli.version=v1.2.3"
+var version = ""
+
+// buildVersion returns the version set with -ldflags, or the module version
+// recorded by 'go install', or "dev" for local builds.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Show pgbranch, pg_dump, pg_restore and server versions",
+		Long: `Show the pgbranch version along with the versions of the pg_dump and
+pg_restore binaries found on PATH, and, inside a pgbranch project, the
+version of the configured PostgreSQL server.
+
+Include this output in bug reports: pg_dump and pg_restore older than the
+server are a common cause of failed pushes and pulls.
+
+Example:
+  pgbranch version`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			red := color.New(color.FgRed).SprintFunc()
+			dim := color.New(color.Faint).SprintFunc()
+
+			fmt.Printf("pgbranch:   %s\n", buildVersion())
+
+			if v, err := postgres.GetPgDumpVersion(); err == nil {
+				fmt.Printf("pg_dump:    %s\n", v)
+			} else {
+				fmt.Printf("pg_dump:    %s\n", red("not found"))
+			}
+
+			if v, err := postgres.GetPgRestoreVersion(); err == nil {
+				fmt.Printf("pg_restore: %s\n", v)
+			} else {
+				fmt.Printf("pg_restore: %s\n", red("not found"))
+			}
+
+			if err := postgres.CheckTools(); err != nil {
+				yellow := color.New(color.FgYellow).SprintFunc()
+				fmt.Printf("%s %v\n", yellow("⚠"), err)
+			}
+
+			if !config.IsInitialized() {
+				fmt.Printf("server:     %s\n", dim("(not in a pgbranch project)"))
+				return nil
+			}
+
+			brancher, err := core.NewBrancher()
+			if err != nil {
+				return err
+			}
+			defer brancher.Close()
+
+			cfg := brancher.Config
+			serverVersion, err := brancher.Client.ServerVersion(ctx)
+			if err != nil {
+				fmt.Printf("server:     %s %s\n", red("unreachable"), dim(fmt.Sprintf("(%s:%d)", cfg.Host, cfg.Port)))
+				return nil
+			}
+
+			fmt.Printf("server:     PostgreSQL %s %s\n",
+				postgres.FormatVersion(serverVersion), dim(fmt.Sprintf("(%s:%d)", cfg.Host, cfg.Port)))
+
+			if err := brancher.Client.CheckPgDumpVersion(ctx); err != nil {
+				yellow := color.New(color.FgYellow).SprintFunc()
+				fmt.Printf("%s %v\n", yellow("⚠"), err)
+			}
+
+			return nil
+		},
+	}
+}
@@ -3,8 +3,12 @@
 package core
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/le-vlad/pgbranch/internal/postgres"
 	"github.com/le-vlad/pgbranch/internal/storage"
@@ -44,18 +48,18 @@ func NewBrancher() (*Brancher, error) {
 	}, nil
 }
 
+// Close releases the database connections held by the Brancher. It is safe
+// to call more than once; the Brancher can still be used afterwards, and
+// opens new connections on demand.
+func (b *Brancher) Close() {
+	if b.Client != nil {
+		b.Client.Close()
+	}
+}
+
 // Initialize sets up pgbranch in the current directory with the given
 // database connection parameters.
 func Initialize(database, host string, port int, user, password string) error {
-	rootDir, err := config.GetRootDir()
-	if err != nil {
-		return err
-	}
-
-	if err := config.EnsureDir(rootDir); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
 	cfg := config.DefaultConfig()
 	cfg.Database = database
 	if host != "" {
@@ -69,10 +73,31 @@ func Initialize(database, host string, port int, user, password string) error {
 	}
 	cfg.Password = password
 
+	return InitializeWithConfig(cfg)
+}
+
+// InitializeWithConfig sets up pgbranch in the current directory with a
+// fully populated configuration.
+func InitializeWithConfig(cfg *config.Config) error {
+	rootDir, err := config.GetRootDir()
+	if err != nil {
+		return err
+	}
+
+	// Validate before creating the directory, so an invalid config doesn't
+	// leave a half-initialized project behind.
 	if err := cfg.Validate(); err != nil {
 		return err
 	}
 
+	if err := config.EnsureDir(rootDir); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if cfg.ProjectID == "" {
+		cfg.ProjectID = config.GenerateProjectID(rootDir, cfg.Database)
+	}
+
 	if err := cfg.Save(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
@@ -85,95 +110,602 @@ func Initialize(database, host string, port int, user, password string) error {
 	return nil
 }
 
+// SnapshotDBName returns the name of the snapshot database for a new branch
+// called name in this project.
+func (b *Brancher) SnapshotDBName(name string) string {
+	return storage.SnapshotDBName(b.Config.SnapshotPrefix, b.Config.Database, b.Config.ProjectID, name)
+}
+
+func (b *Brancher) tempDBName(purpose string) string {
+	return storage.TempDBName(b.Config.SnapshotPrefix, b.Config.Database, b.Config.ProjectID, purpose)
+}
+
+// newRunID returns a short random suffix for the temporary databases of one
+// operation. Unique names keep concurrent operations apart, and mean a
+// database that a failed operation told the user it kept is never reused
+// or dropped by the next one.
+func newRunID() string {
+	var id [4]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// updateMetadata applies fn to a freshly loaded copy of the metadata and
+// saves it while holding the metadata lock, so that changes another pgbranch
+// process made since this Brancher was created are kept. On success,
+// b.Metadata is replaced with the updated copy.
+func (b *Brancher) updateMetadata(fn func(m *storage.Metadata) error) error {
+	var updated *storage.Metadata
+	err := storage.LockedUpdate(func(m *storage.Metadata) error {
+		if err := fn(m); err != nil {
+			return err
+		}
+		updated = m
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.Metadata = updated
+	return nil
+}
+
+// ValidateBranchName checks that name is a valid branch name, that its
+// snapshot database name fits within PostgreSQL's identifier length limit,
+// and that no other branch already uses that snapshot database. Names that
+// only differ in '-', '_', '.' and '/', such as feature-x and feature_x,
+// map to the same database.
+func (b *Brancher) ValidateBranchName(name string) error {
+	if err := storage.ValidateBranchName(name); err != nil {
+		return err
+	}
+
+	snapshotDBName := b.SnapshotDBName(name)
+	if len(snapshotDBName) > storage.MaxIdentifierLength {
+		return fmt.Errorf("branch name '%s' is too long: snapshot database name '%s' exceeds %d bytes",
+			name, snapshotDBName, storage.MaxIdentifierLength)
+	}
+
+	names := b.Metadata.ListBranches()
+	sort.Strings(names)
+	for _, other := range names {
+		if other != name && b.Metadata.Branches[other].Snapshot == snapshotDBName {
+			return fmt.Errorf("branch name '%s' clashes with branch '%s': both use snapshot database '%s'",
+				name, other, snapshotDBName)
+		}
+	}
+
+	return nil
+}
+
 // CreateBranch creates a new branch from the current database state.
 // The branch is stored as a PostgreSQL template database.
-func (b *Brancher) CreateBranch(name string) error {
+func (b *Brancher) CreateBranch(ctx context.Context, name string) error {
+	if err := b.ValidateBranchName(name); err != nil {
+		return err
+	}
+
 	if b.Metadata.BranchExists(name) {
-		return fmt.Errorf("branch '%s' already exists", name)
+		return storage.NewBranchExistsError(name)
 	}
 
-	snapshotDBName := storage.SnapshotDBName(b.Config.Database, name)
+	snapshotDBName := b.SnapshotDBName(name)
 
-	if err := b.Client.CreateSnapshot(snapshotDBName); err != nil {
+	if err := b.Client.CreateSnapshot(ctx, snapshotDBName); err != nil {
+		if ctx.Err() != nil {
+			b.discardSnapshot(ctx, snapshotDBName)
+		}
 		return fmt.Errorf("failed to create snapshot: %w", err)
 	}
 
-	parent := b.Metadata.CurrentBranch
-	b.Metadata.AddBranch(name, parent, snapshotDBName)
+	// A branch whose schema could not be recorded is reported by verify
+	// rather than failing the branch.
+	fingerprint, _ := b.recordSchema(ctx, name, snapshotDBName)
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		if m.BranchExists(name) {
+			return storage.NewBranchExistsError(name)
+		}
+		branch := m.AddBranch(name, m.CurrentBranch, snapshotDBName)
+		branch.SchemaFingerprint = fingerprint
+
+		// Don't save a branch whose creation was interrupted.
+		return ctx.Err()
+	})
+	if err != nil {
+		b.discardSnapshot(ctx, snapshotDBName)
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
 
-	if err := b.Metadata.Save(); err != nil {
-		b.Client.DeleteSnapshot(snapshotDBName)
+	return nil
+}
+
+// CreateBranchFrom creates a new branch by cloning the snapshot of an
+// existing branch, without touching the working database. The source
+// branch is recorded as the new branch's parent.
+func (b *Brancher) CreateBranchFrom(ctx context.Context, name, sourceBranch string) error {
+	if err := b.ValidateBranchName(name); err != nil {
+		return err
+	}
+
+	source, ok := b.Metadata.GetBranch(sourceBranch)
+	if !ok {
+		return storage.NewBranchNotFoundError(sourceBranch)
+	}
+
+	if b.Metadata.BranchExists(name) {
+		return storage.NewBranchExistsError(name)
+	}
+
+	snapshotDBName := b.SnapshotDBName(name)
+
+	if err := b.Client.CreateDatabaseFromTemplate(ctx, source.Snapshot, snapshotDBName); err != nil {
+		if ctx.Err() != nil {
+			b.discardSnapshot(ctx, snapshotDBName)
+		}
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	fingerprint, _ := b.recordSchema(ctx, name, snapshotDBName)
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		if m.BranchExists(name) {
+			return storage.NewBranchExistsError(name)
+		}
+		branch := m.AddBranch(name, sourceBranch, snapshotDBName)
+		branch.SchemaFingerprint = fingerprint
+		return ctx.Err()
+	})
+	if err != nil {
+		b.discardSnapshot(ctx, snapshotDBName)
 		return fmt.Errorf("failed to save metadata: %w", err)
 	}
 
 	return nil
 }
 
+// discardSnapshot drops a snapshot database left behind by a failed or
+// interrupted operation. It runs even if ctx was cancelled: a cancelled
+// CREATE DATABASE may still have completed on the server.
+func (b *Brancher) discardSnapshot(ctx context.Context, snapshotDBName string) {
+	b.Client.DeleteSnapshot(context.WithoutCancel(ctx), snapshotDBName)
+}
+
+// RegisterBranch records snapshotDBName, an existing snapshot database, as
+// a new branch called name with the given parent. source, if not nil, is
+// the remote branch the snapshot was restored from.
+func (b *Brancher) RegisterBranch(name, parent, snapshotDBName string, source *storage.RemoteSource) error {
+	return b.updateMetadata(func(m *storage.Metadata) error {
+		if m.BranchExists(name) {
+			return storage.NewBranchExistsError(name)
+		}
+		branch := m.AddBranch(name, parent, snapshotDBName)
+		branch.Source = source
+		return nil
+	})
+}
+
+// NewTempDBName returns a temporary database name for purpose that is
+// unique to this call, for callers that restore into a database before
+// deciding what to do with it.
+func (b *Brancher) NewTempDBName(purpose string) string {
+	return b.tempDBName(purpose + "_" + newRunID())
+}
+
+// ReplaceBranch makes snapshotDBName, an existing database such as one
+// restored from an archive, the snapshot of the existing branch called
+// name, which is recorded afresh as a root branch with the given source.
+// Protected branches are refused. The old snapshot is renamed aside and
+// only dropped once the metadata points at the new one; if any step fails
+// it is put back, and snapshotDBName is left for the caller to drop.
+func (b *Brancher) ReplaceBranch(ctx context.Context, name, snapshotDBName string, source *storage.RemoteSource) error {
+	branch, ok := b.Metadata.GetBranch(name)
+	if !ok {
+		return b.BranchNotFoundError(name)
+	}
+
+	if branch.Protected {
+		return fmt.Errorf("%w '%s'. Run 'pgbranch branch unprotect %s' to overwrite it",
+			ErrProtectedBranch, name, name)
+	}
+
+	target := branch.Snapshot
+	oldDBName := b.tempDBName("replace_old_" + newRunID())
+
+	if err := b.Client.RenameDatabase(ctx, target, oldDBName); err != nil {
+		return fmt.Errorf("failed to replace snapshot, old snapshot was kept: %w", err)
+	}
+
+	// The branch has no snapshot until one of the two is in place, so roll
+	// back even if ctx was cancelled.
+	rbCtx := context.WithoutCancel(ctx)
+
+	if err := b.Client.RenameDatabase(ctx, snapshotDBName, target); err != nil {
+		if rbErr := b.Client.RenameDatabase(rbCtx, oldDBName, target); rbErr != nil {
+			return fmt.Errorf("failed to replace snapshot: %w (rollback failed: %v; old snapshot kept in '%s')",
+				err, rbErr, oldDBName)
+		}
+		return fmt.Errorf("failed to replace snapshot, old snapshot was kept: %w", err)
+	}
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		if !m.BranchExists(name) {
+			return storage.NewBranchNotFoundError(name)
+		}
+		branch := m.AddBranch(name, "", target)
+		branch.Source = source
+		return nil
+	})
+	if err != nil {
+		if rbErr := b.Client.RenameDatabase(rbCtx, target, snapshotDBName); rbErr != nil {
+			return fmt.Errorf("failed to save metadata: %w (rollback failed: %v; old snapshot kept in '%s')",
+				err, rbErr, oldDBName)
+		}
+		if rbErr := b.Client.RenameDatabase(rbCtx, oldDBName, target); rbErr != nil {
+			return fmt.Errorf("failed to save metadata: %w (rollback failed: %v; old snapshot kept in '%s')",
+				err, rbErr, oldDBName)
+		}
+		return fmt.Errorf("failed to save metadata, old snapshot was kept: %w", err)
+	}
+
+	removeRecordedSchema(target)
+	b.Client.DropDatabaseByName(rbCtx, oldDBName)
+
+	return nil
+}
+
 // Checkout switches to the specified branch by replacing the working database
-// with a copy of the branch's snapshot. The current branch state is saved
-// before switching.
-func (b *Brancher) Checkout(name string) error {
+// with a copy of the branch's snapshot. With autoSave, the current branch
+// state is saved before switching; without it, changes made since the
+// current branch was last saved are lost. The working database is backed up
+// to a temporary database first, so a failed restore leaves it unchanged.
+func (b *Brancher) Checkout(ctx context.Context, name string, autoSave bool) error {
 	branch, ok := b.Metadata.GetBranch(name)
 	if !ok {
-		return fmt.Errorf("branch '%s' does not exist", name)
+		return b.BranchNotFoundError(name)
 	}
 
-	if b.Metadata.CurrentBranch != "" && b.Metadata.CurrentBranch != name {
-		if err := b.UpdateBranch(b.Metadata.CurrentBranch); err != nil {
+	if autoSave && b.Metadata.CurrentBranch != "" && b.Metadata.CurrentBranch != name {
+		message := fmt.Sprintf("Saved before checkout of '%s'", name)
+		if err := b.UpdateBranch(ctx, b.Metadata.CurrentBranch, message); err != nil {
 			return fmt.Errorf("failed to save current branch '%s': %w", b.Metadata.CurrentBranch, err)
 		}
 	}
 
-	snapshotDBName := branch.Snapshot
+	if err := b.restoreWithRollback(ctx, branch.Snapshot); err != nil {
+		return err
+	}
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		m.CurrentBranch = name
+		if err := m.UpdateLastCheckout(name); err != nil {
+			return fmt.Errorf("failed to update last checkout time: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Reset discards all changes to the working database by restoring the
+// current branch's snapshot into it. Unlike Checkout, the working database
+// is not saved to the branch first.
+func (b *Brancher) Reset(ctx context.Context) error {
+	name := b.Metadata.CurrentBranch
+	if name == "" {
+		return fmt.Errorf("%w to reset to", ErrNoCurrentBranch)
+	}
 
-	if err := b.Client.RestoreFromSnapshot(snapshotDBName); err != nil {
+	branch, ok := b.Metadata.GetBranch(name)
+	if !ok {
+		return storage.NewBranchNotFoundError(name)
+	}
+
+	return b.restoreWithRollback(ctx, branch.Snapshot)
+}
+
+// CheckoutPlan describes the actions a checkout would perform.
+type CheckoutPlan struct {
+	Target         string
+	SaveBranch     string
+	Snapshot       string
+	LastCheckoutAt time.Time
+	IsStale        bool
+	AlreadyOn      bool
+}
+
+// CheckoutPlan returns the actions Checkout would take for the given branch
+// without changing any databases or metadata.
+func (b *Brancher) CheckoutPlan(name string) (*CheckoutPlan, error) {
+	branch, ok := b.Metadata.GetBranch(name)
+	if !ok {
+		return nil, b.BranchNotFoundError(name)
+	}
+
+	plan := &CheckoutPlan{
+		Target:         name,
+		Snapshot:       branch.Snapshot,
+		LastCheckoutAt: branch.LastCheckoutAt,
+		IsStale:        branch.IsStale(DefaultStaleDays),
+		AlreadyOn:      b.Metadata.CurrentBranch == name,
+	}
+
+	if b.Metadata.CurrentBranch != "" && !plan.AlreadyOn {
+		plan.SaveBranch = b.Metadata.CurrentBranch
+	}
+
+	return plan, nil
+}
+
+// restoreWithRollback replaces the working database with the given snapshot.
+// If the restore fails, the working database is restored from a backup taken
+// beforehand. The backup is only dropped once the working database holds
+// either the snapshot or the backup again.
+func (b *Brancher) restoreWithRollback(ctx context.Context, snapshotDBName string) error {
+	exists, err := b.Client.DatabaseExists(ctx)
+	if err != nil {
 		return fmt.Errorf("failed to restore branch: %w", err)
 	}
 
-	b.Metadata.CurrentBranch = name
+	if !exists {
+		if err := b.Client.RestoreFromSnapshot(ctx, snapshotDBName); err != nil {
+			return fmt.Errorf("failed to restore branch: %w", err)
+		}
+		return nil
+	}
+
+	backupDBName := b.tempDBName("checkout_" + newRunID())
 
-	if err := b.Metadata.UpdateLastCheckout(name); err != nil {
-		return fmt.Errorf("failed to update last checkout time: %w", err)
+	if err := b.Client.CreateSnapshot(ctx, backupDBName); err != nil {
+		b.discardSnapshot(ctx, backupDBName)
+		return fmt.Errorf("failed to back up working database: %w", err)
 	}
 
-	if err := b.Metadata.Save(); err != nil {
-		return fmt.Errorf("failed to update metadata: %w", err)
+	if err := b.Client.RestoreFromSnapshot(ctx, snapshotDBName); err != nil {
+		// Roll back even if ctx was cancelled, so an interrupted checkout
+		// doesn't leave the working database missing.
+		rbCtx := context.WithoutCancel(ctx)
+		if rbErr := b.Client.RestoreFromSnapshot(rbCtx, backupDBName); rbErr != nil {
+			return fmt.Errorf("failed to restore branch: %w (rollback failed: %v; working database kept in '%s')",
+				err, rbErr, backupDBName)
+		}
+		b.Client.DropDatabaseByName(rbCtx, backupDBName)
+		return fmt.Errorf("failed to restore branch, working database was left unchanged: %w", err)
 	}
 
+	b.Client.DropDatabaseByName(ctx, backupDBName)
+
 	return nil
 }
 
 // DeleteBranch removes a branch and its associated snapshot database.
-// Returns an error if trying to delete the current branch without force.
-func (b *Brancher) DeleteBranch(name string, force bool) error {
+// Returns an error if trying to delete the current branch or a protected
+// branch without force.
+func (b *Brancher) DeleteBranch(ctx context.Context, name string, force bool) error {
 	if name == b.Metadata.CurrentBranch && !force {
-		return fmt.Errorf("cannot delete current branch '%s'. Use --force to override", name)
+		return fmt.Errorf("%w '%s'. Use --force to override", ErrCurrentBranch, name)
 	}
 
 	branch, ok := b.Metadata.GetBranch(name)
 	if !ok {
-		return fmt.Errorf("branch '%s' does not exist", name)
+		return b.BranchNotFoundError(name)
+	}
+
+	if branch.Protected && !force {
+		return fmt.Errorf("%w '%s'. Use --force to override, or run 'pgbranch branch unprotect %s'",
+			ErrProtectedBranch, name, name)
 	}
 
-	if err := b.Client.DeleteSnapshot(branch.Snapshot); err != nil {
+	if err := b.Client.DeleteSnapshot(ctx, branch.Snapshot); err != nil {
 		return fmt.Errorf("failed to delete snapshot database: %w", err)
 	}
+	removeRecordedSchema(branch.Snapshot)
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		if err := m.DeleteBranch(name); err != nil {
+			return err
+		}
+		if m.CurrentBranch == name {
+			m.CurrentBranch = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
 
-	if err := b.Metadata.DeleteBranch(name); err != nil {
+// Rename renames a branch and its snapshot database. Child branches that
+// reference the old name as their parent are updated, and the current
+// branch pointer follows the rename.
+func (b *Brancher) Rename(ctx context.Context, oldName, newName string) error {
+	if err := b.ValidateBranchName(newName); err != nil {
 		return err
 	}
 
-	if b.Metadata.CurrentBranch == name {
-		b.Metadata.CurrentBranch = ""
+	branch, ok := b.Metadata.GetBranch(oldName)
+	if !ok {
+		return storage.NewBranchNotFoundError(oldName)
+	}
+
+	if b.Metadata.BranchExists(newName) {
+		return storage.NewBranchExistsError(newName)
+	}
+
+	oldSnapshot := branch.Snapshot
+	newSnapshot := b.SnapshotDBName(newName)
+
+	if err := b.Client.RenameDatabase(ctx, oldSnapshot, newSnapshot); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
 	}
 
-	if err := b.Metadata.Save(); err != nil {
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		branch, ok := m.GetBranch(oldName)
+		if !ok {
+			return storage.NewBranchNotFoundError(oldName)
+		}
+		if m.BranchExists(newName) {
+			return storage.NewBranchExistsError(newName)
+		}
+
+		delete(m.Branches, oldName)
+		branch.Name = newName
+		branch.Snapshot = newSnapshot
+		m.Branches[newName] = branch
+
+		for _, child := range m.Branches {
+			if child.Parent == oldName {
+				child.Parent = newName
+			}
+		}
+
+		if m.CurrentBranch == oldName {
+			m.CurrentBranch = newName
+		}
+		return nil
+	})
+	if err != nil {
+		// The metadata still points at oldSnapshot, so move the database
+		// back even if ctx was cancelled.
+		if rbErr := b.Client.RenameDatabase(context.WithoutCancel(ctx), newSnapshot, oldSnapshot); rbErr != nil {
+			return fmt.Errorf("failed to save metadata: %w (rollback failed: %v; snapshot kept in '%s', rename it back to '%s')",
+				err, rbErr, newSnapshot, oldSnapshot)
+		}
 		return fmt.Errorf("failed to save metadata: %w", err)
 	}
 
+	moveRecordedSchema(oldSnapshot, newSnapshot)
+
 	return nil
 }
 
+// SquashBranch rebuilds a branch's snapshot database as a fresh copy of
+// itself and clears the branch's parent, making it a root branch. The data
+// in the snapshot is unchanged. Branches created from it keep it as their
+// parent. The old snapshot is renamed aside and only dropped once the copy
+// has taken its place.
+func (b *Brancher) SquashBranch(ctx context.Context, name string) error {
+	branch, ok := b.Metadata.GetBranch(name)
+	if !ok {
+		return storage.NewBranchNotFoundError(name)
+	}
+
+	runID := newRunID()
+	tempDBName := b.tempDBName("squash_" + runID)
+	oldDBName := b.tempDBName("squash_old_" + runID)
+
+	if err := b.Client.CreateDatabaseFromTemplate(ctx, branch.Snapshot, tempDBName); err != nil {
+		b.discardSnapshot(ctx, tempDBName)
+		return fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+
+	if err := b.Client.RenameDatabase(ctx, branch.Snapshot, oldDBName); err != nil {
+		b.discardSnapshot(ctx, tempDBName)
+		return fmt.Errorf("failed to replace snapshot, old snapshot was kept: %w", err)
+	}
+
+	if err := b.Client.RenameDatabase(ctx, tempDBName, branch.Snapshot); err != nil {
+		// The branch has no snapshot until the old one is renamed back,
+		// so roll back even if ctx was cancelled.
+		rbCtx := context.WithoutCancel(ctx)
+		if rbErr := b.Client.RenameDatabase(rbCtx, oldDBName, branch.Snapshot); rbErr != nil {
+			return fmt.Errorf("failed to replace snapshot: %w (rollback failed: %v; old snapshot kept in '%s', its copy in '%s')",
+				err, rbErr, oldDBName, tempDBName)
+		}
+		b.Client.DropDatabaseByName(rbCtx, tempDBName)
+		return fmt.Errorf("failed to replace snapshot, old snapshot was kept: %w", err)
+	}
+
+	b.Client.DropDatabaseByName(context.WithoutCancel(ctx), oldDBName)
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		branch, ok := m.GetBranch(name)
+		if !ok {
+			return storage.NewBranchNotFoundError(name)
+		}
+		branch.Parent = ""
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
+
+// MergeDatabases returns the databases a merge into the target branch must
+// be applied to. This is the branch's snapshot, preceded by the working
+// database when target is the current branch, so that the working database
+// does not silently diverge from its own snapshot.
+func (b *Brancher) MergeDatabases(target string) ([]string, error) {
+	branch, ok := b.Metadata.GetBranch(target)
+	if !ok {
+		return nil, storage.NewBranchNotFoundError(target)
+	}
+
+	if b.Metadata.CurrentBranch == target {
+		return []string{b.Config.Database, branch.Snapshot}, nil
+	}
+
+	return []string{branch.Snapshot}, nil
+}
+
+// MergeBase returns the closest branch that is an ancestor of both left and
+// right by following their Parent chains. A branch counts as its own ancestor, so
+// the merge base of a branch and one of its descendants is the branch itself.
+func (b *Brancher) MergeBase(left, right string) (string, error) {
+	for _, name := range []string{left, right} {
+		if !b.Metadata.BranchExists(name) {
+			return "", storage.NewBranchNotFoundError(name)
+		}
+	}
+
+	ancestors := b.ancestors(left)
+	for _, name := range b.ancestorChain(right) {
+		if ancestors[name] {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("branches '%s' and '%s' have no common ancestor", left, right)
+}
+
+// ancestors returns the set of branches on name's Parent chain, including
+// name itself.
+func (b *Brancher) ancestors(name string) map[string]bool {
+	set := make(map[string]bool)
+	for _, n := range b.ancestorChain(name) {
+		set[n] = true
+	}
+	return set
+}
+
+// ancestorChain returns name followed by its parent, grandparent and so on.
+// The walk stops at a branch without a parent, a parent that no longer
+// exists, or a cycle.
+func (b *Brancher) ancestorChain(name string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+
+	for name != "" && !seen[name] {
+		branch, ok := b.Metadata.GetBranch(name)
+		if !ok {
+			break
+		}
+		seen[name] = true
+		chain = append(chain, name)
+		name = branch.Parent
+	}
+
+	return chain
+}
+
 // BranchInfo contains information about a branch for display purposes.
 type BranchInfo struct {
 	Name      string
@@ -200,6 +732,73 @@ func (b *Brancher) ListBranches() []BranchInfo {
 	return branches
 }
 
+// BranchNode is a branch in the tree built from parent relationships.
+type BranchNode struct {
+	BranchInfo
+	Children []*BranchNode
+
+	// Orphaned is set on roots whose recorded parent no longer exists.
+	Orphaned bool
+}
+
+// BranchTree arranges all branches into trees by their Parent, returning the
+// roots sorted by name with children sorted the same way. A branch whose
+// parent was deleted is returned as an orphaned root. Branches whose parent
+// chain forms a cycle are attached under the first of them reached by name.
+func (b *Brancher) BranchTree() []*BranchNode {
+	nodes := make(map[string]*BranchNode)
+	for _, info := range b.ListBranches() {
+		nodes[info.Name] = &BranchNode{BranchInfo: info}
+	}
+
+	var roots []*BranchNode
+	attached := make(map[string]bool)
+	for _, info := range b.ListBranches() {
+		node := nodes[info.Name]
+		parent := info.Branch.Parent
+		switch {
+		case parent == "":
+			roots = append(roots, node)
+		case nodes[parent] == nil:
+			node.Orphaned = true
+			roots = append(roots, node)
+		default:
+			nodes[parent].Children = append(nodes[parent].Children, node)
+			continue
+		}
+		markAttached(node, attached)
+	}
+
+	for _, info := range b.ListBranches() {
+		if attached[info.Name] {
+			continue
+		}
+		node := nodes[info.Name]
+		parent := nodes[info.Branch.Parent]
+		for i, child := range parent.Children {
+			if child == node {
+				parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+				break
+			}
+		}
+		roots = append(roots, node)
+		markAttached(node, attached)
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Name < roots[j].Name
+	})
+
+	return roots
+}
+
+func markAttached(node *BranchNode, attached map[string]bool) {
+	attached[node.Name] = true
+	for _, child := range node.Children {
+		markAttached(child, attached)
+	}
+}
+
 // CurrentBranch returns the name of the currently checked out branch.
 func (b *Brancher) CurrentBranch() string {
 	return b.Metadata.CurrentBranch
@@ -210,41 +809,224 @@ func (b *Brancher) Status() (currentBranch string, branchCount int) {
 	return b.Metadata.CurrentBranch, len(b.Metadata.Branches)
 }
 
+// SetDescription sets the description of the branch called name. An empty
+// description removes it.
+func (b *Brancher) SetDescription(name, description string) error {
+	if !b.Metadata.BranchExists(name) {
+		return b.BranchNotFoundError(name)
+	}
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		return m.SetDescription(name, description)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
+
+// SetProtected protects the branch called name from deletion and pruning,
+// or removes the protection.
+func (b *Brancher) SetProtected(name string, protected bool) error {
+	if !b.Metadata.BranchExists(name) {
+		return b.BranchNotFoundError(name)
+	}
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		return m.SetProtected(name, protected)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
+
+// AddLabel adds label to the branch called name.
+func (b *Brancher) AddLabel(name, label string) error {
+	if err := storage.ValidateLabel(label); err != nil {
+		return err
+	}
+	if !b.Metadata.BranchExists(name) {
+		return b.BranchNotFoundError(name)
+	}
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		return m.AddLabel(name, label)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveLabel removes label from the branch called name.
+func (b *Brancher) RemoveLabel(name, label string) error {
+	if !b.Metadata.BranchExists(name) {
+		return b.BranchNotFoundError(name)
+	}
+
+	return b.updateMetadata(func(m *storage.Metadata) error {
+		return m.RemoveLabel(name, label)
+	})
+}
+
 // UpdateBranch updates an existing branch's snapshot to match the current
-// database state.
-func (b *Brancher) UpdateBranch(name string) error {
+// database state, records the snapshot's new schema and appends an entry
+// with the given message to the branch's update history.
+func (b *Brancher) UpdateBranch(ctx context.Context, name, message string) error {
 	branch, ok := b.Metadata.GetBranch(name)
 	if !ok {
-		return fmt.Errorf("branch '%s' does not exist", name)
+		return storage.NewBranchNotFoundError(name)
+	}
+
+	if err := b.replaceSnapshot(ctx, branch.Snapshot); err != nil {
+		return err
+	}
+
+	fingerprint, _ := b.recordSchema(ctx, name, branch.Snapshot)
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		branch, ok := m.GetBranch(name)
+		if !ok {
+			return storage.NewBranchNotFoundError(name)
+		}
+		branch.SchemaFingerprint = fingerprint
+		return m.RecordUpdate(name, message)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
 	}
 
-	snapshotDBName := branch.Snapshot
+	return nil
+}
+
+// replaceSnapshot replaces snapshotDBName with a fresh copy of the working
+// database. The copy is created under a temporary name and only swapped in
+// once it succeeds, so a failure at any step leaves the old snapshot intact.
+// The temporary names are unique to this run, so a leftover old snapshot
+// from a failed update is never dropped.
+func (b *Brancher) replaceSnapshot(ctx context.Context, snapshotDBName string) error {
+	runID := newRunID()
+	newDBName := b.tempDBName("update_" + runID)
+	oldDBName := b.tempDBName("update_old_" + runID)
 
-	if err := b.Client.DeleteSnapshot(snapshotDBName); err != nil {
-		return fmt.Errorf("failed to delete old snapshot: %w", err)
+	if err := b.Client.CreateSnapshot(ctx, newDBName); err != nil {
+		b.discardSnapshot(ctx, newDBName)
+		return fmt.Errorf("failed to create updated snapshot, old snapshot was kept: %w", err)
 	}
 
-	if err := b.Client.CreateSnapshot(snapshotDBName); err != nil {
-		return fmt.Errorf("failed to create updated snapshot: %w", err)
+	if err := b.Client.RenameDatabase(ctx, snapshotDBName, oldDBName); err != nil {
+		b.discardSnapshot(ctx, newDBName)
+		return fmt.Errorf("failed to replace snapshot, old snapshot was kept: %w", err)
 	}
 
+	if err := b.Client.RenameDatabase(ctx, newDBName, snapshotDBName); err != nil {
+		// The branch has no snapshot until the old one is renamed back,
+		// so roll back even if ctx was cancelled.
+		rbCtx := context.WithoutCancel(ctx)
+		if rbErr := b.Client.RenameDatabase(rbCtx, oldDBName, snapshotDBName); rbErr != nil {
+			return fmt.Errorf("failed to replace snapshot: %w (rollback failed: %v; old snapshot kept in '%s')",
+				err, rbErr, oldDBName)
+		}
+		b.Client.DropDatabaseByName(rbCtx, newDBName)
+		return fmt.Errorf("failed to replace snapshot, old snapshot was kept: %w", err)
+	}
+
+	b.Client.DropDatabaseByName(ctx, oldDBName)
+
 	return nil
 }
 
+// OrphanedSnapshots returns the databases on the server that are named like
+// a snapshot or temporary database of the configured database but are not
+// the snapshot of any branch or a saved snapshot, such as leftovers of an
+// interrupted operation.
+func (b *Brancher) OrphanedSnapshots(ctx context.Context) ([]string, error) {
+	databases, err := b.Client.ListDatabasesWithPrefix(ctx, storage.SnapshotDBPrefix(b.Config.SnapshotPrefix, b.Config.Database, b.Config.ProjectID))
+	if err != nil {
+		return nil, err
+	}
+	return b.orphanedSnapshots(databases), nil
+}
+
+func (b *Brancher) orphanedSnapshots(databases []string) []string {
+	tracked := make(map[string]bool)
+	for _, branch := range b.Metadata.Branches {
+		tracked[branch.Snapshot] = true
+	}
+	for _, snapshot := range b.Metadata.Snapshots {
+		tracked[snapshot.Database] = true
+	}
+
+	var orphaned []string
+	for _, name := range databases {
+		if tracked[name] {
+			continue
+		}
+		// The legacy prefix also matches databases of projects with an ID.
+		if b.Config.ProjectID == "" && storage.IsNamespacedDBName(b.Config.SnapshotPrefix, b.Config.Database, name) {
+			continue
+		}
+		orphaned = append(orphaned, name)
+	}
+	return orphaned
+}
+
+// DropDatabases drops each of the named databases, returning the ones that
+// were dropped and any errors encountered.
+func (b *Brancher) DropDatabases(ctx context.Context, names []string) (dropped []string, errors []error) {
+	for _, name := range names {
+		if err := b.Client.DropDatabaseByName(ctx, name); err != nil {
+			errors = append(errors, fmt.Errorf("failed to drop '%s': %w", name, err))
+			continue
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, errors
+}
+
 // DefaultStaleDays is the default number of days after which a branch
 // is considered stale.
 const DefaultStaleDays = 7
 
+// StaleOptions selects which stale branches GetStaleBranches returns.
+type StaleOptions struct {
+	// ExcludeCurrent leaves out the checked-out branch.
+	ExcludeCurrent bool
+
+	// ExcludeProtected leaves out protected branches.
+	ExcludeProtected bool
+
+	// LastActivity maps snapshot database names to the last time a client
+	// used them, as returned by SnapshotActivity. Branches whose snapshot
+	// was used within the stale period are not stale.
+	LastActivity map[string]time.Time
+}
+
 // GetStaleBranches returns branches that haven't been accessed in the
 // specified number of days, sorted by staleness (oldest first).
-func (b *Brancher) GetStaleBranches(staleDays int) []BranchInfo {
+func (b *Brancher) GetStaleBranches(staleDays int, opts StaleOptions) []BranchInfo {
 	staleBranches := b.Metadata.GetStaleBranches(staleDays)
 	result := make([]BranchInfo, 0, len(staleBranches))
+	threshold := time.Now().AddDate(0, 0, -staleDays)
 
 	for _, branch := range staleBranches {
+		if last, ok := opts.LastActivity[branch.Snapshot]; ok && last.After(threshold) {
+			continue
+		}
+		isCurrent := branch.Name == b.Metadata.CurrentBranch
+		if isCurrent && opts.ExcludeCurrent {
+			continue
+		}
+		if branch.Protected && opts.ExcludeProtected {
+			continue
+		}
 		result = append(result, BranchInfo{
 			Name:      branch.Name,
-			IsCurrent: branch.Name == b.Metadata.CurrentBranch,
+			IsCurrent: isCurrent,
 			Branch:    branch,
 		})
 	}
@@ -256,11 +1038,27 @@ func (b *Brancher) GetStaleBranches(staleDays int) []BranchInfo {
 	return result
 }
 
+// SnapshotActivity returns the last activity of clients connected to
+// branch snapshot databases, for StaleOptions.LastActivity. Only current
+// connections are seen; PostgreSQL keeps no record of past ones.
+func (b *Brancher) SnapshotActivity(ctx context.Context) (map[string]time.Time, error) {
+	names := make([]string, 0, len(b.Metadata.Branches))
+	for _, branch := range b.Metadata.Branches {
+		names = append(names, branch.Snapshot)
+	}
+	return b.Client.LastActivity(ctx, names)
+}
+
 // PruneBranches deletes multiple branches by name, returning the list of
-// successfully deleted branches and any errors encountered.
-func (b *Brancher) PruneBranches(names []string) (deleted []string, errors []error) {
+// successfully deleted branches and any errors encountered. Protected
+// branches are refused unless force is set.
+func (b *Brancher) PruneBranches(ctx context.Context, names []string, force bool) (deleted []string, errors []error) {
 	for _, name := range names {
-		if err := b.DeleteBranch(name, true); err != nil {
+		if branch, ok := b.Metadata.GetBranch(name); ok && branch.Protected && !force {
+			errors = append(errors, fmt.Errorf("failed to delete '%s': %w", name, ErrProtectedBranch))
+			continue
+		}
+		if err := b.DeleteBranch(ctx, name, true); err != nil {
 			errors = append(errors, fmt.Errorf("failed to delete '%s': %w", name, err))
 		} else {
 			deleted = append(deleted, name)
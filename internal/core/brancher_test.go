@@ -2,7 +2,10 @@ package core
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
@@ -84,14 +87,14 @@ func TestBrancherOperations(t *testing.T) {
 	require.NoError(t, err)
 
 	t.Run("CreateBranch", func(t *testing.T) {
-		err := brancher.CreateBranch("main")
+		err := brancher.CreateBranch(ctx, "main")
 		require.NoError(t, err)
 
 		branch, ok := brancher.Metadata.GetBranch("main")
 		assert.True(t, ok)
 		assert.Equal(t, "main", branch.Name)
 
-		expectedSnapshotDB := storage.SnapshotDBName(cfg.Database, "main")
+		expectedSnapshotDB := brancher.SnapshotDBName("main")
 		assert.Equal(t, expectedSnapshotDB, branch.Snapshot)
 
 		snapshotCfg := &config.Config{
@@ -102,15 +105,15 @@ func TestBrancherOperations(t *testing.T) {
 			Password: cfg.Password,
 		}
 		snapshotClient := postgres.NewClient(snapshotCfg)
-		exists, err := snapshotClient.DatabaseExists()
+		exists, err := snapshotClient.DatabaseExists(ctx)
 		require.NoError(t, err)
 		assert.True(t, exists)
 	})
 
 	t.Run("CreateBranchDuplicate", func(t *testing.T) {
-		err := brancher.CreateBranch("main")
+		err := brancher.CreateBranch(ctx, "main")
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "already exists")
+		assert.ErrorIs(t, err, ErrBranchExists)
 	})
 
 	t.Run("ListBranches", func(t *testing.T) {
@@ -123,7 +126,7 @@ func TestBrancherOperations(t *testing.T) {
 		brancher.Metadata.CurrentBranch = "main"
 		brancher.Metadata.Save()
 
-		err := brancher.CreateBranch("feature-1")
+		err := brancher.CreateBranch(ctx, "feature-1")
 		require.NoError(t, err)
 
 		branch, ok := brancher.Metadata.GetBranch("feature-1")
@@ -179,7 +182,7 @@ func TestCheckoutWorkflow(t *testing.T) {
 	brancher, err := NewBrancher()
 	require.NoError(t, err)
 
-	err = brancher.CreateBranch("main")
+	err = brancher.CreateBranch(ctx, "main")
 	require.NoError(t, err)
 	brancher.Metadata.CurrentBranch = "main"
 	brancher.Metadata.Save()
@@ -208,7 +211,7 @@ func TestCheckoutWorkflow(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, exists)
 
-	err = brancher.Checkout("main")
+	err = brancher.Checkout(ctx, "main", true)
 	require.NoError(t, err)
 
 	assert.Equal(t, "main", brancher.Metadata.CurrentBranch)
@@ -230,6 +233,215 @@ func TestCheckoutWorkflow(t *testing.T) {
 	assert.Equal(t, "19.99", price)
 }
 
+func TestCheckoutRollbackOnRestoreFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1')")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+	brancher.Metadata.CurrentBranch = "main"
+	brancher.Metadata.Save()
+
+	err = brancher.CreateBranch(ctx, "broken")
+	require.NoError(t, err)
+	broken, _ := brancher.Metadata.GetBranch("broken")
+	broken.Snapshot = "pgbranch_snapshot_does_not_exist"
+
+	err = execSQL(ctx, cfg, "INSERT INTO items (name) VALUES ('Item2')")
+	require.NoError(t, err)
+
+	err = brancher.Checkout(ctx, "broken", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "left unchanged")
+
+	assert.Equal(t, "main", brancher.Metadata.CurrentBranch)
+
+	count, err := countRows(ctx, cfg, "items")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	backups, err := brancher.Client.ListDatabasesWithPrefix(ctx, brancher.tempDBName("checkout_"))
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+}
+
+func TestCheckoutPlan(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("old-feature", "main", "testdb_pgbranch_old_feature")
+	meta.Branches["old-feature"].CreatedAt = meta.Branches["old-feature"].CreatedAt.AddDate(0, 0, -30)
+	meta.CurrentBranch = "main"
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	plan, err := b.CheckoutPlan("old-feature")
+	require.NoError(t, err)
+	assert.Equal(t, "main", plan.SaveBranch)
+	assert.Equal(t, "testdb_pgbranch_old_feature", plan.Snapshot)
+	assert.True(t, plan.LastCheckoutAt.IsZero())
+	assert.True(t, plan.IsStale)
+	assert.False(t, plan.AlreadyOn)
+
+	plan, err = b.CheckoutPlan("main")
+	require.NoError(t, err)
+	assert.True(t, plan.AlreadyOn)
+	assert.Empty(t, plan.SaveBranch)
+
+	_, err = b.CheckoutPlan("missing")
+	assert.Error(t, err)
+}
+
+func TestMergeDatabases(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("feature", "main", "testdb_pgbranch_feature")
+	meta.CurrentBranch = "main"
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	dbs, err := b.MergeDatabases("main")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdb", "testdb_pgbranch_main"}, dbs)
+
+	dbs, err = b.MergeDatabases("feature")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdb_pgbranch_feature"}, dbs)
+
+	_, err = b.MergeDatabases("missing")
+	assert.Error(t, err)
+}
+
+func TestBranchTree(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("develop", "main", "testdb_pgbranch_develop")
+	meta.AddBranch("feature-b", "develop", "testdb_pgbranch_feature_b")
+	meta.AddBranch("feature-a", "develop", "testdb_pgbranch_feature_a")
+	meta.AddBranch("hotfix", "main", "testdb_pgbranch_hotfix")
+	meta.AddBranch("leftover", "deleted", "testdb_pgbranch_leftover")
+	meta.AddBranch("loop-a", "loop-b", "testdb_pgbranch_loop_a")
+	meta.AddBranch("loop-b", "loop-a", "testdb_pgbranch_loop_b")
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	roots := b.BranchTree()
+
+	var render func(nodes []*BranchNode, depth int) []string
+	render = func(nodes []*BranchNode, depth int) []string {
+		var lines []string
+		for _, n := range nodes {
+			line := strings.Repeat("  ", depth) + n.Name
+			if n.Orphaned {
+				line += " (orphaned)"
+			}
+			lines = append(lines, line)
+			lines = append(lines, render(n.Children, depth+1)...)
+		}
+		return lines
+	}
+
+	assert.Equal(t, []string{
+		"leftover (orphaned)",
+		"loop-a",
+		"  loop-b",
+		"main",
+		"  develop",
+		"    feature-a",
+		"    feature-b",
+		"  hotfix",
+	}, render(roots, 0))
+}
+
+func TestOrphanedSnapshots(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("feature", "main", "testdb_pgbranch_feature")
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	orphaned := b.orphanedSnapshots([]string{
+		"testdb_pgbranch__checkout",
+		"testdb_pgbranch_deleted",
+		"testdb_pgbranch_feature",
+		"testdb_pgbranch_main",
+	})
+	assert.Equal(t, []string{"testdb_pgbranch__checkout", "testdb_pgbranch_deleted"}, orphaned)
+
+	assert.Empty(t, b.orphanedSnapshots([]string{"testdb_pgbranch_main"}))
+
+	// Saved snapshots are tracked like branches.
+	meta.AddSnapshot("pre-experiment", "testdb_pgbranch__snapshot_pre_experiment")
+	assert.Empty(t, b.orphanedSnapshots([]string{"testdb_pgbranch__snapshot_pre_experiment"}))
+
+	// A legacy project ignores databases of projects with an ID.
+	assert.Empty(t, b.orphanedSnapshots([]string{"testdb_pgbranch_0a1b2c3d_main"}))
+
+	b.Config.ProjectID = "0a1b2c3d"
+	assert.Equal(t, []string{"testdb_pgbranch_0a1b2c3d_old"},
+		b.orphanedSnapshots([]string{"testdb_pgbranch_0a1b2c3d_old"}))
+}
+
+func TestMergeBase(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("develop", "main", "testdb_pgbranch_develop")
+	meta.AddBranch("feature-a", "develop", "testdb_pgbranch_feature_a")
+	meta.AddBranch("feature-b", "develop", "testdb_pgbranch_feature_b")
+	meta.AddBranch("hotfix", "main", "testdb_pgbranch_hotfix")
+	meta.AddBranch("orphan", "", "testdb_pgbranch_orphan")
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	tests := []struct {
+		left, right, want string
+	}{
+		{"feature-a", "feature-b", "develop"},
+		{"feature-a", "hotfix", "main"},
+		{"feature-a", "develop", "develop"},
+		{"main", "feature-b", "main"},
+		{"main", "main", "main"},
+	}
+
+	for _, tt := range tests {
+		base, err := b.MergeBase(tt.left, tt.right)
+		require.NoError(t, err, "%s/%s", tt.left, tt.right)
+		assert.Equal(t, tt.want, base, "%s/%s", tt.left, tt.right)
+	}
+
+	_, err := b.MergeBase("feature-a", "orphan")
+	assert.Error(t, err)
+
+	_, err = b.MergeBase("feature-a", "missing")
+	assert.Error(t, err)
+
+	// A corrupted parent cycle must not loop forever.
+	meta.Branches["main"].Parent = "feature-a"
+	base, err := b.MergeBase("feature-b", "hotfix")
+	require.NoError(t, err)
+	assert.Equal(t, "main", base)
+}
+
 func TestDeleteBranch(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode")
@@ -249,49 +461,508 @@ func TestDeleteBranch(t *testing.T) {
 	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
 	require.NoError(t, err)
 
-	err = execSQL(ctx, cfg, "CREATE TABLE test (id SERIAL PRIMARY KEY)")
+	err = execSQL(ctx, cfg, "CREATE TABLE test (id SERIAL PRIMARY KEY)")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+	err = brancher.CreateBranch(ctx, "feature-1")
+	require.NoError(t, err)
+	brancher.Metadata.CurrentBranch = "main"
+	brancher.Metadata.Save()
+
+	feature1Branch, _ := brancher.Metadata.GetBranch("feature-1")
+	feature1SnapshotDB := feature1Branch.Snapshot
+
+	err = brancher.DeleteBranch(ctx, "feature-1", false)
+	require.NoError(t, err)
+
+	assert.False(t, brancher.Metadata.BranchExists("feature-1"))
+
+	snapshotCfg := &config.Config{
+		Database: feature1SnapshotDB,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.User,
+		Password: cfg.Password,
+	}
+	snapshotClient := postgres.NewClient(snapshotCfg)
+	exists, err := snapshotClient.DatabaseExists(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	err = brancher.DeleteBranch(ctx, "main", false)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrCurrentBranch)
+
+	err = brancher.DeleteBranch(ctx, "main", true)
+	require.NoError(t, err)
+	assert.Empty(t, brancher.Metadata.CurrentBranch)
+}
+
+func TestUpdateBranch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1')")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+	brancher.Metadata.CurrentBranch = "main"
+	brancher.Metadata.Save()
+
+	err = execSQL(ctx, cfg, "INSERT INTO items (name) VALUES ('Item2'), ('Item3'), ('Item4'), ('Item5')")
+	require.NoError(t, err)
+
+	err = brancher.UpdateBranch(ctx, "main", "add more items")
+	require.NoError(t, err)
+
+	branch, _ := brancher.Metadata.GetBranch("main")
+	require.Len(t, branch.UpdateHistory, 1)
+	assert.Equal(t, "add more items", branch.UpdateHistory[0].Message)
+
+	snapshotCfg := &config.Config{
+		Database: branch.Snapshot,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.User,
+		Password: cfg.Password,
+	}
+	snapshotClient := postgres.NewClient(snapshotCfg)
+	exists, err := snapshotClient.DatabaseExists(ctx)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	count, err := countRowsInDB(ctx, snapshotCfg, "items")
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+}
+
+func TestUpdateBranchKeepsSnapshotOnFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1'), ('Item2')")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+
+	// Without the working database there is no template to copy, so
+	// creating the updated snapshot fails.
+	err = brancher.Client.DropDatabase(ctx)
+	require.NoError(t, err)
+
+	err = brancher.UpdateBranch(ctx, "main", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "old snapshot was kept")
+
+	branch, _ := brancher.Metadata.GetBranch("main")
+	assert.Empty(t, branch.UpdateHistory)
+
+	snapshotCfg := *cfg
+	snapshotCfg.Database = branch.Snapshot
+	count, err := countRowsInDB(ctx, &snapshotCfg, "items")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	leftovers, err := brancher.Client.ListDatabasesWithPrefix(ctx, brancher.tempDBName("update_"))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func TestCreateBranchFrom(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1')")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "INSERT INTO items (name) VALUES ('Item2')")
+	require.NoError(t, err)
+
+	err = brancher.CreateBranchFrom(ctx, "feature", "main")
+	require.NoError(t, err)
+
+	branch, ok := brancher.Metadata.GetBranch("feature")
+	require.True(t, ok)
+	assert.Equal(t, "main", branch.Parent)
+
+	snapshotCfg := &config.Config{
+		Database: branch.Snapshot,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.User,
+		Password: cfg.Password,
+	}
+	count, err := countRowsInDB(ctx, snapshotCfg, "items")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	err = brancher.CreateBranchFrom(ctx, "feature", "main")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBranchExists)
+
+	err = brancher.CreateBranchFrom(ctx, "other", "missing")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBranchNotFound)
+}
+
+func TestCreateBranchCancelled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	// Enough data that copying the database takes a moment.
+	err = execSQL(ctx, cfg, "CREATE TABLE items AS SELECT g AS id, md5(g::text) AS name FROM generate_series(1, 500000) g")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	// assertConsistent checks that name either exists as a complete branch
+	// or left nothing behind, in memory, on disk or on the server.
+	assertConsistent := func(t *testing.T, name string, created bool) {
+		_, ok := brancher.Metadata.GetBranch(name)
+		assert.Equal(t, created, ok)
+
+		meta, err := storage.LoadMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, created, meta.BranchExists(name))
+
+		exists, err := postgres.NewClient(&config.Config{
+			Database: brancher.SnapshotDBName(name),
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			User:     cfg.User,
+			Password: cfg.Password,
+		}).DatabaseExists(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, created, exists)
+
+		orphaned, err := brancher.OrphanedSnapshots(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, orphaned)
+	}
+
+	t.Run("CancelledBeforeSnapshot", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := brancher.CreateBranch(cancelled, "cancelled")
+		require.Error(t, err)
+		assertConsistent(t, "cancelled", false)
+	})
+
+	t.Run("CancelledDuringSnapshot", func(t *testing.T) {
+		opCtx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(20*time.Millisecond, cancel)
+		defer timer.Stop()
+
+		// The copy may finish before the cancel lands; either way the
+		// branch must be complete or entirely gone.
+		err := brancher.CreateBranch(opCtx, "interrupted")
+		assertConsistent(t, "interrupted", err == nil)
+	})
+
+	t.Run("CancelledCreateBranchFrom", func(t *testing.T) {
+		require.NoError(t, brancher.CreateBranch(ctx, "main"))
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := brancher.CreateBranchFrom(cancelled, "feature", "main")
+		require.Error(t, err)
+		assertConsistent(t, "feature", false)
+	})
+}
+
+func TestRenameBranch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1')")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "mian")
+	require.NoError(t, err)
+	brancher.Metadata.CurrentBranch = "mian"
+	brancher.Metadata.Save()
+
+	err = brancher.CreateBranch(ctx, "feature")
+	require.NoError(t, err)
+
+	err = brancher.Rename(ctx, "mian", "main")
+	require.NoError(t, err)
+
+	assert.False(t, brancher.Metadata.BranchExists("mian"))
+	assert.Equal(t, "main", brancher.Metadata.CurrentBranch)
+
+	branch, ok := brancher.Metadata.GetBranch("main")
+	require.True(t, ok)
+	assert.Equal(t, "main", branch.Name)
+	assert.Equal(t, brancher.SnapshotDBName("main"), branch.Snapshot)
+
+	feature, _ := brancher.Metadata.GetBranch("feature")
+	assert.Equal(t, "main", feature.Parent)
+
+	snapshotCfg := &config.Config{
+		Database: branch.Snapshot,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.User,
+		Password: cfg.Password,
+	}
+	count, err := countRowsInDB(ctx, snapshotCfg, "items")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	oldCfg := *snapshotCfg
+	oldCfg.Database = brancher.SnapshotDBName("mian")
+	exists, err := postgres.NewClient(&oldCfg).DatabaseExists(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	err = brancher.Rename(ctx, "main", "feature")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBranchExists)
+
+	err = brancher.Rename(ctx, "missing", "other")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBranchNotFound)
+}
+
+func TestSquashBranch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1'), ('Item2')")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+	err = brancher.CreateBranchFrom(ctx, "feature", "main")
+	require.NoError(t, err)
+	err = brancher.CreateBranchFrom(ctx, "feature-child", "feature")
+	require.NoError(t, err)
+
+	err = brancher.SquashBranch(ctx, "feature")
+	require.NoError(t, err)
+
+	branch, ok := brancher.Metadata.GetBranch("feature")
+	require.True(t, ok)
+	assert.Empty(t, branch.Parent)
+	assert.Equal(t, brancher.SnapshotDBName("feature"), branch.Snapshot)
+
+	child, _ := brancher.Metadata.GetBranch("feature-child")
+	assert.Equal(t, "feature", child.Parent)
+
+	snapshotCfg := &config.Config{
+		Database: branch.Snapshot,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.User,
+		Password: cfg.Password,
+	}
+	count, err := countRowsInDB(ctx, snapshotCfg, "items")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	reloaded, err := storage.LoadMetadata()
+	require.NoError(t, err)
+	assert.Empty(t, reloaded.Branches["feature"].Parent)
+
+	leftovers, err := brancher.Client.ListDatabasesWithPrefix(ctx, brancher.tempDBName("squash_"))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+
+	err = brancher.SquashBranch(ctx, "missing")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBranchNotFound)
+}
+
+func TestReplaceBranch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1')")
 	require.NoError(t, err)
 
 	brancher, err := NewBrancher()
 	require.NoError(t, err)
 
-	err = brancher.CreateBranch("main")
+	err = brancher.CreateBranch(ctx, "main")
 	require.NoError(t, err)
-	err = brancher.CreateBranch("feature-1")
+	err = brancher.CreateBranchFrom(ctx, "feature", "main")
 	require.NoError(t, err)
-	brancher.Metadata.CurrentBranch = "main"
-	brancher.Metadata.Save()
 
-	feature1Branch, _ := brancher.Metadata.GetBranch("feature-1")
-	feature1SnapshotDB := feature1Branch.Snapshot
+	err = execSQL(ctx, cfg, "INSERT INTO items (name) VALUES ('Item2')")
+	require.NoError(t, err)
 
-	err = brancher.DeleteBranch("feature-1", false)
+	restored := brancher.NewTempDBName("restore")
+	err = brancher.Client.CreateSnapshot(ctx, restored)
 	require.NoError(t, err)
 
-	assert.False(t, brancher.Metadata.BranchExists("feature-1"))
+	source := &storage.RemoteSource{Remote: "origin", Branch: "feature"}
+	err = brancher.ReplaceBranch(ctx, "feature", restored, source)
+	require.NoError(t, err)
+
+	branch, ok := brancher.Metadata.GetBranch("feature")
+	require.True(t, ok)
+	assert.Empty(t, branch.Parent)
+	assert.Equal(t, brancher.SnapshotDBName("feature"), branch.Snapshot)
+	assert.Equal(t, source, branch.Source)
 
 	snapshotCfg := &config.Config{
-		Database: feature1SnapshotDB,
+		Database: branch.Snapshot,
 		Host:     cfg.Host,
 		Port:     cfg.Port,
 		User:     cfg.User,
 		Password: cfg.Password,
 	}
-	snapshotClient := postgres.NewClient(snapshotCfg)
-	exists, err := snapshotClient.DatabaseExists()
+	count, err := countRowsInDB(ctx, snapshotCfg, "items")
 	require.NoError(t, err)
-	assert.False(t, exists)
+	assert.Equal(t, 2, count)
+
+	leftovers, err := brancher.Client.ListDatabasesWithPrefix(ctx, brancher.tempDBName(""))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
 
-	err = brancher.DeleteBranch("main", false)
+	err = brancher.ReplaceBranch(ctx, "feature", brancher.NewTempDBName("restore"), nil)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot delete current branch")
 
-	err = brancher.DeleteBranch("main", true)
+	count, err = countRowsInDB(ctx, snapshotCfg, "items")
 	require.NoError(t, err)
-	assert.Empty(t, brancher.Metadata.CurrentBranch)
+	assert.Equal(t, 2, count, "a failed replace keeps the old snapshot")
 }
 
-func TestUpdateBranch(t *testing.T) {
+func TestVerifyBranch(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode")
 	}
@@ -310,39 +981,146 @@ func TestUpdateBranch(t *testing.T) {
 	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
 	require.NoError(t, err)
 
-	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100)); INSERT INTO items (name) VALUES ('Item1')")
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100))")
 	require.NoError(t, err)
 
 	brancher, err := NewBrancher()
 	require.NoError(t, err)
 
-	err = brancher.CreateBranch("main")
+	err = brancher.CreateBranch(ctx, "main")
 	require.NoError(t, err)
-	brancher.Metadata.CurrentBranch = "main"
-	brancher.Metadata.Save()
 
-	err = execSQL(ctx, cfg, "INSERT INTO items (name) VALUES ('Item2'), ('Item3'), ('Item4'), ('Item5')")
+	branch, _ := brancher.Metadata.GetBranch("main")
+	assert.NotEmpty(t, branch.SchemaFingerprint)
+
+	result, err := brancher.VerifyBranch(ctx, "main")
 	require.NoError(t, err)
+	assert.False(t, result.Drifted())
 
-	err = brancher.UpdateBranch("main")
+	snapshotCfg := *cfg
+	snapshotCfg.Database = branch.Snapshot
+	err = execSQL(ctx, &snapshotCfg, "ALTER TABLE items ADD COLUMN price INTEGER")
 	require.NoError(t, err)
 
-	branch, _ := brancher.Metadata.GetBranch("main")
-	snapshotCfg := &config.Config{
-		Database: branch.Snapshot,
-		Host:     cfg.Host,
-		Port:     cfg.Port,
-		User:     cfg.User,
-		Password: cfg.Password,
+	result, err = brancher.VerifyBranch(ctx, "main")
+	require.NoError(t, err)
+	assert.True(t, result.Drifted())
+	require.NotNil(t, result.Changes)
+	require.Len(t, result.Changes.Changes, 1)
+	assert.Equal(t, "items.price", result.Changes.Changes[0].ObjectName())
+
+	err = brancher.RecordSchema(ctx, "main")
+	require.NoError(t, err)
+
+	result, err = brancher.VerifyBranch(ctx, "main")
+	require.NoError(t, err)
+	assert.False(t, result.Drifted())
+}
+
+func TestUncommittedChanges(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
 	}
-	snapshotClient := postgres.NewClient(snapshotCfg)
-	exists, err := snapshotClient.DatabaseExists()
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
 	require.NoError(t, err)
-	assert.True(t, exists)
+	defer pg.Stop(ctx)
 
-	count, err := countRowsInDB(ctx, snapshotCfg, "items")
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
 	require.NoError(t, err)
-	assert.Equal(t, 5, count)
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY, name VARCHAR(100))")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	_, err = brancher.UncommittedChanges(ctx)
+	require.Error(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+
+	changes, err := brancher.UncommittedChanges(ctx)
+	require.NoError(t, err)
+	assert.True(t, changes.IsEmpty())
+
+	err = execSQL(ctx, cfg, "ALTER TABLE items ADD COLUMN price INTEGER; ALTER TABLE items DROP COLUMN name")
+	require.NoError(t, err)
+
+	changes, err = brancher.UncommittedChanges(ctx)
+	require.NoError(t, err)
+	require.Len(t, changes.Changes, 2)
+	assert.Equal(t, 1, changes.DestructiveCount())
+}
+
+func TestUpgradeSnapshotNames(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	// Simulate a project initialized before project IDs existed.
+	legacyCfg, err := config.Load()
+	require.NoError(t, err)
+	legacyCfg.ProjectID = ""
+	require.NoError(t, legacyCfg.Save())
+
+	err = execSQL(ctx, cfg, "CREATE TABLE items (id SERIAL PRIMARY KEY); INSERT INTO items DEFAULT VALUES")
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+
+	branch, _ := brancher.Metadata.GetBranch("main")
+	legacyName := storage.SnapshotDBName("", cfg.Database, "", "main")
+	assert.Equal(t, legacyName, branch.Snapshot)
+
+	renamed, err := brancher.UpgradeSnapshotNames(ctx)
+	require.NoError(t, err)
+	require.Len(t, renamed, 1)
+	assert.Equal(t, legacyName, renamed[0].From)
+
+	assert.NotEmpty(t, brancher.Config.ProjectID)
+	assert.Equal(t, brancher.SnapshotDBName("main"), branch.Snapshot)
+	assert.NotEqual(t, legacyName, branch.Snapshot)
+
+	snapshotCfg := *cfg
+	snapshotCfg.Database = branch.Snapshot
+	count, err := countRowsInDB(ctx, &snapshotCfg, "items")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	reloaded, err := NewBrancher()
+	require.NoError(t, err)
+	assert.Equal(t, brancher.Config.ProjectID, reloaded.Config.ProjectID)
+
+	renamed, err = reloaded.UpgradeSnapshotNames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, renamed)
 }
 
 func countRowsInDB(ctx context.Context, cfg *config.Config, table string) (int, error) {
@@ -383,9 +1161,69 @@ func TestCheckoutNonExistentBranch(t *testing.T) {
 	brancher, err := NewBrancher()
 	require.NoError(t, err)
 
-	err = brancher.Checkout("non-existent")
+	err = brancher.Checkout(ctx, "non-existent", true)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBranchNotFound)
+}
+
+func TestReset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, `
+		CREATE TABLE products (id SERIAL PRIMARY KEY, name VARCHAR(100) NOT NULL);
+		INSERT INTO products (name) VALUES ('Widget'), ('Gadget');
+	`)
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.Reset(ctx)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "does not exist")
+	assert.ErrorIs(t, err, ErrNoCurrentBranch)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+	err = brancher.Checkout(ctx, "main", true)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, `DELETE FROM products WHERE name = 'Widget'`)
+	require.NoError(t, err)
+
+	err = brancher.Reset(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, "main", brancher.Metadata.CurrentBranch)
+
+	exists, err := rowExists(ctx, cfg, "products", "name", "Widget")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// The discarded changes must not have been saved to the branch.
+	err = execSQL(ctx, cfg, `DELETE FROM products`)
+	require.NoError(t, err)
+	err = brancher.Reset(ctx)
+	require.NoError(t, err)
+
+	count, err := countRows(ctx, cfg, "products")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
 }
 
 func TestFullE2EWorkflow(t *testing.T) {
@@ -437,7 +1275,7 @@ func TestFullE2EWorkflow(t *testing.T) {
 	brancher, err := NewBrancher()
 	require.NoError(t, err)
 
-	err = brancher.CreateBranch("main")
+	err = brancher.CreateBranch(ctx, "main")
 	require.NoError(t, err)
 	brancher.Metadata.CurrentBranch = "main"
 	brancher.Metadata.Save()
@@ -477,10 +1315,10 @@ func TestFullE2EWorkflow(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 3, commentCount)
 
-	err = brancher.CreateBranch("feature-add-comments")
+	err = brancher.CreateBranch(ctx, "feature-add-comments")
 	require.NoError(t, err)
 
-	err = brancher.Checkout("main")
+	err = brancher.Checkout(ctx, "main", true)
 	require.NoError(t, err)
 
 	userCount, err = countRows(ctx, cfg, "users")
@@ -494,7 +1332,7 @@ func TestFullE2EWorkflow(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, exists)
 
-	err = brancher.Checkout("feature-add-comments")
+	err = brancher.Checkout(ctx, "feature-add-comments", true)
 	require.NoError(t, err)
 
 	userCount, err = countRows(ctx, cfg, "users")
@@ -509,10 +1347,10 @@ func TestFullE2EWorkflow(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, exists)
 
-	err = brancher.Checkout("main")
+	err = brancher.Checkout(ctx, "main", true)
 	require.NoError(t, err)
 
-	err = brancher.DeleteBranch("feature-add-comments", false)
+	err = brancher.DeleteBranch(ctx, "feature-add-comments", false)
 	require.NoError(t, err)
 
 	branches := brancher.ListBranches()
@@ -612,15 +1450,15 @@ func TestCheckoutAutoSave(t *testing.T) {
 	brancher, err := NewBrancher()
 	require.NoError(t, err)
 
-	err = brancher.CreateBranch("main")
+	err = brancher.CreateBranch(ctx, "main")
 	require.NoError(t, err)
 	brancher.Metadata.CurrentBranch = "main"
 	brancher.Metadata.Save()
 
-	err = brancher.CreateBranch("feature")
+	err = brancher.CreateBranch(ctx, "feature")
 	require.NoError(t, err)
 
-	err = brancher.Checkout("feature")
+	err = brancher.Checkout(ctx, "feature", true)
 	require.NoError(t, err)
 
 	featureSQL := `
@@ -638,7 +1476,7 @@ func TestCheckoutAutoSave(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, exists)
 
-	err = brancher.Checkout("main")
+	err = brancher.Checkout(ctx, "main", true)
 	require.NoError(t, err)
 
 	count, err = countRows(ctx, cfg, "items")
@@ -653,7 +1491,7 @@ func TestCheckoutAutoSave(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, exists)
 
-	err = brancher.Checkout("feature")
+	err = brancher.Checkout(ctx, "feature", true)
 	require.NoError(t, err)
 
 	count, err = countRows(ctx, cfg, "items")
@@ -672,3 +1510,217 @@ func TestCheckoutAutoSave(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, exists)
 }
+
+func TestCheckoutWithoutAutoSave(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(t, err)
+	defer pg.Stop(ctx)
+
+	testDir := testutil.SetupTestDir(t)
+	defer testDir.Cleanup(t)
+
+	cfg := pg.GetConfig()
+
+	err = Initialize(cfg.Database, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	require.NoError(t, err)
+
+	setupSQL := `
+		CREATE TABLE items (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL
+		);
+		INSERT INTO items (name) VALUES ('original_item');
+	`
+	err = execSQL(ctx, cfg, setupSQL)
+	require.NoError(t, err)
+
+	brancher, err := NewBrancher()
+	require.NoError(t, err)
+
+	err = brancher.CreateBranch(ctx, "main")
+	require.NoError(t, err)
+	err = brancher.CreateBranch(ctx, "feature")
+	require.NoError(t, err)
+
+	err = brancher.Checkout(ctx, "feature", true)
+	require.NoError(t, err)
+
+	err = execSQL(ctx, cfg, `INSERT INTO items (name) VALUES ('throwaway_item')`)
+	require.NoError(t, err)
+
+	err = brancher.Checkout(ctx, "main", false)
+	require.NoError(t, err)
+	assert.Equal(t, "main", brancher.Metadata.CurrentBranch)
+
+	err = brancher.Checkout(ctx, "feature", true)
+	require.NoError(t, err)
+
+	exists, err := rowExists(ctx, cfg, "items", "name", "throwaway_item")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestHasHeadroom(t *testing.T) {
+	assert.True(t, hasHeadroom(1000, 1100))
+	assert.True(t, hasHeadroom(1000, 5000))
+	assert.False(t, hasHeadroom(1000, 1099))
+	assert.False(t, hasHeadroom(1000, 500))
+	assert.True(t, hasHeadroom(0, 0))
+}
+
+func TestIsLocalHost(t *testing.T) {
+	for _, host := range []string{"", "localhost", "127.0.0.1", "::1", "/var/run/postgresql"} {
+		assert.True(t, isLocalHost(host), host)
+	}
+	for _, host := range []string{"db.example.com", "10.0.0.5"} {
+		assert.False(t, isLocalHost(host), host)
+	}
+}
+
+func TestFreeDiskSpace(t *testing.T) {
+	free, err := freeDiskSpace(t.TempDir())
+	if err == errDiskSpaceUnsupported {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	assert.Greater(t, free, uint64(0))
+
+	_, err = freeDiskSpace(t.TempDir() + "/missing")
+	assert.Error(t, err)
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"main", "main", 0},
+		{"", "main", 4},
+		{"main", "mian", 2},
+		{"feautre-x", "feature-x", 2},
+		{"feature", "features", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, levenshtein(tt.a, tt.b), "%s/%s", tt.a, tt.b)
+	}
+}
+
+func TestBranchNotFoundError(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("feature-x", "main", "testdb_pgbranch_feature_x")
+	meta.AddBranch("feature-y", "main", "testdb_pgbranch_feature_y")
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	err := b.BranchNotFoundError("feautre-x")
+	assert.EqualError(t, err, "branch 'feautre-x' does not exist. Did you mean 'feature-x'?")
+	assert.ErrorIs(t, err, storage.ErrBranchNotFound)
+
+	err = b.BranchNotFoundError("feature-z")
+	assert.EqualError(t, err, "branch 'feature-z' does not exist. Did you mean one of 'feature-x', 'feature-y'?")
+
+	err = b.BranchNotFoundError("production")
+	assert.EqualError(t, err, "branch 'production' does not exist")
+}
+
+func TestValidateBranchNameClash(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("feature-x", "main", "testdb_pgbranch_feature_x")
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	for _, name := range []string{"feature_x", "feature.x", "feature/x"} {
+		err := b.ValidateBranchName(name)
+		assert.EqualError(t, err, "branch name '"+name+"' clashes with branch 'feature-x': both use snapshot database 'testdb_pgbranch_feature_x'")
+	}
+
+	// A branch doesn't clash with itself; that is reported as already
+	// existing where it matters.
+	assert.NoError(t, b.ValidateBranchName("feature-x"))
+	assert.NoError(t, b.ValidateBranchName("feature-y"))
+}
+
+func TestProtectedBranch(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	meta.AddBranch("demo", "main", "testdb_pgbranch_demo")
+	require.NoError(t, meta.SetProtected("demo", true))
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	err := b.DeleteBranch(context.Background(), "demo", false)
+	assert.ErrorIs(t, err, ErrProtectedBranch)
+
+	deleted, errs := b.PruneBranches(context.Background(), []string{"demo"}, false)
+	assert.Empty(t, deleted)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrProtectedBranch)
+
+	err = b.ReplaceBranch(context.Background(), "demo", "testdb_pgbranch_restore", nil)
+	assert.ErrorIs(t, err, ErrProtectedBranch)
+}
+
+func TestGetStaleBranchesOptions(t *testing.T) {
+	meta := storage.NewMetadata()
+	meta.AddBranch("main", "", "testdb_pgbranch_main")
+	for _, name := range []string{"current", "protected", "old"} {
+		branch := meta.AddBranch(name, "main", "testdb_pgbranch_"+name)
+		branch.CreatedAt = branch.CreatedAt.AddDate(0, 0, -30)
+	}
+	require.NoError(t, meta.SetCurrentBranch("current"))
+	require.NoError(t, meta.SetProtected("protected", true))
+
+	b := &Brancher{Config: &config.Config{Database: "testdb"}, Metadata: meta}
+
+	names := func(branches []BranchInfo) []string {
+		var result []string
+		for _, info := range branches {
+			result = append(result, info.Name)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	all := b.GetStaleBranches(7, StaleOptions{})
+	assert.Equal(t, []string{"current", "old", "protected"}, names(all))
+	for _, info := range all {
+		assert.Equal(t, info.Name == "current", info.IsCurrent)
+	}
+
+	assert.Equal(t, []string{"old", "protected"},
+		names(b.GetStaleBranches(7, StaleOptions{ExcludeCurrent: true})))
+	assert.Equal(t, []string{"current", "old"},
+		names(b.GetStaleBranches(7, StaleOptions{ExcludeProtected: true})))
+	assert.Equal(t, []string{"old"},
+		names(b.GetStaleBranches(7, StaleOptions{ExcludeCurrent: true, ExcludeProtected: true})))
+
+	// Recent activity on a snapshot keeps its branch from being stale;
+	// activity older than the stale period doesn't.
+	opts := StaleOptions{LastActivity: map[string]time.Time{
+		"testdb_pgbranch_old":       time.Now().Add(-time.Hour),
+		"testdb_pgbranch_protected": time.Now().AddDate(0, 0, -10),
+	}}
+	assert.Equal(t, []string{"current", "protected"}, names(b.GetStaleBranches(7, opts)))
+}
+
+func TestBrancherCloseIsIdempotent(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 5432, User: "postgres", Database: "testdb"}
+	b := &Brancher{Config: cfg, Metadata: storage.NewMetadata(), Client: postgres.NewClient(cfg)}
+
+	b.Close()
+	b.Close()
+
+	// A Brancher without a client can be closed too.
+	(&Brancher{Config: cfg}).Close()
+}
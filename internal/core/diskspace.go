@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+var errDiskSpaceUnsupported = errors.New("disk space check not supported on this platform")
+
+// DiskSpace describes the space needed to copy a database and the space
+// available on the filesystem PostgreSQL stores it on.
+type DiskSpace struct {
+	Required  int64
+	Available uint64
+	Path      string
+}
+
+// Sufficient reports whether the copy fits, leaving 10% of its size spare
+// so the server isn't left with a full disk.
+func (d *DiskSpace) Sufficient() bool {
+	return hasHeadroom(d.Required, d.Available)
+}
+
+func hasHeadroom(required int64, available uint64) bool {
+	if required <= 0 {
+		return true
+	}
+	needed := uint64(required) + uint64(required)/10
+	return available >= needed
+}
+
+// CheckDiskSpace estimates whether the server has room for a copy of
+// dbName, as made when creating or updating a branch.
+//
+// Free space can only be read from the local filesystem, so a nil
+// DiskSpace and nil error are returned when the server isn't on this
+// machine, or its data directory isn't accessible here (for example when
+// PostgreSQL runs in a container).
+func (b *Brancher) CheckDiskSpace(ctx context.Context, dbName string) (*DiskSpace, error) {
+	if !isLocalHost(b.Config.Host) {
+		return nil, nil
+	}
+
+	size, err := b.Client.DatabaseSize(ctx, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check disk space: %w", err)
+	}
+
+	dir, err := b.Client.DataDirectory(ctx, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check disk space: %w", err)
+	}
+
+	available, err := freeDiskSpace(dir)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || errors.Is(err, errDiskSpaceUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check disk space: %w", err)
+	}
+
+	return &DiskSpace{Required: size, Available: available, Path: dir}, nil
+}
+
+// isLocalHost reports whether host refers to this machine: a loopback
+// address, or a Unix socket directory.
+func isLocalHost(host string) bool {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return strings.HasPrefix(host, "/")
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package core
+
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errDiskSpaceUnsupported
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/le-vlad/pgbranch/internal/storage"
+)
+
+var (
+	// ErrBranchNotFound is matched by errors for a branch that doesn't
+	// exist.
+	ErrBranchNotFound = storage.ErrBranchNotFound
+
+	// ErrBranchExists is matched by errors for a branch name that is
+	// already taken.
+	ErrBranchExists = storage.ErrBranchExists
+
+	// ErrCurrentBranch is returned when deleting the checked-out branch
+	// without force.
+	ErrCurrentBranch = errors.New("cannot delete current branch")
+
+	// ErrProtectedBranch is returned when deleting or pruning a protected
+	// branch without force.
+	ErrProtectedBranch = errors.New("cannot delete protected branch")
+
+	// ErrNoCurrentBranch is returned by operations that need a checked-out
+	// branch when there is none.
+	ErrNoCurrentBranch = errors.New("no current branch")
+)
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/le-vlad/pgbranch/internal/storage"
+)
+
+// SavedSnapshotDBName returns the name of the database holding the saved
+// snapshot called name in this project.
+func (b *Brancher) SavedSnapshotDBName(name string) string {
+	return storage.SavedSnapshotDBName(b.Config.SnapshotPrefix, b.Config.Database, b.Config.ProjectID, name)
+}
+
+// ValidateSnapshotName checks that name is a valid snapshot name and that
+// its database name fits within PostgreSQL's identifier length limit.
+func (b *Brancher) ValidateSnapshotName(name string) error {
+	if err := storage.ValidateSnapshotName(name); err != nil {
+		return err
+	}
+
+	dbName := b.SavedSnapshotDBName(name)
+	if len(dbName) > storage.MaxIdentifierLength {
+		return fmt.Errorf("snapshot name '%s' is too long: database name '%s' exceeds %d bytes",
+			name, dbName, storage.MaxIdentifierLength)
+	}
+
+	return nil
+}
+
+// CreateSavedSnapshot saves a copy of the working database as a snapshot
+// called name. Saved snapshots are checkpoints kept apart from branches:
+// they don't change the current branch and can only be restored over the
+// working database.
+func (b *Brancher) CreateSavedSnapshot(ctx context.Context, name string) error {
+	if err := b.ValidateSnapshotName(name); err != nil {
+		return err
+	}
+
+	if _, ok := b.Metadata.GetSnapshot(name); ok {
+		return storage.NewSnapshotExistsError(name)
+	}
+
+	dbName := b.SavedSnapshotDBName(name)
+
+	if err := b.Client.CreateSnapshot(ctx, dbName); err != nil {
+		if ctx.Err() != nil {
+			b.discardSnapshot(ctx, dbName)
+		}
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		if _, ok := m.GetSnapshot(name); ok {
+			return storage.NewSnapshotExistsError(name)
+		}
+		m.AddSnapshot(name, dbName)
+
+		// Don't save a snapshot whose creation was interrupted.
+		return ctx.Err()
+	})
+	if err != nil {
+		b.discardSnapshot(ctx, dbName)
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreSavedSnapshot replaces the working database with a copy of the
+// saved snapshot called name. The snapshot is kept, so it can be restored
+// again. As with checkout, a failed restore leaves the working database
+// unchanged.
+func (b *Brancher) RestoreSavedSnapshot(ctx context.Context, name string) error {
+	snapshot, ok := b.Metadata.GetSnapshot(name)
+	if !ok {
+		return storage.NewSnapshotNotFoundError(name)
+	}
+
+	return b.restoreWithRollback(ctx, snapshot.Database)
+}
+
+// DeleteSavedSnapshot removes the saved snapshot called name and its
+// database.
+func (b *Brancher) DeleteSavedSnapshot(ctx context.Context, name string) error {
+	snapshot, ok := b.Metadata.GetSnapshot(name)
+	if !ok {
+		return storage.NewSnapshotNotFoundError(name)
+	}
+
+	if err := b.Client.DeleteSnapshot(ctx, snapshot.Database); err != nil {
+		return fmt.Errorf("failed to delete snapshot database: %w", err)
+	}
+
+	if err := b.updateMetadata(func(m *storage.Metadata) error {
+		return m.DeleteSnapshot(name)
+	}); err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
+
+// ListSavedSnapshots returns all saved snapshots, oldest first.
+func (b *Brancher) ListSavedSnapshots() []*storage.Snapshot {
+	return b.Metadata.ListSnapshots()
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/le-vlad/pgbranch/internal/storage"
+)
+
+// maxSuggestions is the most branch names offered for a mistyped name.
+const maxSuggestions = 3
+
+// SimilarBranches returns the names of the existing branches closest to
+// name, sorted. Roughly one edit is allowed for every three characters of
+// name, up to three; if several branches are equally close, all of them
+// are returned, up to maxSuggestions.
+func (b *Brancher) SimilarBranches(name string) []string {
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 3 {
+		limit = 3
+	}
+
+	var names []string
+	best := limit
+	for existing := range b.Metadata.Branches {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(existing))
+		switch {
+		case d < best:
+			best = d
+			names = []string{existing}
+		case d == best:
+			names = append(names, existing)
+		}
+	}
+
+	sort.Strings(names)
+	if len(names) > maxSuggestions {
+		names = names[:maxSuggestions]
+	}
+	return names
+}
+
+// BranchNotFoundError returns the error for a missing branch, suggesting
+// the closest existing branch names. It matches storage.ErrBranchNotFound
+// with errors.Is.
+func (b *Brancher) BranchNotFoundError(name string) error {
+	err := storage.NewBranchNotFoundError(name)
+	if hint := DidYouMean(b.SimilarBranches(name)); hint != "" {
+		return fmt.Errorf("%w. %s", err, hint)
+	}
+	return err
+}
+
+// DidYouMean formats names as a "Did you mean ...?" hint, or returns an
+// empty string if there are none.
+func DidYouMean(names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("Did you mean '%s'?", names[0])
+	default:
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = "'" + name + "'"
+		}
+		return fmt.Sprintf("Did you mean one of %s?", strings.Join(quoted, ", "))
+	}
+}
+
+// levenshtein returns the number of single-character insertions, deletions
+// and substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/le-vlad/pgbranch/internal/storage"
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+// SnapshotRename records a snapshot database renamed by UpgradeSnapshotNames.
+type SnapshotRename struct {
+	Branch string
+	From   string
+	To     string
+}
+
+// UpgradeSnapshotNames moves a project to project-namespaced snapshot names.
+// It assigns the project an ID if it has none, then renames every branch's
+// snapshot database whose name differs from the one a new branch of that
+// name would get. Progress is saved after each rename, so an interrupted
+// upgrade leaves every branch pointing at its database and can be re-run.
+func (b *Brancher) UpgradeSnapshotNames(ctx context.Context) ([]SnapshotRename, error) {
+	if b.Config.ProjectID == "" {
+		rootDir, err := config.GetRootDir()
+		if err != nil {
+			return nil, err
+		}
+		b.Config.ProjectID = config.GenerateProjectID(rootDir, b.Config.Database)
+		if err := b.Config.Save(); err != nil {
+			return nil, fmt.Errorf("failed to save config: %w", err)
+		}
+	}
+
+	var renamed []SnapshotRename
+	for _, info := range b.ListBranches() {
+		branch := info.Branch
+		newName := b.SnapshotDBName(info.Name)
+		if branch.Snapshot == newName {
+			continue
+		}
+		if len(newName) > storage.MaxIdentifierLength {
+			return renamed, fmt.Errorf("cannot rename snapshot of branch '%s': '%s' exceeds %d bytes. Rename the branch first",
+				info.Name, newName, storage.MaxIdentifierLength)
+		}
+
+		if err := b.Client.RenameDatabase(ctx, branch.Snapshot, newName); err != nil {
+			return renamed, fmt.Errorf("failed to rename snapshot of branch '%s': %w", info.Name, err)
+		}
+		moveRecordedSchema(branch.Snapshot, newName)
+
+		renamed = append(renamed, SnapshotRename{Branch: info.Name, From: branch.Snapshot, To: newName})
+
+		err := b.updateMetadata(func(m *storage.Metadata) error {
+			if branch, ok := m.GetBranch(info.Name); ok {
+				branch.Snapshot = newName
+			}
+			return nil
+		})
+		if err != nil {
+			return renamed, fmt.Errorf("failed to save metadata: %w", err)
+		}
+	}
+
+	return renamed, nil
+}
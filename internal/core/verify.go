@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/le-vlad/pgbranch/internal/schema"
+	"github.com/le-vlad/pgbranch/internal/storage"
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+// SchemasDirName is the directory inside the pgbranch directory that holds
+// the recorded schema of each snapshot.
+const SchemasDirName = "schemas"
+
+// VerifyResult is the outcome of comparing a snapshot's schema with the
+// schema recorded when pgbranch last wrote it.
+type VerifyResult struct {
+	Branch   string
+	Recorded string
+	Current  string
+
+	// Changes lists what changed since the schema was recorded. It is nil
+	// when nothing drifted or the recorded schema is no longer available.
+	Changes *schema.ChangeSet
+}
+
+// Drifted reports whether the snapshot was modified outside pgbranch.
+func (r *VerifyResult) Drifted() bool {
+	return r.Recorded != r.Current
+}
+
+// RecordSchema extracts the schema of a branch's snapshot and stores it as
+// the branch's expected state for VerifyBranch.
+func (b *Brancher) RecordSchema(ctx context.Context, name string) error {
+	branch, ok := b.Metadata.GetBranch(name)
+	if !ok {
+		return storage.NewBranchNotFoundError(name)
+	}
+
+	fingerprint, recordErr := b.recordSchema(ctx, name, branch.Snapshot)
+
+	err := b.updateMetadata(func(m *storage.Metadata) error {
+		branch, ok := m.GetBranch(name)
+		if !ok {
+			return storage.NewBranchNotFoundError(name)
+		}
+		branch.SchemaFingerprint = fingerprint
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return recordErr
+}
+
+// VerifyBranch re-extracts the schema of a branch's snapshot and compares it
+// with the schema recorded when the snapshot was created or last updated.
+func (b *Brancher) VerifyBranch(ctx context.Context, name string) (*VerifyResult, error) {
+	branch, ok := b.Metadata.GetBranch(name)
+	if !ok {
+		return nil, storage.NewBranchNotFoundError(name)
+	}
+
+	if branch.SchemaFingerprint == "" {
+		return nil, fmt.Errorf("no schema fingerprint recorded for branch '%s'. Run 'pgbranch verify --record %s' to record one", name, name)
+	}
+
+	current, err := b.extractSnapshotSchema(ctx, name, branch.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := current.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{
+		Branch:   name,
+		Recorded: branch.SchemaFingerprint,
+		Current:  fingerprint,
+	}
+
+	if !result.Drifted() {
+		return result, nil
+	}
+
+	path, err := recordedSchemaPath(branch.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if recorded, err := schema.LoadFile(path); err == nil {
+		result.Changes = schema.DiffAgainstRecorded(recorded, current)
+	}
+
+	return result, nil
+}
+
+// UncommittedChanges extracts the schema of the working database and diffs
+// it against the current branch's snapshot, returning the changes made since
+// the branch was created, checked out or last updated.
+func (b *Brancher) UncommittedChanges(ctx context.Context) (*schema.ChangeSet, error) {
+	name := b.Metadata.CurrentBranch
+	if name == "" {
+		return nil, ErrNoCurrentBranch
+	}
+
+	branch, ok := b.Metadata.GetBranch(name)
+	if !ok {
+		return nil, storage.NewBranchNotFoundError(name)
+	}
+
+	snapshot, err := b.extractSnapshotSchema(ctx, name, branch.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	connURL := b.Config.ConnectionURLForDB(b.Config.Database)
+	working, err := schema.ExtractFromURL(ctx, connURL, b.Config.Database, schema.ExtractOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract schema of working database: %w", err)
+	}
+
+	return schema.Diff(snapshot, working), nil
+}
+
+// recordSchema extracts the schema of branch name's snapshot, saves it as
+// the snapshot's recorded schema file and returns its fingerprint.
+// Extraction takes a while, so it runs before the metadata lock is taken;
+// the caller stores the fingerprint on the branch inside updateMetadata. On
+// failure the fingerprint is empty, so that a stale one is never compared.
+func (b *Brancher) recordSchema(ctx context.Context, name, snapshotDBName string) (string, error) {
+	s, err := b.extractSnapshotSchema(ctx, name, snapshotDBName)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint, err := s.Fingerprint()
+	if err != nil {
+		return "", err
+	}
+
+	path, err := recordedSchemaPath(snapshotDBName)
+	if err != nil {
+		return "", err
+	}
+	if err := s.SaveFile(path); err != nil {
+		return "", err
+	}
+
+	return fingerprint, nil
+}
+
+func (b *Brancher) extractSnapshotSchema(ctx context.Context, name, snapshotDBName string) (*schema.Schema, error) {
+	connURL := b.Config.ConnectionURLForDB(snapshotDBName)
+	s, err := schema.ExtractFromURL(ctx, connURL, snapshotDBName, schema.ExtractOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract schema of '%s': %w", name, err)
+	}
+	return s, nil
+}
+
+// recordedSchemaPath returns the path of the recorded schema for a snapshot.
+func recordedSchemaPath(snapshotDBName string) (string, error) {
+	rootDir, err := config.GetRootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rootDir, SchemasDirName, snapshotDBName+".json"), nil
+}
+
+// removeRecordedSchema deletes the recorded schema for a snapshot, if any.
+func removeRecordedSchema(snapshotDBName string) {
+	if path, err := recordedSchemaPath(snapshotDBName); err == nil {
+		os.Remove(path)
+	}
+}
+
+// moveRecordedSchema renames the recorded schema file when a snapshot is
+// renamed.
+func moveRecordedSchema(oldSnapshot, newSnapshot string) {
+	oldPath, err := recordedSchemaPath(oldSnapshot)
+	if err != nil {
+		return
+	}
+	newPath, err := recordedSchemaPath(newSnapshot)
+	if err != nil {
+		return
+	}
+	os.Rename(oldPath, newPath)
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func CopySchema(ctx context.Context, sourceConn, targetConn *pgx.Conn, tables []string, sourceDB string) error {
-	extracted, err := schema.ExtractFromConnection(ctx, sourceConn, sourceDB)
+	extracted, err := schema.ExtractFromConnection(ctx, sourceConn, sourceDB, schema.ExtractOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to extract schema from source: %w", err)
 	}
@@ -270,9 +270,11 @@ func isRefTableRequested(refTable, currentSchema string, requested map[string]bo
 }
 
 func findUsedEnums(s *schema.Schema, requested map[string]bool) map[string]bool {
+	// Enums outside public appear schema-qualified in column types.
 	enumNames := make(map[string]bool)
 	for _, enum := range s.Enums {
 		enumNames[enum.Name] = true
+		enumNames[enum.FullName()] = true
 	}
 
 	used := make(map[string]bool)
@@ -287,7 +289,7 @@ func findUsedEnums(s *schema.Schema, requested map[string]bool) map[string]bool
 			}
 			if enumNames[dt] {
 				for _, enum := range s.Enums {
-					if enum.Name == dt {
+					if enum.Name == dt || enum.FullName() == dt {
 						used[enum.FullName()] = true
 					}
 				}
@@ -6,8 +6,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/le-vlad/pgbranch/pkg/config"
 )
 
@@ -16,6 +19,9 @@ type Client struct {
 	Config     *config.Config
 	runDump    func(ctx context.Context, args []string, env []string, w io.Writer) error
 	runRestore func(ctx context.Context, args []string, env []string, r io.Reader) (string, error)
+
+	poolMu sync.Mutex
+	pool   *pgxpool.Pool
 }
 
 // NewClient creates a new PostgreSQL client with the given configuration.
@@ -27,30 +33,47 @@ func NewClient(cfg *config.Config) *Client {
 	}
 }
 
-func (c *Client) connect(ctx context.Context, dbName string) (*pgx.Conn, error) {
-	connStr := c.Config.ConnectionURLForDB(dbName)
-	conn, err := pgx.Connect(ctx, connStr)
+// adminPool returns a connection pool to the maintenance database, creating
+// it on first use. Statements run on the pool are not wrapped in a
+// transaction, so CREATE DATABASE and DROP DATABASE work as usual.
+func (c *Client) adminPool(ctx context.Context) (*pgxpool.Pool, error) {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+
+	if c.pool != nil {
+		return c.pool, nil
+	}
+
+	pool, err := pgxpool.New(ctx, c.Config.ConnectionURLForDB("postgres"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database %s: %w", dbName, err)
+		return nil, fmt.Errorf("failed to connect to database postgres: %w", err)
 	}
-	return conn, nil
+
+	c.pool = pool
+	return pool, nil
 }
 
-func (c *Client) connectAdmin(ctx context.Context) (*pgx.Conn, error) {
-	return c.connect(ctx, "postgres")
+// Close releases the client's pooled connections. The client can still be
+// used afterwards; a new pool is created on demand.
+func (c *Client) Close() {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+
+	if c.pool != nil {
+		c.pool.Close()
+		c.pool = nil
+	}
 }
 
 // DatabaseExists checks if the configured database exists.
-func (c *Client) DatabaseExists() (bool, error) {
-	ctx := context.Background()
-	conn, err := c.connectAdmin(ctx)
+func (c *Client) DatabaseExists(ctx context.Context) (bool, error) {
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return false, fmt.Errorf("failed to check database existence: %w", err)
 	}
-	defer conn.Close(ctx)
 
 	var exists bool
-	err = conn.QueryRow(ctx,
+	err = pool.QueryRow(ctx,
 		"SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)",
 		c.Config.Database,
 	).Scan(&exists)
@@ -62,15 +85,13 @@ func (c *Client) DatabaseExists() (bool, error) {
 }
 
 // CreateDatabase creates the configured database.
-func (c *Client) CreateDatabase() error {
-	ctx := context.Background()
-	conn, err := c.connectAdmin(ctx)
+func (c *Client) CreateDatabase(ctx context.Context) error {
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create database: %w", err)
 	}
-	defer conn.Close(ctx)
 
-	_, err = conn.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s", pgx.Identifier{c.Config.Database}.Sanitize()))
+	_, err = pool.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s", pgx.Identifier{c.Config.Database}.Sanitize()))
 	if err != nil {
 		return fmt.Errorf("failed to create database: %w", err)
 	}
@@ -78,15 +99,13 @@ func (c *Client) CreateDatabase() error {
 }
 
 // DropDatabase drops the configured database if it exists.
-func (c *Client) DropDatabase() error {
-	ctx := context.Background()
-	conn, err := c.connectAdmin(ctx)
+func (c *Client) DropDatabase(ctx context.Context) error {
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to drop database: %w", err)
 	}
-	defer conn.Close(ctx)
 
-	_, err = conn.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", pgx.Identifier{c.Config.Database}.Sanitize()))
+	_, err = pool.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", pgx.Identifier{c.Config.Database}.Sanitize()))
 	if err != nil {
 		return fmt.Errorf("failed to drop database: %w", err)
 	}
@@ -94,20 +113,18 @@ func (c *Client) DropDatabase() error {
 }
 
 // TerminateConnections terminates all connections to the configured database.
-func (c *Client) TerminateConnections() error {
-	return c.TerminateConnectionsTo(c.Config.Database)
+func (c *Client) TerminateConnections(ctx context.Context) error {
+	return c.TerminateConnectionsTo(ctx, c.Config.Database)
 }
 
 // TestConnection verifies that a connection can be established to PostgreSQL.
-func (c *Client) TestConnection() error {
-	ctx := context.Background()
-	conn, err := c.connectAdmin(ctx)
+func (c *Client) TestConnection(ctx context.Context) error {
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
 	}
-	defer conn.Close(ctx)
 
-	err = conn.Ping(ctx)
+	err = pool.Ping(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
 	}
@@ -116,22 +133,19 @@ func (c *Client) TestConnection() error {
 
 // CreateDatabaseFromTemplate creates a new database using the specified
 // template database.
-func (c *Client) CreateDatabaseFromTemplate(templateDB, newDB string) error {
-	ctx := context.Background()
-
-	c.TerminateConnectionsTo(templateDB)
+func (c *Client) CreateDatabaseFromTemplate(ctx context.Context, templateDB, newDB string) error {
+	c.TerminateConnectionsTo(ctx, templateDB)
 
-	conn, err := c.connectAdmin(ctx)
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create database from template: %w", err)
 	}
-	defer conn.Close(ctx)
 
 	query := fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s",
 		pgx.Identifier{newDB}.Sanitize(),
 		pgx.Identifier{templateDB}.Sanitize(),
 	)
-	_, err = conn.Exec(ctx, query)
+	_, err = pool.Exec(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to create database from template: %w", err)
 	}
@@ -139,15 +153,13 @@ func (c *Client) CreateDatabaseFromTemplate(templateDB, newDB string) error {
 }
 
 // TerminateConnectionsTo terminates all connections to the specified database.
-func (c *Client) TerminateConnectionsTo(dbName string) error {
-	ctx := context.Background()
-	conn, err := c.connectAdmin(ctx)
+func (c *Client) TerminateConnectionsTo(ctx context.Context, dbName string) error {
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return nil
 	}
-	defer conn.Close(ctx)
 
-	_, _ = conn.Exec(ctx, `
+	_, _ = pool.Exec(ctx, `
 		SELECT pg_terminate_backend(pid)
 		FROM pg_stat_activity
 		WHERE datname = $1 AND pid <> pg_backend_pid()
@@ -157,20 +169,186 @@ func (c *Client) TerminateConnectionsTo(dbName string) error {
 }
 
 // DropDatabaseByName drops the specified database if it exists.
-func (c *Client) DropDatabaseByName(dbName string) error {
-	ctx := context.Background()
+func (c *Client) DropDatabaseByName(ctx context.Context, dbName string) error {
+	c.TerminateConnectionsTo(ctx, dbName)
 
-	c.TerminateConnectionsTo(dbName)
-
-	conn, err := c.connectAdmin(ctx)
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to drop database: %w", err)
 	}
-	defer conn.Close(ctx)
 
-	_, err = conn.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", pgx.Identifier{dbName}.Sanitize()))
+	_, err = pool.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", pgx.Identifier{dbName}.Sanitize()))
 	if err != nil {
 		return fmt.Errorf("failed to drop database: %w", err)
 	}
 	return nil
 }
+
+// RenameDatabase renames a database using ALTER DATABASE ... RENAME TO.
+// Connections to the old database are terminated first, since PostgreSQL
+// refuses to rename a database that is in use.
+func (c *Client) RenameDatabase(ctx context.Context, oldName, newName string) error {
+	c.TerminateConnectionsTo(ctx, oldName)
+
+	pool, err := c.adminPool(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to rename database: %w", err)
+	}
+
+	var exists bool
+	err = pool.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)",
+		newName,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to rename database: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("failed to rename database: database %s already exists", newName)
+	}
+
+	query := fmt.Sprintf("ALTER DATABASE %s RENAME TO %s",
+		pgx.Identifier{oldName}.Sanitize(),
+		pgx.Identifier{newName}.Sanitize(),
+	)
+	_, err = pool.Exec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to rename database: %w", err)
+	}
+	return nil
+}
+
+// ListDatabasesWithPrefix returns the names of all databases on the server
+// that start with prefix, sorted by name.
+func (c *Client) ListDatabasesWithPrefix(ctx context.Context, prefix string) ([]string, error) {
+	pool, err := c.adminPool(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	rows, err := pool.Query(ctx,
+		"SELECT datname FROM pg_database WHERE left(datname, length($1)) = $1 ORDER BY datname",
+		prefix,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	return names, nil
+}
+
+// ServerVersion returns the server's version as reported by
+// server_version_num, for example 160004 for 16.4.
+func (c *Client) ServerVersion(ctx context.Context) (int, error) {
+	pool, err := c.adminPool(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get server version: %w", err)
+	}
+
+	var version int
+	err = pool.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get server version: %w", err)
+	}
+
+	return version, nil
+}
+
+// MajorVersion returns the major version of a server_version_num value,
+// for example 16 for 160004. Versions before 10 yield 9.
+func MajorVersion(versionNum int) int {
+	return versionNum / 10000
+}
+
+// FormatVersion formats a server_version_num value the way PostgreSQL
+// prints it, for example "16.4" for 160004 and "9.6.24" for 90624.
+func FormatVersion(versionNum int) string {
+	if versionNum >= 100000 {
+		return fmt.Sprintf("%d.%d", versionNum/10000, versionNum%10000)
+	}
+	return fmt.Sprintf("%d.%d.%d", versionNum/10000, versionNum/100%100, versionNum%100)
+}
+
+// DatabaseSize returns the on-disk size of the named database in bytes,
+// as reported by pg_database_size().
+func (c *Client) DatabaseSize(ctx context.Context, dbName string) (int64, error) {
+	pool, err := c.adminPool(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	var size int64
+	err = pool.QueryRow(ctx, "SELECT pg_database_size($1)", dbName).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	return size, nil
+}
+
+// LastActivity returns, for each of the named databases that has clients
+// connected, the most recent time one of them connected, started a
+// transaction or query, or changed state, as seen in pg_stat_activity.
+// Databases without connections are left out. Sessions of other roles
+// hide these times from unprivileged users and count as active now.
+func (c *Client) LastActivity(ctx context.Context, dbNames []string) (map[string]time.Time, error) {
+	pool, err := c.adminPool(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database activity: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT datname, max(coalesce(greatest(backend_start, xact_start, query_start, state_change), now()))
+		FROM pg_stat_activity
+		WHERE datname = ANY($1) AND pid <> pg_backend_pid()
+		GROUP BY datname`,
+		dbNames,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database activity: %w", err)
+	}
+	defer rows.Close()
+
+	activity := make(map[string]time.Time)
+	for rows.Next() {
+		var name string
+		var last time.Time
+		if err := rows.Scan(&name, &last); err != nil {
+			return nil, fmt.Errorf("failed to read database activity: %w", err)
+		}
+		activity[name] = last
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read database activity: %w", err)
+	}
+
+	return activity, nil
+}
+
+// DataDirectory returns the directory the named database's files are stored
+// in on the server: its tablespace location, or the data directory for
+// databases in the default tablespace. Reading data_directory requires
+// superuser or the pg_read_all_settings role.
+func (c *Client) DataDirectory(ctx context.Context, dbName string) (string, error) {
+	pool, err := c.adminPool(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+
+	var dir string
+	err = pool.QueryRow(ctx, `
+		SELECT coalesce(nullif(pg_tablespace_location(dattablespace), ''), current_setting('data_directory'))
+		FROM pg_database
+		WHERE datname = $1
+	`, dbName).Scan(&dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %w", err)
+	}
+
+	return dir, nil
+}
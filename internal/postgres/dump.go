@@ -1,14 +1,22 @@
 package postgres
 
 import (
+	"context"
+
 	"github.com/le-vlad/pgbranch/pkg/config"
 )
 
-func (c *Client) CreateSnapshot(snapshotDBName string) error {
-	return c.CreateDatabaseFromTemplate(c.Config.Database, snapshotDBName)
+// CreateSnapshot copies the working database into snapshotDBName using
+// CREATE DATABASE ... TEMPLATE, which PostgreSQL implements as a file-level
+// copy. PostgreSQL refuses to use a database as a template while anyone else
+// is connected to it, so connections to the working database are terminated
+// first. BenchmarkCreateSnapshot compares the clone with a dump and restore.
+func (c *Client) CreateSnapshot(ctx context.Context, snapshotDBName string) error {
+	return c.CreateDatabaseFromTemplate(ctx, c.Config.Database, snapshotDBName)
 }
 
-func CreateSnapshotDB(cfg *config.Config, snapshotDBName string) error {
+func CreateSnapshotDB(ctx context.Context, cfg *config.Config, snapshotDBName string) error {
 	client := NewClient(cfg)
-	return client.CreateSnapshot(snapshotDBName)
+	defer client.Close()
+	return client.CreateSnapshot(ctx, snapshotDBName)
 }
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
@@ -31,12 +33,12 @@ func TestClientIntegration(t *testing.T) {
 	client := NewClient(cfg)
 
 	t.Run("TestConnection", func(t *testing.T) {
-		err := client.TestConnection()
+		err := client.TestConnection(ctx)
 		require.NoError(t, err)
 	})
 
 	t.Run("DatabaseExists", func(t *testing.T) {
-		exists, err := client.DatabaseExists()
+		exists, err := client.DatabaseExists(ctx)
 		require.NoError(t, err)
 		assert.True(t, exists)
 	})
@@ -51,29 +53,111 @@ func TestClientIntegration(t *testing.T) {
 		}
 		newClient := NewClient(newCfg)
 
-		exists, err := newClient.DatabaseExists()
+		exists, err := newClient.DatabaseExists(ctx)
 		require.NoError(t, err)
 		assert.False(t, exists)
 
-		err = newClient.CreateDatabase()
+		err = newClient.CreateDatabase(ctx)
 		require.NoError(t, err)
 
-		exists, err = newClient.DatabaseExists()
+		exists, err = newClient.DatabaseExists(ctx)
 		require.NoError(t, err)
 		assert.True(t, exists)
 
-		err = newClient.DropDatabase()
+		err = newClient.DropDatabase(ctx)
 		require.NoError(t, err)
 
-		exists, err = newClient.DatabaseExists()
+		exists, err = newClient.DatabaseExists(ctx)
 		require.NoError(t, err)
 		assert.False(t, exists)
 	})
 
 	t.Run("TerminateConnections", func(t *testing.T) {
-		err := client.TerminateConnections()
+		err := client.TerminateConnections(ctx)
 		require.NoError(t, err)
 	})
+
+	t.Run("RenameDatabase", func(t *testing.T) {
+		oldCfg := &config.Config{
+			Database: "test_rename_old",
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			User:     cfg.User,
+			Password: cfg.Password,
+		}
+		oldClient := NewClient(oldCfg)
+		require.NoError(t, oldClient.CreateDatabase(ctx))
+
+		err := client.RenameDatabase(ctx, "test_rename_old", "test_rename_new")
+		require.NoError(t, err)
+
+		exists, err := oldClient.DatabaseExists(ctx)
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		newCfg := *oldCfg
+		newCfg.Database = "test_rename_new"
+		newClient := NewClient(&newCfg)
+		exists, err = newClient.DatabaseExists(ctx)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		err = client.RenameDatabase(ctx, "test_rename_new", cfg.Database)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+
+		require.NoError(t, newClient.DropDatabase(ctx))
+	})
+
+	t.Run("ListDatabasesWithPrefix", func(t *testing.T) {
+		for _, name := range []string{"test_list_a", "test_list_b", "test_listx"} {
+			require.NoError(t, client.CreateDatabaseFromTemplate(ctx, cfg.Database, name))
+			defer client.DropDatabaseByName(ctx, name)
+		}
+
+		names, err := client.ListDatabasesWithPrefix(ctx, "test_list_")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test_list_a", "test_list_b"}, names)
+	})
+
+	t.Run("ServerVersion", func(t *testing.T) {
+		version, err := client.ServerVersion(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, MajorVersion(version), 10)
+	})
+
+	t.Run("DatabaseSize", func(t *testing.T) {
+		size, err := client.DatabaseSize(ctx, cfg.Database)
+		require.NoError(t, err)
+		assert.Greater(t, size, int64(0))
+
+		_, err = client.DatabaseSize(ctx, "test_size_missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("LastActivity", func(t *testing.T) {
+		activity, err := client.LastActivity(ctx, []string{cfg.Database})
+		require.NoError(t, err)
+		assert.NotContains(t, activity, cfg.Database)
+
+		conn, err := pgx.Connect(ctx, cfg.ConnectionURLForDB(cfg.Database))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		activity, err = client.LastActivity(ctx, []string{cfg.Database})
+		require.NoError(t, err)
+		require.Contains(t, activity, cfg.Database)
+		assert.WithinDuration(t, time.Now(), activity[cfg.Database], time.Minute)
+	})
+
+	t.Run("DataDirectory", func(t *testing.T) {
+		dir, err := client.DataDirectory(ctx, cfg.Database)
+		require.NoError(t, err)
+		assert.NotEmpty(t, dir)
+
+		_, err = client.DataDirectory(ctx, "test_size_missing")
+		assert.Error(t, err)
+	})
 }
 
 func TestSnapshotAndRestoreIntegration(t *testing.T) {
@@ -107,7 +191,7 @@ func TestSnapshotAndRestoreIntegration(t *testing.T) {
 	snapshotDBName := cfg.Database + "_snapshot_test"
 
 	t.Run("CreateSnapshot", func(t *testing.T) {
-		err := client.CreateSnapshot(snapshotDBName)
+		err := client.CreateSnapshot(ctx, snapshotDBName)
 		require.NoError(t, err)
 
 		snapshotCfg := &config.Config{
@@ -118,7 +202,7 @@ func TestSnapshotAndRestoreIntegration(t *testing.T) {
 			Password: cfg.Password,
 		}
 		snapshotClient := NewClient(snapshotCfg)
-		exists, err := snapshotClient.DatabaseExists()
+		exists, err := snapshotClient.DatabaseExists(ctx)
 		require.NoError(t, err)
 		assert.True(t, exists)
 	})
@@ -135,7 +219,7 @@ func TestSnapshotAndRestoreIntegration(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, 3, count)
 
-		err = client.RestoreFromSnapshot(snapshotDBName)
+		err = client.RestoreFromSnapshot(ctx, snapshotDBName)
 		require.NoError(t, err)
 
 		count, err = countRows(ctx, cfg, "users")
@@ -152,7 +236,7 @@ func TestSnapshotAndRestoreIntegration(t *testing.T) {
 	})
 
 	t.Run("DeleteSnapshot", func(t *testing.T) {
-		err := client.DeleteSnapshot(snapshotDBName)
+		err := client.DeleteSnapshot(ctx, snapshotDBName)
 		require.NoError(t, err)
 
 		snapshotCfg := &config.Config{
@@ -163,7 +247,7 @@ func TestSnapshotAndRestoreIntegration(t *testing.T) {
 			Password: cfg.Password,
 		}
 		snapshotClient := NewClient(snapshotCfg)
-		exists, err := snapshotClient.DatabaseExists()
+		exists, err := snapshotClient.DatabaseExists(ctx)
 		require.NoError(t, err)
 		assert.False(t, exists)
 	})
@@ -197,7 +281,7 @@ func TestCreateSnapshotDBAndRestoreFromSnapshotDB(t *testing.T) {
 
 	snapshotDBName := cfg.Database + "_helper_test_snapshot"
 
-	err = CreateSnapshotDB(cfg, snapshotDBName)
+	err = CreateSnapshotDB(ctx, cfg, snapshotDBName)
 	require.NoError(t, err)
 
 	snapshotCfg := &config.Config{
@@ -208,7 +292,7 @@ func TestCreateSnapshotDBAndRestoreFromSnapshotDB(t *testing.T) {
 		Password: cfg.Password,
 	}
 	snapshotClient := NewClient(snapshotCfg)
-	exists, err := snapshotClient.DatabaseExists()
+	exists, err := snapshotClient.DatabaseExists(ctx)
 	require.NoError(t, err)
 	assert.True(t, exists)
 
@@ -219,17 +303,172 @@ func TestCreateSnapshotDBAndRestoreFromSnapshotDB(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, count)
 
-	err = RestoreFromSnapshotDB(cfg, snapshotDBName)
+	err = RestoreFromSnapshotDB(ctx, cfg, snapshotDBName)
 	require.NoError(t, err)
 
 	count, err = countRows(ctx, cfg, "products")
 	require.NoError(t, err)
 	assert.Equal(t, 2, count)
 
-	err = DeleteSnapshotDB(cfg, snapshotDBName)
+	err = DeleteSnapshotDB(ctx, cfg, snapshotDBName)
 	require.NoError(t, err)
 }
 
+// benchmarkRows is the size of the table the copy benchmarks and tests work
+// on, a moderately sized database of a few tens of megabytes.
+const benchmarkRows = 500000
+
+// minCloneSpeedup is how many times faster than a pg_dump/pg_restore round
+// trip a template clone must be. A clone is usually an order of magnitude
+// faster, so the margin leaves room for slow machines.
+const minCloneSpeedup = 2
+
+// startBenchmarkDatabase starts a PostgreSQL container whose working
+// database holds benchmarkRows rows, for comparing a template clone with a
+// pg_dump/pg_restore round trip. It skips without pg_dump and pg_restore.
+func startBenchmarkDatabase(tb testing.TB) (*config.Config, *Client) {
+	tb.Helper()
+
+	if testing.Short() {
+		tb.Skip("skipping integration comparison in short mode")
+	}
+	if err := CheckTools(); err != nil {
+		tb.Skip(err)
+	}
+
+	ctx := context.Background()
+
+	pg, err := testutil.StartPostgresContainer(ctx)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { pg.Stop(ctx) })
+
+	cfg := pg.GetConfig()
+	client := NewClient(cfg)
+	tb.Cleanup(client.Close)
+
+	err = execSQL(ctx, cfg, fmt.Sprintf(`
+		CREATE TABLE events (id SERIAL PRIMARY KEY, payload TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL);
+		INSERT INTO events (payload, created_at)
+			SELECT md5(i::text) || md5((i * 2)::text), now() - i * interval '1 second'
+			FROM generate_series(1, %d) AS i;
+		CREATE INDEX idx_events_created_at ON events (created_at);
+	`, benchmarkRows))
+	require.NoError(tb, err)
+
+	return cfg, client
+}
+
+// dumpRestoreCopy copies source into a new database target with pg_dump and
+// pg_restore, the approach the template clone is compared against.
+func dumpRestoreCopy(ctx context.Context, client *Client, source, target string) error {
+	var dump bytes.Buffer
+	if err := client.DumpDatabase(ctx, source, &dump, nil); err != nil {
+		return err
+	}
+	return client.RestoreSnapshotFromReader(ctx, target, &dump, nil)
+}
+
+// BenchmarkRestoreFromSnapshot compares replacing the working database with
+// a template clone of a snapshot, as checkout does, against restoring it
+// from a dump of the snapshot. Run it with:
+//
+//	go test ./internal/postgres -run '^$' -bench RestoreFromSnapshot -benchtime 5x
+func BenchmarkRestoreFromSnapshot(b *testing.B) {
+	cfg, client := startBenchmarkDatabase(b)
+	ctx := context.Background()
+
+	snapshotDBName := cfg.Database + "_bench_snapshot"
+	require.NoError(b, client.CreateSnapshot(ctx, snapshotDBName))
+
+	b.Run("TemplateClone", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, client.RestoreFromSnapshot(ctx, snapshotDBName))
+		}
+	})
+
+	b.Run("DumpRestore", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			client.TerminateConnections(ctx)
+			require.NoError(b, client.DropDatabase(ctx))
+			require.NoError(b, dumpRestoreCopy(ctx, client, snapshotDBName, cfg.Database))
+		}
+	})
+}
+
+func TestRestoreFromSnapshotFasterThanDumpRestore(t *testing.T) {
+	cfg, client := startBenchmarkDatabase(t)
+	ctx := context.Background()
+
+	snapshotDBName := cfg.Database + "_bench_snapshot"
+	require.NoError(t, client.CreateSnapshot(ctx, snapshotDBName))
+
+	start := time.Now()
+	require.NoError(t, client.RestoreFromSnapshot(ctx, snapshotDBName))
+	cloneTime := time.Since(start)
+
+	client.TerminateConnections(ctx)
+	require.NoError(t, client.DropDatabase(ctx))
+	start = time.Now()
+	require.NoError(t, dumpRestoreCopy(ctx, client, snapshotDBName, cfg.Database))
+	dumpRestoreTime := time.Since(start)
+
+	t.Logf("template clone: %v, dump/restore: %v", cloneTime, dumpRestoreTime)
+	assert.Less(t, cloneTime*minCloneSpeedup, dumpRestoreTime,
+		"restoring from a template clone should be at least %dx faster than a dump/restore", minCloneSpeedup)
+}
+
+// BenchmarkCreateSnapshot compares copying the working database into a
+// new snapshot with a template clone, as creating a branch does, against
+// copying it with a dump and restore. Run it with:
+//
+//	go test ./internal/postgres -run '^$' -bench CreateSnapshot -benchtime 5x
+func BenchmarkCreateSnapshot(b *testing.B) {
+	cfg, client := startBenchmarkDatabase(b)
+	ctx := context.Background()
+
+	snapshotDBName := cfg.Database + "_bench_snapshot"
+
+	b.Run("TemplateClone", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, client.CreateSnapshot(ctx, snapshotDBName))
+
+			b.StopTimer()
+			require.NoError(b, client.DropDatabaseByName(ctx, snapshotDBName))
+			b.StartTimer()
+		}
+	})
+
+	b.Run("DumpRestore", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, dumpRestoreCopy(ctx, client, cfg.Database, snapshotDBName))
+
+			b.StopTimer()
+			require.NoError(b, client.DropDatabaseByName(ctx, snapshotDBName))
+			b.StartTimer()
+		}
+	})
+}
+
+func TestCreateSnapshotFasterThanDumpRestore(t *testing.T) {
+	cfg, client := startBenchmarkDatabase(t)
+	ctx := context.Background()
+
+	snapshotDBName := cfg.Database + "_bench_snapshot"
+
+	start := time.Now()
+	require.NoError(t, client.CreateSnapshot(ctx, snapshotDBName))
+	cloneTime := time.Since(start)
+
+	require.NoError(t, client.DropDatabaseByName(ctx, snapshotDBName))
+	start = time.Now()
+	require.NoError(t, dumpRestoreCopy(ctx, client, cfg.Database, snapshotDBName))
+	dumpRestoreTime := time.Since(start)
+
+	t.Logf("template clone: %v, dump/restore: %v", cloneTime, dumpRestoreTime)
+	assert.Less(t, cloneTime*minCloneSpeedup, dumpRestoreTime,
+		"creating a snapshot with a template clone should be at least %dx faster than a dump/restore", minCloneSpeedup)
+}
+
 func execSQL(ctx context.Context, cfg *config.Config, sql string) error {
 	conn, err := pgx.Connect(ctx, cfg.ConnectionURLForDB(cfg.Database))
 	if err != nil {
@@ -370,6 +609,64 @@ func TestBuildDumpArgs(t *testing.T) {
 	})
 }
 
+func TestClientAdminPoolIsReusedUntilClosed(t *testing.T) {
+	cfg := &config.Config{Host: "localhost", Port: 5432, User: "postgres", Database: "mydb"}
+	client := NewClient(cfg)
+
+	// Creating the pool does not connect, so no server is needed.
+	first, err := client.adminPool(context.Background())
+	require.NoError(t, err)
+	second, err := client.adminPool(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	client.Close()
+	client.Close()
+
+	third, err := client.adminPool(context.Background())
+	require.NoError(t, err)
+	assert.NotSame(t, first, third)
+	client.Close()
+}
+
+func TestMajorVersion(t *testing.T) {
+	assert.Equal(t, 16, MajorVersion(160004))
+	assert.Equal(t, 10, MajorVersion(100023))
+	assert.Equal(t, 9, MajorVersion(90624))
+}
+
+func TestFormatVersion(t *testing.T) {
+	assert.Equal(t, "16.4", FormatVersion(160004))
+	assert.Equal(t, "10.0", FormatVersion(100000))
+	assert.Equal(t, "9.6.24", FormatVersion(90624))
+}
+
+func TestCheckTools_MissingBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := CheckTools()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pg_dump not found")
+}
+
+func TestParseToolMajorVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   int
+	}{
+		{"pg_dump (PostgreSQL) 16.4", 16},
+		{"pg_dump (PostgreSQL) 16.4 (Ubuntu 16.4-1.pgdg22.04+1)", 16},
+		{"pg_restore (PostgreSQL) 17beta1", 17},
+		{"pg_dump (PostgreSQL) 9.6.24", 9},
+		{"", 0},
+		{"not a version", 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ParseToolMajorVersion(tt.output), tt.output)
+	}
+}
+
 func TestBuildRestoreArgs(t *testing.T) {
 	cfg := &config.Config{
 		Host: "dbhost",
@@ -378,7 +675,7 @@ func TestBuildRestoreArgs(t *testing.T) {
 	}
 	client := NewClient(cfg)
 
-	args := client.buildRestoreArgs("targetdb")
+	args := client.buildRestoreArgs("targetdb", nil)
 	expected := []string{
 		"-h", "dbhost",
 		"-p", "5433",
@@ -391,6 +688,35 @@ func TestBuildRestoreArgs(t *testing.T) {
 	assert.Equal(t, expected, args)
 }
 
+func TestRestoreDatabase_ParallelJobsUsesTempFile(t *testing.T) {
+	cfg := &config.Config{Host: "restorehost", Port: 5434, User: "restoreuser"}
+	client := newMockClient(cfg)
+
+	var capturedArgs []string
+	var capturedReader io.Reader
+	var fileContents []byte
+	client.runRestore = func(ctx context.Context, args []string, env []string, r io.Reader) (string, error) {
+		capturedArgs = args
+		capturedReader = r
+		data, err := os.ReadFile(args[len(args)-1])
+		require.NoError(t, err)
+		fileContents = data
+		return "", nil
+	}
+
+	err := client.RestoreDatabase(context.Background(), "targetdb", bytes.NewReader([]byte("dump data")), &RestoreOptions{Jobs: 4})
+	require.NoError(t, err)
+
+	require.GreaterOrEqual(t, len(capturedArgs), 3)
+	dumpFile := capturedArgs[len(capturedArgs)-1]
+	assert.Equal(t, []string{"-j", "4"}, capturedArgs[len(capturedArgs)-3:len(capturedArgs)-1])
+	assert.Nil(t, capturedReader)
+	assert.Equal(t, "dump data", string(fileContents))
+
+	_, err = os.Stat(dumpFile)
+	assert.True(t, os.IsNotExist(err), "temporary dump file should be removed")
+}
+
 func TestBuildEnv(t *testing.T) {
 	t.Run("without password", func(t *testing.T) {
 		cfg := &config.Config{
@@ -425,6 +751,21 @@ func TestBuildEnv(t *testing.T) {
 		}
 		assert.True(t, found, "expected PGPASSWORD=secret123 in env")
 	})
+
+	t.Run("with ssl settings", func(t *testing.T) {
+		cfg := &config.Config{
+			Host:        "localhost",
+			Port:        5432,
+			User:        "testuser",
+			SSLMode:     "verify-full",
+			SSLRootCert: "/etc/ssl/ca.pem",
+		}
+		client := NewClient(cfg)
+		env := client.buildEnv()
+
+		assert.Contains(t, env, "PGSSLMODE=verify-full")
+		assert.Contains(t, env, "PGSSLROOTCERT=/etc/ssl/ca.pem")
+	})
 }
 
 func TestSanitizeIdentifier(t *testing.T) {
@@ -511,7 +852,7 @@ func TestRestoreDatabase_Success(t *testing.T) {
 		return "", nil
 	}
 
-	err := client.RestoreDatabase(context.Background(), "mydb", bytes.NewReader(nil))
+	err := client.RestoreDatabase(context.Background(), "mydb", bytes.NewReader(nil), nil)
 	require.NoError(t, err)
 }
 
@@ -522,7 +863,7 @@ func TestRestoreDatabase_NonCriticalError(t *testing.T) {
 		return `pg_restore: ERROR: unrecognized configuration parameter "some_param"`, fmt.Errorf("exit status 1")
 	}
 
-	err := client.RestoreDatabase(context.Background(), "mydb", bytes.NewReader(nil))
+	err := client.RestoreDatabase(context.Background(), "mydb", bytes.NewReader(nil), nil)
 	require.NoError(t, err)
 }
 
@@ -533,7 +874,7 @@ func TestRestoreDatabase_CriticalError(t *testing.T) {
 		return `pg_restore: ERROR: relation "foo" does not exist`, fmt.Errorf("exit status 1")
 	}
 
-	err := client.RestoreDatabase(context.Background(), "mydb", bytes.NewReader(nil))
+	err := client.RestoreDatabase(context.Background(), "mydb", bytes.NewReader(nil), nil)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "pg_restore failed")
 }
@@ -548,7 +889,7 @@ func TestRestoreDatabase_PassesCorrectArgs(t *testing.T) {
 		return "", nil
 	}
 
-	err := client.RestoreDatabase(context.Background(), "targetdb", bytes.NewReader(nil))
+	err := client.RestoreDatabase(context.Background(), "targetdb", bytes.NewReader(nil), nil)
 	require.NoError(t, err)
 
 	expected := []string{
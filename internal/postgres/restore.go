@@ -1,35 +1,43 @@
 package postgres
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/le-vlad/pgbranch/pkg/config"
 )
 
-func (c *Client) RestoreFromSnapshot(snapshotDBName string) error {
-	c.TerminateConnections()
+// RestoreFromSnapshot replaces the working database with a copy of the
+// snapshot database. Snapshots always live on the same server as the working
+// database, so this is a template clone (a file-level copy) rather than a
+// pg_dump/pg_restore round trip. BenchmarkRestoreFromSnapshot compares the
+// two.
+func (c *Client) RestoreFromSnapshot(ctx context.Context, snapshotDBName string) error {
+	c.TerminateConnections(ctx)
 
-	if err := c.DropDatabase(); err != nil {
+	if err := c.DropDatabase(ctx); err != nil {
 		return fmt.Errorf("failed to drop database: %w", err)
 	}
 
-	if err := c.CreateDatabaseFromTemplate(snapshotDBName, c.Config.Database); err != nil {
+	if err := c.CreateDatabaseFromTemplate(ctx, snapshotDBName, c.Config.Database); err != nil {
 		return fmt.Errorf("failed to create database from snapshot: %w", err)
 	}
 
 	return nil
 }
 
-func RestoreFromSnapshotDB(cfg *config.Config, snapshotDBName string) error {
+func RestoreFromSnapshotDB(ctx context.Context, cfg *config.Config, snapshotDBName string) error {
 	client := NewClient(cfg)
-	return client.RestoreFromSnapshot(snapshotDBName)
+	defer client.Close()
+	return client.RestoreFromSnapshot(ctx, snapshotDBName)
 }
 
-func (c *Client) DeleteSnapshot(snapshotDBName string) error {
-	return c.DropDatabaseByName(snapshotDBName)
+func (c *Client) DeleteSnapshot(ctx context.Context, snapshotDBName string) error {
+	return c.DropDatabaseByName(ctx, snapshotDBName)
 }
 
-func DeleteSnapshotDB(cfg *config.Config, snapshotDBName string) error {
+func DeleteSnapshotDB(ctx context.Context, cfg *config.Config, snapshotDBName string) error {
 	client := NewClient(cfg)
-	return client.DeleteSnapshot(snapshotDBName)
+	defer client.Close()
+	return client.DeleteSnapshot(ctx, snapshotDBName)
 }
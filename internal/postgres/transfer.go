@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/le-vlad/pgbranch/pkg/config"
@@ -18,6 +20,14 @@ type DumpOptions struct {
 	ExcludeTables []string
 }
 
+// RestoreOptions configures pg_restore behavior
+type RestoreOptions struct {
+	// Jobs is the number of parallel pg_restore workers. Values above 1
+	// require the dump to be read from a file, so it is first written to a
+	// temporary file, which needs as much free disk space as the dump.
+	Jobs int
+}
+
 func defaultRunDump(ctx context.Context, args []string, env []string, w io.Writer) error {
 	cmd := exec.CommandContext(ctx, "pg_dump", args...)
 	cmd.Stdout = w
@@ -74,8 +84,21 @@ func (c *Client) buildDumpArgs(dbName string, opts *DumpOptions) []string {
 
 // RestoreDatabase restores a pg_dump to the specified database from the provided reader.
 // The database must already exist and be empty.
-func (c *Client) RestoreDatabase(ctx context.Context, dbName string, r io.Reader) error {
-	args := c.buildRestoreArgs(dbName)
+func (c *Client) RestoreDatabase(ctx context.Context, dbName string, r io.Reader, opts *RestoreOptions) error {
+	args := c.buildRestoreArgs(dbName, opts)
+
+	if opts != nil && opts.Jobs > 1 {
+		// pg_restore cannot run parallel jobs when reading from stdin.
+		dumpFile, err := writeTempDump(r)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(dumpFile)
+
+		args = append(args, dumpFile)
+		r = nil
+	}
+
 	stderrStr, err := c.runRestore(ctx, args, c.buildEnv(), r)
 	if err != nil {
 		if isCriticalRestoreError(stderrStr) {
@@ -130,8 +153,8 @@ func isCriticalRestoreError(stderr string) bool {
 	return true
 }
 
-func (c *Client) buildRestoreArgs(dbName string) []string {
-	return []string{
+func (c *Client) buildRestoreArgs(dbName string, opts *RestoreOptions) []string {
+	args := []string{
 		"-h", c.Config.Host,
 		"-p", fmt.Sprintf("%d", c.Config.Port),
 		"-U", c.Config.User,
@@ -140,15 +163,50 @@ func (c *Client) buildRestoreArgs(dbName string) []string {
 		"--no-owner",
 		"--no-privileges",
 	}
+
+	if opts != nil && opts.Jobs > 1 {
+		args = append(args, "-j", fmt.Sprintf("%d", opts.Jobs))
+	}
+
+	return args
+}
+
+// writeTempDump copies a dump to a temporary file and returns its path. The
+// caller is responsible for removing the file.
+func writeTempDump(r io.Reader) (string, error) {
+	f, err := os.CreateTemp("", "pgbranch-restore-*.pgc")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary dump file: %w", err)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temporary dump file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temporary dump file: %w", err)
+	}
+
+	return f.Name(), nil
 }
 
 // buildEnv creates environment variables for pg_dump/pg_restore commands
-// It inherits the current environment and adds PGPASSWORD if configured
+// It inherits the current environment and adds PGPASSWORD and the TLS
+// settings if configured
 func (c *Client) buildEnv() []string {
 	env := os.Environ()
 	if c.Config.Password != "" {
 		env = append(env, fmt.Sprintf("PGPASSWORD=%s", c.Config.Password))
 	}
+	if c.Config.SSLMode != "" {
+		env = append(env, fmt.Sprintf("PGSSLMODE=%s", c.Config.SSLMode))
+	}
+	if c.Config.SSLRootCert != "" {
+		env = append(env, fmt.Sprintf("PGSSLROOTCERT=%s", c.Config.SSLRootCert))
+	}
 	return env
 }
 
@@ -156,29 +214,28 @@ func (c *Client) DumpSnapshotToWriter(ctx context.Context, snapshotDBName string
 	return c.DumpDatabase(ctx, snapshotDBName, w, nil)
 }
 
-func (c *Client) RestoreSnapshotFromReader(ctx context.Context, snapshotDBName string, r io.Reader) error {
-	if err := c.CreateEmptyDatabase(snapshotDBName); err != nil {
+func (c *Client) RestoreSnapshotFromReader(ctx context.Context, snapshotDBName string, r io.Reader, opts *RestoreOptions) error {
+	if err := c.CreateEmptyDatabase(ctx, snapshotDBName); err != nil {
 		return fmt.Errorf("failed to create database for restore: %w", err)
 	}
 
-	if err := c.RestoreDatabase(ctx, snapshotDBName, r); err != nil {
-		c.DropDatabaseByName(snapshotDBName)
+	if err := c.RestoreDatabase(ctx, snapshotDBName, r, opts); err != nil {
+		// Drop the partial database even if the restore was interrupted.
+		c.DropDatabaseByName(context.WithoutCancel(ctx), snapshotDBName)
 		return fmt.Errorf("failed to restore database: %w", err)
 	}
 
 	return nil
 }
 
-func (c *Client) CreateEmptyDatabase(dbName string) error {
-	ctx := context.Background()
-	conn, err := c.connectAdmin(ctx)
+func (c *Client) CreateEmptyDatabase(ctx context.Context, dbName string) error {
+	pool, err := c.adminPool(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
-	defer conn.Close(ctx)
 
 	query := fmt.Sprintf("CREATE DATABASE %s", sanitizeIdentifier(dbName))
-	_, err = conn.Exec(ctx, query)
+	_, err = pool.Exec(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to create database: %w", err)
 	}
@@ -191,6 +248,21 @@ func sanitizeIdentifier(name string) string {
 	return fmt.Sprintf(`"%s"`, escaped)
 }
 
+// CheckTools checks that pg_dump and pg_restore can be run, which pushing,
+// pulling, exporting and importing branches need. It fails early with an
+// error saying what to install, rather than deep inside a dump or restore.
+func CheckTools() error {
+	for _, tool := range []string{"pg_dump", "pg_restore"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("%s not found; install the PostgreSQL client tools and make sure they are on PATH", tool)
+		}
+		if err := exec.Command(tool, "--version").Run(); err != nil {
+			return fmt.Errorf("failed to run %s --version: %w", tool, err)
+		}
+	}
+	return nil
+}
+
 func GetPgDumpVersion() (string, error) {
 	cmd := exec.Command("pg_dump", "--version")
 	output, err := cmd.Output()
@@ -209,12 +281,56 @@ func GetPgRestoreVersion() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func DumpDatabaseToWriter(cfg *config.Config, dbName string, w io.Writer) error {
+var toolVersionPattern = regexp.MustCompile(`\(PostgreSQL\)\s+(\d+)`)
+
+// ParseToolMajorVersion returns the major version from the output of
+// pg_dump --version or pg_restore --version, for example 16 for
+// "pg_dump (PostgreSQL) 16.4 (Ubuntu 16.4-1)" and 9 for 9.6.24, matching
+// MajorVersion. It returns 0 if the output has no version.
+func ParseToolMajorVersion(output string) int {
+	m := toolVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return 0
+	}
+	major, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+// CheckPgDumpVersion returns an error naming both versions when the local
+// pg_dump is from an older major version than the server, since pg_dump
+// refuses to dump newer servers. It returns nil when either version can't
+// be determined.
+func (c *Client) CheckPgDumpVersion(ctx context.Context) error {
+	dumpVersion, err := GetPgDumpVersion()
+	if err != nil {
+		return nil
+	}
+	dumpMajor := ParseToolMajorVersion(dumpVersion)
+
+	serverVersion, err := c.ServerVersion(ctx)
+	if err != nil || dumpMajor == 0 {
+		return nil
+	}
+
+	if dumpMajor >= MajorVersion(serverVersion) {
+		return nil
+	}
+
+	return fmt.Errorf("%s is older than the server (PostgreSQL %s) and will likely fail to dump it; install the PostgreSQL %d client tools",
+		dumpVersion, FormatVersion(serverVersion), MajorVersion(serverVersion))
+}
+
+func DumpDatabaseToWriter(ctx context.Context, cfg *config.Config, dbName string, w io.Writer) error {
 	client := NewClient(cfg)
-	return client.DumpSnapshotToWriter(context.Background(), dbName, w)
+	defer client.Close()
+	return client.DumpSnapshotToWriter(ctx, dbName, w)
 }
 
-func RestoreDatabaseFromReader(cfg *config.Config, dbName string, r io.Reader) error {
+func RestoreDatabaseFromReader(ctx context.Context, cfg *config.Config, dbName string, r io.Reader) error {
 	client := NewClient(cfg)
-	return client.RestoreSnapshotFromReader(context.Background(), dbName, r)
+	defer client.Close()
+	return client.RestoreSnapshotFromReader(ctx, dbName, r, nil)
 }
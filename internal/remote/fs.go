@@ -127,11 +127,11 @@ func (r *FilesystemRemote) List(ctx context.Context) ([]RemoteBranch, error) {
 		}
 
 		name := entry.Name()
-		if !strings.HasSuffix(name, ".pgbranch") {
+		if !isArchiveFile(name) {
 			continue
 		}
 
-		branchName := strings.TrimSuffix(name, ".pgbranch")
+		branchName := archiveNameToBranch(name)
 
 		info, err := entry.Info()
 		if err != nil {
@@ -175,3 +175,20 @@ func (r *FilesystemRemote) Exists(ctx context.Context, branchName string) (bool,
 
 	return true, nil
 }
+
+func (r *FilesystemRemote) Test(ctx context.Context) error {
+	if err := r.ensureDir(); err != nil {
+		return fmt.Errorf("failed to create remote directory: %w", err)
+	}
+
+	probePath := filepath.Join(r.path, ProbeFileName)
+	if err := os.WriteFile(probePath, probeData, 0644); err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", r.path, err)
+	}
+
+	if err := os.Remove(probePath); err != nil {
+		return fmt.Errorf("failed to remove probe file: %w", err)
+	}
+
+	return nil
+}
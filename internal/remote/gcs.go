@@ -177,7 +177,8 @@ func (r *GCSRemote) List(ctx context.Context) ([]RemoteBranch, error) {
 	var branches []RemoteBranch
 
 	it := r.client.Objects(ctx, &storage.Query{
-		Prefix: prefix,
+		Prefix:    prefix,
+		Delimiter: "/",
 	})
 
 	for {
@@ -188,6 +189,11 @@ func (r *GCSRemote) List(ctx context.Context) ([]RemoteBranch, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to list GCS objects: %w", err)
 		}
+		// With a delimiter, nested prefixes come back as entries with only
+		// Prefix set.
+		if attrs.Name == "" || !isDirectChild(prefix, attrs.Name) {
+			continue
+		}
 
 		filename := path.Base(attrs.Name)
 		if !isArchiveFile(filename) {
@@ -231,3 +237,26 @@ func (r *GCSRemote) Exists(ctx context.Context, branchName string) (bool, error)
 
 	return true, nil
 }
+
+func (r *GCSRemote) Test(ctx context.Context) error {
+	key := ProbeFileName
+	if r.prefix != "" {
+		key = path.Join(r.prefix, ProbeFileName)
+	}
+
+	obj := r.client.Object(key)
+	w := obj.NewWriter(ctx)
+	if _, err := w.Write(probeData); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write probe object to GCS: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write probe object to GCS: %w", err)
+	}
+
+	if err := obj.Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete probe object from GCS: %w", err)
+	}
+
+	return nil
+}
@@ -102,7 +102,7 @@ func (it *mockGCSIterator) Next() (*storage.ObjectAttrs, error) {
 func TestGCSRemote_ObjectKey(t *testing.T) {
 	r := &GCSRemote{name: "test", prefix: "", client: &mockGCSBucket{}}
 	got := r.objectKey("feature/branch")
-	want := "feature_branch.pgbranch"
+	want := "feature%2Fbranch.pgbranch"
 	if got != want {
 		t.Errorf("objectKey() without prefix = %q, want %q", got, want)
 	}
@@ -223,6 +223,28 @@ func TestGCSRemote_List_WithObjects(t *testing.T) {
 	}
 }
 
+func TestGCSRemote_List_SkipsNestedPrefixes(t *testing.T) {
+	bucket := &mockGCSBucket{
+		listObjs: []*storage.ObjectAttrs{
+			{Name: "team-a/main.pgbranch", Size: 100},
+			{Name: "team-a/nested/stale.pgbranch", Size: 200},
+			{Prefix: "team-a/nested/"},
+		},
+	}
+	r := &GCSRemote{name: "test", prefix: "team-a", client: bucket}
+
+	branches, err := r.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(branches) != 1 {
+		t.Fatalf("List() returned %d branches, want 1", len(branches))
+	}
+	if branches[0].Name != "main" {
+		t.Errorf("branches[0].Name = %q, want %q", branches[0].Name, "main")
+	}
+}
+
 func TestGCSRemote_List_Empty(t *testing.T) {
 	bucket := &mockGCSBucket{}
 	r := &GCSRemote{name: "test", client: bucket}
@@ -323,3 +345,27 @@ func TestGCSRemote_Exists_OtherError(t *testing.T) {
 		t.Errorf("Exists() = true, want false on error")
 	}
 }
+
+func TestGCSRemote_Test(t *testing.T) {
+	obj := &mockGCSObject{}
+	bucket := &mockGCSBucket{
+		objects: map[string]*mockGCSObject{"backups/" + ProbeFileName: obj},
+	}
+	r := &GCSRemote{name: "test", prefix: "backups", client: bucket}
+
+	if err := r.Test(context.Background()); err != nil {
+		t.Fatalf("Test() error: %v", err)
+	}
+	if obj.written.Len() == 0 {
+		t.Errorf("probe object was not written")
+	}
+	if !obj.deleted {
+		t.Errorf("probe object was not deleted")
+	}
+
+	obj = &mockGCSObject{writeCloseErr: errors.New("permission denied")}
+	bucket.objects["backups/"+ProbeFileName] = obj
+	if err := r.Test(context.Background()); err == nil {
+		t.Fatalf("Test() expected error, got nil")
+	}
+}
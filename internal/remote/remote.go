@@ -25,7 +25,9 @@ type Remote interface {
 	Type() string
 
 	// Push uploads a snapshot archive to the remote
-	// The reader should contain the archive data
+	// The reader should contain the archive data. size is its length, or
+	// -1 if it isn't known until the reader is drained; remotes that need
+	// a length up front must buffer the data themselves.
 	Push(ctx context.Context, branchName string, r io.Reader, size int64) error
 
 	// Pull downloads a snapshot archive from the remote
@@ -40,8 +42,18 @@ type Remote interface {
 
 	// Exists checks if a branch exists on the remote
 	Exists(ctx context.Context, branchName string) (bool, error)
+
+	// Test checks that the remote is reachable and writable by writing and
+	// removing a small probe object
+	Test(ctx context.Context) error
 }
 
+// ProbeFileName is the name of the object written by Remote.Test. It does
+// not end in .pgbranch, so it is never listed as a branch.
+const ProbeFileName = ".pgbranch-probe"
+
+var probeData = []byte("pgbranch remote test\n")
+
 type Config struct {
 	Name string `json:"name"`
 
@@ -157,9 +169,36 @@ func New(cfg *Config) (Remote, error) {
 	return factory(cfg)
 }
 
+// archiveNameEscaper percent-encodes the characters that cannot appear in a
+// file name or object key segment, and '%' itself so the mapping reverses.
+var archiveNameEscaper = strings.NewReplacer(
+	"%", "%25",
+	"/", "%2F",
+	"\\", "%5C",
+	":", "%3A",
+)
+
+// ArchiveFileName returns the file name a branch is stored under on a remote.
+// Characters that are unsafe in file names are percent-encoded, so
+// archiveNameToBranch can recover the original branch name.
 func ArchiveFileName(branchName string) string {
-	safe := strings.ReplaceAll(branchName, "/", "_")
-	safe = strings.ReplaceAll(safe, "\\", "_")
-	safe = strings.ReplaceAll(safe, ":", "_")
-	return fmt.Sprintf("%s.pgbranch", safe)
+	return fmt.Sprintf("%s.pgbranch", archiveNameEscaper.Replace(branchName))
+}
+
+func isArchiveFile(filename string) bool {
+	return len(filename) > 9 && filename[len(filename)-9:] == ".pgbranch"
+}
+
+// archiveNameToBranch reverses ArchiveFileName. Names that are not valid
+// percent-encoding, such as archives pushed before branch names were
+// encoded, are returned as they are.
+func archiveNameToBranch(filename string) string {
+	if len(filename) <= 9 {
+		return ""
+	}
+	name := filename[:len(filename)-9]
+	if decoded, err := url.PathUnescape(name); err == nil {
+		return decoded
+	}
+	return name
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -160,9 +161,11 @@ func TestArchiveFileName(t *testing.T) {
 		expected string
 	}{
 		{name: "simple name", branch: "main", expected: "main.pgbranch"},
-		{name: "with slashes", branch: "feature/foo/bar", expected: "feature_foo_bar.pgbranch"},
-		{name: "with backslashes", branch: "feature\\foo", expected: "feature_foo.pgbranch"},
-		{name: "with colons", branch: "ref:heads:main", expected: "ref_heads_main.pgbranch"},
+		{name: "with slashes", branch: "feature/foo/bar", expected: "feature%2Ffoo%2Fbar.pgbranch"},
+		{name: "with backslashes", branch: "feature\\foo", expected: "feature%5Cfoo.pgbranch"},
+		{name: "with colons", branch: "ref:heads:main", expected: "ref%3Aheads%3Amain.pgbranch"},
+		{name: "with percent", branch: "100%", expected: "100%25.pgbranch"},
+		{name: "with underscores", branch: "feature_foo", expected: "feature_foo.pgbranch"},
 	}
 
 	for _, tt := range tests {
@@ -175,6 +178,55 @@ func TestArchiveFileName(t *testing.T) {
 	}
 }
 
+func TestArchiveFileNameRoundTrip(t *testing.T) {
+	branches := []string{
+		"main",
+		"feature/login",
+		"feature/foo/bar",
+		"feature_login",
+		"feature\\foo",
+		"ref:heads:main",
+		"100%",
+		"release-1.2",
+	}
+
+	for _, branch := range branches {
+		t.Run(branch, func(t *testing.T) {
+			filename := ArchiveFileName(branch)
+			if !isArchiveFile(filename) {
+				t.Fatalf("isArchiveFile(%q) = false, want true", filename)
+			}
+			if got := archiveNameToBranch(filename); got != branch {
+				t.Errorf("archiveNameToBranch(ArchiveFileName(%q)) = %q", branch, got)
+			}
+		})
+	}
+}
+
+func TestFilesystemRemote_ListDecodesBranchNames(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewFilesystemRemote(&Config{Name: "local", Type: "fs", URL: dir})
+	if err != nil {
+		t.Fatalf("NewFilesystemRemote() error: %v", err)
+	}
+
+	if err := r.Push(context.Background(), "feature/login", strings.NewReader("data"), 4); err != nil {
+		t.Fatalf("Push() error: %v", err)
+	}
+
+	branches, err := r.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(branches) != 1 || branches[0].Name != "feature/login" {
+		t.Fatalf("List() = %v, want one branch named %q", branches, "feature/login")
+	}
+
+	if exists, err := r.Exists(context.Background(), branches[0].Name); err != nil || !exists {
+		t.Errorf("Exists(%q) = %v, %v; want true, nil", branches[0].Name, exists, err)
+	}
+}
+
 func TestParseURL_Filesystem(t *testing.T) {
 	cfg, err := ParseURL("local", "/tmp/backups")
 	if err != nil {
@@ -360,3 +412,35 @@ func TestFilesystemRemote_ListNonExistentDir(t *testing.T) {
 		t.Errorf("List() returned %d branches, want 0", len(branches))
 	}
 }
+
+func TestFilesystemRemote_Test(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("writable directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "snapshots")
+		r := &FilesystemRemote{name: "test", path: dir}
+
+		if err := r.Test(ctx); err != nil {
+			t.Fatalf("Test() error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ProbeFileName)); !os.IsNotExist(err) {
+			t.Errorf("probe file left behind: %v", err)
+		}
+	})
+
+	t.Run("read-only directory", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("permissions are not enforced for root")
+		}
+		dir := t.TempDir()
+		if err := os.Chmod(dir, 0555); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(dir, 0755)
+
+		r := &FilesystemRemote{name: "test", path: dir}
+		if err := r.Test(ctx); err == nil {
+			t.Errorf("Test() expected error for read-only directory, got nil")
+		}
+	})
+}
@@ -7,10 +7,13 @@ import (
 	"io"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	awscreds "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/le-vlad/pgbranch/internal/credentials"
 )
@@ -21,8 +24,20 @@ type s3API interface {
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
 }
 
+// multipartThreshold is the archive size above which Push streams the
+// archive with a multipart upload instead of a single PutObject.
+const multipartThreshold = 8 * 1024 * 1024
+
+// defaultPartSize is the multipart part size used when the remote has no
+// part_size option.
+const defaultPartSize = manager.DefaultUploadPartSize
+
 func init() {
 	Register("s3", NewS3Remote)
 	Register("r2", NewS3Remote) // R2 is S3-compatible
@@ -33,6 +48,7 @@ type S3Remote struct {
 	remoteType string // "s3" or "r2"
 	bucket     string
 	prefix     string
+	partSize   int64
 	client     s3API
 }
 
@@ -48,6 +64,11 @@ func NewS3Remote(cfg *Config) (Remote, error) {
 		remoteType = "s3"
 	}
 
+	partSize, err := parsePartSize(cfg.Options["part_size"])
+	if err != nil {
+		return nil, err
+	}
+
 	ctx := context.Background()
 	awsCfg, err := loadAWSConfig(ctx, cfg.Options, remoteType)
 	if err != nil {
@@ -61,10 +82,29 @@ func NewS3Remote(cfg *Config) (Remote, error) {
 		remoteType: remoteType,
 		bucket:     bucket,
 		prefix:     prefix,
+		partSize:   partSize,
 		client:     client,
 	}, nil
 }
 
+// parsePartSize parses the part_size option, given in bytes. An empty value
+// selects the default part size.
+func parsePartSize(value string) (int64, error) {
+	if value == "" {
+		return defaultPartSize, nil
+	}
+
+	partSize, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid part_size %q: %w", value, err)
+	}
+	if partSize < manager.MinUploadPartSize {
+		return 0, fmt.Errorf("invalid part_size %d: must be at least %d bytes", partSize, manager.MinUploadPartSize)
+	}
+
+	return partSize, nil
+}
+
 func loadAWSConfig(ctx context.Context, options map[string]string, remoteType string) (aws.Config, error) {
 	var optFns []func(*config.LoadOptions) error
 
@@ -127,18 +167,40 @@ func (r *S3Remote) objectKey(branchName string) string {
 func (r *S3Remote) Push(ctx context.Context, branchName string, reader io.Reader, size int64) error {
 	key := r.objectKey(branchName)
 
-	// TODO: For large files, use multipart upload
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return fmt.Errorf("failed to read archive data: %w", err)
+	if size >= 0 && size < multipartThreshold {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read archive data: %w", err)
+		}
+
+		_, err = r.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(r.bucket),
+			Key:           aws.String(key),
+			Body:          bytes.NewReader(data),
+			ContentLength: aws.Int64(int64(len(data))),
+			ContentType:   aws.String("application/x-pgbranch"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload to S3: %w", err)
+		}
+
+		return nil
 	}
 
-	_, err = r.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:        aws.String(r.bucket),
-		Key:           aws.String(key),
-		Body:          bytes.NewReader(data),
-		ContentLength: aws.Int64(int64(len(data))),
-		ContentType:   aws.String("application/x-pgbranch"),
+	partSize := r.partSize
+	if partSize == 0 {
+		partSize = defaultPartSize
+	}
+
+	uploader := manager.NewUploader(r.client, func(u *manager.Uploader) {
+		u.PartSize = partSize
+	})
+
+	_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		Body:        reader,
+		ContentType: aws.String("application/x-pgbranch"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
@@ -179,6 +241,7 @@ func (r *S3Remote) List(ctx context.Context) ([]RemoteBranch, error) {
 		input := &s3.ListObjectsV2Input{
 			Bucket:            aws.String(r.bucket),
 			Prefix:            aws.String(prefix),
+			Delimiter:         aws.String("/"),
 			ContinuationToken: continuationToken,
 		}
 
@@ -188,7 +251,7 @@ func (r *S3Remote) List(ctx context.Context) ([]RemoteBranch, error) {
 		}
 
 		for _, obj := range output.Contents {
-			if obj.Key == nil {
+			if obj.Key == nil || !isDirectChild(prefix, *obj.Key) {
 				continue
 			}
 
@@ -216,7 +279,7 @@ func (r *S3Remote) List(ctx context.Context) ([]RemoteBranch, error) {
 			branches = append(branches, branch)
 		}
 
-		if !aws.ToBool(output.IsTruncated) {
+		if !aws.ToBool(output.IsTruncated) || output.NextContinuationToken == nil {
 			break
 		}
 		continuationToken = output.NextContinuationToken
@@ -255,13 +318,36 @@ func (r *S3Remote) Exists(ctx context.Context, branchName string) (bool, error)
 	return true, nil
 }
 
-func isArchiveFile(filename string) bool {
-	return len(filename) > 9 && filename[len(filename)-9:] == ".pgbranch"
-}
+func (r *S3Remote) Test(ctx context.Context) error {
+	key := ProbeFileName
+	if r.prefix != "" {
+		key = path.Join(r.prefix, ProbeFileName)
+	}
 
-func archiveNameToBranch(filename string) string {
-	if len(filename) <= 9 {
-		return ""
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(r.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(probeData),
+		ContentLength: aws.Int64(int64(len(probeData))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write probe object to bucket %s: %w", r.bucket, err)
 	}
-	return filename[:len(filename)-9]
+
+	_, err = r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete probe object from bucket %s: %w", r.bucket, err)
+	}
+
+	return nil
+}
+
+// isDirectChild reports whether key sits directly under prefix rather than
+// in a nested prefix below it. Branches pushed under another prefix in the
+// same bucket must not show up in this remote's listing.
+func isDirectChild(prefix, key string) bool {
+	return strings.HasPrefix(key, prefix) && !strings.Contains(key[len(prefix):], "/")
 }
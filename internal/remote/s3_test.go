@@ -5,6 +5,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -18,6 +21,11 @@ type mockS3Client struct {
 	headObjectFn    func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	deleteObjectFn  func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 	listObjectsV2Fn func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+
+	createMultipartUploadFn   func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	uploadPartFn              func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	completeMultipartUploadFn func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	abortMultipartUploadFn    func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
 }
 
 func (m *mockS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
@@ -40,6 +48,22 @@ func (m *mockS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjects
 	return m.listObjectsV2Fn(ctx, params, optFns...)
 }
 
+func (m *mockS3Client) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return m.createMultipartUploadFn(ctx, params, optFns...)
+}
+
+func (m *mockS3Client) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return m.uploadPartFn(ctx, params, optFns...)
+}
+
+func (m *mockS3Client) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return m.completeMultipartUploadFn(ctx, params, optFns...)
+}
+
+func (m *mockS3Client) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return m.abortMultipartUploadFn(ctx, params, optFns...)
+}
+
 func newTestS3Remote(mock s3API, bucket, prefix string) *S3Remote {
 	return &S3Remote{name: "test", remoteType: "s3", bucket: bucket, prefix: prefix, client: mock}
 }
@@ -105,6 +129,123 @@ func TestS3Remote_Push_Error(t *testing.T) {
 	}
 }
 
+// patternReader produces n bytes of synthetic data without allocating.
+type patternReader struct {
+	remaining int64
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	for i := range p {
+		p[i] = byte(i)
+	}
+	r.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+func TestS3Remote_Push_Multipart(t *testing.T) {
+	const size = 128 * 1024 * 1024
+
+	var (
+		mu         sync.Mutex
+		uploaded   int64
+		parts      int
+		peakHeap   uint64
+		completed  bool
+		putObjects int
+	)
+
+	mock := &mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			putObjects++
+			return &s3.PutObjectOutput{}, nil
+		},
+		createMultipartUploadFn: func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			if aws.ToString(params.ContentType) != "application/x-pgbranch" {
+				t.Errorf("ContentType = %q, want %q", aws.ToString(params.ContentType), "application/x-pgbranch")
+			}
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+		},
+		uploadPartFn: func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			n, err := io.Copy(io.Discard, params.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+
+			mu.Lock()
+			uploaded += n
+			parts++
+			if m.HeapInuse > peakHeap {
+				peakHeap = m.HeapInuse
+			}
+			mu.Unlock()
+
+			return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", aws.ToInt32(params.PartNumber)))}, nil
+		},
+		completeMultipartUploadFn: func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			completed = true
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+		abortMultipartUploadFn: func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+			t.Errorf("AbortMultipartUpload called unexpectedly")
+			return &s3.AbortMultipartUploadOutput{}, nil
+		},
+	}
+	r := newTestS3Remote(mock, "bucket", "")
+	r.partSize = 5 * 1024 * 1024
+
+	runtime.GC()
+
+	err := r.Push(context.Background(), "big", &patternReader{remaining: size}, size)
+	if err != nil {
+		t.Fatalf("Push() unexpected error: %v", err)
+	}
+
+	if putObjects != 0 {
+		t.Errorf("PutObject called %d times, want 0", putObjects)
+	}
+	if !completed {
+		t.Errorf("CompleteMultipartUpload was not called")
+	}
+	if uploaded != size {
+		t.Errorf("uploaded %d bytes, want %d", uploaded, size)
+	}
+	if wantParts := (size + r.partSize - 1) / r.partSize; int64(parts) != wantParts {
+		t.Errorf("uploaded %d parts, want %d", parts, wantParts)
+	}
+	if peakHeap >= size/2 {
+		t.Errorf("peak heap in use = %d bytes, want well below archive size %d", peakHeap, size)
+	}
+}
+
+func TestParsePartSize(t *testing.T) {
+	got, err := parsePartSize("")
+	if err != nil || got != defaultPartSize {
+		t.Errorf("parsePartSize(\"\") = %d, %v; want %d, nil", got, err, defaultPartSize)
+	}
+
+	got, err = parsePartSize("16777216")
+	if err != nil || got != 16777216 {
+		t.Errorf("parsePartSize(\"16777216\") = %d, %v; want 16777216, nil", got, err)
+	}
+
+	if _, err := parsePartSize("1024"); err == nil {
+		t.Errorf("parsePartSize(\"1024\") expected error for part size below minimum")
+	}
+
+	if _, err := parsePartSize("big"); err == nil {
+		t.Errorf("parsePartSize(\"big\") expected error for non-numeric value")
+	}
+}
+
 func TestS3Remote_Pull_Success(t *testing.T) {
 	payload := []byte("restored-data-here")
 	mock := &mockS3Client{
@@ -228,6 +369,82 @@ func TestS3Remote_List_Pagination(t *testing.T) {
 	}
 }
 
+func TestS3Remote_List_SeparatePrefixes(t *testing.T) {
+	keys := []string{
+		"team-a/main.pgbranch",
+		"team-a/nested/stale.pgbranch",
+		"team-b/main.pgbranch",
+		"team-b/dev.pgbranch",
+		"root.pgbranch",
+	}
+	mock := &mockS3Client{
+		listObjectsV2Fn: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			if aws.ToString(params.Delimiter) != "/" {
+				t.Errorf("Delimiter = %q, want %q", aws.ToString(params.Delimiter), "/")
+			}
+			// Return every key under the prefix, ignoring the delimiter, so
+			// List has to filter nested keys itself.
+			var contents []s3types.Object
+			for _, key := range keys {
+				if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+					contents = append(contents, s3types.Object{Key: aws.String(key)})
+				}
+			}
+			return &s3.ListObjectsV2Output{Contents: contents, IsTruncated: aws.Bool(false)}, nil
+		},
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "team-a", want: []string{"main"}},
+		{prefix: "team-b/", want: []string{"main", "dev"}},
+		{prefix: "", want: []string{"root"}},
+	}
+
+	for _, tt := range tests {
+		r := newTestS3Remote(mock, "bucket", tt.prefix)
+
+		branches, err := r.List(context.Background())
+		if err != nil {
+			t.Fatalf("List() with prefix %q unexpected error: %v", tt.prefix, err)
+		}
+		var names []string
+		for _, b := range branches {
+			names = append(names, b.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("List() with prefix %q = %v, want %v", tt.prefix, names, tt.want)
+		}
+	}
+}
+
+func TestS3Remote_List_TruncatedWithoutToken(t *testing.T) {
+	callCount := 0
+	mock := &mockS3Client{
+		listObjectsV2Fn: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			callCount++
+			if callCount > 1 {
+				t.Fatalf("ListObjectsV2 called again without a continuation token")
+			}
+			return &s3.ListObjectsV2Output{
+				Contents:    []s3types.Object{{Key: aws.String("main.pgbranch")}},
+				IsTruncated: aws.Bool(true),
+			}, nil
+		},
+	}
+	r := newTestS3Remote(mock, "bucket", "")
+
+	branches, err := r.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(branches) != 1 {
+		t.Errorf("List() returned %d branches, want 1", len(branches))
+	}
+}
+
 func TestS3Remote_List_Empty(t *testing.T) {
 	mock := &mockS3Client{
 		listObjectsV2Fn: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
@@ -360,6 +577,8 @@ func TestArchiveNameToBranch(t *testing.T) {
 	}{
 		{"main.pgbranch", "main"},
 		{"feature_bar.pgbranch", "feature_bar"},
+		{"feature%2Fbar.pgbranch", "feature/bar"},
+		{"bad%zz.pgbranch", "bad%zz"},
 		{".pgbranch", ""},
 		{"short", ""},
 		{"", ""},
@@ -372,3 +591,43 @@ func TestArchiveNameToBranch(t *testing.T) {
 		})
 	}
 }
+
+func TestS3Remote_Test(t *testing.T) {
+	t.Run("writes and deletes probe", func(t *testing.T) {
+		var putKey, deleteKey string
+		mock := &mockS3Client{
+			putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				putKey = aws.ToString(params.Key)
+				return &s3.PutObjectOutput{}, nil
+			},
+			deleteObjectFn: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				deleteKey = aws.ToString(params.Key)
+				return &s3.DeleteObjectOutput{}, nil
+			},
+		}
+		r := newTestS3Remote(mock, "bucket", "pfx")
+
+		if err := r.Test(context.Background()); err != nil {
+			t.Fatalf("Test() unexpected error: %v", err)
+		}
+		if putKey != "pfx/"+ProbeFileName {
+			t.Errorf("put key = %q, want %q", putKey, "pfx/"+ProbeFileName)
+		}
+		if deleteKey != putKey {
+			t.Errorf("delete key = %q, want %q", deleteKey, putKey)
+		}
+	})
+
+	t.Run("put error", func(t *testing.T) {
+		mock := &mockS3Client{
+			putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				return nil, fmt.Errorf("access denied")
+			},
+		}
+		r := newTestS3Remote(mock, "bucket", "")
+
+		if err := r.Test(context.Background()); err == nil {
+			t.Fatalf("Test() expected error, got nil")
+		}
+	})
+}
@@ -3,6 +3,7 @@ package schema
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -40,6 +41,8 @@ func (r *ApplyResult) Success() bool {
 
 // Apply executes all changes in the ChangeSet.
 // Changes are applied in a transaction that is rolled back if any change fails.
+// Changes that can't run in a transaction block, such as added enum values,
+// are applied before it and stay applied.
 func (a *Applier) Apply(ctx context.Context, cs *ChangeSet) (*ApplyResult, error) {
 	result := &ApplyResult{
 		Applied: make([]Change, 0, len(cs.Changes)),
@@ -50,13 +53,27 @@ func (a *Applier) Apply(ctx context.Context, cs *ChangeSet) (*ApplyResult, error
 		return result, nil
 	}
 
+	outside, inside := a.generator.SplitTransactional(cs)
+	for _, change := range outside.Changes {
+		sql := a.generator.GenerateChange(change)
+		if _, err := a.conn.Exec(ctx, sql); err != nil {
+			result.Failed = append(result.Failed, ChangeError{
+				Change: change,
+				SQL:    sql,
+				Error:  err,
+			})
+			return result, fmt.Errorf("failed to apply change: %w", err)
+		}
+		result.Applied = append(result.Applied, change)
+	}
+
 	tx, err := a.conn.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	for _, change := range cs.Changes {
+	for _, change := range inside.Changes {
 		sql := a.generator.GenerateChange(change)
 		if sql == "" {
 			continue
@@ -132,37 +149,59 @@ func OrderChanges(cs *ChangeSet) *ChangeSet {
 	ordered := NewChangeSet()
 
 	// Order of operations:
-	// 1. Create enums (tables may depend on them)
-	// 2. Add enum values
-	// 3. Create tables
-	// 4. Add columns
-	// 5. Create indexes
-	// 6. Add constraints
-	// 7. Create/replace functions
-	// 8. Drop constraints (before dropping columns)
-	// 9. Drop indexes
-	// 10. Alter columns
-	// 11. Drop columns
-	// 12. Drop tables
-	// 13. Drop enums
-	// 14. Drop functions
+	// 1. Create schemas (everything else may live in them)
+	// 2. Create enums (tables may depend on them)
+	// 3. Add enum values
+	// 4. Drop or reorder enum values by recreating the type (before new tables and columns use it)
+	// 5. Create sequences (column defaults may use them)
+	// 6. Create tables
+	// 7. Rename columns (before adds, which may reuse an old name)
+	// 8. Add columns
+	// 9. Drop indexes (before creating, so redefined indexes can reuse the name)
+	// 10. Create indexes
+	// 11. Add constraints
+	// 12. Create/replace functions
+	// 13. Drop views (before the columns and tables they select from change)
+	// 14. Drop constraints (before dropping columns)
+	// 15. Alter columns
+	// 16. Alter sequences (after their owning columns exist)
+	// 17. Create/replace views (once the tables and functions they use exist)
+	// 18. Set comments
+	// 19. Drop columns
+	// 20. Drop tables
+	// 21. Drop sequences (once no column default uses them)
+	// 22. Drop enums
+	// 23. Drop functions
+	// 24. Drop schemas (once everything in them is gone)
 
 	order := []ChangeType{
+		ChangeCreateSchema,
 		ChangeCreateEnum,
 		ChangeAddEnumValue,
+		ChangeDropEnumValue,
+		ChangeReorderEnum,
+		ChangeCreateSequence,
 		ChangeCreateTable,
+		ChangeRenameColumn,
 		ChangeAddColumn,
+		ChangeDropIndex,
 		ChangeCreateIndex,
 		ChangeAddConstraint,
 		ChangeCreateFunction,
 		ChangeReplaceFunction,
+		ChangeDropView,
 		ChangeDropConstraint,
-		ChangeDropIndex,
 		ChangeAlterColumn,
+		ChangeAlterSequence,
+		ChangeCreateView,
+		ChangeReplaceView,
+		ChangeComment,
 		ChangeDropColumn,
 		ChangeDropTable,
+		ChangeDropSequence,
 		ChangeDropEnum,
 		ChangeDropFunction,
+		ChangeDropSchema,
 	}
 
 	for _, ct := range order {
@@ -189,19 +228,44 @@ func ValidateChanges(cs *ChangeSet) (warnings []string, errors []string) {
 						fmt.Sprintf("Changing %s from %s to %s may lose precision",
 							change.ObjectName(), oldType, newType))
 				}
+				using := usingExpression(change.ColumnName, change.Alteration)
 				if isStringType(oldType) && isNumericType(newType) {
 					errors = append(errors,
-						fmt.Sprintf("Changing %s from %s to %s may fail if data cannot be converted",
-							change.ObjectName(), oldType, newType))
+						fmt.Sprintf("Changing %s from %s to %s (USING %s) may fail if data cannot be converted",
+							change.ObjectName(), oldType, newType, using))
+				} else if change.Alteration.Using == "" && using != "" {
+					warnings = append(warnings,
+						fmt.Sprintf("Changing %s from %s to %s has no implicit cast; converting with USING %s",
+							change.ObjectName(), oldType, newType, using))
 				}
 			}
 
+			if change.Alteration.GeneratedChanged && change.Alteration.NewGenerated != "" {
+				warnings = append(warnings,
+					fmt.Sprintf("Making %s generated, or changing its expression before PostgreSQL 17, drops and adds the column again; indexes on it are lost and views using it make this fail",
+						change.ObjectName()))
+			}
+
 			if change.Alteration.NullableChanged && !change.Alteration.NewNullable {
 				warnings = append(warnings,
 					fmt.Sprintf("Setting %s to NOT NULL may fail if column contains NULL values",
 						change.ObjectName()))
 			}
 
+		case *AddColumnChange:
+			if !change.Column.IsNullable && change.Column.DefaultValue == nil && change.Column.Identity == "" && change.Column.Generated == "" {
+				errors = append(errors,
+					fmt.Sprintf("Adding NOT NULL column %s without a default will fail if %s has any rows; add a DEFAULT or make the column nullable first",
+						change.ObjectName(), change.TableName))
+			}
+
+		case *ReplaceViewChange:
+			if !change.OldView.Materialized && !change.NewView.Materialized {
+				warnings = append(warnings,
+					fmt.Sprintf("Replacing view %s will fail if the new definition removes, renames or retypes any of its columns",
+						change.ObjectName()))
+			}
+
 		case *DropColumnChange:
 			warnings = append(warnings,
 				fmt.Sprintf("Dropping column %s will permanently delete all data in that column",
@@ -211,6 +275,16 @@ func ValidateChanges(cs *ChangeSet) (warnings []string, errors []string) {
 			warnings = append(warnings,
 				fmt.Sprintf("Dropping table %s will permanently delete all data in that table",
 					change.ObjectName()))
+
+		case *DropEnumValueChange:
+			warnings = append(warnings,
+				fmt.Sprintf("Dropping values from enum %s recreates the type and rewrites %d column(s) under a lock; it fails while rows hold a dropped value or views use those columns",
+					change.ObjectName(), len(change.Columns)))
+
+		case *ReorderEnumChange:
+			warnings = append(warnings,
+				fmt.Sprintf("Reordering enum %s recreates the type and rewrites %d column(s) under a lock; it fails while views use those columns",
+					change.ObjectName(), len(change.Columns)))
 		}
 	}
 
@@ -218,9 +292,10 @@ func ValidateChanges(cs *ChangeSet) (warnings []string, errors []string) {
 }
 
 func isNumericType(t string) bool {
-	numericTypes := []string{"integer", "int", "bigint", "smallint", "decimal", "numeric", "real", "double"}
+	t = baseTypeName(t)
+	numericTypes := []string{"integer", "int", "bigint", "smallint", "decimal", "numeric", "real", "double", "double precision"}
 	for _, nt := range numericTypes {
-		if t == nt || fmt.Sprintf("%s", t) == nt {
+		if t == nt {
 			return true
 		}
 	}
@@ -228,5 +303,34 @@ func isNumericType(t string) bool {
 }
 
 func isStringType(t string) bool {
+	t = baseTypeName(t)
 	return t == "text" || t == "varchar" || t == "character varying" || t == "char" || t == "character"
 }
+
+func isBooleanType(t string) bool {
+	t = baseTypeName(t)
+	return t == "boolean" || t == "bool"
+}
+
+// baseTypeName strips a type's modifier, so varchar(255) becomes varchar.
+func baseTypeName(t string) string {
+	if i := strings.Index(t, "("); i >= 0 {
+		t = t[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// needsExplicitCast reports whether PostgreSQL has no implicit conversion
+// from oldType to newType, so changing a column's type needs a USING
+// clause: from text to anything but text, and between booleans and numbers.
+// Array types are left alone.
+func needsExplicitCast(oldType, newType string) bool {
+	if oldType == "" || strings.HasSuffix(oldType, "[]") || strings.HasSuffix(newType, "[]") {
+		return false
+	}
+	if isStringType(oldType) {
+		return !isStringType(newType)
+	}
+	return (isBooleanType(oldType) && isNumericType(newType)) ||
+		(isNumericType(oldType) && isBooleanType(newType))
+}
@@ -1,19 +1,29 @@
 package schema
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // ChangeType represents the type of schema change.
 type ChangeType string
 
 const (
+	// Schema changes
+	ChangeCreateSchema ChangeType = "CREATE_SCHEMA"
+	ChangeDropSchema   ChangeType = "DROP_SCHEMA"
+
 	// Table changes
 	ChangeCreateTable ChangeType = "CREATE_TABLE"
 	ChangeDropTable   ChangeType = "DROP_TABLE"
 
 	// Column changes
-	ChangeAddColumn   ChangeType = "ADD_COLUMN"
-	ChangeDropColumn  ChangeType = "DROP_COLUMN"
-	ChangeAlterColumn ChangeType = "ALTER_COLUMN"
+	ChangeAddColumn    ChangeType = "ADD_COLUMN"
+	ChangeDropColumn   ChangeType = "DROP_COLUMN"
+	ChangeAlterColumn  ChangeType = "ALTER_COLUMN"
+	ChangeRenameColumn ChangeType = "RENAME_COLUMN"
 
 	// Index changes
 	ChangeCreateIndex ChangeType = "CREATE_INDEX"
@@ -24,14 +34,29 @@ const (
 	ChangeDropConstraint ChangeType = "DROP_CONSTRAINT"
 
 	// Enum changes
-	ChangeCreateEnum   ChangeType = "CREATE_ENUM"
-	ChangeDropEnum     ChangeType = "DROP_ENUM"
-	ChangeAddEnumValue ChangeType = "ADD_ENUM_VALUE"
+	ChangeCreateEnum    ChangeType = "CREATE_ENUM"
+	ChangeDropEnum      ChangeType = "DROP_ENUM"
+	ChangeAddEnumValue  ChangeType = "ADD_ENUM_VALUE"
+	ChangeDropEnumValue ChangeType = "DROP_ENUM_VALUE"
+	ChangeReorderEnum   ChangeType = "REORDER_ENUM"
+
+	// Sequence changes
+	ChangeCreateSequence ChangeType = "CREATE_SEQUENCE"
+	ChangeDropSequence   ChangeType = "DROP_SEQUENCE"
+	ChangeAlterSequence  ChangeType = "ALTER_SEQUENCE"
 
 	// Function changes
 	ChangeCreateFunction  ChangeType = "CREATE_FUNCTION"
 	ChangeDropFunction    ChangeType = "DROP_FUNCTION"
 	ChangeReplaceFunction ChangeType = "REPLACE_FUNCTION"
+
+	// View changes
+	ChangeCreateView  ChangeType = "CREATE_VIEW"
+	ChangeDropView    ChangeType = "DROP_VIEW"
+	ChangeReplaceView ChangeType = "REPLACE_VIEW"
+
+	// Comment changes
+	ChangeComment ChangeType = "COMMENT"
 )
 
 // Change represents a single schema change.
@@ -86,6 +111,127 @@ func (cs *ChangeSet) DestructiveCount() int {
 	return count
 }
 
+// SetUsing sets the USING expression for the type change of column, named
+// table.column as in AlterColumnChange.ObjectName.
+func (cs *ChangeSet) SetUsing(column, expr string) error {
+	for _, c := range cs.Changes {
+		alter, ok := c.(*AlterColumnChange)
+		if ok && alter.Alteration.TypeChanged && alter.ObjectName() == column {
+			alter.Alteration.Using = expr
+			return nil
+		}
+	}
+	return fmt.Errorf("no type change for column '%s'", column)
+}
+
+// RenameCandidate is a dropped and an added column of one table that may be
+// a single renamed column.
+type RenameCandidate struct {
+	TableName string
+	OldName   string
+	NewName   string
+}
+
+func (r RenameCandidate) String() string {
+	return fmt.Sprintf("%s.%s → %s", r.TableName, r.OldName, r.NewName)
+}
+
+// RenameCandidates returns the tables where exactly one column was dropped
+// and exactly one added, with the same type, nullability, default and
+// identity. Diff reports these as a drop and an add, which loses the
+// column's data; RenameColumn turns them into a rename. Tables with more
+// than one dropped or added column are left out, since the pairing would
+// be a guess.
+func (cs *ChangeSet) RenameCandidates() []RenameCandidate {
+	drops := make(map[string][]*DropColumnChange)
+	adds := make(map[string][]*AddColumnChange)
+	for _, c := range cs.Changes {
+		switch change := c.(type) {
+		case *DropColumnChange:
+			drops[change.TableName] = append(drops[change.TableName], change)
+		case *AddColumnChange:
+			adds[change.TableName] = append(adds[change.TableName], change)
+		}
+	}
+
+	var candidates []RenameCandidate
+	for table, dropped := range drops {
+		added := adds[table]
+		if len(dropped) != 1 || len(added) != 1 {
+			continue
+		}
+		if !dropped[0].Column.sameDefinition(added[0].Column) {
+			continue
+		}
+		candidates = append(candidates, RenameCandidate{
+			TableName: table,
+			OldName:   dropped[0].Column.Name,
+			NewName:   added[0].Column.Name,
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].TableName < candidates[j].TableName
+	})
+	return candidates
+}
+
+// RenameColumn replaces the drop of column, named table.column as in
+// DropColumnChange.ObjectName, and the add of newName in the same table
+// with a RenameColumnChange. Both columns must have the same definition.
+func (cs *ChangeSet) RenameColumn(column, newName string) error {
+	dropIndex := -1
+	for i, c := range cs.Changes {
+		if drop, ok := c.(*DropColumnChange); ok && drop.ObjectName() == column {
+			dropIndex = i
+			break
+		}
+	}
+	if dropIndex < 0 {
+		return fmt.Errorf("no dropped column '%s'", column)
+	}
+	drop := cs.Changes[dropIndex].(*DropColumnChange)
+
+	addIndex := -1
+	for i, c := range cs.Changes {
+		if add, ok := c.(*AddColumnChange); ok && add.TableName == drop.TableName && add.Column.Name == newName {
+			addIndex = i
+			break
+		}
+	}
+	if addIndex < 0 {
+		return fmt.Errorf("no added column '%s' in table %s", newName, drop.TableName)
+	}
+	add := cs.Changes[addIndex].(*AddColumnChange)
+
+	if !drop.Column.sameDefinition(add.Column) {
+		return fmt.Errorf("cannot rename '%s' to '%s': the columns are defined differently", column, newName)
+	}
+
+	cs.Changes[dropIndex] = &RenameColumnChange{
+		TableName: drop.TableName,
+		OldName:   drop.Column.Name,
+		Column:    add.Column,
+	}
+	cs.Changes = append(cs.Changes[:addIndex], cs.Changes[addIndex+1:]...)
+	return nil
+}
+
+// Remove removes the changes of type t from cs and returns them.
+func (cs *ChangeSet) Remove(t ChangeType) []Change {
+	var removed []Change
+	kept := cs.Changes[:0]
+	for _, c := range cs.Changes {
+		if c.Type() == t {
+			removed = append(removed, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	cs.Changes = kept
+	return removed
+}
+
 func (cs *ChangeSet) ByType(t ChangeType) []Change {
 	var result []Change
 	for _, c := range cs.Changes {
@@ -96,6 +242,23 @@ func (cs *ChangeSet) ByType(t ChangeType) []Change {
 	return result
 }
 
+// SortedByType returns the changes of type t sorted by object name. The
+// sort is stable, so changes to the same object, such as values added to
+// one enum, keep their order.
+func (cs *ChangeSet) SortedByType(t ChangeType) []Change {
+	result := cs.ByType(t)
+	sortByObjectName(result)
+	return result
+}
+
+// sortByObjectName sorts changes by object name, keeping the order of
+// changes to the same object.
+func sortByObjectName(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].ObjectName() < changes[j].ObjectName()
+	})
+}
+
 func (cs *ChangeSet) Summary() map[ChangeType]int {
 	summary := make(map[ChangeType]int)
 	for _, c := range cs.Changes {
@@ -104,6 +267,87 @@ func (cs *ChangeSet) Summary() map[ChangeType]int {
 	return summary
 }
 
+// ChangeJSON is the stable JSON representation of a single change.
+type ChangeJSON struct {
+	Type        ChangeType `json:"type"`
+	Object      string     `json:"object"`
+	Description string     `json:"description"`
+	Destructive bool       `json:"destructive"`
+}
+
+// ChangeSetJSON is the stable JSON representation of a change set.
+type ChangeSetJSON struct {
+	Changes     []ChangeJSON       `json:"changes"`
+	Summary     map[ChangeType]int `json:"summary"`
+	Total       int                `json:"total"`
+	Destructive int                `json:"destructive"`
+}
+
+// ToJSON serializes the change set to indented JSON, as built by JSON.
+func (cs *ChangeSet) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(cs.JSON(), "", "  ")
+}
+
+// JSON returns the JSON representation of the change set. Changes are put
+// in application order by OrderChanges and sorted by object name within
+// each change type, so the output is deterministic.
+func (cs *ChangeSet) JSON() ChangeSetJSON {
+	ordered := OrderChanges(cs).Changes
+
+	for start := 0; start < len(ordered); {
+		end := start + 1
+		for end < len(ordered) && ordered[end].Type() == ordered[start].Type() {
+			end++
+		}
+		sortByObjectName(ordered[start:end])
+		start = end
+	}
+
+	out := ChangeSetJSON{
+		Changes:     make([]ChangeJSON, 0, len(ordered)),
+		Summary:     cs.Summary(),
+		Total:       len(ordered),
+		Destructive: cs.DestructiveCount(),
+	}
+
+	for _, c := range ordered {
+		out.Changes = append(out.Changes, ChangeJSON{
+			Type:        c.Type(),
+			Object:      c.ObjectName(),
+			Description: c.Description(),
+			Destructive: c.IsDestructive(),
+		})
+	}
+
+	return out
+}
+
+// CreateSchemaChange creates a PostgreSQL schema (namespace), not to be
+// confused with the Schema describing a whole database.
+type CreateSchemaChange struct {
+	Name string
+}
+
+func (c *CreateSchemaChange) Type() ChangeType    { return ChangeCreateSchema }
+func (c *CreateSchemaChange) IsDestructive() bool { return false }
+func (c *CreateSchemaChange) ObjectName() string  { return c.Name }
+func (c *CreateSchemaChange) Description() string {
+	return fmt.Sprintf("Create schema %s", c.Name)
+}
+
+// DropSchemaChange drops a PostgreSQL schema once the objects in it have
+// been dropped by their own changes.
+type DropSchemaChange struct {
+	Name string
+}
+
+func (c *DropSchemaChange) Type() ChangeType    { return ChangeDropSchema }
+func (c *DropSchemaChange) IsDestructive() bool { return false }
+func (c *DropSchemaChange) ObjectName() string  { return c.Name }
+func (c *DropSchemaChange) Description() string {
+	return fmt.Sprintf("Drop schema %s", c.Name)
+}
+
 type CreateTableChange struct {
 	Table *Table
 }
@@ -154,16 +398,47 @@ func (c *DropColumnChange) Description() string {
 	return fmt.Sprintf("Drop column %s.%s", c.TableName, c.Column.Name)
 }
 
+// RenameColumnChange renames a column, keeping its data. Diff never
+// produces it; see ChangeSet.RenameColumn.
+type RenameColumnChange struct {
+	TableName string
+	OldName   string
+	Column    *Column
+}
+
+func (c *RenameColumnChange) Type() ChangeType    { return ChangeRenameColumn }
+func (c *RenameColumnChange) IsDestructive() bool { return false }
+func (c *RenameColumnChange) ObjectName() string {
+	return fmt.Sprintf("%s.%s", c.TableName, c.Column.Name)
+}
+func (c *RenameColumnChange) Description() string {
+	return fmt.Sprintf("Rename column %s.%s to %s", c.TableName, c.OldName, c.Column.Name)
+}
+
 type ColumnAlteration struct {
-	TypeChanged     bool
-	OldType         string
-	NewType         string
-	NullableChanged bool
-	OldNullable     bool
-	NewNullable     bool
-	DefaultChanged  bool
-	OldDefault      *string
-	NewDefault      *string
+	TypeChanged      bool
+	OldType          string
+	NewType          string
+	NullableChanged  bool
+	OldNullable      bool
+	NewNullable      bool
+	DefaultChanged   bool
+	OldDefault       *string
+	NewDefault       *string
+	IdentityChanged  bool
+	OldIdentity      string
+	NewIdentity      string
+	CollationChanged bool
+	OldCollation     string
+	NewCollation     string
+	GeneratedChanged bool
+	OldGenerated     string
+	NewGenerated     string
+
+	// Using is the expression for the USING clause of a type change. When
+	// empty, a cast to the new type is used if PostgreSQL cannot convert
+	// the column implicitly.
+	Using string
 }
 
 type AlterColumnChange struct {
@@ -182,6 +457,11 @@ func (c *AlterColumnChange) IsDestructive() bool {
 	if c.Alteration.NullableChanged && !c.Alteration.NewNullable {
 		return true
 	}
+	// A regular column is dropped and added again to make it generated,
+	// losing its values.
+	if c.Alteration.GeneratedChanged && c.Alteration.OldGenerated == "" {
+		return true
+	}
 	return false
 }
 func (c *AlterColumnChange) ObjectName() string {
@@ -192,6 +472,17 @@ func (c *AlterColumnChange) Description() string {
 	if c.Alteration.TypeChanged {
 		parts = append(parts, fmt.Sprintf("type %s → %s", c.Alteration.OldType, c.Alteration.NewType))
 	}
+	if c.Alteration.GeneratedChanged {
+		if c.Alteration.NewGenerated == "" {
+			parts = append(parts, "drop generation expression")
+		} else {
+			parts = append(parts, fmt.Sprintf("generated as (%s)", c.Alteration.NewGenerated))
+		}
+	}
+	if c.Alteration.CollationChanged {
+		parts = append(parts, fmt.Sprintf("collation %s → %s",
+			collationLabel(c.Alteration.OldCollation), collationLabel(c.Alteration.NewCollation)))
+	}
 	if c.Alteration.NullableChanged {
 		if c.Alteration.NewNullable {
 			parts = append(parts, "set nullable")
@@ -206,9 +497,27 @@ func (c *AlterColumnChange) Description() string {
 			parts = append(parts, fmt.Sprintf("set default %s", *c.Alteration.NewDefault))
 		}
 	}
+	if c.Alteration.IdentityChanged {
+		switch {
+		case c.Alteration.NewIdentity == "":
+			parts = append(parts, "drop identity")
+		case c.Alteration.OldIdentity == "":
+			parts = append(parts, fmt.Sprintf("add identity (generated %s)", c.Alteration.NewIdentity))
+		default:
+			parts = append(parts, fmt.Sprintf("set generated %s", c.Alteration.NewIdentity))
+		}
+	}
 	return fmt.Sprintf("Alter column %s.%s: %s", c.TableName, c.ColumnName, joinParts(parts))
 }
 
+// collationLabel names a Column.Collation for descriptions.
+func collationLabel(collation string) string {
+	if collation == "" {
+		return "default"
+	}
+	return collation
+}
+
 type CreateIndexChange struct {
 	Index *Index
 }
@@ -299,6 +608,138 @@ func (c *AddEnumValueChange) Description() string {
 	return fmt.Sprintf("Add value '%s' to enum %s", c.Value, c.EnumName)
 }
 
+// DropEnumValueChange removes values from an enum. PostgreSQL cannot drop
+// an enum value, so the type is recreated: the old type is renamed, the
+// new one is created with the remaining values, the columns using it are
+// converted, and the old type is dropped. This rewrites and locks the
+// tables of those columns, and fails while rows hold a removed value.
+type DropEnumValueChange struct {
+	OldEnum *Enum
+	NewEnum *Enum
+
+	// Columns are the columns that use the enum.
+	Columns []EnumColumn
+}
+
+// EnumColumn is a column whose type is an enum, or an array of one.
+type EnumColumn struct {
+	TableName string
+	Column    *Column
+}
+
+func (c *DropEnumValueChange) Type() ChangeType    { return ChangeDropEnumValue }
+func (c *DropEnumValueChange) IsDestructive() bool { return true }
+func (c *DropEnumValueChange) ObjectName() string  { return c.NewEnum.FullName() }
+func (c *DropEnumValueChange) Description() string {
+	quoted := make([]string, 0, len(c.Values()))
+	for _, v := range c.Values() {
+		quoted = append(quoted, "'"+v+"'")
+	}
+	return fmt.Sprintf("Drop value(s) %s from enum %s", strings.Join(quoted, ", "), c.NewEnum.FullName())
+}
+
+// Values returns the values removed from the enum, in their old order.
+func (c *DropEnumValueChange) Values() []string {
+	kept := make(map[string]bool, len(c.NewEnum.Values))
+	for _, v := range c.NewEnum.Values {
+		kept[v] = true
+	}
+	var removed []string
+	for _, v := range c.OldEnum.Values {
+		if !kept[v] {
+			removed = append(removed, v)
+		}
+	}
+	return removed
+}
+
+// ReorderEnumChange puts an enum's values in a new order. Enum values
+// sort in their declared order, and PostgreSQL cannot move a value, so
+// the type is recreated as for DropEnumValueChange.
+type ReorderEnumChange struct {
+	OldEnum *Enum
+	NewEnum *Enum
+
+	// Columns are the columns that use the enum.
+	Columns []EnumColumn
+}
+
+func (c *ReorderEnumChange) Type() ChangeType    { return ChangeReorderEnum }
+func (c *ReorderEnumChange) IsDestructive() bool { return false }
+func (c *ReorderEnumChange) ObjectName() string  { return c.NewEnum.FullName() }
+func (c *ReorderEnumChange) Description() string {
+	return fmt.Sprintf("Reorder values of enum %s to (%s)", c.NewEnum.FullName(), strings.Join(c.NewEnum.Values, ", "))
+}
+
+// CreateSequenceChange creates a sequence. Ownership is left to a
+// following AlterSequenceChange, since the owning column may not exist yet
+// when the sequence is created.
+type CreateSequenceChange struct {
+	Sequence *Sequence
+}
+
+func (c *CreateSequenceChange) Type() ChangeType    { return ChangeCreateSequence }
+func (c *CreateSequenceChange) IsDestructive() bool { return false }
+func (c *CreateSequenceChange) ObjectName() string  { return c.Sequence.FullName() }
+func (c *CreateSequenceChange) Description() string {
+	return fmt.Sprintf("Create sequence %s", c.Sequence.FullName())
+}
+
+type DropSequenceChange struct {
+	Sequence *Sequence
+}
+
+func (c *DropSequenceChange) Type() ChangeType    { return ChangeDropSequence }
+func (c *DropSequenceChange) IsDestructive() bool { return false }
+func (c *DropSequenceChange) ObjectName() string  { return c.Sequence.FullName() }
+func (c *DropSequenceChange) Description() string {
+	return fmt.Sprintf("Drop sequence %s", c.Sequence.FullName())
+}
+
+type AlterSequenceChange struct {
+	OldSequence *Sequence
+	NewSequence *Sequence
+}
+
+func (c *AlterSequenceChange) Type() ChangeType    { return ChangeAlterSequence }
+func (c *AlterSequenceChange) IsDestructive() bool { return false }
+func (c *AlterSequenceChange) ObjectName() string  { return c.NewSequence.FullName() }
+func (c *AlterSequenceChange) Description() string {
+	from, to := c.OldSequence, c.NewSequence
+
+	var parts []string
+	if from.DataType != to.DataType {
+		parts = append(parts, fmt.Sprintf("type %s → %s", from.DataType, to.DataType))
+	}
+	if from.Increment != to.Increment {
+		parts = append(parts, fmt.Sprintf("increment %d → %d", from.Increment, to.Increment))
+	}
+	if from.MinValue != to.MinValue || from.MaxValue != to.MaxValue {
+		parts = append(parts, fmt.Sprintf("range %d..%d", to.MinValue, to.MaxValue))
+	}
+	if from.Start != to.Start {
+		parts = append(parts, fmt.Sprintf("start %d → %d", from.Start, to.Start))
+	}
+	if from.Cache != to.Cache {
+		parts = append(parts, fmt.Sprintf("cache %d → %d", from.Cache, to.Cache))
+	}
+	if from.Cycle != to.Cycle {
+		if to.Cycle {
+			parts = append(parts, "cycle")
+		} else {
+			parts = append(parts, "no cycle")
+		}
+	}
+	if from.OwnedBy() != to.OwnedBy() {
+		if to.OwnedBy() == "" {
+			parts = append(parts, "owned by none")
+		} else {
+			parts = append(parts, fmt.Sprintf("owned by %s", to.OwnedBy()))
+		}
+	}
+	return fmt.Sprintf("Alter sequence %s: %s", to.FullName(), joinParts(parts))
+}
+
 type CreateFunctionChange struct {
 	Function *Function
 }
@@ -333,6 +774,75 @@ func (c *ReplaceFunctionChange) Description() string {
 	return fmt.Sprintf("Replace function %s", c.NewFunction.Signature())
 }
 
+type CreateViewChange struct {
+	View *View
+}
+
+func (c *CreateViewChange) Type() ChangeType    { return ChangeCreateView }
+func (c *CreateViewChange) IsDestructive() bool { return false }
+func (c *CreateViewChange) ObjectName() string  { return c.View.FullName() }
+func (c *CreateViewChange) Description() string {
+	return fmt.Sprintf("Create %s %s", viewKind(c.View), c.View.FullName())
+}
+
+type DropViewChange struct {
+	View *View
+}
+
+func (c *DropViewChange) Type() ChangeType    { return ChangeDropView }
+func (c *DropViewChange) IsDestructive() bool { return false } // Views can be recreated
+func (c *DropViewChange) ObjectName() string  { return c.View.FullName() }
+func (c *DropViewChange) Description() string {
+	return fmt.Sprintf("Drop %s %s", viewKind(c.View), c.View.FullName())
+}
+
+type ReplaceViewChange struct {
+	OldView *View
+	NewView *View
+}
+
+func (c *ReplaceViewChange) Type() ChangeType    { return ChangeReplaceView }
+func (c *ReplaceViewChange) IsDestructive() bool { return false }
+func (c *ReplaceViewChange) ObjectName() string  { return c.NewView.FullName() }
+func (c *ReplaceViewChange) Description() string {
+	return fmt.Sprintf("Replace %s %s", viewKind(c.NewView), c.NewView.FullName())
+}
+
+func viewKind(v *View) string {
+	if v.Materialized {
+		return "materialized view"
+	}
+	return "view"
+}
+
+// CommentChange sets or removes the comment on a table or column. An empty
+// ColumnName means the comment belongs to the table itself.
+type CommentChange struct {
+	TableName  string
+	ColumnName string
+	OldComment string
+	NewComment string
+}
+
+func (c *CommentChange) Type() ChangeType    { return ChangeComment }
+func (c *CommentChange) IsDestructive() bool { return false }
+func (c *CommentChange) ObjectName() string {
+	if c.ColumnName == "" {
+		return c.TableName
+	}
+	return fmt.Sprintf("%s.%s", c.TableName, c.ColumnName)
+}
+func (c *CommentChange) Description() string {
+	kind := "column"
+	if c.ColumnName == "" {
+		kind = "table"
+	}
+	if c.NewComment == "" {
+		return fmt.Sprintf("Remove comment on %s %s", kind, c.ObjectName())
+	}
+	return fmt.Sprintf("Set comment on %s %s", kind, c.ObjectName())
+}
+
 func joinParts(parts []string) string {
 	if len(parts) == 0 {
 		return ""
@@ -1,19 +1,49 @@
 package schema
 
+import "sort"
+
 // Diff compares two schemas and returns a ChangeSet representing
 // the changes needed to transform 'from' into 'to'.
 func Diff(from, to *Schema) *ChangeSet {
 	cs := NewChangeSet()
 
+	diffNamespaces(from, to, cs)
+
 	diffEnums(from, to, cs)
 
+	diffSequences(from, to, cs)
+
 	diffTables(from, to, cs)
 
 	diffFunctions(from, to, cs)
 
+	diffViews(from, to, cs)
+
 	return cs
 }
 
+func diffNamespaces(from, to *Schema, cs *ChangeSet) {
+	inFrom := make(map[string]bool, len(from.Namespaces))
+	for _, name := range from.Namespaces {
+		inFrom[name] = true
+	}
+	inTo := make(map[string]bool, len(to.Namespaces))
+	for _, name := range to.Namespaces {
+		inTo[name] = true
+	}
+
+	for _, name := range from.Namespaces {
+		if !inTo[name] {
+			cs.Add(&DropSchemaChange{Name: name})
+		}
+	}
+	for _, name := range to.Namespaces {
+		if !inFrom[name] {
+			cs.Add(&CreateSchemaChange{Name: name})
+		}
+	}
+}
+
 func diffEnums(from, to *Schema, cs *ChangeSet) {
 	for name, fromEnum := range from.Enums {
 		if _, exists := to.Enums[name]; !exists {
@@ -29,9 +59,66 @@ func diffEnums(from, to *Schema, cs *ChangeSet) {
 		}
 
 		diffEnumValues(fromEnum, toEnum, cs)
+
+		// Recreating the type for dropped values also puts the rest in
+		// the new order, so a reorder only needs its own change without
+		// drops.
+		drop := &DropEnumValueChange{OldEnum: fromEnum, NewEnum: toEnum}
+		if len(drop.Values()) > 0 {
+			drop.Columns = enumColumns(from, fromEnum.FullName())
+			cs.Add(drop)
+		} else if enumReordered(fromEnum, toEnum) {
+			cs.Add(&ReorderEnumChange{
+				OldEnum: fromEnum,
+				NewEnum: toEnum,
+				Columns: enumColumns(from, fromEnum.FullName()),
+			})
+		}
 	}
 }
 
+// enumReordered reports whether the values from and to share are in a
+// different order. Values only in to are left out, since ADD VALUE puts
+// them in place.
+func enumReordered(from, to *Enum) bool {
+	inFrom := make(map[string]bool, len(from.Values))
+	for _, v := range from.Values {
+		inFrom[v] = true
+	}
+
+	var shared []string
+	for _, v := range to.Values {
+		if inFrom[v] {
+			shared = append(shared, v)
+		}
+	}
+
+	i := 0
+	for _, v := range from.Values {
+		if i < len(shared) && v == shared[i] {
+			i++
+		}
+	}
+	return i < len(shared)
+}
+
+// enumColumns returns the columns in s whose type is the named enum, or an
+// array of it, sorted by table name and then column position.
+func enumColumns(s *Schema, enumName string) []EnumColumn {
+	var columns []EnumColumn
+	for _, table := range s.SortedTables() {
+		for _, col := range table.SortedColumns() {
+			if col.DataType == enumName {
+				columns = append(columns, EnumColumn{TableName: table.FullName(), Column: col})
+			}
+		}
+	}
+	sort.SliceStable(columns, func(i, j int) bool {
+		return columns[i].TableName < columns[j].TableName
+	})
+	return columns
+}
+
 func diffEnumValues(from, to *Enum, cs *ChangeSet) {
 	fromValues := make(map[string]int) // value -> position
 	for i, v := range from.Values {
@@ -45,7 +132,7 @@ func diffEnumValues(from, to *Enum, cs *ChangeSet) {
 				after = to.Values[i-1]
 			}
 			cs.Add(&AddEnumValueChange{
-				EnumName: to.Name,
+				EnumName: to.FullName(),
 				Value:    v,
 				After:    after,
 			})
@@ -53,6 +140,47 @@ func diffEnumValues(from, to *Enum, cs *ChangeSet) {
 	}
 }
 
+func diffSequences(from, to *Schema, cs *ChangeSet) {
+	for name, fromSeq := range from.Sequences {
+		if _, exists := to.Sequences[name]; exists {
+			continue
+		}
+		// An owned sequence goes away with its column, so dropping it
+		// again after the column or table is dropped would fail.
+		if fromSeq.OwnedByTable != "" && !hasColumn(to, fromSeq.OwnedByTable, fromSeq.OwnedByColumn) {
+			continue
+		}
+		cs.Add(&DropSequenceChange{Sequence: fromSeq})
+	}
+
+	for name, toSeq := range to.Sequences {
+		fromSeq, exists := from.Sequences[name]
+		if !exists {
+			cs.Add(&CreateSequenceChange{Sequence: toSeq})
+			if toSeq.OwnedByTable != "" {
+				unowned := *toSeq
+				unowned.OwnedByTable = ""
+				unowned.OwnedByColumn = ""
+				cs.Add(&AlterSequenceChange{OldSequence: &unowned, NewSequence: toSeq})
+			}
+			continue
+		}
+
+		if !fromSeq.Equals(toSeq) {
+			cs.Add(&AlterSequenceChange{OldSequence: fromSeq, NewSequence: toSeq})
+		}
+	}
+}
+
+func hasColumn(s *Schema, tableName, columnName string) bool {
+	table, ok := s.Tables[tableName]
+	if !ok {
+		return false
+	}
+	_, ok = table.Columns[columnName]
+	return ok
+}
+
 func diffTables(from, to *Schema, cs *ChangeSet) {
 	for name, fromTable := range from.Tables {
 		if _, exists := to.Tables[name]; !exists {
@@ -75,6 +203,34 @@ func diffTableContents(from, to *Table, cs *ChangeSet) {
 	diffColumns(from, to, cs)
 	diffIndexes(from, to, cs)
 	diffConstraints(from, to, cs)
+	diffComments(from, to, cs)
+}
+
+func diffComments(from, to *Table, cs *ChangeSet) {
+	tableName := to.FullName()
+
+	if from.Comment != to.Comment {
+		cs.Add(&CommentChange{
+			TableName:  tableName,
+			OldComment: from.Comment,
+			NewComment: to.Comment,
+		})
+	}
+
+	for name, toCol := range to.Columns {
+		fromCol, exists := from.Columns[name]
+		if !exists {
+			continue
+		}
+		if fromCol.Comment != toCol.Comment {
+			cs.Add(&CommentChange{
+				TableName:  tableName,
+				ColumnName: name,
+				OldComment: fromCol.Comment,
+				NewComment: toCol.Comment,
+			})
+		}
+	}
 }
 
 func diffColumns(from, to *Table, cs *ChangeSet) {
@@ -123,6 +279,18 @@ func computeColumnAlteration(from, to *Column) ColumnAlteration {
 		alt.NewType = toType
 	}
 
+	if from.Generated != to.Generated {
+		alt.GeneratedChanged = true
+		alt.OldGenerated = from.Generated
+		alt.NewGenerated = to.Generated
+	}
+
+	if from.Collation != to.Collation {
+		alt.CollationChanged = true
+		alt.OldCollation = from.Collation
+		alt.NewCollation = to.Collation
+	}
+
 	if from.IsNullable != to.IsNullable {
 		alt.NullableChanged = true
 		alt.OldNullable = from.IsNullable
@@ -135,12 +303,18 @@ func computeColumnAlteration(from, to *Column) ColumnAlteration {
 		alt.DefaultChanged = true
 		alt.OldDefault = fromDefault
 		alt.NewDefault = toDefault
-	} else if fromDefault != nil && toDefault != nil && *fromDefault != *toDefault {
+	} else if fromDefault != nil && toDefault != nil && !defaultsEqual(*fromDefault, *toDefault) {
 		alt.DefaultChanged = true
 		alt.OldDefault = fromDefault
 		alt.NewDefault = toDefault
 	}
 
+	if from.Identity != to.Identity {
+		alt.IdentityChanged = true
+		alt.OldIdentity = from.Identity
+		alt.NewIdentity = to.Identity
+	}
+
 	return alt
 }
 
@@ -228,3 +402,26 @@ func diffFunctions(from, to *Schema, cs *ChangeSet) {
 		}
 	}
 }
+
+func diffViews(from, to *Schema, cs *ChangeSet) {
+	for name, fromView := range from.Views {
+		if _, exists := to.Views[name]; !exists {
+			cs.Add(&DropViewChange{View: fromView})
+		}
+	}
+
+	for name, toView := range to.Views {
+		fromView, exists := from.Views[name]
+		if !exists {
+			cs.Add(&CreateViewChange{View: toView})
+			continue
+		}
+
+		if !fromView.Equals(toView) {
+			cs.Add(&ReplaceViewChange{
+				OldView: fromView,
+				NewView: toView,
+			})
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
@@ -22,23 +23,104 @@ func NewExtractor(conn dbQuerier) *Extractor {
 	return &Extractor{conn: conn}
 }
 
-func (e *Extractor) Extract(ctx context.Context, dbName string) (*Schema, error) {
+// DefaultExcludeSchemas lists schemas created by common extensions, such as
+// PostGIS's topology and tiger geocoder, pg_cron and pglogical. Their
+// objects are managed by the extension, not by the application.
+var DefaultExcludeSchemas = []string{"cron", "pglogical", "tiger", "tiger_data", "topology"}
+
+// ExtractOptions selects the objects Extract leaves out. Patterns use
+// path.Match syntax, so "tmp_*" matches every name starting with "tmp_".
+type ExtractOptions struct {
+	// ExcludeSchemas lists schemas whose objects are all left out.
+	ExcludeSchemas []string
+
+	// ExcludeTables lists tables to leave out, with their indexes,
+	// constraints and owned sequences. A pattern without a dot matches the
+	// table name in any schema; "schema.table" matches one schema only.
+	ExcludeTables []string
+}
+
+// DefaultExtractOptions returns options that leave out DefaultExcludeSchemas.
+func DefaultExtractOptions() ExtractOptions {
+	return ExtractOptions{ExcludeSchemas: append([]string(nil), DefaultExcludeSchemas...)}
+}
+
+// Validate returns an error for malformed patterns.
+func (o ExtractOptions) Validate() error {
+	for _, pattern := range o.ExcludeSchemas {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid schema pattern '%s': %w", pattern, err)
+		}
+	}
+	for _, pattern := range o.ExcludeTables {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid table pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (o ExtractOptions) excludesSchema(schema string) bool {
+	for _, pattern := range o.ExcludeSchemas {
+		if ok, _ := path.Match(pattern, schema); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (o ExtractOptions) excludesTable(schema, name string) bool {
+	if o.excludesSchema(schema) {
+		return true
+	}
+	for _, pattern := range o.ExcludeTables {
+		target := name
+		if strings.Contains(pattern, ".") {
+			target = schema + "." + name
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract reads the schema of the database e is connected to, leaving out
+// the objects excluded by opts.
+func (e *Extractor) Extract(ctx context.Context, dbName string, opts ExtractOptions) (*Schema, error) {
 	schema := NewSchema(dbName)
 
+	namespaces, err := e.extractNamespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract schemas: %w", err)
+	}
+	for _, ns := range namespaces {
+		if !opts.excludesSchema(ns) {
+			schema.Namespaces = append(schema.Namespaces, ns)
+		}
+	}
+
 	enums, err := e.extractEnums(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract enums: %w", err)
 	}
 	for _, enum := range enums {
-		schema.Enums[enum.Name] = enum
+		if !opts.excludesSchema(enum.Schema) {
+			schema.Enums[enum.FullName()] = enum
+		}
 	}
 
 	tables, err := e.extractTables(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract tables: %w", err)
 	}
+	excludedTables := make(map[string]bool)
 	for _, table := range tables {
-		schema.Tables[table.Name] = table
+		if opts.excludesTable(table.Schema, table.Name) {
+			excludedTables[table.FullName()] = true
+			continue
+		}
+		schema.Tables[table.FullName()] = table
 	}
 
 	for _, table := range schema.Tables {
@@ -71,22 +153,74 @@ func (e *Extractor) Extract(ctx context.Context, dbName string) (*Schema, error)
 		}
 	}
 
+	sequences, err := e.extractSequences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract sequences: %w", err)
+	}
+	for _, seq := range sequences {
+		if opts.excludesSchema(seq.Schema) || excludedTables[seq.OwnedByTable] {
+			continue
+		}
+		schema.Sequences[seq.FullName()] = seq
+	}
+
 	functions, err := e.extractFunctions(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract functions: %w", err)
 	}
 	for _, fn := range functions {
-		schema.Functions[fn.Signature()] = fn
+		if !opts.excludesSchema(fn.Schema) {
+			schema.Functions[fn.FullName()] = fn
+		}
+	}
+
+	views, err := e.extractViews(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract views: %w", err)
+	}
+	for _, view := range views {
+		if !opts.excludesSchema(view.Schema) {
+			schema.Views[view.FullName()] = view
+		}
 	}
 
 	return schema, nil
 }
 
+// extractNamespaces returns the user-created schemas other than public.
+func (e *Extractor) extractNamespaces(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT nspname
+		FROM pg_namespace
+		WHERE nspname NOT IN ('information_schema', 'public')
+		  AND nspname NOT LIKE 'pg\_%'  -- reserved for system schemas
+		ORDER BY nspname
+	`
+
+	rows, err := e.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var namespaces []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, name)
+	}
+
+	return namespaces, rows.Err()
+}
+
 func (e *Extractor) extractTables(ctx context.Context) ([]*Table, error) {
 	query := `
 		SELECT
 			table_name,
-			table_schema
+			table_schema,
+			obj_description(format('%I.%I', table_schema, table_name)::regclass, 'pg_class') AS comment
 		FROM information_schema.tables
 		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
 		  AND table_type = 'BASE TABLE'
@@ -102,10 +236,15 @@ func (e *Extractor) extractTables(ctx context.Context) ([]*Table, error) {
 	var tables []*Table
 	for rows.Next() {
 		var name, schema string
-		if err := rows.Scan(&name, &schema); err != nil {
+		var comment *string
+		if err := rows.Scan(&name, &schema, &comment); err != nil {
 			return nil, err
 		}
-		tables = append(tables, NewTable(name, schema))
+		table := NewTable(name, schema)
+		if comment != nil {
+			table.Comment = *comment
+		}
+		tables = append(tables, table)
 	}
 
 	return tables, rows.Err()
@@ -122,7 +261,14 @@ func (e *Extractor) extractColumns(ctx context.Context, schemaName, tableName st
 			character_maximum_length,
 			numeric_precision,
 			numeric_scale,
-			udt_name
+			udt_name,
+			col_description(format('%I.%I', table_schema, table_name)::regclass, ordinal_position::int) AS comment,
+			identity_generation,
+			udt_schema,
+			collation_schema,
+			collation_name,
+			is_generated,
+			generation_expression
 		FROM information_schema.columns
 		WHERE table_schema = $1 AND table_name = $2
 		ORDER BY ordinal_position
@@ -144,11 +290,19 @@ func (e *Extractor) extractColumns(ctx context.Context, schemaName, tableName st
 			numPrecision               *int
 			numScale                   *int
 			udtName                    string
+			comment                    *string
+			identity                   *string
+			udtSchema                  string
+			collationSchema            *string
+			collationName              *string
+			isGenerated                *string
+			generationExpr             *string
 		)
 
 		if err := rows.Scan(
 			&name, &dataType, &isNullable, &defaultValue,
-			&position, &charMaxLen, &numPrecision, &numScale, &udtName,
+			&position, &charMaxLen, &numPrecision, &numScale, &udtName, &comment, &identity, &udtSchema,
+			&collationSchema, &collationName, &isGenerated, &generationExpr,
 		); err != nil {
 			return nil, err
 		}
@@ -164,12 +318,26 @@ func (e *Extractor) extractColumns(ctx context.Context, schemaName, tableName st
 			NumericScale:     numScale,
 		}
 
+		if comment != nil {
+			col.Comment = *comment
+		}
+		if identity != nil {
+			col.Identity = *identity
+		}
+		if isGenerated != nil && *isGenerated == "ALWAYS" && generationExpr != nil {
+			col.Generated = *generationExpr
+		}
+		// collation_name is NULL for the type's default collation.
+		if collationName != nil && collationSchema != nil {
+			col.Collation = qualifiedTypeName(*collationSchema, *collationName)
+		}
+
 		if dataType == "ARRAY" {
 			col.IsArray = true
-			col.ElementType = strings.TrimPrefix(udtName, "_")
+			col.ElementType = qualifiedTypeName(udtSchema, strings.TrimPrefix(udtName, "_"))
 			col.DataType = col.ElementType
 		} else if dataType == "USER-DEFINED" {
-			col.DataType = udtName
+			col.DataType = qualifiedTypeName(udtSchema, udtName)
 		}
 
 		columns = append(columns, col)
@@ -178,6 +346,15 @@ func (e *Extractor) extractColumns(ctx context.Context, schemaName, tableName st
 	return columns, rows.Err()
 }
 
+// qualifiedTypeName qualifies a type from a schema other than pg_catalog or
+// public, such as an enum in an app schema, so it resolves outside it.
+func qualifiedTypeName(schema, name string) string {
+	if schema == "pg_catalog" {
+		return name
+	}
+	return qualifiedName(schema, name)
+}
+
 func (e *Extractor) extractIndexes(ctx context.Context, schemaName, tableName string) ([]*Index, error) {
 	query := `
 		SELECT
@@ -221,7 +398,7 @@ func (e *Extractor) extractIndexes(ctx context.Context, schemaName, tableName st
 
 		indexes = append(indexes, &Index{
 			Name:       name,
-			TableName:  tableName,
+			TableName:  qualifiedName(schemaName, tableName),
 			Type:       indexType,
 			IsUnique:   isUnique,
 			IsPrimary:  isPrimary,
@@ -309,7 +486,7 @@ func (e *Extractor) extractConstraints(ctx context.Context, schemaName, tableNam
 		con := &Constraint{
 			Name:       name,
 			Type:       ConstraintType(conType),
-			TableName:  tableName,
+			TableName:  qualifiedName(schemaName, tableName),
 			Definition: definition,
 			Columns:    columns,
 		}
@@ -374,6 +551,85 @@ func (e *Extractor) extractEnums(ctx context.Context) ([]*Enum, error) {
 	return enums, rows.Err()
 }
 
+// extractSequences returns the sequences that are not identity sequences,
+// along with the column each one is owned by, if any.
+func (e *Extractor) extractSequences(ctx context.Context) ([]*Sequence, error) {
+	query := `
+		SELECT
+			s.sequencename,
+			s.schemaname,
+			s.data_type::text,
+			s.start_value,
+			s.increment_by,
+			s.min_value,
+			s.max_value,
+			s.cache_size,
+			s.cycle,
+			tn.nspname AS owned_by_schema,
+			t.relname AS owned_by_table,
+			a.attname AS owned_by_column
+		FROM pg_sequences s
+		JOIN pg_namespace n ON n.nspname = s.schemaname
+		JOIN pg_class c ON c.relname = s.sequencename AND c.relnamespace = n.oid
+		LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass
+		  AND d.objid = c.oid
+		  AND d.refclassid = 'pg_class'::regclass
+		  AND d.deptype IN ('a', 'i')
+		LEFT JOIN pg_class t ON t.oid = d.refobjid
+		LEFT JOIN pg_namespace tn ON tn.oid = t.relnamespace
+		LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+		WHERE s.schemaname NOT IN ('pg_catalog', 'information_schema')
+		  AND d.deptype IS DISTINCT FROM 'i'  -- identity sequences belong to their column
+		ORDER BY s.schemaname, s.sequencename
+	`
+
+	rows, err := e.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sequences []*Sequence
+	for rows.Next() {
+		var (
+			name, schema, dataType           string
+			start, increment, minVal, maxVal int64
+			cache                            int64
+			cycle                            bool
+			ownedBySchema, ownedByTable      *string
+			ownedByColumn                    *string
+		)
+
+		if err := rows.Scan(
+			&name, &schema, &dataType, &start, &increment, &minVal, &maxVal,
+			&cache, &cycle, &ownedBySchema, &ownedByTable, &ownedByColumn,
+		); err != nil {
+			return nil, err
+		}
+
+		seq := &Sequence{
+			Name:      name,
+			Schema:    schema,
+			DataType:  dataType,
+			Start:     start,
+			Increment: increment,
+			MinValue:  minVal,
+			MaxValue:  maxVal,
+			Cache:     cache,
+			Cycle:     cycle,
+		}
+
+		if ownedBySchema != nil && ownedByTable != nil && ownedByColumn != nil {
+			seq.OwnedByTable = qualifiedName(*ownedBySchema, *ownedByTable)
+			seq.OwnedByColumn = *ownedByColumn
+		}
+
+		sequences = append(sequences, seq)
+	}
+
+	return sequences, rows.Err()
+}
+
 func (e *Extractor) extractFunctions(ctx context.Context) ([]*Function, error) {
 	query := `
 		SELECT
@@ -421,17 +677,61 @@ func (e *Extractor) extractFunctions(ctx context.Context) ([]*Function, error) {
 	return functions, rows.Err()
 }
 
-func ExtractFromConnection(ctx context.Context, conn *pgx.Conn, dbName string) (*Schema, error) {
+func (e *Extractor) extractViews(ctx context.Context) ([]*View, error) {
+	query := `
+		SELECT viewname, schemaname, definition, false AS materialized
+		FROM pg_views
+		WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
+		UNION ALL
+		SELECT matviewname, schemaname, definition, true AS materialized
+		FROM pg_matviews
+		WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY 2, 1
+	`
+
+	rows, err := e.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var views []*View
+	for rows.Next() {
+		var name, schema, definition string
+		var materialized bool
+
+		if err := rows.Scan(&name, &schema, &definition, &materialized); err != nil {
+			return nil, err
+		}
+
+		// pg_get_viewdef ends the query with a semicolon; drop it so the
+		// definition can be embedded in CREATE VIEW.
+		definition = strings.TrimSuffix(strings.TrimSpace(definition), ";")
+		hash := sha256.Sum256([]byte(definition))
+
+		views = append(views, &View{
+			Name:           name,
+			Schema:         schema,
+			Materialized:   materialized,
+			Definition:     definition,
+			DefinitionHash: hex.EncodeToString(hash[:8]),
+		})
+	}
+
+	return views, rows.Err()
+}
+
+func ExtractFromConnection(ctx context.Context, conn *pgx.Conn, dbName string, opts ExtractOptions) (*Schema, error) {
 	extractor := NewExtractor(conn)
-	return extractor.Extract(ctx, dbName)
+	return extractor.Extract(ctx, dbName, opts)
 }
 
-func ExtractFromURL(ctx context.Context, connURL string, dbName string) (*Schema, error) {
+func ExtractFromURL(ctx context.Context, connURL string, dbName string, opts ExtractOptions) (*Schema, error) {
 	conn, err := pgx.Connect(ctx, connURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 	defer conn.Close(ctx)
 
-	return ExtractFromConnection(ctx, conn, dbName)
+	return ExtractFromConnection(ctx, conn, dbName, opts)
 }
@@ -77,7 +77,9 @@ type mockConn struct {
 func (m *mockConn) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
 	for key, rows := range m.results {
 		if strings.Contains(sql, key) {
-			return rows, nil
+			// Each query reads the rows from the start, so per-table
+			// queries see the same rows whatever order tables come in.
+			return &mockRows{data: rows.data, scanErr: rows.scanErr, err: rows.err}, nil
 		}
 	}
 	return &mockRows{}, nil
@@ -92,7 +94,7 @@ func TestExtract_Tables(t *testing.T) {
 	}}
 
 	ext := NewExtractor(conn)
-	schema, err := ext.Extract(context.Background(), "testdb")
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.NoError(t, err)
 
 	assert.Len(t, schema.Tables, 2)
@@ -115,7 +117,7 @@ func TestExtract_Columns(t *testing.T) {
 	}}
 
 	ext := NewExtractor(conn)
-	schema, err := ext.Extract(context.Background(), "testdb")
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.NoError(t, err)
 
 	tbl := schema.Tables["users"]
@@ -144,6 +146,28 @@ func TestExtract_Columns(t *testing.T) {
 	assert.Equal(t, "text", tags.DataType)
 }
 
+func TestExtract_Comments(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"information_schema.tables": {data: [][]any{
+			{"users", "public", strPtr("Registered users")},
+			{"orders", "public", (*string)(nil)},
+		}},
+		"information_schema.columns": {data: [][]any{
+			{"id", "integer", "NO", (*string)(nil), 1, (*int)(nil), (*int)(nil), (*int)(nil), "int4", strPtr("Primary key")},
+			{"name", "text", "YES", (*string)(nil), 2, (*int)(nil), (*int)(nil), (*int)(nil), "text", (*string)(nil)},
+		}},
+	}}
+
+	ext := NewExtractor(conn)
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Registered users", schema.Tables["users"].Comment)
+	assert.Empty(t, schema.Tables["orders"].Comment)
+	assert.Equal(t, "Primary key", schema.Tables["users"].Columns["id"].Comment)
+	assert.Empty(t, schema.Tables["users"].Columns["name"].Comment)
+}
+
 func TestExtract_Indexes(t *testing.T) {
 	conn := &mockConn{results: map[string]*mockRows{
 		"information_schema.tables": {data: [][]any{
@@ -156,7 +180,7 @@ func TestExtract_Indexes(t *testing.T) {
 	}}
 
 	ext := NewExtractor(conn)
-	schema, err := ext.Extract(context.Background(), "testdb")
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.NoError(t, err)
 
 	tbl := schema.Tables["users"]
@@ -189,7 +213,7 @@ func TestExtract_Constraints(t *testing.T) {
 	}}
 
 	ext := NewExtractor(conn)
-	schema, err := ext.Extract(context.Background(), "testdb")
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.NoError(t, err)
 
 	tbl := schema.Tables["users"]
@@ -218,7 +242,7 @@ func TestExtract_Enums(t *testing.T) {
 	}}
 
 	ext := NewExtractor(conn)
-	schema, err := ext.Extract(context.Background(), "testdb")
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.NoError(t, err)
 
 	assert.Len(t, schema.Enums, 1)
@@ -237,7 +261,7 @@ func TestExtract_Functions(t *testing.T) {
 	}}
 
 	ext := NewExtractor(conn)
-	schema, err := ext.Extract(context.Background(), "testdb")
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.NoError(t, err)
 
 	assert.Len(t, schema.Functions, 1)
@@ -252,16 +276,233 @@ func TestExtract_Functions(t *testing.T) {
 	assert.NotEmpty(t, fn.BodyHash)
 }
 
+func TestExtract_Sequences(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"information_schema.tables": {data: [][]any{
+			{"users", "public"},
+		}},
+		"information_schema.columns": {data: [][]any{
+			{"id", "bigint", "NO", (*string)(nil), 1, (*int)(nil), (*int)(nil), (*int)(nil), "int8", (*string)(nil), strPtr("ALWAYS")},
+			{"ref", "integer", "NO", strPtr("nextval('users_ref_seq'::regclass)"), 2, (*int)(nil), (*int)(nil), (*int)(nil), "int4", (*string)(nil), (*string)(nil)},
+		}},
+		"pg_sequences": {data: [][]any{
+			{"users_ref_seq", "public", "integer", int64(1), int64(1), int64(1), int64(2147483647), int64(1), false, strPtr("public"), strPtr("users"), strPtr("ref")},
+			{"invoice_no", "public", "bigint", int64(1000), int64(1), int64(1), int64(9223372036854775807), int64(1), false, (*string)(nil), (*string)(nil), (*string)(nil)},
+		}},
+	}}
+
+	ext := NewExtractor(conn)
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "ALWAYS", schema.Tables["users"].Columns["id"].Identity)
+	assert.Empty(t, schema.Tables["users"].Columns["ref"].Identity)
+
+	require.Len(t, schema.Sequences, 2)
+
+	serial := schema.Sequences["users_ref_seq"]
+	require.NotNil(t, serial)
+	assert.Equal(t, "integer", serial.DataType)
+	assert.Equal(t, int64(2147483647), serial.MaxValue)
+	assert.Equal(t, "users.ref", serial.OwnedBy())
+
+	standalone := schema.Sequences["invoice_no"]
+	require.NotNil(t, standalone)
+	assert.Equal(t, int64(1000), standalone.Start)
+	assert.Empty(t, standalone.OwnedBy())
+}
+
+func TestExtract_ExcludeOptions(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"information_schema.tables": {data: [][]any{
+			{"users", "public"},
+			{"schema_migrations", "public"},
+			{"tmp_import", "staging"},
+			{"events", "staging"},
+			{"topology", "topology"},
+		}},
+		"pg_sequences": {data: [][]any{
+			{"schema_migrations_id_seq", "public", "integer", int64(1), int64(1), int64(1), int64(2147483647), int64(1), false, strPtr("public"), strPtr("schema_migrations"), strPtr("id")},
+			{"topology_id_seq", "topology", "integer", int64(1), int64(1), int64(1), int64(2147483647), int64(1), false, (*string)(nil), (*string)(nil), (*string)(nil)},
+			{"invoice_no", "public", "bigint", int64(1), int64(1), int64(1), int64(9223372036854775807), int64(1), false, (*string)(nil), (*string)(nil), (*string)(nil)},
+		}},
+		"pg_proc": {data: [][]any{
+			{"greet", "public", "", "text", "sql", "SELECT 'hi'"},
+			{"gettopologyid", "topology", "toponame varchar", "integer", "plpgsql", "SELECT 1"},
+		}},
+	}}
+
+	t.Run("default options", func(t *testing.T) {
+		schema, err := NewExtractor(conn).Extract(context.Background(), "testdb", DefaultExtractOptions())
+		require.NoError(t, err)
+
+		assert.NotContains(t, schema.Tables, "topology.topology")
+		assert.NotContains(t, schema.Sequences, "topology.topology_id_seq")
+		assert.NotContains(t, schema.Functions, "topology.gettopologyid(toponame varchar)")
+		assert.Len(t, schema.Tables, 4)
+		assert.Len(t, schema.Functions, 1)
+	})
+
+	t.Run("excluded tables", func(t *testing.T) {
+		opts := DefaultExtractOptions()
+		opts.ExcludeTables = []string{"schema_migrations", "staging.tmp_*"}
+
+		schema, err := NewExtractor(conn).Extract(context.Background(), "testdb", opts)
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []string{"users", "staging.events"}, keys(schema.Tables))
+		assert.ElementsMatch(t, []string{"invoice_no"}, keys(schema.Sequences), "sequences owned by excluded tables are left out")
+	})
+
+	t.Run("no exclusions", func(t *testing.T) {
+		schema, err := NewExtractor(conn).Extract(context.Background(), "testdb", ExtractOptions{})
+		require.NoError(t, err)
+
+		assert.Len(t, schema.Tables, 5)
+		assert.Len(t, schema.Sequences, 3)
+		assert.Len(t, schema.Functions, 2)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		err := ExtractOptions{ExcludeTables: []string{"tmp_["}}.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid table pattern")
+	})
+}
+
+func keys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
+
+func TestExtract_Views(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"pg_matviews": {data: [][]any{
+			{"active_users", "public", " SELECT users.id\n   FROM users;", false},
+			{"daily_totals", "reporting", " SELECT 1 AS total;", true},
+		}},
+	}}
+
+	ext := NewExtractor(conn)
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, schema.Views, 2)
+
+	view := schema.Views["active_users"]
+	require.NotNil(t, view)
+	assert.False(t, view.Materialized)
+	assert.Equal(t, "SELECT users.id\n   FROM users", view.Definition)
+	assert.NotEmpty(t, view.DefinitionHash)
+
+	matview := schema.Views["reporting.daily_totals"]
+	require.NotNil(t, matview)
+	assert.True(t, matview.Materialized)
+	assert.Equal(t, "reporting.daily_totals", matview.FullName())
+}
+
+func TestExtract_MultipleSchemas(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"nspname NOT LIKE": {data: [][]any{
+			{"audit"},
+		}},
+		"information_schema.tables": {data: [][]any{
+			{"users", "public"},
+			{"users", "audit"},
+		}},
+	}}
+
+	ext := NewExtractor(conn)
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"audit"}, schema.Namespaces)
+	require.Len(t, schema.Tables, 2)
+	assert.Equal(t, "public", schema.Tables["users"].Schema)
+	require.Contains(t, schema.Tables, "audit.users")
+	assert.Equal(t, "audit", schema.Tables["audit.users"].Schema)
+}
+
+func TestExtract_QualifiesTypesOutsidePublic(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"information_schema.tables": {data: [][]any{
+			{"events", "audit"},
+		}},
+		"information_schema.columns": {data: [][]any{
+			{"status", "USER-DEFINED", "YES", (*string)(nil), 1, (*int)(nil), (*int)(nil), (*int)(nil), "status", (*string)(nil), (*string)(nil), "audit"},
+			{"tags", "ARRAY", "YES", (*string)(nil), 2, (*int)(nil), (*int)(nil), (*int)(nil), "_text", (*string)(nil), (*string)(nil), "pg_catalog"},
+		}},
+	}}
+
+	ext := NewExtractor(conn)
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
+	require.NoError(t, err)
+
+	tbl := schema.Tables["audit.events"]
+	require.NotNil(t, tbl)
+	assert.Equal(t, "audit.status", tbl.Columns["status"].DataType)
+	assert.Equal(t, "text", tbl.Columns["tags"].DataType)
+}
+
+func TestExtract_ColumnCollation(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"information_schema.tables": {data: [][]any{
+			{"users", "public"},
+		}},
+		"information_schema.columns": {data: [][]any{
+			{"name", "text", "YES", (*string)(nil), 1, (*int)(nil), (*int)(nil), (*int)(nil), "text", (*string)(nil), (*string)(nil), "pg_catalog", (*string)(nil), (*string)(nil)},
+			{"code", "text", "YES", (*string)(nil), 2, (*int)(nil), (*int)(nil), (*int)(nil), "text", (*string)(nil), (*string)(nil), "pg_catalog", strPtr("pg_catalog"), strPtr("C")},
+			{"email", "text", "YES", (*string)(nil), 3, (*int)(nil), (*int)(nil), (*int)(nil), "text", (*string)(nil), (*string)(nil), "pg_catalog", strPtr("app"), strPtr("case_insensitive")},
+		}},
+	}}
+
+	ext := NewExtractor(conn)
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
+	require.NoError(t, err)
+
+	tbl := schema.Tables["users"]
+	require.NotNil(t, tbl)
+	assert.Empty(t, tbl.Columns["name"].Collation)
+	assert.Equal(t, "C", tbl.Columns["code"].Collation)
+	assert.Equal(t, "app.case_insensitive", tbl.Columns["email"].Collation)
+}
+
+func TestExtract_GeneratedColumn(t *testing.T) {
+	conn := &mockConn{results: map[string]*mockRows{
+		"information_schema.tables": {data: [][]any{
+			{"orders", "public"},
+		}},
+		"information_schema.columns": {data: [][]any{
+			{"price", "numeric", "NO", (*string)(nil), 1, (*int)(nil), (*int)(nil), (*int)(nil), "numeric", (*string)(nil), (*string)(nil), "pg_catalog", (*string)(nil), (*string)(nil), strPtr("NEVER"), (*string)(nil)},
+			{"total", "numeric", "YES", (*string)(nil), 2, (*int)(nil), (*int)(nil), (*int)(nil), "numeric", (*string)(nil), (*string)(nil), "pg_catalog", (*string)(nil), (*string)(nil), strPtr("ALWAYS"), strPtr("(price * (2)::numeric)")},
+		}},
+	}}
+
+	ext := NewExtractor(conn)
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
+	require.NoError(t, err)
+
+	tbl := schema.Tables["orders"]
+	require.NotNil(t, tbl)
+	assert.Empty(t, tbl.Columns["price"].Generated)
+	assert.Equal(t, "(price * (2)::numeric)", tbl.Columns["total"].Generated)
+}
+
 func TestExtract_EmptyDatabase(t *testing.T) {
 	conn := &mockConn{results: map[string]*mockRows{}}
 
 	ext := NewExtractor(conn)
-	schema, err := ext.Extract(context.Background(), "testdb")
+	schema, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.NoError(t, err)
 
 	assert.Empty(t, schema.Tables)
 	assert.Empty(t, schema.Enums)
 	assert.Empty(t, schema.Functions)
+	assert.Empty(t, schema.Sequences)
+	assert.Empty(t, schema.Views)
 }
 
 type errorConn struct{}
@@ -275,7 +516,7 @@ func (m *errorConn) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, er
 
 func TestExtract_QueryError(t *testing.T) {
 	ext := NewExtractor(&errorConn{})
-	_, err := ext.Extract(context.Background(), "testdb")
+	_, err := ext.Extract(context.Background(), "testdb", ExtractOptions{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "connection refused")
 }
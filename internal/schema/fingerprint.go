@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Fingerprint returns a hash of the schema's structure. Two schemas have the
+// same fingerprint exactly when they describe the same tables, enums and
+// functions, regardless of which database they were extracted from.
+func (s *Schema) Fingerprint() (string, error) {
+	unnamed := *s
+	unnamed.Name = ""
+
+	// encoding/json sorts map keys, so the encoding is deterministic.
+	data, err := json.Marshal(&unnamed)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize schema: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// SaveFile writes the schema as JSON to path, creating parent directories
+// as needed.
+func (s *Schema) SaveFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize schema: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadFile reads a schema previously written with SaveFile.
+func LoadFile(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse schema file: %w", err)
+	}
+
+	return &s, nil
+}
+
+// DiffAgainstRecorded returns the changes made to a database since its
+// schema was recorded, in the order they would be applied.
+func DiffAgainstRecorded(recorded, current *Schema) *ChangeSet {
+	return OrderChanges(Diff(recorded, current))
+}
@@ -0,0 +1,132 @@
+package schema
+
+import "fmt"
+
+// Inverse returns the changes that undo cs, in application order, for a
+// down migration. Changes that lose data, such as dropping a column, can
+// only be undone structurally; the returned notes describe what the
+// inverse cannot restore.
+func (cs *ChangeSet) Inverse() (*ChangeSet, []string) {
+	inverse := NewChangeSet()
+	var notes []string
+
+	for _, c := range cs.Changes {
+		switch change := c.(type) {
+		case *CreateSchemaChange:
+			inverse.Add(&DropSchemaChange{Name: change.Name})
+		case *DropSchemaChange:
+			inverse.Add(&CreateSchemaChange{Name: change.Name})
+
+		case *CreateTableChange:
+			inverse.Add(&DropTableChange{Table: change.Table})
+		case *DropTableChange:
+			inverse.Add(&CreateTableChange{Table: change.Table})
+			for _, idx := range change.Table.SortedIndexes() {
+				if !idx.IsPrimary {
+					inverse.Add(&CreateIndexChange{Index: idx})
+				}
+			}
+			notes = append(notes, fmt.Sprintf("table %s is recreated empty; its rows were deleted", change.Table.FullName()))
+
+		case *AddColumnChange:
+			inverse.Add(&DropColumnChange{TableName: change.TableName, Column: change.Column})
+		case *DropColumnChange:
+			inverse.Add(&AddColumnChange{TableName: change.TableName, Column: change.Column})
+			notes = append(notes, fmt.Sprintf("column %s is recreated without its values", change.ObjectName()))
+		case *AlterColumnChange:
+			inverse.Add(&AlterColumnChange{
+				TableName:  change.TableName,
+				ColumnName: change.ColumnName,
+				OldColumn:  change.NewColumn,
+				NewColumn:  change.OldColumn,
+				Alteration: computeColumnAlteration(change.NewColumn, change.OldColumn),
+			})
+			if change.Alteration.TypeChanged {
+				notes = append(notes, fmt.Sprintf("column %s is converted back from %s to %s; values the first conversion changed are not restored",
+					change.ObjectName(), change.Alteration.NewType, change.Alteration.OldType))
+			}
+			if change.Alteration.GeneratedChanged && change.Alteration.OldGenerated == "" {
+				notes = append(notes, fmt.Sprintf("column %s keeps its generated values; the values it held before are not restored",
+					change.ObjectName()))
+			}
+		case *RenameColumnChange:
+			old := *change.Column
+			old.Name = change.OldName
+			inverse.Add(&RenameColumnChange{TableName: change.TableName, OldName: change.Column.Name, Column: &old})
+
+		case *CreateIndexChange:
+			inverse.Add(&DropIndexChange{Index: change.Index})
+		case *DropIndexChange:
+			inverse.Add(&CreateIndexChange{Index: change.Index})
+
+		case *AddConstraintChange:
+			inverse.Add(&DropConstraintChange{TableName: change.TableName, Constraint: change.Constraint})
+		case *DropConstraintChange:
+			inverse.Add(&AddConstraintChange{TableName: change.TableName, Constraint: change.Constraint})
+
+		case *CreateEnumChange:
+			inverse.Add(&DropEnumChange{Enum: change.Enum})
+		case *DropEnumChange:
+			inverse.Add(&CreateEnumChange{Enum: change.Enum})
+		case *AddEnumValueChange:
+			notes = append(notes, fmt.Sprintf("value '%s' stays in enum %s; PostgreSQL cannot remove enum values",
+				change.Value, change.EnumName))
+		case *DropEnumValueChange:
+			removed := make(map[string]bool)
+			for _, v := range change.Values() {
+				removed[v] = true
+			}
+			for i, v := range change.OldEnum.Values {
+				if !removed[v] {
+					continue
+				}
+				if i == 0 {
+					notes = append(notes, fmt.Sprintf("value '%s' goes back at the end of enum %s rather than first", v, change.ObjectName()))
+					inverse.Add(&AddEnumValueChange{EnumName: change.ObjectName(), Value: v})
+					continue
+				}
+				inverse.Add(&AddEnumValueChange{EnumName: change.ObjectName(), Value: v, After: change.OldEnum.Values[i-1]})
+			}
+		case *ReorderEnumChange:
+			inverse.Add(&ReorderEnumChange{OldEnum: change.NewEnum, NewEnum: change.OldEnum, Columns: change.Columns})
+
+		case *CreateSequenceChange:
+			inverse.Add(&DropSequenceChange{Sequence: change.Sequence})
+		case *DropSequenceChange:
+			inverse.Add(&CreateSequenceChange{Sequence: change.Sequence})
+			notes = append(notes, fmt.Sprintf("sequence %s is recreated at its start value", change.Sequence.FullName()))
+		case *AlterSequenceChange:
+			inverse.Add(&AlterSequenceChange{OldSequence: change.NewSequence, NewSequence: change.OldSequence})
+
+		case *CreateFunctionChange:
+			inverse.Add(&DropFunctionChange{Function: change.Function})
+		case *DropFunctionChange:
+			inverse.Add(&CreateFunctionChange{Function: change.Function})
+		case *ReplaceFunctionChange:
+			inverse.Add(&ReplaceFunctionChange{OldFunction: change.NewFunction, NewFunction: change.OldFunction})
+
+		case *CreateViewChange:
+			inverse.Add(&DropViewChange{View: change.View})
+		case *DropViewChange:
+			inverse.Add(&CreateViewChange{View: change.View})
+			if change.View.Materialized {
+				notes = append(notes, fmt.Sprintf("materialized view %s is recreated and needs a REFRESH", change.View.FullName()))
+			}
+		case *ReplaceViewChange:
+			inverse.Add(&ReplaceViewChange{OldView: change.NewView, NewView: change.OldView})
+
+		case *CommentChange:
+			inverse.Add(&CommentChange{
+				TableName:  change.TableName,
+				ColumnName: change.ColumnName,
+				OldComment: change.NewComment,
+				NewComment: change.OldComment,
+			})
+
+		default:
+			notes = append(notes, fmt.Sprintf("%s cannot be undone", change.Description()))
+		}
+	}
+
+	return OrderChanges(inverse), notes
+}
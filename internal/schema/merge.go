@@ -0,0 +1,220 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergeConflict describes an object that was changed differently on both
+// sides of a three-way merge.
+type MergeConflict struct {
+	Object string
+	Source []Change
+	Target []Change
+}
+
+// ThreeWayDiff computes the changes source made relative to their common
+// ancestor that can be applied to target without clobbering target's own
+// changes. Changes target already made identically are skipped. Source
+// changes to an object target also changed differently are left out of the
+// returned ChangeSet and reported as conflicts, sorted by object name.
+func ThreeWayDiff(ancestor, source, target *Schema) (*ChangeSet, []MergeConflict) {
+	sourceChanges := Diff(ancestor, source)
+	targetChanges := Diff(ancestor, target)
+
+	gen := NewSQLGenerator()
+	sourceSQL := make(map[string]bool)
+	for _, c := range sourceChanges.Changes {
+		sourceSQL[gen.GenerateChange(c)] = true
+	}
+	targetSQL := make(map[string]bool)
+	for _, c := range targetChanges.Changes {
+		targetSQL[gen.GenerateChange(c)] = true
+	}
+
+	cs := NewChangeSet()
+	conflicts := make(map[string]*MergeConflict)
+
+	for _, sc := range sourceChanges.Changes {
+		if targetSQL[gen.GenerateChange(sc)] {
+			continue
+		}
+
+		var overlapping []Change
+		for _, tc := range targetChanges.Changes {
+			if sourceSQL[gen.GenerateChange(tc)] {
+				continue
+			}
+			if objectsOverlap(sc.ObjectName(), tc.ObjectName()) {
+				overlapping = append(overlapping, tc)
+			}
+		}
+
+		if len(overlapping) == 0 {
+			cs.Add(sc)
+			continue
+		}
+
+		conflict, ok := conflicts[sc.ObjectName()]
+		if !ok {
+			conflict = &MergeConflict{Object: sc.ObjectName(), Target: overlapping}
+			conflicts[sc.ObjectName()] = conflict
+		}
+		conflict.Source = append(conflict.Source, sc)
+	}
+
+	result := make([]MergeConflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Object < result[j].Object
+	})
+
+	return cs, result
+}
+
+// objectsOverlap reports whether two change object names refer to the same
+// object, or one is a table and the other a column of it.
+func objectsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// Conflict is a difference between two schemas that merging cannot resolve
+// by applying DDL, reported before any SQL runs.
+type Conflict struct {
+	Object      string
+	Description string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: %s", c.Object, c.Description)
+}
+
+// DetectConflicts reports differences between source and target that would
+// make a merge of source into target fail or silently change data: columns
+// with the same name but different types, constraints whose name is reused
+// for a different definition or table, and enums whose shared values are
+// ordered differently. Conflicts are sorted by object name.
+func DetectConflicts(source, target *Schema) []Conflict {
+	var conflicts []Conflict
+
+	conflicts = append(conflicts, detectColumnConflicts(source, target)...)
+	conflicts = append(conflicts, detectConstraintConflicts(source, target)...)
+	conflicts = append(conflicts, detectEnumConflicts(source, target)...)
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].Object < conflicts[j].Object
+	})
+
+	return conflicts
+}
+
+func detectColumnConflicts(source, target *Schema) []Conflict {
+	var conflicts []Conflict
+
+	for name, sourceTable := range source.Tables {
+		targetTable, ok := target.Tables[name]
+		if !ok {
+			continue
+		}
+
+		for colName, sourceCol := range sourceTable.Columns {
+			targetCol, ok := targetTable.Columns[colName]
+			if !ok || sourceCol.FullType() == targetCol.FullType() {
+				continue
+			}
+			conflicts = append(conflicts, Conflict{
+				Object: fmt.Sprintf("%s.%s", sourceTable.FullName(), colName),
+				Description: fmt.Sprintf("column is %s on source but %s on target",
+					sourceCol.FullType(), targetCol.FullType()),
+			})
+		}
+	}
+
+	return conflicts
+}
+
+func detectConstraintConflicts(source, target *Schema) []Conflict {
+	var conflicts []Conflict
+
+	targetConstraints := make(map[string]*Table)
+	for _, table := range target.Tables {
+		for name := range table.Constraints {
+			targetConstraints[table.Schema+"."+name] = table
+		}
+	}
+
+	for _, sourceTable := range source.Tables {
+		for name, sourceCon := range sourceTable.Constraints {
+			targetTable, ok := targetConstraints[sourceTable.Schema+"."+name]
+			if !ok {
+				continue
+			}
+			targetCon := targetTable.Constraints[name]
+
+			switch {
+			case targetTable.FullName() != sourceTable.FullName():
+				conflicts = append(conflicts, Conflict{
+					Object: name,
+					Description: fmt.Sprintf("constraint is on table %s on source but %s on target",
+						sourceTable.FullName(), targetTable.FullName()),
+				})
+			case !sourceCon.Equals(targetCon):
+				conflicts = append(conflicts, Conflict{
+					Object: name,
+					Description: fmt.Sprintf("constraint is %s on source but %s on target",
+						sourceCon.Definition, targetCon.Definition),
+				})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+func detectEnumConflicts(source, target *Schema) []Conflict {
+	var conflicts []Conflict
+
+	for name, sourceEnum := range source.Enums {
+		targetEnum, ok := target.Enums[name]
+		if !ok {
+			continue
+		}
+
+		inSource := make(map[string]bool)
+		for _, v := range sourceEnum.Values {
+			inSource[v] = true
+		}
+		inTarget := make(map[string]bool)
+		for _, v := range targetEnum.Values {
+			inTarget[v] = true
+		}
+
+		// Only values present on both sides are compared; values added on
+		// one side can be inserted with ADD VALUE ... BEFORE/AFTER.
+		var sourceOrder, targetOrder []string
+		for _, v := range sourceEnum.Values {
+			if inTarget[v] {
+				sourceOrder = append(sourceOrder, v)
+			}
+		}
+		for _, v := range targetEnum.Values {
+			if inSource[v] {
+				targetOrder = append(targetOrder, v)
+			}
+		}
+
+		if strings.Join(sourceOrder, ",") == strings.Join(targetOrder, ",") {
+			continue
+		}
+		conflicts = append(conflicts, Conflict{
+			Object: sourceEnum.FullName(),
+			Description: fmt.Sprintf("enum values are ordered (%s) on source but (%s) on target",
+				strings.Join(sourceOrder, ", "), strings.Join(targetOrder, ", ")),
+		})
+	}
+
+	return conflicts
+}
@@ -1,6 +1,9 @@
 package schema
 
 import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -95,6 +98,18 @@ func TestColumnEquals(t *testing.T) {
 			col2:     Column{Name: "id", DataType: "integer"},
 			expected: false,
 		},
+		{
+			name:     "different generation expressions",
+			col1:     Column{Name: "total", DataType: "numeric", Generated: "(price * qty)"},
+			col2:     Column{Name: "total", DataType: "numeric", Generated: "(price * (qty + 1))"},
+			expected: false,
+		},
+		{
+			name:     "different collations",
+			col1:     Column{Name: "name", DataType: "text", Collation: "C"},
+			col2:     Column{Name: "name", DataType: "text"},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -104,6 +119,54 @@ func TestColumnEquals(t *testing.T) {
 	}
 }
 
+func TestNormalizeDefault(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"public.gen_random_uuid()", "gen_random_uuid()"},
+		{`"public".gen_random_uuid()`, "gen_random_uuid()"},
+		{"gen_random_uuid()", "gen_random_uuid()"},
+		{"nextval('public.users_id_seq'::regclass)", "nextval('users_id_seq'::regclass)"},
+		{"billing.next_invoice()", "billing.next_invoice()"},
+		{"mypublic.fn()", "mypublic.fn()"},
+		{"  now()  +   interval '1  day'", "now() + interval '1  day'"},
+		{"'a\tb'::text", "'a\tb'::text"},
+		{"'public.x'::text", "'public.x'::text"},
+		{"'it''s public.x'::text", "'it''s public.x'::text"},
+		{"public.f('public.x')", "f('public.x')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeDefault(tt.input))
+		})
+	}
+}
+
+func TestDefaultsEqualKeepsQualifierInLiterals(t *testing.T) {
+	assert.False(t, defaultsEqual("'public.x'::text", "'x'::text"))
+	assert.True(t, defaultsEqual("nextval('public.users_id_seq'::regclass)", "nextval('users_id_seq'::regclass)"))
+}
+
+func TestDiffIgnoresCosmeticDefaultChanges(t *testing.T) {
+	from := NewSchema("test")
+	to := NewSchema("test")
+
+	from.Tables["users"] = NewTable("users", "public")
+	from.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "uuid", Position: 1, DefaultValue: strPtr("public.gen_random_uuid()")}
+
+	to.Tables["users"] = NewTable("users", "public")
+	to.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "uuid", Position: 1, DefaultValue: strPtr("gen_random_uuid()")}
+
+	assert.True(t, Diff(from, to).IsEmpty())
+
+	to.Tables["users"].Columns["id"].DefaultValue = strPtr("uuid_generate_v4()")
+	cs := Diff(from, to)
+	require.Len(t, cs.Changes, 1)
+	assert.True(t, cs.Changes[0].(*AlterColumnChange).Alteration.DefaultChanged)
+}
+
 func TestDiffTables(t *testing.T) {
 	t.Run("detect new table", func(t *testing.T) {
 		from := NewSchema("test")
@@ -219,6 +282,262 @@ func TestDiffColumns(t *testing.T) {
 	})
 }
 
+func TestDiffComments(t *testing.T) {
+	from := NewSchema("test")
+	to := NewSchema("test")
+
+	from.Tables["users"] = NewTable("users", "public")
+	from.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+	from.Tables["users"].Columns["email"] = &Column{Name: "email", DataType: "text", Position: 2, Comment: "Login email"}
+
+	to.Tables["users"] = NewTable("users", "public")
+	to.Tables["users"].Comment = "Registered users"
+	to.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1, Comment: "Primary key"}
+	to.Tables["users"].Columns["email"] = &Column{Name: "email", DataType: "text", Position: 2}
+
+	cs := Diff(from, to)
+
+	require.Len(t, cs.Changes, 3)
+	comments := make(map[string]*CommentChange)
+	for _, c := range cs.Changes {
+		require.Equal(t, ChangeComment, c.Type())
+		assert.False(t, c.IsDestructive())
+		comments[c.ObjectName()] = c.(*CommentChange)
+	}
+
+	require.Contains(t, comments, "users")
+	assert.Equal(t, "Registered users", comments["users"].NewComment)
+	assert.Equal(t, "Set comment on table users", comments["users"].Description())
+
+	require.Contains(t, comments, "users.id")
+	assert.Equal(t, "Primary key", comments["users.id"].NewComment)
+
+	require.Contains(t, comments, "users.email")
+	assert.Equal(t, "Login email", comments["users.email"].OldComment)
+	assert.Empty(t, comments["users.email"].NewComment)
+	assert.Equal(t, "Remove comment on column users.email", comments["users.email"].Description())
+}
+
+func TestDiffIndexDefinitions(t *testing.T) {
+	newSchemas := func(fromDef, toDef string) (*Schema, *Schema) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Tables["users"] = NewTable("users", "public")
+		from.Tables["users"].Indexes["idx_users"] = &Index{
+			Name: "idx_users", TableName: "users", Type: "btree", Columns: []string{"email"}, Definition: fromDef,
+		}
+
+		to.Tables["users"] = NewTable("users", "public")
+		to.Tables["users"].Indexes["idx_users"] = &Index{
+			Name: "idx_users", TableName: "users", Type: "btree", Columns: []string{"email"}, Definition: toDef,
+		}
+
+		return from, to
+	}
+
+	t.Run("partial index predicate change", func(t *testing.T) {
+		from, to := newSchemas(
+			"CREATE INDEX idx_users ON public.users USING btree (email) WHERE (deleted_at IS NULL)",
+			"CREATE INDEX idx_users ON public.users USING btree (email) WHERE (active = true)",
+		)
+
+		cs := OrderChanges(Diff(from, to))
+
+		require.Len(t, cs.Changes, 2)
+		assert.Equal(t, ChangeDropIndex, cs.Changes[0].Type())
+		assert.Equal(t, ChangeCreateIndex, cs.Changes[1].Type())
+
+		gen := NewSQLGenerator()
+		assert.Equal(t,
+			"CREATE INDEX idx_users ON public.users USING btree (email) WHERE (active = true);",
+			gen.GenerateChange(cs.Changes[1]))
+	})
+
+	t.Run("expression index change", func(t *testing.T) {
+		from, to := newSchemas(
+			"CREATE INDEX idx_users ON public.users USING btree (lower(email))",
+			"CREATE INDEX idx_users ON public.users USING btree (upper(email))",
+		)
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 2)
+		assert.Len(t, cs.ByType(ChangeDropIndex), 1)
+		assert.Len(t, cs.ByType(ChangeCreateIndex), 1)
+	})
+
+	t.Run("identical definitions", func(t *testing.T) {
+		def := "CREATE INDEX idx_users ON public.users USING btree (email) INCLUDE (name)"
+		from, to := newSchemas(def, def)
+
+		assert.True(t, Diff(from, to).IsEmpty())
+	})
+}
+
+func TestThreeWayDiff(t *testing.T) {
+	newUsers := func() *Schema {
+		s := NewSchema("test")
+		s.Tables["users"] = NewTable("users", "public")
+		s.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+		s.Tables["users"].Columns["email"] = &Column{Name: "email", DataType: "text", Position: 2, IsNullable: true}
+		return s
+	}
+
+	ancestor := newUsers()
+
+	source := newUsers()
+	source.Tables["users"].Columns["name"] = &Column{Name: "name", DataType: "text", Position: 3, IsNullable: true}
+	source.Tables["users"].Columns["email"].DataType = "varchar"
+	source.Tables["posts"] = NewTable("posts", "public")
+	source.Tables["posts"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+
+	target := newUsers()
+	target.Tables["users"].Columns["email"].IsNullable = false
+	target.Tables["posts"] = NewTable("posts", "public")
+	target.Tables["posts"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+	target.Tables["audit"] = NewTable("audit", "public")
+
+	cs, conflicts := ThreeWayDiff(ancestor, source, target)
+
+	// Only the new column is applied: posts already exists on target, the
+	// email change conflicts, and target's audit table is left alone.
+	require.Len(t, cs.Changes, 1)
+	assert.Equal(t, ChangeAddColumn, cs.Changes[0].Type())
+	assert.Equal(t, "users.name", cs.Changes[0].ObjectName())
+
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "users.email", conflicts[0].Object)
+	require.Len(t, conflicts[0].Source, 1)
+	require.Len(t, conflicts[0].Target, 1)
+	assert.Equal(t, ChangeAlterColumn, conflicts[0].Target[0].Type())
+}
+
+func TestThreeWayDiffTableColumnConflict(t *testing.T) {
+	ancestor := NewSchema("test")
+	ancestor.Tables["users"] = NewTable("users", "public")
+	ancestor.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+
+	source := NewSchema("test")
+	source.Tables["users"] = NewTable("users", "public")
+	source.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+	source.Tables["users"].Columns["name"] = &Column{Name: "name", DataType: "text", Position: 2}
+
+	target := NewSchema("test")
+
+	cs, conflicts := ThreeWayDiff(ancestor, source, target)
+
+	assert.True(t, cs.IsEmpty())
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "users.name", conflicts[0].Object)
+	assert.Equal(t, ChangeDropTable, conflicts[0].Target[0].Type())
+}
+
+func TestDetectConflicts(t *testing.T) {
+	source := NewSchema("test")
+	source.Tables["users"] = NewTable("users", "public")
+	source.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+	source.Tables["users"].Columns["x"] = &Column{Name: "x", DataType: "integer", Position: 2}
+	source.Tables["users"].Constraints["users_x_check"] = &Constraint{
+		Name: "users_x_check", Type: ConstraintCheck, TableName: "users", Definition: "CHECK ((x > 0))",
+	}
+	source.Tables["users"].Constraints["shared_key"] = &Constraint{
+		Name: "shared_key", Type: ConstraintUnique, TableName: "users", Definition: "UNIQUE (id)",
+	}
+	source.Enums["status"] = &Enum{Name: "status", Schema: "public", Values: []string{"active", "new", "archived"}}
+	source.Enums["role"] = &Enum{Name: "role", Schema: "public", Values: []string{"user", "staff", "admin"}}
+
+	target := NewSchema("test")
+	target.Tables["users"] = NewTable("users", "public")
+	target.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+	target.Tables["users"].Columns["x"] = &Column{Name: "x", DataType: "text", Position: 2}
+	target.Tables["users"].Constraints["users_x_check"] = &Constraint{
+		Name: "users_x_check", Type: ConstraintCheck, TableName: "users", Definition: "CHECK ((x <> ''::text))",
+	}
+	target.Tables["accounts"] = NewTable("accounts", "public")
+	target.Tables["accounts"].Constraints["shared_key"] = &Constraint{
+		Name: "shared_key", Type: ConstraintUnique, TableName: "accounts", Definition: "UNIQUE (id)",
+	}
+	target.Enums["status"] = &Enum{Name: "status", Schema: "public", Values: []string{"new", "active"}}
+	target.Enums["role"] = &Enum{Name: "role", Schema: "public", Values: []string{"user", "admin"}}
+
+	conflicts := DetectConflicts(source, target)
+
+	require.Len(t, conflicts, 4)
+	assert.Equal(t, "shared_key", conflicts[0].Object)
+	assert.Contains(t, conflicts[0].Description, "on table users on source but accounts on target")
+	assert.Equal(t, "status", conflicts[1].Object)
+	assert.Equal(t, "enum values are ordered (active, new) on source but (new, active) on target", conflicts[1].Description)
+	assert.Equal(t, "users.x", conflicts[2].Object)
+	assert.Equal(t, "users.x: column is integer on source but text on target", conflicts[2].String())
+	assert.Equal(t, "users_x_check", conflicts[3].Object)
+}
+
+func TestDetectConflictsIdenticalSchemas(t *testing.T) {
+	newSchema := func() *Schema {
+		s := NewSchema("test")
+		s.Tables["users"] = NewTable("users", "public")
+		s.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+		s.Tables["users"].Constraints["users_pkey"] = &Constraint{
+			Name: "users_pkey", Type: ConstraintPrimaryKey, TableName: "users", Definition: "PRIMARY KEY (id)",
+		}
+		s.Enums["status"] = &Enum{Name: "status", Schema: "public", Values: []string{"new", "active"}}
+		return s
+	}
+
+	assert.Empty(t, DetectConflicts(newSchema(), newSchema()))
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	newSchema := func(name string) *Schema {
+		s := NewSchema(name)
+		s.Tables["users"] = NewTable("users", "public")
+		s.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1}
+		s.Tables["users"].Columns["email"] = &Column{Name: "email", DataType: "text", Position: 2}
+		s.Enums["status"] = &Enum{Name: "status", Schema: "public", Values: []string{"new", "active"}}
+		return s
+	}
+
+	a, err := newSchema("app_pgbranch_main").Fingerprint()
+	require.NoError(t, err)
+	b, err := newSchema("app_pgbranch_feature").Fingerprint()
+	require.NoError(t, err)
+	assert.Equal(t, a, b, "fingerprint should not depend on the database name")
+
+	changed := newSchema("app_pgbranch_main")
+	changed.Tables["users"].Columns["email"].DataType = "varchar"
+	c, err := changed.Fingerprint()
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+}
+
+func TestSchemaSaveLoadFile(t *testing.T) {
+	recorded := NewSchema("app")
+	recorded.Tables["users"] = NewTable("users", "public")
+	recorded.Tables["users"].Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1, DefaultValue: strPtr("nextval('users_id_seq'::regclass)")}
+
+	path := filepath.Join(t.TempDir(), "schemas", "app.json")
+	require.NoError(t, recorded.SaveFile(path))
+
+	loaded, err := LoadFile(path)
+	require.NoError(t, err)
+
+	want, _ := recorded.Fingerprint()
+	got, _ := loaded.Fingerprint()
+	assert.Equal(t, want, got)
+
+	current, err := LoadFile(path)
+	require.NoError(t, err)
+	current.Tables["users"].Columns["name"] = &Column{Name: "name", DataType: "text", Position: 2, IsNullable: true}
+
+	cs := DiffAgainstRecorded(loaded, current)
+	require.Len(t, cs.Changes, 1)
+	assert.Equal(t, ChangeAddColumn, cs.Changes[0].Type())
+
+	_, err = LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
 func TestDiffEnums(t *testing.T) {
 	t.Run("detect new enum", func(t *testing.T) {
 		from := NewSchema("test")
@@ -259,6 +578,76 @@ func TestDiffEnums(t *testing.T) {
 		assert.Equal(t, "deleted", addVal.Value)
 		assert.Equal(t, "active", addVal.After)
 	})
+
+	t.Run("detect removed enum value", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Enums["status"] = &Enum{Name: "status", Values: []string{"pending", "active", "deleted"}}
+		to.Enums["status"] = &Enum{Name: "status", Values: []string{"pending", "active"}}
+		from.Tables["users"] = &Table{
+			Name: "users",
+			Columns: map[string]*Column{
+				"id":     {Name: "id", DataType: "integer", Position: 1},
+				"status": {Name: "status", DataType: "status", Position: 2},
+			},
+		}
+		to.Tables["users"] = from.Tables["users"]
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		drop, ok := cs.Changes[0].(*DropEnumValueChange)
+		require.True(t, ok)
+		assert.Equal(t, []string{"deleted"}, drop.Values())
+		require.Len(t, drop.Columns, 1)
+		assert.Equal(t, "users", drop.Columns[0].TableName)
+		assert.Equal(t, "status", drop.Columns[0].Column.Name)
+		assert.True(t, drop.IsDestructive())
+		assert.Equal(t, "Drop value(s) 'deleted' from enum status", drop.Description())
+	})
+
+	t.Run("detect reordered enum values", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Enums["letter"] = &Enum{Name: "letter", Values: []string{"a", "b"}}
+		to.Enums["letter"] = &Enum{Name: "letter", Values: []string{"b", "a"}}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		reorder, ok := cs.Changes[0].(*ReorderEnumChange)
+		require.True(t, ok)
+		assert.False(t, reorder.IsDestructive())
+		assert.Equal(t, "Reorder values of enum letter to (b, a)", reorder.Description())
+	})
+
+	t.Run("added values are not a reorder", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Enums["letter"] = &Enum{Name: "letter", Values: []string{"a", "c"}}
+		to.Enums["letter"] = &Enum{Name: "letter", Values: []string{"a", "b", "c"}}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		assert.Equal(t, ChangeAddEnumValue, cs.Changes[0].Type())
+	})
+
+	t.Run("reorder with dropped values is one change", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Enums["letter"] = &Enum{Name: "letter", Values: []string{"a", "b", "c"}}
+		to.Enums["letter"] = &Enum{Name: "letter", Values: []string{"b", "a"}}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		assert.Equal(t, ChangeDropEnumValue, cs.Changes[0].Type())
+	})
 }
 
 func TestDiffFunctions(t *testing.T) {
@@ -305,6 +694,214 @@ func TestDiffFunctions(t *testing.T) {
 	})
 }
 
+func TestDiffSequences(t *testing.T) {
+	t.Run("detect new owned sequence", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		to.Sequences["orders_id_seq"] = &Sequence{
+			Name:          "orders_id_seq",
+			DataType:      "integer",
+			Start:         1,
+			Increment:     1,
+			MinValue:      1,
+			MaxValue:      2147483647,
+			Cache:         1,
+			OwnedByTable:  "orders",
+			OwnedByColumn: "id",
+		}
+
+		cs := OrderChanges(Diff(from, to))
+
+		require.Len(t, cs.Changes, 2)
+		assert.Equal(t, ChangeCreateSequence, cs.Changes[0].Type())
+		assert.Equal(t, ChangeAlterSequence, cs.Changes[1].Type())
+		assert.Equal(t, "Alter sequence orders_id_seq: owned by orders.id", cs.Changes[1].Description())
+	})
+
+	t.Run("detect altered sequence", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Sequences["invoice_no"] = &Sequence{Name: "invoice_no", DataType: "bigint", Increment: 1, Cache: 1}
+		to.Sequences["invoice_no"] = &Sequence{Name: "invoice_no", DataType: "bigint", Increment: 10, Cache: 1}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		assert.Equal(t, ChangeAlterSequence, cs.Changes[0].Type())
+		assert.Equal(t, "Alter sequence invoice_no: increment 1 → 10", cs.Changes[0].Description())
+	})
+
+	t.Run("sequence dropped with its table is not dropped again", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		table := NewTable("orders", "public")
+		table.Columns["id"] = &Column{Name: "id", DataType: "integer"}
+		from.Tables["orders"] = table
+		from.Sequences["orders_id_seq"] = &Sequence{
+			Name:          "orders_id_seq",
+			OwnedByTable:  "orders",
+			OwnedByColumn: "id",
+		}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		assert.Equal(t, ChangeDropTable, cs.Changes[0].Type())
+	})
+}
+
+func TestDiffSerialToIdentity(t *testing.T) {
+	serial := NewSchema("test")
+	serialTable := NewTable("users", "public")
+	serialTable.Columns["id"] = &Column{
+		Name:         "id",
+		DataType:     "integer",
+		DefaultValue: strPtr("nextval('users_id_seq'::regclass)"),
+	}
+	serial.Tables["users"] = serialTable
+	serial.Sequences["users_id_seq"] = &Sequence{
+		Name:          "users_id_seq",
+		DataType:      "integer",
+		OwnedByTable:  "users",
+		OwnedByColumn: "id",
+	}
+
+	identity := NewSchema("test")
+	identityTable := NewTable("users", "public")
+	identityTable.Columns["id"] = &Column{
+		Name:     "id",
+		DataType: "integer",
+		Identity: "ALWAYS",
+	}
+	identity.Tables["users"] = identityTable
+
+	cs := OrderChanges(Diff(serial, identity))
+
+	require.Len(t, cs.Changes, 2)
+	assert.Equal(t, ChangeAlterColumn, cs.Changes[0].Type())
+	assert.Equal(t, "Alter column users.id: drop default, add identity (generated ALWAYS)", cs.Changes[0].Description())
+	assert.Equal(t, ChangeDropSequence, cs.Changes[1].Type())
+
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+	assert.Equal(t, []string{
+		"ALTER TABLE users ALTER COLUMN id DROP DEFAULT;\n" +
+			"ALTER TABLE users ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY;\n" +
+			"SELECT setval(pg_get_serial_sequence('users', 'id'), coalesce(max(id), 0) + 1, false) FROM users;",
+		"DROP SEQUENCE users_id_seq;",
+	}, gen.Generate(cs))
+}
+
+func TestDiffViews(t *testing.T) {
+	t.Run("detect new and dropped views", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Views["old_report"] = &View{Name: "old_report", DefinitionHash: "aaa"}
+		to.Views["active_users"] = &View{Name: "active_users", DefinitionHash: "bbb"}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 2)
+		assert.Len(t, cs.ByType(ChangeDropView), 1)
+		assert.Len(t, cs.ByType(ChangeCreateView), 1)
+	})
+
+	t.Run("detect changed definition", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Views["active_users"] = &View{Name: "active_users", DefinitionHash: "aaa"}
+		to.Views["active_users"] = &View{Name: "active_users", DefinitionHash: "bbb"}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		assert.Equal(t, ChangeReplaceView, cs.Changes[0].Type())
+	})
+
+	t.Run("unchanged view", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+
+		from.Views["active_users"] = &View{Name: "active_users", DefinitionHash: "aaa"}
+		to.Views["active_users"] = &View{Name: "active_users", DefinitionHash: "aaa"}
+
+		assert.True(t, Diff(from, to).IsEmpty())
+	})
+}
+
+func TestDiffMultipleSchemas(t *testing.T) {
+	from := NewSchema("test")
+	from.Tables["users"] = NewTable("users", "public")
+
+	to := NewSchema("test")
+	to.Namespaces = []string{"audit"}
+	to.Tables["users"] = NewTable("users", "public")
+	auditUsers := NewTable("users", "audit")
+	auditUsers.Columns["id"] = &Column{Name: "id", DataType: "bigint", Position: 1}
+	to.Tables[auditUsers.FullName()] = auditUsers
+
+	cs := OrderChanges(Diff(from, to))
+
+	require.Len(t, cs.Changes, 2)
+	assert.Equal(t, ChangeCreateSchema, cs.Changes[0].Type())
+	assert.Equal(t, ChangeCreateTable, cs.Changes[1].Type())
+	assert.Equal(t, "audit.users", cs.Changes[1].ObjectName())
+
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+	assert.Equal(t, []string{
+		"CREATE SCHEMA audit;",
+		"CREATE TABLE audit.users (\n    id bigint NOT NULL\n);",
+	}, gen.Generate(cs))
+
+	t.Run("drop schema last", func(t *testing.T) {
+		cs := OrderChanges(Diff(to, from))
+
+		require.Len(t, cs.Changes, 2)
+		assert.Equal(t, ChangeDropTable, cs.Changes[0].Type())
+		assert.Equal(t, ChangeDropSchema, cs.Changes[1].Type())
+		assert.Equal(t, "DROP SCHEMA audit;", gen.GenerateChange(cs.Changes[1]))
+	})
+}
+
+func TestDiffSameTableNameInTwoSchemas(t *testing.T) {
+	build := func(analyticsType string) *Schema {
+		s := NewSchema("test")
+		s.Namespaces = []string{"analytics"}
+		for _, table := range []*Table{NewTable("events", "public"), NewTable("events", "analytics")} {
+			colType := "text"
+			if table.Schema == "analytics" {
+				colType = analyticsType
+			}
+			table.Columns["payload"] = &Column{Name: "payload", DataType: colType, IsNullable: true}
+			s.Tables[table.FullName()] = table
+		}
+		return s
+	}
+
+	from := build("text")
+	to := build("jsonb")
+
+	require.Len(t, from.Tables, 2)
+
+	cs := Diff(from, to)
+
+	require.Len(t, cs.Changes, 1)
+	alter, ok := cs.Changes[0].(*AlterColumnChange)
+	require.True(t, ok)
+	assert.Equal(t, "analytics.events.payload", alter.ObjectName())
+
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+	assert.Equal(t, "ALTER TABLE analytics.events ALTER COLUMN payload TYPE jsonb USING payload::jsonb;",
+		gen.GenerateChange(alter))
+}
+
 func TestChangeSetDestructive(t *testing.T) {
 	cs := NewChangeSet()
 
@@ -345,6 +942,26 @@ func TestSQLGeneration(t *testing.T) {
 		assert.Equal(t, "ALTER TABLE users ADD COLUMN email text NOT NULL;", sql)
 	})
 
+	t.Run("add generated column", func(t *testing.T) {
+		change := &AddColumnChange{
+			TableName: "orders",
+			Column:    &Column{Name: "total", DataType: "numeric", IsNullable: true, Generated: "(price * qty)"},
+		}
+
+		sql := gen.GenerateChange(change)
+		assert.Equal(t, "ALTER TABLE orders ADD COLUMN total numeric GENERATED ALWAYS AS ((price * qty)) STORED;", sql)
+	})
+
+	t.Run("add column with collation", func(t *testing.T) {
+		change := &AddColumnChange{
+			TableName: "users",
+			Column:    &Column{Name: "code", DataType: "text", IsNullable: true, Collation: "C"},
+		}
+
+		sql := gen.GenerateChange(change)
+		assert.Equal(t, `ALTER TABLE users ADD COLUMN code text COLLATE "C";`, sql)
+	})
+
 	t.Run("drop column", func(t *testing.T) {
 		change := &DropColumnChange{
 			TableName: "users",
@@ -355,6 +972,17 @@ func TestSQLGeneration(t *testing.T) {
 		assert.Equal(t, "ALTER TABLE users DROP COLUMN old_field;", sql)
 	})
 
+	t.Run("rename column", func(t *testing.T) {
+		change := &RenameColumnChange{
+			TableName: "app.users",
+			OldName:   "name",
+			Column:    &Column{Name: "Full Name", DataType: "text"},
+		}
+
+		sql := gen.GenerateChange(change)
+		assert.Equal(t, `ALTER TABLE app.users RENAME COLUMN name TO "Full Name";`, sql)
+	})
+
 	t.Run("create enum", func(t *testing.T) {
 		change := &CreateEnumChange{
 			Enum: &Enum{
@@ -377,6 +1005,45 @@ func TestSQLGeneration(t *testing.T) {
 		sql := gen.GenerateChange(change)
 		assert.Equal(t, "ALTER TYPE status ADD VALUE 'deleted' AFTER 'active';", sql)
 	})
+
+	t.Run("drop enum value", func(t *testing.T) {
+		def := "'pending'::app.status"
+		change := &DropEnumValueChange{
+			OldEnum: &Enum{Name: "status", Schema: "app", Values: []string{"pending", "active", "deleted"}},
+			NewEnum: &Enum{Name: "status", Schema: "app", Values: []string{"pending", "active"}},
+			Columns: []EnumColumn{
+				{TableName: "orders", Column: &Column{Name: "history", DataType: "app.status", IsArray: true}},
+				{TableName: "users", Column: &Column{Name: "status", DataType: "app.status", DefaultValue: &def}},
+			},
+		}
+
+		sql := gen.GenerateChange(change)
+		assert.Equal(t, strings.Join([]string{
+			"ALTER TYPE app.status RENAME TO status_old;",
+			"CREATE TYPE app.status AS ENUM ('pending', 'active');",
+			"ALTER TABLE orders ALTER COLUMN history TYPE app.status[] USING history::text[]::app.status[];",
+			"ALTER TABLE users ALTER COLUMN status DROP DEFAULT;",
+			"ALTER TABLE users ALTER COLUMN status TYPE app.status USING status::text::app.status;",
+			"ALTER TABLE users ALTER COLUMN status SET DEFAULT 'pending'::app.status;",
+			"DROP TYPE app.status_old;",
+		}, "\n"), sql)
+	})
+
+	t.Run("reorder enum", func(t *testing.T) {
+		change := &ReorderEnumChange{
+			OldEnum: &Enum{Name: "letter", Values: []string{"a", "b"}},
+			NewEnum: &Enum{Name: "letter", Values: []string{"b", "a"}},
+			Columns: []EnumColumn{{TableName: "words", Column: &Column{Name: "initial", DataType: "letter"}}},
+		}
+
+		sql := gen.GenerateChange(change)
+		assert.Equal(t, strings.Join([]string{
+			"ALTER TYPE letter RENAME TO letter_old;",
+			"CREATE TYPE letter AS ENUM ('b', 'a');",
+			"ALTER TABLE words ALTER COLUMN initial TYPE letter USING initial::text::letter;",
+			"DROP TYPE letter_old;",
+		}, "\n"), sql)
+	})
 }
 
 func TestOrderChanges(t *testing.T) {
@@ -398,6 +1065,43 @@ func TestOrderChanges(t *testing.T) {
 	assert.Equal(t, ChangeDropColumn, ordered.Changes[3].Type())
 }
 
+func TestChangeSetToJSON(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&DropColumnChange{TableName: "users", Column: &Column{Name: "old"}})
+	cs.Add(&CreateTableChange{Table: &Table{Name: "zebras", Schema: "public"}})
+	cs.Add(&CreateTableChange{Table: &Table{Name: "apples", Schema: "public"}})
+
+	data, err := cs.ToJSON()
+	require.NoError(t, err)
+
+	var out ChangeSetJSON
+	require.NoError(t, json.Unmarshal(data, &out))
+
+	require.Len(t, out.Changes, 3)
+	assert.Equal(t, ChangeJSON{Type: ChangeCreateTable, Object: "apples", Description: "Create table apples"}, out.Changes[0])
+	assert.Equal(t, "zebras", out.Changes[1].Object)
+	assert.Equal(t, ChangeDropColumn, out.Changes[2].Type)
+	assert.True(t, out.Changes[2].Destructive)
+	assert.Equal(t, 3, out.Total)
+	assert.Equal(t, 1, out.Destructive)
+	assert.Equal(t, 2, out.Summary[ChangeCreateTable])
+
+	again, err := cs.ToJSON()
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(again))
+}
+
+func TestEmptyChangeSetToJSON(t *testing.T) {
+	data, err := NewChangeSet().ToJSON()
+	require.NoError(t, err)
+
+	var out ChangeSetJSON
+	require.NoError(t, json.Unmarshal(data, &out))
+	assert.NotNil(t, out.Changes)
+	assert.Empty(t, out.Changes)
+	assert.Equal(t, 0, out.Total)
+}
+
 func TestTableFullName(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -500,6 +1204,12 @@ func TestIndexEquals(t *testing.T) {
 			other:    Index{Name: "idx_other", IsUnique: true, Type: "btree", Columns: []string{"email"}},
 			expected: false,
 		},
+		{
+			name: "different definition",
+			other: Index{Name: "idx_users_email", IsUnique: true, Type: "btree", Columns: []string{"email"},
+				Definition: "CREATE UNIQUE INDEX idx_users_email ON public.users USING btree (email) WHERE (active)"},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -740,6 +1450,43 @@ func TestChangeSetSummary(t *testing.T) {
 	assert.Equal(t, 1, summary[ChangeCreateTable])
 }
 
+func TestChangeSetRemove(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&AddEnumValueChange{EnumName: "status", Value: "archived"})
+	cs.Add(&DropEnumValueChange{OldEnum: &Enum{Name: "mood"}, NewEnum: &Enum{Name: "mood"}})
+	cs.Add(&CreateSchemaChange{Name: "billing"})
+
+	removed := cs.Remove(ChangeDropEnumValue)
+
+	require.Len(t, removed, 1)
+	assert.Equal(t, "mood", removed[0].ObjectName())
+	require.Len(t, cs.Changes, 2)
+	assert.Equal(t, ChangeAddEnumValue, cs.Changes[0].Type())
+	assert.Equal(t, ChangeCreateSchema, cs.Changes[1].Type())
+	assert.Empty(t, cs.Remove(ChangeDropEnumValue))
+}
+
+func TestChangeSetSortedByType(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&CreateIndexChange{Index: &Index{Name: "idx_users_name"}})
+	cs.Add(&AddEnumValueChange{EnumName: "status", Value: "archived"})
+	cs.Add(&CreateIndexChange{Index: &Index{Name: "idx_orders_total"}})
+	cs.Add(&AddEnumValueChange{EnumName: "mood", Value: "ok"})
+	cs.Add(&AddEnumValueChange{EnumName: "status", Value: "deleted"})
+
+	var names []string
+	for _, c := range cs.SortedByType(ChangeCreateIndex) {
+		names = append(names, c.ObjectName())
+	}
+	assert.Equal(t, []string{"idx_orders_total", "idx_users_name"}, names)
+
+	var values []string
+	for _, c := range cs.SortedByType(ChangeAddEnumValue) {
+		values = append(values, c.(*AddEnumValueChange).Value)
+	}
+	assert.Equal(t, []string{"ok", "archived", "deleted"}, values, "values added to one enum keep their order")
+}
+
 func TestCreateTableChange(t *testing.T) {
 	c := &CreateTableChange{Table: &Table{Name: "users", Schema: "public"}}
 
@@ -776,6 +1523,76 @@ func TestDropColumnChange(t *testing.T) {
 	assert.Equal(t, "Drop column users.old_field", c.Description())
 }
 
+func TestRenameCandidates(t *testing.T) {
+	text := func(name string) *Column { return &Column{Name: name, DataType: "text", IsNullable: true} }
+
+	from := NewSchema("from")
+	to := NewSchema("to")
+	for _, s := range []*Schema{from, to} {
+		for _, name := range []string{"users", "orders", "events"} {
+			s.Tables[name] = NewTable(name, "public")
+		}
+	}
+
+	// users: one drop and one add with the same definition.
+	from.Tables["users"].Columns["name"] = text("name")
+	to.Tables["users"].Columns["full_name"] = text("full_name")
+
+	// orders: same shape, but the type differs.
+	from.Tables["orders"].Columns["total"] = text("total")
+	to.Tables["orders"].Columns["amount"] = &Column{Name: "amount", DataType: "numeric", IsNullable: true}
+
+	// events: two drops and two adds, so the pairing would be a guess.
+	from.Tables["events"].Columns["a"] = text("a")
+	from.Tables["events"].Columns["b"] = text("b")
+	to.Tables["events"].Columns["c"] = text("c")
+	to.Tables["events"].Columns["d"] = text("d")
+
+	cs := Diff(from, to)
+	assert.Equal(t, []RenameCandidate{{TableName: "users", OldName: "name", NewName: "full_name"}}, cs.RenameCandidates())
+}
+
+func TestChangeSetRenameColumn(t *testing.T) {
+	newChangeSet := func() *ChangeSet {
+		cs := NewChangeSet()
+		cs.Add(&DropColumnChange{TableName: "users", Column: &Column{Name: "name", DataType: "text"}})
+		cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "full_name", DataType: "text"}})
+		cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "age", DataType: "integer"}})
+		return cs
+	}
+
+	t.Run("replaces the drop and add", func(t *testing.T) {
+		cs := newChangeSet()
+		require.NoError(t, cs.RenameColumn("users.name", "full_name"))
+
+		require.Len(t, cs.Changes, 2)
+		rename, ok := cs.Changes[0].(*RenameColumnChange)
+		require.True(t, ok)
+		assert.Equal(t, "name", rename.OldName)
+		assert.Equal(t, "users.full_name", rename.ObjectName())
+		assert.False(t, cs.HasDestructive())
+		assert.Empty(t, cs.RenameCandidates())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cs := newChangeSet()
+
+		err := cs.RenameColumn("users.missing", "full_name")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no dropped column 'users.missing'")
+
+		err = cs.RenameColumn("users.name", "nickname")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no added column 'nickname'")
+
+		err = cs.RenameColumn("users.name", "age")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "defined differently")
+
+		assert.Len(t, cs.Changes, 3, "failed renames leave the change set alone")
+	})
+}
+
 func TestAlterColumnChange(t *testing.T) {
 	t.Run("type change is destructive", func(t *testing.T) {
 		c := &AlterColumnChange{
@@ -959,73 +1776,486 @@ func TestDropFunctionChange(t *testing.T) {
 	assert.Equal(t, "Drop function greet(text)", c.Description())
 }
 
-func TestReplaceFunctionChange(t *testing.T) {
-	c := &ReplaceFunctionChange{
-		OldFunction: &Function{Name: "greet", Schema: "public", Arguments: "text"},
-		NewFunction: &Function{Name: "greet", Schema: "public", Arguments: "text"},
+func TestReplaceFunctionChange(t *testing.T) {
+	c := &ReplaceFunctionChange{
+		OldFunction: &Function{Name: "greet", Schema: "public", Arguments: "text"},
+		NewFunction: &Function{Name: "greet", Schema: "public", Arguments: "text"},
+	}
+	assert.Equal(t, ChangeReplaceFunction, c.Type())
+	assert.False(t, c.IsDestructive())
+	assert.Equal(t, "greet(text)", c.ObjectName())
+	assert.Equal(t, "Replace function greet(text)", c.Description())
+}
+
+func TestGenerateDropTable(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+
+	sql := gen.GenerateChange(&DropTableChange{Table: &Table{Name: "users", Schema: "public"}})
+	assert.Equal(t, "DROP TABLE users;", sql)
+}
+
+func TestGenerateAddColumnSafeMode(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.SafeMode = true
+
+	t.Run("not null with default is backfilled", func(t *testing.T) {
+		sql := gen.GenerateChange(&AddColumnChange{
+			TableName: "users",
+			Column:    &Column{Name: "active", DataType: "boolean", DefaultValue: strPtr("true")},
+		})
+		assert.Equal(t, "ALTER TABLE users ADD COLUMN active boolean;\n"+
+			"ALTER TABLE users ALTER COLUMN active SET DEFAULT true;", sql)
+	})
+
+	t.Run("not null without default leaves backfill to the user", func(t *testing.T) {
+		sql := gen.GenerateChange(&AddColumnChange{
+			TableName: "users",
+			Column:    &Column{Name: "email", DataType: "text"},
+		})
+		assert.Equal(t, "ALTER TABLE users ADD COLUMN email text;\n"+
+			"-- TODO: backfill users.email, then run: ALTER TABLE users ALTER COLUMN email SET NOT NULL;", sql)
+	})
+
+	t.Run("nullable column is a single statement", func(t *testing.T) {
+		sql := gen.GenerateChange(&AddColumnChange{
+			TableName: "users",
+			Column:    &Column{Name: "bio", DataType: "text", IsNullable: true},
+		})
+		assert.Equal(t, "ALTER TABLE users ADD COLUMN bio text;", sql)
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		sql := NewSQLGenerator().GenerateChange(&AddColumnChange{
+			TableName: "users",
+			Column:    &Column{Name: "active", DataType: "boolean", DefaultValue: strPtr("true")},
+		})
+		assert.Equal(t, "ALTER TABLE users ADD COLUMN active boolean NOT NULL DEFAULT true;", sql)
+	})
+}
+
+func TestAfterCommit(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "active", DataType: "boolean", DefaultValue: strPtr("true")}})
+	cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "email", DataType: "text"}})
+	cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "bio", DataType: "text", IsNullable: true}})
+
+	t.Run("off without safe mode", func(t *testing.T) {
+		assert.Empty(t, NewSQLGenerator().AfterCommit(cs))
+	})
+
+	t.Run("single update before PostgreSQL 11", func(t *testing.T) {
+		gen := NewSQLGenerator()
+		gen.SafeMode = true
+
+		assert.Equal(t, []string{
+			"UPDATE users SET active = DEFAULT WHERE active IS NULL;",
+			"ALTER TABLE users ALTER COLUMN active SET NOT NULL;",
+		}, gen.AfterCommit(cs))
+	})
+
+	t.Run("batched from PostgreSQL 11", func(t *testing.T) {
+		gen := NewSQLGenerator()
+		gen.SafeMode = true
+		gen.TargetVersion = 110000
+
+		statements := gen.AfterCommit(cs)
+		require.Len(t, statements, 2)
+		assert.True(t, strings.HasPrefix(statements[0], "DO $backfill$"))
+		assert.Contains(t, statements[0], "UPDATE users SET active = DEFAULT\n")
+		assert.Contains(t, statements[0], "SELECT ctid FROM users WHERE active IS NULL LIMIT 10000")
+		assert.Contains(t, statements[0], "COMMIT;")
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN active SET NOT NULL;", statements[1])
+	})
+}
+
+func TestGenerateAlterColumn(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+
+	t.Run("type change", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "count",
+			Alteration: ColumnAlteration{TypeChanged: true, NewType: "bigint"},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN count TYPE bigint;", sql)
+	})
+
+	t.Run("set not null", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "email",
+			Alteration: ColumnAlteration{NullableChanged: true, NewNullable: false},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN email SET NOT NULL;", sql)
+	})
+
+	t.Run("drop not null", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "email",
+			Alteration: ColumnAlteration{NullableChanged: true, NewNullable: true},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN email DROP NOT NULL;", sql)
+	})
+
+	t.Run("set default", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "status",
+			Alteration: ColumnAlteration{DefaultChanged: true, NewDefault: strPtr("'active'")},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN status SET DEFAULT 'active';", sql)
+	})
+
+	t.Run("drop default", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "status",
+			Alteration: ColumnAlteration{DefaultChanged: true, NewDefault: nil},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN status DROP DEFAULT;", sql)
+	})
+
+	t.Run("varchar type change", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "name",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "text", NewType: "varchar(255)"},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN name TYPE varchar(255);", sql)
+	})
+
+	t.Run("enum cast default is not quoted again", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "status",
+			Alteration: ColumnAlteration{DefaultChanged: true, NewDefault: strPtr("'active'::status")},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN status SET DEFAULT 'active'::status;", sql)
+	})
+
+	t.Run("mixed case enum type is quoted", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "status",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "text", NewType: "UserStatus"},
+		})
+		assert.Equal(t, `ALTER TABLE users ALTER COLUMN status TYPE "UserStatus" USING status::"UserStatus";`, sql)
+	})
+
+	t.Run("string to numeric adds using cast", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "count",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "text", NewType: "integer"},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN count TYPE integer USING count::integer;", sql)
+	})
+
+	t.Run("boolean to numeric adds using cast", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "flag",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "boolean", NewType: "integer"},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN flag TYPE integer USING flag::integer;", sql)
+	})
+
+	t.Run("implicit conversion has no using", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "count",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "integer", NewType: "varchar(20)"},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN count TYPE varchar(20);", sql)
+	})
+
+	t.Run("using override", func(t *testing.T) {
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "count",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "text", NewType: "integer", Using: "NULLIF(count, '')::integer"},
+		})
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN count TYPE integer USING NULLIF(count, '')::integer;", sql)
+	})
+
+	t.Run("collation change", func(t *testing.T) {
+		from := &Column{Name: "name", DataType: "text"}
+		to := &Column{Name: "name", DataType: "text", Collation: "C"}
+
+		change := &AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "name",
+			OldColumn:  from,
+			NewColumn:  to,
+			Alteration: computeColumnAlteration(from, to),
+		}
+		assert.Equal(t, `ALTER TABLE users ALTER COLUMN name TYPE text COLLATE "C";`, gen.GenerateChange(change))
+		assert.Equal(t, "Alter column users.name: collation default → C", change.Description())
+		assert.False(t, change.IsDestructive())
+
+		back := &AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "name",
+			OldColumn:  to,
+			NewColumn:  from,
+			Alteration: computeColumnAlteration(to, from),
+		}
+		assert.Equal(t, `ALTER TABLE users ALTER COLUMN name TYPE text COLLATE "default";`, gen.GenerateChange(back))
+	})
+
+	t.Run("type change keeps collation", func(t *testing.T) {
+		from := &Column{Name: "code", DataType: "text", Collation: "C"}
+		to := &Column{Name: "code", DataType: "character varying", CharMaxLength: intPtr(10), Collation: "C"}
+
+		sql := gen.GenerateChange(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "code",
+			OldColumn:  from,
+			NewColumn:  to,
+			Alteration: computeColumnAlteration(from, to),
+		})
+		assert.Equal(t, `ALTER TABLE users ALTER COLUMN code TYPE varchar(10) COLLATE "C";`, sql)
+	})
+}
+
+func TestGenerateAlterColumnGenerated(t *testing.T) {
+	regular := &Column{Name: "total", DataType: "numeric", IsNullable: true}
+	generated := &Column{Name: "total", DataType: "numeric", IsNullable: true, Generated: "(price * qty)"}
+	changed := &Column{Name: "total", DataType: "numeric", IsNullable: true, Generated: "((price * qty) - discount)"}
+
+	alter := func(from, to *Column) *AlterColumnChange {
+		return &AlterColumnChange{
+			TableName:  "orders",
+			ColumnName: "total",
+			OldColumn:  from,
+			NewColumn:  to,
+			Alteration: computeColumnAlteration(from, to),
+		}
 	}
-	assert.Equal(t, ChangeReplaceFunction, c.Type())
-	assert.False(t, c.IsDestructive())
-	assert.Equal(t, "greet(text)", c.ObjectName())
-	assert.Equal(t, "Replace function greet(text)", c.Description())
-}
 
-func TestGenerateDropTable(t *testing.T) {
 	gen := NewSQLGenerator()
 	gen.IncludeComments = false
 
-	sql := gen.GenerateChange(&DropTableChange{Table: &Table{Name: "users", Schema: "public"}})
-	assert.Equal(t, "DROP TABLE users;", sql)
+	t.Run("diff", func(t *testing.T) {
+		from := NewSchema("test")
+		to := NewSchema("test")
+		from.Tables["orders"] = &Table{Name: "orders", Columns: map[string]*Column{"total": generated}}
+		to.Tables["orders"] = &Table{Name: "orders", Columns: map[string]*Column{"total": changed}}
+
+		cs := Diff(from, to)
+
+		require.Len(t, cs.Changes, 1)
+		change, ok := cs.Changes[0].(*AlterColumnChange)
+		require.True(t, ok)
+		assert.True(t, change.Alteration.GeneratedChanged)
+		assert.Equal(t, "Alter column orders.total: generated as (((price * qty) - discount))", change.Description())
+		assert.False(t, change.IsDestructive())
+	})
+
+	t.Run("make regular column generated", func(t *testing.T) {
+		change := alter(regular, generated)
+		assert.True(t, change.IsDestructive())
+		assert.Equal(t, "ALTER TABLE orders DROP COLUMN total;\n"+
+			"ALTER TABLE orders ADD COLUMN total numeric GENERATED ALWAYS AS ((price * qty)) STORED;",
+			gen.GenerateChange(change))
+	})
+
+	t.Run("change expression before PostgreSQL 17", func(t *testing.T) {
+		assert.Equal(t, "ALTER TABLE orders DROP COLUMN total;\n"+
+			"ALTER TABLE orders ADD COLUMN total numeric GENERATED ALWAYS AS (((price * qty) - discount)) STORED;",
+			gen.GenerateChange(alter(generated, changed)))
+	})
+
+	t.Run("change expression on PostgreSQL 17", func(t *testing.T) {
+		gen := NewSQLGenerator()
+		gen.TargetVersion = 170002
+		assert.Equal(t, "ALTER TABLE orders ALTER COLUMN total SET EXPRESSION AS (((price * qty) - discount));",
+			gen.GenerateChange(alter(generated, changed)))
+	})
+
+	t.Run("make generated column regular", func(t *testing.T) {
+		change := alter(generated, regular)
+		assert.False(t, change.IsDestructive())
+		assert.Equal(t, "ALTER TABLE orders ALTER COLUMN total DROP EXPRESSION;", gen.GenerateChange(change))
+	})
 }
 
-func TestGenerateAlterColumn(t *testing.T) {
+func TestGenerateAlterColumnIdentity(t *testing.T) {
 	gen := NewSQLGenerator()
 	gen.IncludeComments = false
 
-	t.Run("type change", func(t *testing.T) {
+	t.Run("drop identity before setting a default", func(t *testing.T) {
 		sql := gen.GenerateChange(&AlterColumnChange{
 			TableName:  "users",
-			ColumnName: "count",
-			Alteration: ColumnAlteration{TypeChanged: true, NewType: "bigint"},
+			ColumnName: "id",
+			Alteration: ColumnAlteration{
+				IdentityChanged: true,
+				OldIdentity:     "BY DEFAULT",
+				DefaultChanged:  true,
+				NewDefault:      strPtr("nextval('users_id_seq'::regclass)"),
+			},
 		})
-		assert.Equal(t, "ALTER TABLE users ALTER COLUMN count TYPE bigint;", sql)
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN id DROP IDENTITY;\n"+
+			"ALTER TABLE users ALTER COLUMN id SET DEFAULT nextval('users_id_seq'::regclass);", sql)
 	})
 
-	t.Run("set not null", func(t *testing.T) {
+	t.Run("change identity generation", func(t *testing.T) {
 		sql := gen.GenerateChange(&AlterColumnChange{
 			TableName:  "users",
-			ColumnName: "email",
-			Alteration: ColumnAlteration{NullableChanged: true, NewNullable: false},
+			ColumnName: "id",
+			Alteration: ColumnAlteration{IdentityChanged: true, OldIdentity: "BY DEFAULT", NewIdentity: "ALWAYS"},
 		})
-		assert.Equal(t, "ALTER TABLE users ALTER COLUMN email SET NOT NULL;", sql)
+		assert.Equal(t, "ALTER TABLE users ALTER COLUMN id SET GENERATED ALWAYS;", sql)
 	})
+}
 
-	t.Run("drop not null", func(t *testing.T) {
-		sql := gen.GenerateChange(&AlterColumnChange{
-			TableName:  "users",
-			ColumnName: "email",
-			Alteration: ColumnAlteration{NullableChanged: true, NewNullable: true},
-		})
-		assert.Equal(t, "ALTER TABLE users ALTER COLUMN email DROP NOT NULL;", sql)
+func TestGenerateSequences(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+
+	seq := &Sequence{
+		Name:      "invoice_no",
+		DataType:  "bigint",
+		Start:     1000,
+		Increment: 1,
+		MinValue:  1,
+		MaxValue:  9223372036854775807,
+		Cache:     1,
+		Cycle:     true,
+	}
+
+	assert.Equal(t,
+		"CREATE SEQUENCE invoice_no AS bigint INCREMENT BY 1 MINVALUE 1 MAXVALUE 9223372036854775807 START WITH 1000 CACHE 1 CYCLE;",
+		gen.GenerateChange(&CreateSequenceChange{Sequence: seq}))
+
+	assert.Equal(t, "DROP SEQUENCE invoice_no;", gen.GenerateChange(&DropSequenceChange{Sequence: seq}))
+
+	altered := *seq
+	altered.Cycle = false
+	altered.OwnedByTable = "Invoices"
+	altered.OwnedByColumn = "no"
+	assert.Equal(t, `ALTER SEQUENCE invoice_no NO CYCLE OWNED BY "Invoices".no;`,
+		gen.GenerateChange(&AlterSequenceChange{OldSequence: seq, NewSequence: &altered}))
+
+	disowned := altered
+	disowned.OwnedByTable = ""
+	disowned.OwnedByColumn = ""
+	assert.Equal(t, "ALTER SEQUENCE invoice_no OWNED BY NONE;",
+		gen.GenerateChange(&AlterSequenceChange{OldSequence: &altered, NewSequence: &disowned}))
+}
+
+func TestGenerateCreateTableIdentityColumn(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+
+	table := NewTable("events", "public")
+	table.Columns["id"] = &Column{Name: "id", DataType: "bigint", Position: 1, Identity: "BY DEFAULT"}
+
+	sql := gen.GenerateChange(&CreateTableChange{Table: table})
+	assert.Equal(t, "CREATE TABLE events (\n    id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY\n);", sql)
+}
+
+func TestGenerateViews(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+
+	view := &View{Name: "active_users", Definition: "SELECT id, email\n   FROM users\n  WHERE active"}
+	matview := &View{Name: "daily_totals", Materialized: true, Definition: "SELECT day, sum(total) AS total\n   FROM orders\n  GROUP BY day"}
+
+	assert.Equal(t, "CREATE VIEW active_users AS\nSELECT id, email\n   FROM users\n  WHERE active;",
+		gen.GenerateChange(&CreateViewChange{View: view}))
+	assert.Equal(t, "CREATE MATERIALIZED VIEW daily_totals AS\nSELECT day, sum(total) AS total\n   FROM orders\n  GROUP BY day;",
+		gen.GenerateChange(&CreateViewChange{View: matview}))
+	assert.Equal(t, "DROP VIEW active_users;", gen.GenerateChange(&DropViewChange{View: view}))
+	assert.Equal(t, "DROP MATERIALIZED VIEW daily_totals;", gen.GenerateChange(&DropViewChange{View: matview}))
+
+	t.Run("replace view in place", func(t *testing.T) {
+		updated := &View{Name: "active_users", Definition: "SELECT id\n   FROM users"}
+		assert.Equal(t, "CREATE OR REPLACE VIEW active_users AS\nSELECT id\n   FROM users;",
+			gen.GenerateChange(&ReplaceViewChange{OldView: view, NewView: updated}))
 	})
 
-	t.Run("set default", func(t *testing.T) {
-		sql := gen.GenerateChange(&AlterColumnChange{
-			TableName:  "users",
-			ColumnName: "status",
-			Alteration: ColumnAlteration{DefaultChanged: true, NewDefault: strPtr("'active'")},
-		})
-		assert.Equal(t, "ALTER TABLE users ALTER COLUMN status SET DEFAULT 'active';", sql)
+	t.Run("replace materialized view", func(t *testing.T) {
+		updated := &View{Name: "daily_totals", Materialized: true, Definition: "SELECT 1"}
+		assert.Equal(t, "DROP MATERIALIZED VIEW daily_totals;\nCREATE MATERIALIZED VIEW daily_totals AS\nSELECT 1;",
+			gen.GenerateChange(&ReplaceViewChange{OldView: matview, NewView: updated}))
 	})
+}
 
-	t.Run("drop default", func(t *testing.T) {
-		sql := gen.GenerateChange(&AlterColumnChange{
-			TableName:  "users",
-			ColumnName: "status",
-			Alteration: ColumnAlteration{DefaultChanged: true, NewDefault: nil},
-		})
-		assert.Equal(t, "ALTER TABLE users ALTER COLUMN status DROP DEFAULT;", sql)
+func TestOrderChangesViews(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&CreateViewChange{View: &View{Name: "v"}})
+	cs.Add(&DropColumnChange{TableName: "users", Column: &Column{Name: "legacy"}})
+	cs.Add(&DropViewChange{View: &View{Name: "old_v"}})
+	cs.Add(&CreateFunctionChange{Function: &Function{Name: "f"}})
+	cs.Add(&CreateTableChange{Table: NewTable("users", "public")})
+
+	var types []ChangeType
+	for _, c := range OrderChanges(cs).Changes {
+		types = append(types, c.Type())
+	}
+
+	assert.Equal(t, []ChangeType{
+		ChangeCreateTable,
+		ChangeCreateFunction,
+		ChangeDropView,
+		ChangeCreateView,
+		ChangeDropColumn,
+	}, types)
+}
+
+func TestChangeSetSetUsing(t *testing.T) {
+	cs := NewChangeSet()
+	typeChange := &AlterColumnChange{
+		TableName:  "users",
+		ColumnName: "count",
+		Alteration: ColumnAlteration{TypeChanged: true, OldType: "text", NewType: "integer"},
+	}
+	cs.Add(&AlterColumnChange{
+		TableName:  "users",
+		ColumnName: "email",
+		Alteration: ColumnAlteration{NullableChanged: true},
 	})
+	cs.Add(typeChange)
+
+	require.NoError(t, cs.SetUsing("users.count", "trim(count)::integer"))
+	assert.Equal(t, "trim(count)::integer", typeChange.Alteration.Using)
+
+	assert.Error(t, cs.SetUsing("users.email", "email"))
+	assert.Error(t, cs.SetUsing("users.missing", "1"))
+}
+
+func TestFormatType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"integer", "integer"},
+		{"varchar(255)", "varchar(255)"},
+		{"numeric(10,2)", "numeric(10,2)"},
+		{"numeric(10, 2)", "numeric(10,2)"},
+		{"character varying", "character varying"},
+		{"timestamp with time zone", "timestamp with time zone"},
+		{"double precision", "double precision"},
+		{"text[]", "text[]"},
+		{"varchar(20)[]", "varchar(20)[]"},
+		{"status", "status"},
+		{"UserStatus", `"UserStatus"`},
+		{"UserStatus[]", `"UserStatus"[]`},
+		{"public.my_type", "public.my_type"},
+		{"Billing.Plan", `"Billing"."Plan"`},
+		{"int; DROP TABLE users", `"int; DROP TABLE users"`},
+		{`bad"type`, `"bad""type"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatType(tt.in))
+		})
+	}
 }
 
 func TestGenerateCreateIndex(t *testing.T) {
@@ -1060,6 +2290,9 @@ func TestGenerateDropIndex(t *testing.T) {
 
 	sql := gen.GenerateChange(&DropIndexChange{Index: &Index{Name: "idx_email"}})
 	assert.Equal(t, "DROP INDEX idx_email;", sql)
+
+	sql = gen.GenerateChange(&DropIndexChange{Index: &Index{Name: "idx_email", TableName: "Billing.users"}})
+	assert.Equal(t, `DROP INDEX "Billing".idx_email;`, sql)
 }
 
 func TestGenerateAddConstraint(t *testing.T) {
@@ -1151,6 +2384,37 @@ func TestGenerateCreateTable(t *testing.T) {
 	assert.Contains(t, sql, ");")
 }
 
+func TestGenerateComment(t *testing.T) {
+	gen := NewSQLGenerator()
+
+	sql := gen.GenerateChange(&CommentChange{TableName: "users", NewComment: "Registered users"})
+	assert.Equal(t, "COMMENT ON TABLE users IS 'Registered users';", sql)
+
+	sql = gen.GenerateChange(&CommentChange{TableName: "users", ColumnName: "email", NewComment: "User's email"})
+	assert.Equal(t, "COMMENT ON COLUMN users.email IS 'User''s email';", sql)
+
+	sql = gen.GenerateChange(&CommentChange{TableName: "users", ColumnName: "email", OldComment: "old"})
+	assert.Equal(t, "COMMENT ON COLUMN users.email IS NULL;", sql)
+}
+
+func TestGenerateCreateTableWithComments(t *testing.T) {
+	gen := NewSQLGenerator()
+
+	table := NewTable("users", "public")
+	table.Comment = "Registered users"
+	table.Columns["id"] = &Column{Name: "id", DataType: "integer", Position: 1, Comment: "Primary key"}
+
+	sql := gen.GenerateChange(&CreateTableChange{Table: table})
+	assert.Contains(t, sql, "COMMENT ON TABLE users IS 'Registered users';")
+	assert.Contains(t, sql, "COMMENT ON COLUMN users.id IS 'Primary key';")
+
+	sql = gen.GenerateChange(&AddColumnChange{
+		TableName: "users",
+		Column:    &Column{Name: "email", DataType: "text", IsNullable: true, Comment: "Login email"},
+	})
+	assert.Equal(t, "ALTER TABLE users ADD COLUMN email text;\nCOMMENT ON COLUMN users.email IS 'Login email';", sql)
+}
+
 func TestGenerateMigrationFile(t *testing.T) {
 	gen := NewSQLGenerator()
 	gen.IncludeComments = false
@@ -1170,6 +2434,253 @@ func TestGenerateMigrationFile(t *testing.T) {
 	assert.Contains(t, result, "COMMIT;")
 }
 
+func TestChangeSetInverse(t *testing.T) {
+	oldDefault := "0"
+	users := &Table{
+		Schema: "public",
+		Name:   "users",
+		Columns: map[string]*Column{
+			"id": {Name: "id", DataType: "integer", Position: 1},
+		},
+		Indexes: map[string]*Index{
+			"users_pkey":     {Name: "users_pkey", TableName: "users", Columns: []string{"id"}, IsPrimary: true, Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (id)"},
+			"users_id_index": {Name: "users_id_index", TableName: "users", Columns: []string{"id"}, Definition: "CREATE INDEX users_id_index ON public.users USING btree (id)"},
+		},
+	}
+
+	cs := NewChangeSet()
+	cs.Add(&DropTableChange{Table: users})
+	cs.Add(&AddColumnChange{TableName: "orders", Column: &Column{Name: "total", DataType: "integer"}})
+	cs.Add(&DropColumnChange{TableName: "orders", Column: &Column{Name: "note", DataType: "text", IsNullable: true}})
+	cs.Add(&AlterColumnChange{
+		TableName:  "orders",
+		ColumnName: "qty",
+		OldColumn:  &Column{Name: "qty", DataType: "integer", DefaultValue: &oldDefault},
+		NewColumn:  &Column{Name: "qty", DataType: "integer"},
+		Alteration: ColumnAlteration{DefaultChanged: true, OldDefault: &oldDefault},
+	})
+	cs.Add(&RenameColumnChange{TableName: "orders", OldName: "name", Column: &Column{Name: "title", DataType: "text"}})
+	cs.Add(&AddEnumValueChange{EnumName: "status", Value: "archived"})
+
+	inverse, notes := cs.Inverse()
+
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+	sql := strings.Join(gen.Generate(inverse), "\n")
+
+	assert.Contains(t, sql, "CREATE TABLE users")
+	assert.Contains(t, sql, "CREATE INDEX users_id_index")
+	assert.NotContains(t, sql, "CREATE UNIQUE INDEX users_pkey")
+	assert.Contains(t, sql, "ALTER TABLE orders DROP COLUMN total;")
+	assert.Contains(t, sql, "ALTER TABLE orders ADD COLUMN note text;")
+	assert.Contains(t, sql, "ALTER TABLE orders ALTER COLUMN qty SET DEFAULT 0;")
+	assert.Contains(t, sql, "ALTER TABLE orders RENAME COLUMN title TO name;")
+
+	require.Len(t, notes, 3)
+	assert.Contains(t, notes[0], "users is recreated empty")
+	assert.Contains(t, notes[1], "orders.note is recreated without its values")
+	assert.Contains(t, notes[2], "cannot remove enum values")
+
+	t.Run("round trip", func(t *testing.T) {
+		cs := NewChangeSet()
+		cs.Add(&CreateSchemaChange{Name: "billing"})
+		cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "email", DataType: "text"}})
+
+		twice, notes := cs.Inverse()
+		twice, _ = twice.Inverse()
+
+		assert.Empty(t, notes)
+		assert.Equal(t, gen.Generate(OrderChanges(cs)), gen.Generate(twice))
+	})
+}
+
+func TestGenerateDownMigrationFile(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+
+	cs := NewChangeSet()
+	cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "email", DataType: "text", IsNullable: true}})
+	cs.Add(&DropColumnChange{TableName: "users", Column: &Column{Name: "old_field", DataType: "text", IsNullable: true}})
+
+	result := gen.GenerateDownMigrationFile(cs, "add email, drop old_field")
+
+	assert.Contains(t, result, "-- Down migration generated by pgbranch")
+	assert.Contains(t, result, "-- Reverts: add email, drop old_field")
+	assert.Contains(t, result, "-- IRREVERSIBLE: column users.old_field is recreated without its values")
+	assert.Contains(t, result, "ALTER TABLE users DROP COLUMN email;")
+	assert.Contains(t, result, "ALTER TABLE users ADD COLUMN old_field text;")
+	assert.Contains(t, result, "BEGIN;")
+	assert.Contains(t, result, "COMMIT;")
+}
+
+func TestGenerateGooseMigrationFile(t *testing.T) {
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+
+	cs := NewChangeSet()
+	cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "email", DataType: "text", IsNullable: true}})
+	cs.Add(&CreateFunctionChange{Function: &Function{
+		Schema:     "public",
+		Name:       "touch",
+		Definition: "CREATE OR REPLACE FUNCTION public.touch()\n RETURNS trigger\n LANGUAGE plpgsql\nAS $function$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$function$",
+	}})
+
+	result := gen.GenerateGooseMigrationFile(cs, "add email")
+
+	up, down, ok := strings.Cut(result, "-- +goose Down\n")
+	require.True(t, ok)
+	assert.Contains(t, up, "-- Description: add email")
+	assert.Contains(t, up, "-- +goose Up\n")
+	assert.Contains(t, up, "ALTER TABLE users ADD COLUMN email text;")
+	assert.Contains(t, up, "-- +goose StatementBegin\nCREATE OR REPLACE FUNCTION public.touch()")
+	assert.Contains(t, up, "$function$;\n-- +goose StatementEnd")
+	assert.Contains(t, down, "ALTER TABLE users DROP COLUMN email;")
+	assert.Contains(t, down, "DROP FUNCTION")
+	assert.NotContains(t, result, "BEGIN;")
+	assert.NotContains(t, result, "COMMIT;")
+}
+
+func TestSplitGooseMigrationFile(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&CreateTableChange{Table: &Table{
+		Name:    "t",
+		Schema:  "public",
+		Columns: map[string]*Column{"id": {Name: "id", DataType: "integer", Position: 1}},
+	}})
+	cs.Add(&AddEnumValueChange{EnumName: "status", Value: "archived"})
+
+	// PostgreSQL 11 can't add enum values in a transaction, so the file
+	// is written with NO TRANSACTION and explicit BEGIN and COMMIT.
+	for _, version := range []int{0, 110000} {
+		gen := NewSQLGenerator()
+		gen.TargetVersion = version
+
+		result := gen.GenerateGooseMigrationFile(cs, "create t")
+		require.Contains(t, result, "-- +goose Down")
+
+		var statements []string
+		for _, batch := range SplitBatches(SplitStatements(result)) {
+			for _, stmt := range batch.Statements {
+				statements = append(statements, stmt.SQL)
+			}
+		}
+
+		require.NotEmpty(t, statements)
+		assert.Contains(t, strings.Join(statements, "\n"), "CREATE TABLE")
+		for _, stmt := range statements {
+			assert.NotContains(t, stmt, "DROP TABLE", "statements of the Down section must not run")
+		}
+	}
+}
+
+func TestGenerateMigrationFileNonTransactional(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&AddEnumValueChange{EnumName: "status", Value: "archived"})
+	cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "email", DataType: "text", IsNullable: true}})
+
+	addValue := "ALTER TYPE status ADD VALUE 'archived';"
+
+	for _, tt := range []struct {
+		name    string
+		version int
+		inside  bool
+	}{
+		{"unknown version", 0, false},
+		{"PostgreSQL 11", 110000, false},
+		{"PostgreSQL 12", 120000, true},
+		{"PostgreSQL 16", 160004, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := NewSQLGenerator()
+			gen.IncludeComments = false
+			gen.TargetVersion = tt.version
+
+			result := gen.GenerateMigrationFile(cs, "")
+
+			before, transaction, ok := strings.Cut(result, "BEGIN;")
+			require.True(t, ok)
+			assert.Contains(t, transaction, "ALTER TABLE users ADD COLUMN email text;")
+			if tt.inside {
+				assert.Contains(t, transaction, addValue)
+				assert.NotContains(t, before, addValue)
+			} else {
+				assert.Contains(t, before, addValue)
+				assert.NotContains(t, transaction, addValue)
+			}
+		})
+	}
+
+	t.Run("only non-transactional changes", func(t *testing.T) {
+		only := NewChangeSet()
+		only.Add(&AddEnumValueChange{EnumName: "status", Value: "archived"})
+
+		result := NewSQLGenerator().GenerateMigrationFile(only, "")
+		assert.Contains(t, result, addValue)
+		assert.NotContains(t, result, "BEGIN;")
+		assert.NotContains(t, result, "COMMIT;")
+	})
+
+	t.Run("goose", func(t *testing.T) {
+		gen := NewSQLGenerator()
+		gen.IncludeComments = false
+		gen.TargetVersion = 110000
+
+		result := gen.GenerateGooseMigrationFile(cs, "")
+		assert.Contains(t, result, "-- +goose NO TRANSACTION")
+		assert.Contains(t, result, "-- +goose Up\n"+addValue+"\n\n-- +goose StatementBegin\nBEGIN;\n\nALTER TABLE users ADD COLUMN email text;")
+
+		gen.TargetVersion = 160004
+		assert.NotContains(t, gen.GenerateGooseMigrationFile(cs, ""), "NO TRANSACTION")
+	})
+}
+
+func TestGenerateMigrationFileAfterCommit(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "active", DataType: "boolean", DefaultValue: strPtr("true")}})
+
+	gen := NewSQLGenerator()
+	gen.IncludeComments = false
+	gen.SafeMode = true
+
+	backfill := "UPDATE users SET active = DEFAULT WHERE active IS NULL;"
+	setNotNull := "ALTER TABLE users ALTER COLUMN active SET NOT NULL;"
+
+	t.Run("after the transaction", func(t *testing.T) {
+		result := gen.GenerateMigrationFile(cs, "")
+
+		transaction, after, ok := strings.Cut(result, "COMMIT;")
+		require.True(t, ok)
+		assert.Contains(t, transaction, "ALTER TABLE users ALTER COLUMN active SET DEFAULT true;")
+		assert.NotContains(t, transaction, backfill)
+		assert.Contains(t, after, backfill+"\n\n"+setNotNull)
+	})
+
+	t.Run("left out when separate", func(t *testing.T) {
+		split := *gen
+		split.SeparateAfterCommit = true
+
+		result := split.GenerateMigrationFile(cs, "")
+		assert.NotContains(t, result, backfill)
+		assert.NotContains(t, result, setNotNull)
+
+		file := split.GenerateAfterCommitMigrationFile(backfill, "")
+		assert.Contains(t, file, backfill)
+		assert.NotContains(t, file, "BEGIN;")
+	})
+
+	t.Run("goose", func(t *testing.T) {
+		result := gen.GenerateGooseMigrationFile(cs, "")
+		assert.Contains(t, result, "-- +goose NO TRANSACTION")
+
+		up, _, ok := strings.Cut(result, "-- +goose Down")
+		require.True(t, ok)
+		_, after, ok := strings.Cut(up, "COMMIT;")
+		require.True(t, ok)
+		assert.Contains(t, after, backfill)
+		assert.Contains(t, after, setNotNull)
+	})
+}
+
 func TestQuoteIdent(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1215,6 +2726,12 @@ func TestQuoteIdent(t *testing.T) {
 	}
 }
 
+func TestQuoteQualifiedIdent(t *testing.T) {
+	assert.Equal(t, "users", quoteQualifiedIdent("users"))
+	assert.Equal(t, "billing.users", quoteQualifiedIdent("billing.users"))
+	assert.Equal(t, `"Billing"."user"`, quoteQualifiedIdent("Billing.user"))
+}
+
 func TestGenerateWithComments(t *testing.T) {
 	gen := NewSQLGenerator()
 	gen.IncludeComments = true
@@ -1279,6 +2796,34 @@ func TestValidateChanges(t *testing.T) {
 		require.Len(t, errors, 1)
 		assert.Contains(t, errors[0], "text")
 		assert.Contains(t, errors[0], "integer")
+		assert.Contains(t, errors[0], "USING count::integer")
+	})
+
+	t.Run("type change without implicit cast warns about using", func(t *testing.T) {
+		cs := NewChangeSet()
+		cs.Add(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "active",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "text", NewType: "boolean"},
+		})
+
+		warnings, errors := ValidateChanges(cs)
+		assert.Len(t, errors, 0)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "USING active::boolean")
+	})
+
+	t.Run("type change with using override is not warned about", func(t *testing.T) {
+		cs := NewChangeSet()
+		cs.Add(&AlterColumnChange{
+			TableName:  "users",
+			ColumnName: "active",
+			Alteration: ColumnAlteration{TypeChanged: true, OldType: "text", NewType: "boolean", Using: "active = 'yes'"},
+		})
+
+		warnings, errors := ValidateChanges(cs)
+		assert.Empty(t, warnings)
+		assert.Empty(t, errors)
 	})
 
 	t.Run("numeric to string type change is warning", func(t *testing.T) {
@@ -1297,9 +2842,30 @@ func TestValidateChanges(t *testing.T) {
 		assert.Contains(t, warnings[0], "precision")
 	})
 
-	t.Run("no warnings or errors for safe changes", func(t *testing.T) {
+	t.Run("add not null column without default is error", func(t *testing.T) {
 		cs := NewChangeSet()
 		cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "email", DataType: "text"}})
+
+		warnings, errors := ValidateChanges(cs)
+		assert.Len(t, warnings, 0)
+		require.Len(t, errors, 1)
+		assert.Contains(t, errors[0], "NOT NULL")
+		assert.Contains(t, errors[0], "users.email")
+		assert.Contains(t, errors[0], "DEFAULT")
+	})
+
+	t.Run("add not null column with default is safe", func(t *testing.T) {
+		cs := NewChangeSet()
+		cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "active", DataType: "boolean", DefaultValue: strPtr("true")}})
+
+		warnings, errors := ValidateChanges(cs)
+		assert.Empty(t, warnings)
+		assert.Empty(t, errors)
+	})
+
+	t.Run("no warnings or errors for safe changes", func(t *testing.T) {
+		cs := NewChangeSet()
+		cs.Add(&AddColumnChange{TableName: "users", Column: &Column{Name: "email", DataType: "text", IsNullable: true}})
 		cs.Add(&CreateTableChange{Table: &Table{Name: "logs", Schema: "public"}})
 
 		warnings, errors := ValidateChanges(cs)
@@ -1315,3 +2881,108 @@ func intPtr(i int) *int {
 func strPtr(s string) *string {
 	return &s
 }
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- Migration generated by pgbranch
+BEGIN;
+
+CREATE TABLE notes (body text DEFAULT 'a;b');
+/* a /* nested */ comment; */
+INSERT INTO notes VALUES (E'it\'s;'), ('don''t;');
+CREATE FUNCTION f() RETURNS int AS $body$
+BEGIN
+  RETURN 1;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT "odd;name", $1 FROM notes;
+
+COMMIT;
+-- trailing comment`
+
+	statements := SplitStatements(script)
+
+	var sqls []string
+	var lines []int
+	for _, stmt := range statements {
+		sqls = append(sqls, stmt.SQL)
+		lines = append(lines, stmt.Line)
+	}
+
+	assert.Equal(t, []string{
+		"BEGIN",
+		"CREATE TABLE notes (body text DEFAULT 'a;b')",
+		"INSERT INTO notes VALUES (E'it\\'s;'), ('don''t;')",
+		"CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql",
+		`SELECT "odd;name", $1 FROM notes`,
+		"COMMIT",
+	}, sqls)
+	assert.Equal(t, []int{2, 4, 6, 7, 12, 14}, lines)
+}
+
+func TestIsTransactionControl(t *testing.T) {
+	for _, stmt := range []string{"BEGIN", "begin;", "COMMIT", "END", "START TRANSACTION"} {
+		assert.True(t, IsTransactionControl(stmt), stmt)
+	}
+	for _, stmt := range []string{"", "CREATE TABLE t (id int)", "ROLLBACK", "START"} {
+		assert.False(t, IsTransactionControl(stmt), stmt)
+	}
+}
+
+func TestSplitBatches(t *testing.T) {
+	sqls := func(batch Batch) []string {
+		var out []string
+		for _, stmt := range batch.Statements {
+			out = append(out, stmt.SQL)
+		}
+		return out
+	}
+
+	t.Run("without transaction control", func(t *testing.T) {
+		batches := SplitBatches(SplitStatements("CREATE TABLE a (id int);\nCREATE TABLE b (id int);"))
+		require.Len(t, batches, 1)
+		assert.True(t, batches[0].Transactional)
+		assert.Equal(t, []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"}, sqls(batches[0]))
+	})
+
+	t.Run("statements outside the transaction run on their own", func(t *testing.T) {
+		script := `ALTER TYPE status ADD VALUE 'archived';
+ALTER TYPE status ADD VALUE 'deleted';
+
+BEGIN;
+ALTER TABLE users ADD COLUMN email text;
+CREATE INDEX users_email ON users (email);
+COMMIT;
+
+UPDATE users SET email = '' WHERE email IS NULL;`
+
+		batches := SplitBatches(SplitStatements(script))
+		require.Len(t, batches, 4)
+
+		assert.False(t, batches[0].Transactional)
+		assert.Equal(t, []string{"ALTER TYPE status ADD VALUE 'archived'"}, sqls(batches[0]))
+		assert.False(t, batches[1].Transactional)
+		assert.Equal(t, []string{"ALTER TYPE status ADD VALUE 'deleted'"}, sqls(batches[1]))
+
+		assert.True(t, batches[2].Transactional)
+		assert.Equal(t, []string{
+			"ALTER TABLE users ADD COLUMN email text",
+			"CREATE INDEX users_email ON users (email)",
+		}, sqls(batches[2]))
+		assert.Equal(t, 5, batches[2].Statements[0].Line)
+
+		assert.False(t, batches[3].Transactional)
+		assert.Equal(t, 9, batches[3].Statements[0].Line)
+	})
+
+	t.Run("begin without commit", func(t *testing.T) {
+		batches := SplitBatches(SplitStatements("BEGIN;\nCREATE TABLE a (id int);\nCREATE TABLE b (id int);"))
+		require.Len(t, batches, 1)
+		assert.True(t, batches[0].Transactional)
+		assert.Len(t, batches[0].Statements, 2)
+	})
+
+	t.Run("empty transaction", func(t *testing.T) {
+		assert.Empty(t, SplitBatches(SplitStatements("BEGIN;\nCOMMIT;")))
+		assert.Empty(t, SplitBatches(nil))
+	})
+}
@@ -0,0 +1,289 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// gooseDownAnnotation starts the Down section of a goose migration file.
+const gooseDownAnnotation = "-- +goose Down"
+
+// Statement is one SQL statement of a script, with the line it starts on.
+type Statement struct {
+	SQL  string
+	Line int
+}
+
+// SplitStatements splits a SQL script into statements at the semicolons
+// that end them. Semicolons inside string literals, quoted identifiers,
+// dollar-quoted bodies and comments don't split. Comments before a
+// statement are left out, as are statements that are only comments.
+// In a goose migration file, the script ends at the "-- +goose Down"
+// annotation, so only the Up section is returned.
+func SplitStatements(script string) []Statement {
+	var (
+		statements []Statement
+		start      int // offset of the current statement's first code
+		line       = 1
+		startLine  = 1
+		hasCode    bool
+	)
+
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, Statement{
+				SQL:  strings.TrimSpace(script[start:end]),
+				Line: startLine,
+			})
+		}
+		hasCode = false
+	}
+
+	// markCode records that the current statement has more than comments
+	// and whitespace, and that it starts at offset i.
+	markCode := func(i int) {
+		if !hasCode {
+			hasCode = true
+			start = i
+			startLine = line
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\n':
+			line++
+
+		case c == '-' && strings.HasPrefix(script[i:], gooseDownAnnotation):
+			flush(i)
+			return statements
+
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end - 1
+			}
+
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			// Block comments nest in PostgreSQL.
+			depth := 0
+			for ; i < len(script); i++ {
+				if strings.HasPrefix(script[i:], "/*") {
+					depth++
+					i++
+				} else if strings.HasPrefix(script[i:], "*/") {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				} else if script[i] == '\n' {
+					line++
+				}
+			}
+
+		case c == '\'' || c == '"':
+			markCode(i)
+			// A doubled quote is an escaped quote, which this loop
+			// handles as a closing quote followed by an opening one.
+			// E'...' strings also escape with a backslash.
+			escapes := c == '\'' && i > 0 && (script[i-1] == 'E' || script[i-1] == 'e')
+			for i++; i < len(script) && script[i] != c; i++ {
+				if escapes && script[i] == '\\' {
+					i++
+				}
+				if i < len(script) && script[i] == '\n' {
+					line++
+				}
+			}
+
+		case c == '$':
+			markCode(i)
+			if tag, ok := dollarQuoteTag(script[i:]); ok {
+				end := strings.Index(script[i+len(tag):], tag)
+				if end < 0 {
+					end = len(script) - i - len(tag)
+				}
+				body := script[i : i+len(tag)+end]
+				line += strings.Count(body, "\n")
+				i += len(body) + len(tag) - 1
+			}
+
+		case c == ';':
+			flush(i)
+
+		case c != ' ' && c != '\t' && c != '\r':
+			markCode(i)
+		}
+	}
+	flush(len(script))
+
+	return statements
+}
+
+// dollarQuoteTag returns the opening tag, such as $$ or $body$, that s
+// starts with.
+func dollarQuoteTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1], true
+		}
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 1) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// IsTransactionControl reports whether stmt begins or ends a transaction,
+// like the BEGIN and COMMIT around migration files.
+func IsTransactionControl(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(strings.TrimSuffix(stmt, ";")))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "BEGIN", "COMMIT", "END":
+		return true
+	case "START":
+		return len(fields) > 1 && fields[1] == "TRANSACTION"
+	}
+	return false
+}
+
+// isTransactionStart reports whether stmt, which IsTransactionControl
+// accepts, begins a transaction rather than ending one.
+func isTransactionStart(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stmt))
+	return fields[0] == "BEGIN" || fields[0] == "START"
+}
+
+// Batch is a group of a script's statements that are applied together.
+type Batch struct {
+	Statements []Statement
+	// Transactional batches run in one transaction. Other batches hold a
+	// single statement, which runs on its own.
+	Transactional bool
+}
+
+// SplitBatches groups a script's statements the way the script runs them.
+// Statements between BEGIN and COMMIT form a transactional batch, and each
+// statement outside a transaction block is a batch of its own, like the
+// ALTER TYPE ... ADD VALUE that migration files run before BEGIN for old
+// servers. A script without BEGIN or COMMIT is one transactional batch.
+// The BEGIN and COMMIT statements themselves are left out.
+func SplitBatches(statements []Statement) []Batch {
+	hasControl := false
+	for _, stmt := range statements {
+		if IsTransactionControl(stmt.SQL) {
+			hasControl = true
+			break
+		}
+	}
+	if !hasControl {
+		if len(statements) == 0 {
+			return nil
+		}
+		return []Batch{{Statements: statements, Transactional: true}}
+	}
+
+	var (
+		batches []Batch
+		block   *Batch // the open transaction block, if any
+	)
+	closeBlock := func() {
+		if block != nil && len(block.Statements) > 0 {
+			batches = append(batches, *block)
+		}
+		block = nil
+	}
+
+	for _, stmt := range statements {
+		switch {
+		case IsTransactionControl(stmt.SQL):
+			if !isTransactionStart(stmt.SQL) {
+				closeBlock()
+			} else if block == nil {
+				block = &Batch{Transactional: true}
+			}
+		case block != nil:
+			block.Statements = append(block.Statements, stmt)
+		default:
+			batches = append(batches, Batch{Statements: []Statement{stmt}})
+		}
+	}
+	// A BEGIN without COMMIT still runs its statements in a transaction.
+	closeBlock()
+
+	return batches
+}
+
+// StatementError is returned by ApplyStatements and ApplyBatches for the
+// statement that failed.
+type StatementError struct {
+	Statement Statement
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement at line %d failed: %v", e.Statement.Line, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyStatements executes statements in one transaction, which is rolled
+// back if any of them fails. The failing statement is reported as a
+// *StatementError.
+func ApplyStatements(ctx context.Context, conn *pgx.Conn, statements []Statement) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt.SQL); err != nil {
+			return &StatementError{Statement: stmt, Err: err}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ApplyBatches applies batches in order and stops at the first statement
+// that fails, which is reported as a *StatementError. A failing
+// transactional batch is rolled back, but the batches before it have
+// already been committed; kept is the number of statements in them.
+func ApplyBatches(ctx context.Context, conn *pgx.Conn, batches []Batch) (kept int, err error) {
+	for _, batch := range batches {
+		if batch.Transactional {
+			err = ApplyStatements(ctx, conn, batch.Statements)
+		} else {
+			for _, stmt := range batch.Statements {
+				if _, execErr := conn.Exec(ctx, stmt.SQL); execErr != nil {
+					err = &StatementError{Statement: stmt, Err: execErr}
+					break
+				}
+			}
+		}
+		if err != nil {
+			return kept, err
+		}
+		kept += len(batch.Statements)
+	}
+	return kept, nil
+}
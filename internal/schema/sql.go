@@ -2,6 +2,7 @@ package schema
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -9,6 +10,22 @@ import (
 // SQLGenerator generates SQL statements from a ChangeSet.
 type SQLGenerator struct {
 	IncludeComments bool
+
+	// SafeMode splits adding a NOT NULL column into steps that don't hold
+	// a long lock on large tables: add the column as nullable in the
+	// migration's transaction, then backfill it and set NOT NULL after it
+	// commits (see AfterCommit).
+	SafeMode bool
+
+	// SeparateAfterCommit leaves the statements AfterCommit returns out of
+	// generated migration files, for callers that write each of them to a
+	// file of its own with GenerateAfterCommitMigrationFile.
+	SeparateAfterCommit bool
+
+	// TargetVersion is the server_version_num of the server the SQL is
+	// meant for, such as 110000 for PostgreSQL 11. Zero means unknown, in
+	// which case the generator assumes the oldest version it supports.
+	TargetVersion int
 }
 
 func NewSQLGenerator() *SQLGenerator {
@@ -17,6 +34,56 @@ func NewSQLGenerator() *SQLGenerator {
 	}
 }
 
+// NonTransactional reports whether the SQL for c cannot run inside a
+// transaction block on the target version. Before PostgreSQL 12, ALTER
+// TYPE ... ADD VALUE is such a statement.
+func (g *SQLGenerator) NonTransactional(c Change) bool {
+	switch c.(type) {
+	case *AddEnumValueChange:
+		return g.TargetVersion < 120000
+	}
+	return false
+}
+
+// SplitTransactional splits cs, keeping its order, into the changes that
+// must run outside a transaction block and those that can run in one.
+func (g *SQLGenerator) SplitTransactional(cs *ChangeSet) (outside, inside *ChangeSet) {
+	outside, inside = NewChangeSet(), NewChangeSet()
+	for _, change := range cs.Changes {
+		if g.NonTransactional(change) {
+			outside.Add(change)
+		} else {
+			inside.Add(change)
+		}
+	}
+	return outside, inside
+}
+
+// backfillBatchSize is the number of rows each batch of a safe-mode
+// backfill updates.
+const backfillBatchSize = 10000
+
+// AfterCommit returns the statements for the changes in cs that must run
+// after the migration's transaction commits, each in a transaction of its
+// own. In safe mode, backfilling a NOT NULL column added with a default and
+// then setting NOT NULL are such statements, so that the lock ADD COLUMN
+// takes is released before every row is updated.
+func (g *SQLGenerator) AfterCommit(cs *ChangeSet) []string {
+	var statements []string
+	for _, change := range cs.Changes {
+		add, ok := change.(*AddColumnChange)
+		if !ok || !g.safeAddColumn(add) || add.Column.DefaultValue == nil {
+			continue
+		}
+		statements = append(statements,
+			g.generateBackfill(add),
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;",
+				quoteQualifiedIdent(add.TableName), quoteIdent(add.Column.Name)),
+		)
+	}
+	return statements
+}
+
 func (g *SQLGenerator) Generate(cs *ChangeSet) []string {
 	var statements []string
 
@@ -38,6 +105,10 @@ func (g *SQLGenerator) Generate(cs *ChangeSet) []string {
 
 func (g *SQLGenerator) GenerateChange(c Change) string {
 	switch change := c.(type) {
+	case *CreateSchemaChange:
+		return g.generateCreateSchema(change)
+	case *DropSchemaChange:
+		return g.generateDropSchema(change)
 	case *CreateTableChange:
 		return g.generateCreateTable(change)
 	case *DropTableChange:
@@ -48,6 +119,8 @@ func (g *SQLGenerator) GenerateChange(c Change) string {
 		return g.generateDropColumn(change)
 	case *AlterColumnChange:
 		return g.generateAlterColumn(change)
+	case *RenameColumnChange:
+		return g.generateRenameColumn(change)
 	case *CreateIndexChange:
 		return g.generateCreateIndex(change)
 	case *DropIndexChange:
@@ -62,12 +135,30 @@ func (g *SQLGenerator) GenerateChange(c Change) string {
 		return g.generateDropEnum(change)
 	case *AddEnumValueChange:
 		return g.generateAddEnumValue(change)
+	case *DropEnumValueChange:
+		return g.generateRecreateEnum(change.OldEnum, change.NewEnum, change.Columns)
+	case *ReorderEnumChange:
+		return g.generateRecreateEnum(change.OldEnum, change.NewEnum, change.Columns)
+	case *CreateSequenceChange:
+		return g.generateCreateSequence(change)
+	case *DropSequenceChange:
+		return g.generateDropSequence(change)
+	case *AlterSequenceChange:
+		return g.generateAlterSequence(change)
 	case *CreateFunctionChange:
 		return g.generateCreateFunction(change)
 	case *DropFunctionChange:
 		return g.generateDropFunction(change)
 	case *ReplaceFunctionChange:
 		return g.generateReplaceFunction(change)
+	case *CreateViewChange:
+		return g.generateCreateView(change)
+	case *DropViewChange:
+		return g.generateDropView(change)
+	case *ReplaceViewChange:
+		return g.generateReplaceView(change)
+	case *CommentChange:
+		return g.generateCommentOn(change)
 	default:
 		return ""
 	}
@@ -81,11 +172,19 @@ func (g *SQLGenerator) generateComment(c Change) string {
 	return fmt.Sprintf("-- %s%s", c.Description(), destructive)
 }
 
+func (g *SQLGenerator) generateCreateSchema(c *CreateSchemaChange) string {
+	return fmt.Sprintf("CREATE SCHEMA %s;", quoteIdent(c.Name))
+}
+
+func (g *SQLGenerator) generateDropSchema(c *DropSchemaChange) string {
+	return fmt.Sprintf("DROP SCHEMA %s;", quoteIdent(c.Name))
+}
+
 func (g *SQLGenerator) generateCreateTable(c *CreateTableChange) string {
 	table := c.Table
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", quoteIdent(table.FullName())))
+	sb.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", quoteQualifiedIdent(table.FullName())))
 
 	columns := table.SortedColumns()
 	for i, col := range columns {
@@ -108,6 +207,22 @@ func (g *SQLGenerator) generateCreateTable(c *CreateTableChange) string {
 		}
 	}
 
+	if table.Comment != "" {
+		extra = append(extra, g.generateCommentOn(&CommentChange{
+			TableName:  table.FullName(),
+			NewComment: table.Comment,
+		}))
+	}
+	for _, col := range columns {
+		if col.Comment != "" {
+			extra = append(extra, g.generateCommentOn(&CommentChange{
+				TableName:  table.FullName(),
+				ColumnName: col.Name,
+				NewComment: col.Comment,
+			}))
+		}
+	}
+
 	result := sb.String()
 	if len(extra) > 0 {
 		result += "\n" + strings.Join(extra, "\n")
@@ -121,12 +236,25 @@ func (g *SQLGenerator) columnDefinition(col *Column) string {
 
 	sb.WriteString(quoteIdent(col.Name))
 	sb.WriteString(" ")
-	sb.WriteString(col.FullType())
+	sb.WriteString(formatType(col.FullType()))
+	sb.WriteString(collateClause(col.Collation))
 
 	if !col.IsNullable {
 		sb.WriteString(" NOT NULL")
 	}
 
+	if col.Identity != "" {
+		sb.WriteString(" GENERATED ")
+		sb.WriteString(col.Identity)
+		sb.WriteString(" AS IDENTITY")
+	}
+
+	if col.Generated != "" {
+		sb.WriteString(" GENERATED ALWAYS AS (")
+		sb.WriteString(col.Generated)
+		sb.WriteString(") STORED")
+	}
+
 	if col.DefaultValue != nil {
 		sb.WriteString(" DEFAULT ")
 		sb.WriteString(*col.DefaultValue)
@@ -136,35 +264,160 @@ func (g *SQLGenerator) columnDefinition(col *Column) string {
 }
 
 func (g *SQLGenerator) generateDropTable(c *DropTableChange) string {
-	return fmt.Sprintf("DROP TABLE %s;", quoteIdent(c.Table.FullName()))
+	return fmt.Sprintf("DROP TABLE %s;", quoteQualifiedIdent(c.Table.FullName()))
 }
 
 func (g *SQLGenerator) generateAddColumn(c *AddColumnChange) string {
-	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;",
-		quoteIdent(c.TableName),
-		g.columnDefinition(c.Column),
-	)
+	var sql string
+	if g.safeAddColumn(c) {
+		sql = g.generateSafeAddColumn(c)
+	} else {
+		sql = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;",
+			quoteQualifiedIdent(c.TableName),
+			g.columnDefinition(c.Column),
+		)
+	}
+	if c.Column.Comment != "" {
+		sql += "\n" + g.generateCommentOn(&CommentChange{
+			TableName:  c.TableName,
+			ColumnName: c.Column.Name,
+			NewComment: c.Column.Comment,
+		})
+	}
+	return sql
+}
+
+// safeAddColumn reports whether c is added in steps in safe mode. Identity
+// and generated columns fill themselves in, so there is nothing to
+// backfill.
+func (g *SQLGenerator) safeAddColumn(c *AddColumnChange) bool {
+	return g.SafeMode && !c.Column.IsNullable && c.Column.Identity == "" && c.Column.Generated == ""
+}
+
+// generateSafeAddColumn adds a NOT NULL column as nullable and sets its
+// default for new rows; the backfill and SET NOT NULL follow after the
+// transaction commits (see AfterCommit). Without a default there is nothing
+// to backfill with, so NOT NULL is left out and a comment says what to run
+// once the column has been filled in.
+func (g *SQLGenerator) generateSafeAddColumn(c *AddColumnChange) string {
+	tableName := quoteQualifiedIdent(c.TableName)
+	colName := quoteIdent(c.Column.Name)
+
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s%s;", tableName, colName, formatType(c.Column.FullType()), collateClause(c.Column.Collation)),
+	}
+
+	if c.Column.DefaultValue != nil {
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;", tableName, colName, *c.Column.DefaultValue))
+	} else {
+		statements = append(statements,
+			fmt.Sprintf("-- TODO: backfill %s.%s, then run: ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;",
+				c.TableName, c.Column.Name, tableName, colName))
+	}
+
+	return strings.Join(statements, "\n")
+}
+
+// generateBackfill sets c's column to its default on existing rows. From
+// PostgreSQL 11 on, a DO block updates the rows in batches and commits
+// after each one, so no batch holds its row locks for long; older servers
+// can't commit inside DO, so there it is a single UPDATE.
+func (g *SQLGenerator) generateBackfill(c *AddColumnChange) string {
+	tableName := quoteQualifiedIdent(c.TableName)
+	colName := quoteIdent(c.Column.Name)
+
+	if g.TargetVersion < 110000 {
+		return fmt.Sprintf("UPDATE %s SET %s = DEFAULT WHERE %s IS NULL;", tableName, colName, colName)
+	}
+
+	return fmt.Sprintf(`DO $backfill$
+DECLARE
+	updated bigint;
+BEGIN
+	LOOP
+		UPDATE %[1]s SET %[2]s = DEFAULT
+		WHERE ctid = ANY (ARRAY(SELECT ctid FROM %[1]s WHERE %[2]s IS NULL LIMIT %[3]d));
+		GET DIAGNOSTICS updated = ROW_COUNT;
+		EXIT WHEN updated = 0;
+		COMMIT;
+	END LOOP;
+END
+$backfill$;`, tableName, colName, backfillBatchSize)
 }
 
 func (g *SQLGenerator) generateDropColumn(c *DropColumnChange) string {
 	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;",
-		quoteIdent(c.TableName),
+		quoteQualifiedIdent(c.TableName),
+		quoteIdent(c.Column.Name),
+	)
+}
+
+func (g *SQLGenerator) generateRenameColumn(c *RenameColumnChange) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;",
+		quoteQualifiedIdent(c.TableName),
+		quoteIdent(c.OldName),
 		quoteIdent(c.Column.Name),
 	)
 }
 
 func (g *SQLGenerator) generateAlterColumn(c *AlterColumnChange) string {
 	var statements []string
-	tableName := quoteIdent(c.TableName)
+	tableName := quoteQualifiedIdent(c.TableName)
 	colName := quoteIdent(c.ColumnName)
 
-	if c.Alteration.TypeChanged {
+	// A regular column can't be made generated in place, and a generation
+	// expression can only be replaced from PostgreSQL 17 on. Otherwise
+	// the column is dropped and added again, which also brings along any
+	// other change to its definition.
+	if c.Alteration.GeneratedChanged && c.Alteration.NewGenerated != "" &&
+		(c.Alteration.OldGenerated == "" || g.TargetVersion < 170000) {
+		return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, colName) + "\n" +
+			g.generateAddColumn(&AddColumnChange{TableName: c.TableName, Column: c.NewColumn})
+	}
+
+	if c.Alteration.GeneratedChanged {
+		if c.Alteration.NewGenerated == "" {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP EXPRESSION;", tableName, colName))
+		} else {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET EXPRESSION AS (%s);",
+					tableName, colName, c.Alteration.NewGenerated))
+		}
+	}
+
+	// Identity is dropped first, since an identity column cannot have a
+	// default, and added last, once the column is NOT NULL with no default.
+	if c.Alteration.IdentityChanged && c.Alteration.NewIdentity == "" {
 		statements = append(statements,
-			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;",
-				tableName, colName, c.Alteration.NewType),
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP IDENTITY;",
+				tableName, colName),
 		)
 	}
 
+	// A collation is changed with ALTER COLUMN ... TYPE, repeating the
+	// column's type when only the collation changes.
+	if c.Alteration.TypeChanged || c.Alteration.CollationChanged {
+		newType := c.Alteration.NewType
+		if !c.Alteration.TypeChanged {
+			newType = c.NewColumn.FullType()
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s",
+			tableName, colName, formatType(newType))
+		if c.Alteration.CollationChanged && c.Alteration.NewCollation == "" {
+			stmt += ` COLLATE "default"`
+		} else if c.Alteration.CollationChanged {
+			stmt += collateClause(c.Alteration.NewCollation)
+		} else if c.NewColumn != nil {
+			stmt += collateClause(c.NewColumn.Collation)
+		}
+		if using := usingExpression(c.ColumnName, c.Alteration); using != "" {
+			stmt += " USING " + using
+		}
+		statements = append(statements, stmt+";")
+	}
+
 	if c.Alteration.NullableChanged {
 		if c.Alteration.NewNullable {
 			statements = append(statements,
@@ -186,6 +439,8 @@ func (g *SQLGenerator) generateAlterColumn(c *AlterColumnChange) string {
 					tableName, colName),
 			)
 		} else {
+			// Defaults are stored as SQL expressions (e.g. 'active'::status),
+			// so they are emitted as they are rather than quoted.
 			statements = append(statements,
 				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;",
 					tableName, colName, *c.Alteration.NewDefault),
@@ -193,9 +448,41 @@ func (g *SQLGenerator) generateAlterColumn(c *AlterColumnChange) string {
 		}
 	}
 
+	if c.Alteration.IdentityChanged && c.Alteration.NewIdentity != "" {
+		if c.Alteration.OldIdentity == "" {
+			// A new identity sequence starts at 1; move it past the
+			// existing values, e.g. when converting a serial column.
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ADD GENERATED %s AS IDENTITY;",
+					tableName, colName, c.Alteration.NewIdentity),
+				fmt.Sprintf("SELECT setval(pg_get_serial_sequence(%s, %s), coalesce(max(%s), 0) + 1, false) FROM %s;",
+					quoteLiteral(tableName), quoteLiteral(c.ColumnName), colName, tableName),
+			)
+		} else {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET GENERATED %s;",
+					tableName, colName, c.Alteration.NewIdentity),
+			)
+		}
+	}
+
 	return strings.Join(statements, "\n")
 }
 
+// usingExpression returns the USING expression for a column type change:
+// the alteration's own Using if set, otherwise a cast to the new type when
+// PostgreSQL has no implicit conversion from the old one. It returns "" when
+// no USING clause is needed.
+func usingExpression(column string, alt ColumnAlteration) string {
+	if alt.Using != "" {
+		return alt.Using
+	}
+	if !needsExplicitCast(alt.OldType, alt.NewType) {
+		return ""
+	}
+	return quoteIdent(column) + "::" + formatType(alt.NewType)
+}
+
 func (g *SQLGenerator) generateCreateIndex(c *CreateIndexChange) string {
 	if c.Index.Definition != "" {
 		return c.Index.Definition + ";"
@@ -209,18 +496,20 @@ func (g *SQLGenerator) generateCreateIndex(c *CreateIndexChange) string {
 	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);",
 		unique,
 		quoteIdent(c.Index.Name),
-		quoteIdent(c.Index.TableName),
+		quoteQualifiedIdent(c.Index.TableName),
 		strings.Join(quoteIdents(c.Index.Columns), ", "),
 	)
 }
 
 func (g *SQLGenerator) generateDropIndex(c *DropIndexChange) string {
-	return fmt.Sprintf("DROP INDEX %s;", quoteIdent(c.Index.Name))
+	// Indexes live in their table's schema.
+	schema, _ := splitQualifiedName(c.Index.TableName)
+	return fmt.Sprintf("DROP INDEX %s;", quoteQualifiedIdent(qualifiedName(schema, c.Index.Name)))
 }
 
 func (g *SQLGenerator) generateAddConstraint(c *AddConstraintChange) string {
 	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;",
-		quoteIdent(c.TableName),
+		quoteQualifiedIdent(c.TableName),
 		quoteIdent(c.Constraint.Name),
 		c.Constraint.Definition,
 	)
@@ -228,7 +517,7 @@ func (g *SQLGenerator) generateAddConstraint(c *AddConstraintChange) string {
 
 func (g *SQLGenerator) generateDropConstraint(c *DropConstraintChange) string {
 	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;",
-		quoteIdent(c.TableName),
+		quoteQualifiedIdent(c.TableName),
 		quoteIdent(c.Constraint.Name),
 	)
 }
@@ -239,29 +528,126 @@ func (g *SQLGenerator) generateCreateEnum(c *CreateEnumChange) string {
 		values[i] = quoteLiteral(v)
 	}
 	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);",
-		quoteIdent(c.Enum.FullName()),
+		quoteQualifiedIdent(c.Enum.FullName()),
 		strings.Join(values, ", "),
 	)
 }
 
 func (g *SQLGenerator) generateDropEnum(c *DropEnumChange) string {
-	return fmt.Sprintf("DROP TYPE %s;", quoteIdent(c.Enum.FullName()))
+	return fmt.Sprintf("DROP TYPE %s;", quoteQualifiedIdent(c.Enum.FullName()))
 }
 
 func (g *SQLGenerator) generateAddEnumValue(c *AddEnumValueChange) string {
 	if c.After != "" {
 		return fmt.Sprintf("ALTER TYPE %s ADD VALUE %s AFTER %s;",
-			quoteIdent(c.EnumName),
+			quoteQualifiedIdent(c.EnumName),
 			quoteLiteral(c.Value),
 			quoteLiteral(c.After),
 		)
 	}
 	return fmt.Sprintf("ALTER TYPE %s ADD VALUE %s;",
-		quoteIdent(c.EnumName),
+		quoteQualifiedIdent(c.EnumName),
 		quoteLiteral(c.Value),
 	)
 }
 
+// generateRecreateEnum replaces an enum with one holding newEnum's values
+// in newEnum's order: the old type is renamed out of the way, the new one
+// created, each column converted to it through text, and the old type
+// dropped. Column defaults are dropped during the conversion, since a
+// default of the old type can't be cast, and set again afterwards.
+func (g *SQLGenerator) generateRecreateEnum(oldEnum, newEnum *Enum, columns []EnumColumn) string {
+	enumName := quoteQualifiedIdent(newEnum.FullName())
+	oldName := oldEnum.Name + "_old"
+
+	statements := []string{
+		fmt.Sprintf("ALTER TYPE %s RENAME TO %s;", quoteQualifiedIdent(oldEnum.FullName()), quoteIdent(oldName)),
+		g.generateCreateEnum(&CreateEnumChange{Enum: newEnum}),
+	}
+
+	for _, ec := range columns {
+		tableName := quoteQualifiedIdent(ec.TableName)
+		colName := quoteIdent(ec.Column.Name)
+
+		newType, textType := enumName, "text"
+		if ec.Column.IsArray {
+			newType, textType = newType+"[]", textType+"[]"
+		}
+
+		if ec.Column.DefaultValue != nil {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT;", tableName, colName))
+		}
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s::%s;",
+				tableName, colName, newType, colName, textType, newType))
+		if ec.Column.DefaultValue != nil {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;", tableName, colName, *ec.Column.DefaultValue))
+		}
+	}
+
+	statements = append(statements,
+		fmt.Sprintf("DROP TYPE %s;", quoteQualifiedIdent(qualifiedName(oldEnum.Schema, oldName))))
+
+	return strings.Join(statements, "\n")
+}
+
+func (g *SQLGenerator) generateCreateSequence(c *CreateSequenceChange) string {
+	seq := c.Sequence
+	sql := fmt.Sprintf("CREATE SEQUENCE %s AS %s INCREMENT BY %d MINVALUE %d MAXVALUE %d START WITH %d CACHE %d",
+		quoteQualifiedIdent(seq.FullName()), formatType(seq.DataType), seq.Increment, seq.MinValue, seq.MaxValue, seq.Start, seq.Cache)
+	if seq.Cycle {
+		sql += " CYCLE"
+	}
+	return sql + ";"
+}
+
+func (g *SQLGenerator) generateDropSequence(c *DropSequenceChange) string {
+	return fmt.Sprintf("DROP SEQUENCE %s;", quoteQualifiedIdent(c.Sequence.FullName()))
+}
+
+func (g *SQLGenerator) generateAlterSequence(c *AlterSequenceChange) string {
+	from, to := c.OldSequence, c.NewSequence
+
+	var options []string
+	if from.DataType != to.DataType {
+		options = append(options, "AS "+formatType(to.DataType))
+	}
+	if from.Increment != to.Increment {
+		options = append(options, fmt.Sprintf("INCREMENT BY %d", to.Increment))
+	}
+	if from.MinValue != to.MinValue {
+		options = append(options, fmt.Sprintf("MINVALUE %d", to.MinValue))
+	}
+	if from.MaxValue != to.MaxValue {
+		options = append(options, fmt.Sprintf("MAXVALUE %d", to.MaxValue))
+	}
+	if from.Start != to.Start {
+		options = append(options, fmt.Sprintf("START WITH %d", to.Start))
+	}
+	if from.Cache != to.Cache {
+		options = append(options, fmt.Sprintf("CACHE %d", to.Cache))
+	}
+	if from.Cycle != to.Cycle {
+		if to.Cycle {
+			options = append(options, "CYCLE")
+		} else {
+			options = append(options, "NO CYCLE")
+		}
+	}
+	if from.OwnedBy() != to.OwnedBy() {
+		if to.OwnedByTable == "" {
+			options = append(options, "OWNED BY NONE")
+		} else {
+			options = append(options, fmt.Sprintf("OWNED BY %s.%s",
+				quoteQualifiedIdent(to.OwnedByTable), quoteIdent(to.OwnedByColumn)))
+		}
+	}
+
+	return fmt.Sprintf("ALTER SEQUENCE %s %s;", quoteQualifiedIdent(to.FullName()), strings.Join(options, " "))
+}
+
 func (g *SQLGenerator) generateCreateFunction(c *CreateFunctionChange) string {
 	return c.Function.Definition + ";"
 }
@@ -278,10 +664,110 @@ func (g *SQLGenerator) generateReplaceFunction(c *ReplaceFunctionChange) string
 	return def + ";"
 }
 
+func (g *SQLGenerator) generateCreateView(c *CreateViewChange) string {
+	if c.View.Materialized {
+		return fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS\n%s;", quoteQualifiedIdent(c.View.FullName()), c.View.Definition)
+	}
+	return fmt.Sprintf("CREATE VIEW %s AS\n%s;", quoteQualifiedIdent(c.View.FullName()), c.View.Definition)
+}
+
+func (g *SQLGenerator) generateDropView(c *DropViewChange) string {
+	if c.View.Materialized {
+		return fmt.Sprintf("DROP MATERIALIZED VIEW %s;", quoteQualifiedIdent(c.View.FullName()))
+	}
+	return fmt.Sprintf("DROP VIEW %s;", quoteQualifiedIdent(c.View.FullName()))
+}
+
+// generateReplaceView replaces a plain view in place. Materialized views
+// have no CREATE OR REPLACE, and a view can't be replaced by one of the
+// other kind, so those are dropped and created again.
+func (g *SQLGenerator) generateReplaceView(c *ReplaceViewChange) string {
+	if !c.OldView.Materialized && !c.NewView.Materialized {
+		return fmt.Sprintf("CREATE OR REPLACE VIEW %s AS\n%s;", quoteQualifiedIdent(c.NewView.FullName()), c.NewView.Definition)
+	}
+	return g.generateDropView(&DropViewChange{View: c.OldView}) + "\n" +
+		g.generateCreateView(&CreateViewChange{View: c.NewView})
+}
+
+func (g *SQLGenerator) generateCommentOn(c *CommentChange) string {
+	comment := "NULL"
+	if c.NewComment != "" {
+		comment = quoteLiteral(c.NewComment)
+	}
+
+	if c.ColumnName == "" {
+		return fmt.Sprintf("COMMENT ON TABLE %s IS %s;", quoteQualifiedIdent(c.TableName), comment)
+	}
+	return fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;",
+		quoteQualifiedIdent(c.TableName), quoteIdent(c.ColumnName), comment)
+}
+
 func (g *SQLGenerator) GenerateMigrationFile(cs *ChangeSet, description string) string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("-- Migration generated by pgbranch\n"))
+	writeMigrationHeader(&sb, cs, description)
+	g.writeTransaction(&sb, cs)
+
+	return sb.String()
+}
+
+// GenerateDownMigrationFile generates a migration file that reverts the
+// forward migration of cs, built from cs.Inverse(). What the inverse
+// cannot restore is listed at the top as IRREVERSIBLE comments.
+func (g *SQLGenerator) GenerateDownMigrationFile(cs *ChangeSet, description string) string {
+	var sb strings.Builder
+
+	sb.WriteString("-- Down migration generated by pgbranch\n")
+	sb.WriteString(fmt.Sprintf("-- Generated at: %s\n", time.Now().Format(time.RFC3339)))
+	if description != "" {
+		sb.WriteString(fmt.Sprintf("-- Reverts: %s\n", description))
+	}
+	sb.WriteString("\n")
+
+	inverse, notes := cs.Inverse()
+	writeIrreversibleNotes(&sb, notes)
+	g.writeTransaction(&sb, inverse)
+
+	return sb.String()
+}
+
+// GenerateGooseMigrationFile generates a migration file for goose: the
+// forward migration under a "-- +goose Up" annotation and its inverse
+// under "-- +goose Down". goose runs each section in a transaction of its
+// own, so neither has BEGIN or COMMIT. Statements with semicolons of
+// their own, such as function bodies, are wrapped in StatementBegin and
+// StatementEnd so that goose doesn't split them.
+//
+// When a statement can't run in a transaction block, or must run after it
+// (see AfterCommit), the file is marked NO TRANSACTION; the remaining
+// statements of each section then go to goose as one statement wrapped in
+// BEGIN and COMMIT.
+func (g *SQLGenerator) GenerateGooseMigrationFile(cs *ChangeSet, description string) string {
+	var sb strings.Builder
+
+	inverse, notes := cs.Inverse()
+	upOutside, upInside := g.SplitTransactional(cs)
+	downOutside, downInside := g.SplitTransactional(inverse)
+	noTransaction := !upOutside.IsEmpty() || !downOutside.IsEmpty() ||
+		len(g.AfterCommit(upInside)) > 0 || len(g.AfterCommit(downInside)) > 0
+
+	writeMigrationHeader(&sb, cs, description)
+	if noTransaction {
+		sb.WriteString("-- +goose NO TRANSACTION\n\n")
+	}
+
+	sb.WriteString("-- +goose Up\n")
+	g.writeGooseSection(&sb, upOutside, upInside, noTransaction)
+
+	sb.WriteString("-- +goose Down\n")
+	writeIrreversibleNotes(&sb, notes)
+	g.writeGooseSection(&sb, downOutside, downInside, noTransaction)
+
+	return sb.String()
+}
+
+func writeMigrationHeader(sb *strings.Builder, cs *ChangeSet, description string) {
+	sb.WriteString("-- Migration generated by pgbranch\n")
 	sb.WriteString(fmt.Sprintf("-- Generated at: %s\n", time.Now().Format(time.RFC3339)))
 	if description != "" {
 		sb.WriteString(fmt.Sprintf("-- Description: %s\n", description))
@@ -301,23 +787,111 @@ func (g *SQLGenerator) GenerateMigrationFile(cs *ChangeSet, description string)
 		sb.WriteString(fmt.Sprintf("-- WARNING: This migration contains %d destructive change(s)\n\n",
 			cs.DestructiveCount()))
 	}
+}
+
+func writeIrreversibleNotes(sb *strings.Builder, notes []string) {
+	if len(notes) == 0 {
+		return
+	}
+	for _, note := range notes {
+		sb.WriteString(fmt.Sprintf("-- IRREVERSIBLE: %s\n", note))
+	}
+	sb.WriteString("\n")
+}
+
+// writeTransaction writes the statements for cs to sb, wrapped in BEGIN
+// and COMMIT. Statements that can't run in a transaction block come
+// first, outside it, and those AfterCommit returns come after it.
+func (g *SQLGenerator) writeTransaction(sb *strings.Builder, cs *ChangeSet) {
+	outside, inside := g.SplitTransactional(cs)
+	if !outside.IsEmpty() {
+		sb.WriteString("-- Outside the transaction: these statements cannot run inside a\n")
+		sb.WriteString("-- transaction block on the target PostgreSQL version.\n\n")
+		for _, stmt := range g.Generate(outside) {
+			writeStatement(sb, stmt)
+		}
+		if inside.IsEmpty() {
+			return
+		}
+	}
 
 	sb.WriteString("BEGIN;\n\n")
+	for _, stmt := range g.Generate(inside) {
+		writeStatement(sb, stmt)
+	}
+	sb.WriteString("COMMIT;\n")
 
-	statements := g.Generate(cs)
-	for _, stmt := range statements {
-		sb.WriteString(stmt)
+	if g.SeparateAfterCommit {
+		return
+	}
+	if after := g.AfterCommit(inside); len(after) > 0 {
 		sb.WriteString("\n")
-		if !strings.HasPrefix(stmt, "--") {
-			sb.WriteString("\n")
+		writeAfterCommitNote(sb)
+		for _, stmt := range after {
+			writeStatement(sb, stmt)
 		}
 	}
+}
 
-	sb.WriteString("COMMIT;\n")
+func writeAfterCommitNote(sb *strings.Builder) {
+	sb.WriteString("-- After the transaction: each statement commits on its own, so the\n")
+	sb.WriteString("-- tables are not locked while existing rows are backfilled.\n\n")
+}
+
+// GenerateAfterCommitMigrationFile generates a migration file holding only
+// stmt, one of the statements AfterCommit returns, outside any transaction
+// block.
+func (g *SQLGenerator) GenerateAfterCommitMigrationFile(stmt, description string) string {
+	var sb strings.Builder
+
+	writeMigrationHeader(&sb, NewChangeSet(), description)
+	writeAfterCommitNote(&sb)
+	writeStatement(&sb, stmt)
 
 	return sb.String()
 }
 
+func (g *SQLGenerator) writeGooseSection(sb *strings.Builder, outside, inside *ChangeSet, noTransaction bool) {
+	writeGooseStatements(sb, g.Generate(outside))
+	if !noTransaction {
+		writeGooseStatements(sb, g.Generate(inside))
+		return
+	}
+	if inside.IsEmpty() {
+		return
+	}
+
+	sb.WriteString("-- +goose StatementBegin\nBEGIN;\n\n")
+	for _, stmt := range g.Generate(inside) {
+		writeStatement(sb, stmt)
+	}
+	sb.WriteString("COMMIT;\n-- +goose StatementEnd\n\n")
+
+	writeGooseStatements(sb, g.AfterCommit(inside))
+}
+
+func writeGooseStatements(sb *strings.Builder, statements []string) {
+	for _, stmt := range statements {
+		// goose ends a statement at any line ending in a semicolon, which
+		// would cut a function body short.
+		if len(SplitStatements(stmt)) == 1 && strings.Count(stmt, ";") > 1 {
+			sb.WriteString("-- +goose StatementBegin\n" + stmt + "\n-- +goose StatementEnd\n\n")
+			continue
+		}
+		writeStatement(sb, stmt)
+	}
+}
+
+// writeStatement writes stmt on its own line, followed by a blank line
+// unless it is a comment that belongs to the statement after it.
+func writeStatement(sb *strings.Builder, stmt string) {
+	sb.WriteString(stmt)
+	sb.WriteString("\n")
+	if !strings.HasPrefix(stmt, "--") {
+		sb.WriteString("\n")
+	}
+}
+
 func quoteIdent(name string) string {
 	if isSimpleIdent(name) {
 		return name
@@ -326,6 +900,49 @@ func quoteIdent(name string) string {
 	return `"` + escaped + `"`
 }
 
+// quoteQualifiedIdent quotes a name built by qualifiedName, such as a
+// FullName, quoting the schema and the name separately.
+func quoteQualifiedIdent(fullName string) string {
+	schema, name := splitQualifiedName(fullName)
+	if schema == "" {
+		return quoteIdent(name)
+	}
+	return quoteIdent(schema) + "." + quoteIdent(name)
+}
+
+var (
+	typeModifierPattern = regexp.MustCompile(`\(\s*\d+\s*(,\s*\d+\s*)?\)$`)
+	builtinTypePattern  = regexp.MustCompile(`^[a-z_][a-z0-9_]*( [a-z_][a-z0-9_]*)*$`)
+)
+
+// formatType renders a column type for use in DDL. Built-in type names,
+// including multi-word ones like "double precision", are kept as they are,
+// along with a numeric modifier such as (255) or (10,2) and any [] suffixes.
+// Anything else, such as a mixed-case enum name, is quoted as an identifier,
+// so a type name can never inject SQL.
+func formatType(t string) string {
+	base := strings.TrimSpace(t)
+
+	var arraySuffix string
+	for strings.HasSuffix(base, "[]") {
+		base = strings.TrimSuffix(base, "[]")
+		arraySuffix += "[]"
+	}
+
+	var modifier string
+	if loc := typeModifierPattern.FindStringIndex(base); loc != nil {
+		modifier = strings.ReplaceAll(base[loc[0]:], " ", "")
+		base = base[:loc[0]]
+	}
+
+	if !builtinTypePattern.MatchString(base) {
+		parts := strings.Split(base, ".")
+		base = strings.Join(quoteIdents(parts), ".")
+	}
+
+	return base + modifier + arraySuffix
+}
+
 func quoteIdents(names []string) []string {
 	result := make([]string, len(names))
 	for i, name := range names {
@@ -334,6 +951,15 @@ func quoteIdents(names []string) []string {
 	return result
 }
 
+// collateClause returns the COLLATE clause for a Column.Collation, or ""
+// for the default collation.
+func collateClause(collation string) string {
+	if collation == "" {
+		return ""
+	}
+	return " COLLATE " + quoteQualifiedIdent(collation)
+}
+
 func quoteLiteral(s string) string {
 	escaped := strings.ReplaceAll(s, "'", "''")
 	return "'" + escaped + "'"
@@ -2,15 +2,36 @@ package schema
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
+	"unicode"
 )
 
+// Schema is the structure of a database. Objects are keyed by their
+// FullName, which is qualified with the PostgreSQL schema they live in
+// except for the public schema, so tables of the same name in different
+// schemas don't collide while schemas recorded before qualification keep
+// their keys and fingerprint.
 type Schema struct {
 	Name      string
 	Tables    map[string]*Table
 	Enums     map[string]*Enum
 	Functions map[string]*Function
+
+	// Sequences holds the standalone sequences, including those backing
+	// serial columns. Identity sequences belong to their column (see
+	// Column.Identity) and are not listed. Omitted from JSON when empty so
+	// schemas without sequences keep their fingerprint.
+	Sequences map[string]*Sequence `json:",omitempty"`
+
+	// Views holds views and materialized views. Omitted from JSON when
+	// empty, like Sequences.
+	Views map[string]*View `json:",omitempty"`
+
+	// Namespaces lists the PostgreSQL schemas other than public, which
+	// always exists.
+	Namespaces []string `json:",omitempty"`
 }
 
 func NewSchema(name string) *Schema {
@@ -19,12 +40,15 @@ func NewSchema(name string) *Schema {
 		Tables:    make(map[string]*Table),
 		Enums:     make(map[string]*Enum),
 		Functions: make(map[string]*Function),
+		Sequences: make(map[string]*Sequence),
+		Views:     make(map[string]*View),
 	}
 }
 
 type Table struct {
 	Name        string
 	Schema      string
+	Comment     string
 	Columns     map[string]*Column
 	Indexes     map[string]*Index
 	Constraints map[string]*Constraint
@@ -40,6 +64,24 @@ func NewTable(name, schema string) *Table {
 	}
 }
 
+// qualifiedName qualifies name with schema, leaving names in the public
+// schema unqualified as the FullName methods do.
+func qualifiedName(schema, name string) string {
+	if schema == "" || schema == "public" {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", schema, name)
+}
+
+// splitQualifiedName splits a name built by qualifiedName back into its
+// schema, which is empty for unqualified names, and the name itself.
+func splitQualifiedName(fullName string) (schema, name string) {
+	if i := strings.Index(fullName, "."); i >= 0 {
+		return fullName[:i], fullName[i+1:]
+	}
+	return "", fullName
+}
+
 func (t *Table) FullName() string {
 	if t.Schema == "" || t.Schema == "public" {
 		return t.Name
@@ -94,6 +136,20 @@ type Column struct {
 
 	IsArray     bool
 	ElementType string
+
+	// Identity is "ALWAYS" or "BY DEFAULT" for identity columns, and empty
+	// otherwise.
+	Identity string `json:",omitempty"`
+
+	// Collation is the column's collation, such as "C", qualified like
+	// DataType, and empty for the type's default collation.
+	Collation string `json:",omitempty"`
+
+	// Generated is the expression of a GENERATED ALWAYS AS ... STORED
+	// column, and empty for other columns.
+	Generated string `json:",omitempty"`
+
+	Comment string
 }
 
 func (c *Column) FullType() string {
@@ -132,13 +188,90 @@ func (c *Column) Equals(other *Column) bool {
 	if c.IsNullable != other.IsNullable {
 		return false
 	}
+	if c.Identity != other.Identity {
+		return false
+	}
+	if c.Collation != other.Collation {
+		return false
+	}
+	if c.Generated != other.Generated {
+		return false
+	}
 	if c.DefaultValue == nil && other.DefaultValue == nil {
 		return true
 	}
 	if c.DefaultValue == nil || other.DefaultValue == nil {
 		return false
 	}
-	return *c.DefaultValue == *other.DefaultValue
+	return defaultsEqual(*c.DefaultValue, *other.DefaultValue)
+}
+
+var publicQualifier = regexp.MustCompile(`(^|[^A-Za-z0-9_$"])(?:public|"public")\.`)
+
+// normalizeDefault rewrites a column default expression so that cosmetic
+// differences do not register as changes. The public schema qualifier is
+// stripped (public.gen_random_uuid() and gen_random_uuid() are the same
+// function), but only outside string literals, so 'public.x'::text and
+// 'x'::text stay different; literals cast to regclass name a relation and
+// are stripped too. Whitespace outside string literals is collapsed.
+func normalizeDefault(expr string) string {
+	runes := []rune(strings.TrimSpace(expr))
+
+	var sb strings.Builder
+	var plain strings.Builder   // text outside literals not yet written
+	var literal strings.Builder // contents of the current literal
+	inLiteral := false
+	pendingSpace := false
+	for i, r := range runes {
+		if inLiteral {
+			if r != '\'' {
+				literal.WriteRune(r)
+				continue
+			}
+			value := literal.String()
+			if strings.HasPrefix(string(runes[i+1:]), "::regclass") {
+				value = publicQualifier.ReplaceAllString(value, "$1")
+			}
+			sb.WriteString(value)
+			sb.WriteRune(r)
+			literal.Reset()
+			inLiteral = false
+			continue
+		}
+
+		if unicode.IsSpace(r) {
+			pendingSpace = true
+			continue
+		}
+		if pendingSpace {
+			plain.WriteRune(' ')
+			pendingSpace = false
+		}
+		if r == '\'' {
+			sb.WriteString(publicQualifier.ReplaceAllString(plain.String(), "$1"))
+			plain.Reset()
+			sb.WriteRune(r)
+			inLiteral = true
+			continue
+		}
+		plain.WriteRune(r)
+	}
+	sb.WriteString(publicQualifier.ReplaceAllString(plain.String(), "$1"))
+	sb.WriteString(literal.String())
+
+	return sb.String()
+}
+
+// sameDefinition reports whether c and other are equal apart from their
+// names.
+func (c *Column) sameDefinition(other *Column) bool {
+	renamed := *other
+	renamed.Name = c.Name
+	return c.Equals(&renamed)
+}
+
+func defaultsEqual(a, b string) bool {
+	return normalizeDefault(a) == normalizeDefault(b)
 }
 
 type Index struct {
@@ -172,7 +305,9 @@ func (i *Index) Equals(other *Index) bool {
 			return false
 		}
 	}
-	return true
+	// The definition covers what the fields above cannot: partial index
+	// predicates, expressions, INCLUDE columns and operator classes.
+	return i.Definition == other.Definition
 }
 
 type ConstraintType string
@@ -270,6 +405,81 @@ func (f *Function) Equals(other *Function) bool {
 	return f.BodyHash == other.BodyHash
 }
 
+// Sequence is a standalone sequence. OwnedByTable (the table's FullName)
+// and OwnedByColumn name the column the sequence belongs to, as for a
+// serial column, and are empty when it has no owner.
+type Sequence struct {
+	Name      string
+	Schema    string
+	DataType  string
+	Start     int64
+	Increment int64
+	MinValue  int64
+	MaxValue  int64
+	Cache     int64
+	Cycle     bool
+
+	OwnedByTable  string
+	OwnedByColumn string
+}
+
+func (s *Sequence) FullName() string {
+	if s.Schema == "" || s.Schema == "public" {
+		return s.Name
+	}
+	return fmt.Sprintf("%s.%s", s.Schema, s.Name)
+}
+
+// OwnedBy returns the owning column as table.column, or "" if the sequence
+// has no owner.
+func (s *Sequence) OwnedBy() string {
+	if s.OwnedByTable == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s", s.OwnedByTable, s.OwnedByColumn)
+}
+
+func (s *Sequence) Equals(other *Sequence) bool {
+	if s.Name != other.Name {
+		return false
+	}
+	return s.DataType == other.DataType &&
+		s.Start == other.Start &&
+		s.Increment == other.Increment &&
+		s.MinValue == other.MinValue &&
+		s.MaxValue == other.MaxValue &&
+		s.Cache == other.Cache &&
+		s.Cycle == other.Cycle &&
+		s.OwnedBy() == other.OwnedBy()
+}
+
+// View is a view or, if Materialized is set, a materialized view.
+// Definition is the view's query as returned by pg_get_viewdef.
+type View struct {
+	Name           string
+	Schema         string
+	Materialized   bool
+	Definition     string
+	DefinitionHash string
+}
+
+func (v *View) FullName() string {
+	if v.Schema == "" || v.Schema == "public" {
+		return v.Name
+	}
+	return fmt.Sprintf("%s.%s", v.Schema, v.Name)
+}
+
+func (v *View) Equals(other *View) bool {
+	if v.Name != other.Name {
+		return false
+	}
+	if v.Materialized != other.Materialized {
+		return false
+	}
+	return v.DefinitionHash == other.DefinitionHash
+}
+
 func (s *Schema) SortedTables() []*Table {
 	tables := make([]*Table, 0, len(s.Tables))
 	for _, t := range s.Tables {
@@ -302,3 +512,25 @@ func (s *Schema) SortedFunctions() []*Function {
 	})
 	return funcs
 }
+
+func (s *Schema) SortedSequences() []*Sequence {
+	seqs := make([]*Sequence, 0, len(s.Sequences))
+	for _, seq := range s.Sequences {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i].Name < seqs[j].Name
+	})
+	return seqs
+}
+
+func (s *Schema) SortedViews() []*View {
+	views := make([]*View, 0, len(s.Views))
+	for _, v := range s.Views {
+		views = append(views, v)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].Name < views[j].Name
+	})
+	return views
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBranchNotFound is matched by errors for a branch that doesn't exist.
+	ErrBranchNotFound = errors.New("branch does not exist")
+
+	// ErrBranchExists is matched by errors for a branch name that is
+	// already taken.
+	ErrBranchExists = errors.New("branch already exists")
+)
+
+// BranchError is an error about a named branch. It matches its Err
+// (ErrBranchNotFound or ErrBranchExists) with errors.Is.
+type BranchError struct {
+	Name string
+	Err  error
+}
+
+func (e *BranchError) Error() string {
+	switch e.Err {
+	case ErrBranchNotFound:
+		return fmt.Sprintf("branch '%s' does not exist", e.Name)
+	case ErrBranchExists:
+		return fmt.Sprintf("branch '%s' already exists", e.Name)
+	default:
+		return fmt.Sprintf("branch '%s': %v", e.Name, e.Err)
+	}
+}
+
+func (e *BranchError) Unwrap() error {
+	return e.Err
+}
+
+// NewBranchNotFoundError returns an error for the missing branch name.
+func NewBranchNotFoundError(name string) error {
+	return &BranchError{Name: name, Err: ErrBranchNotFound}
+}
+
+// NewBranchExistsError returns an error for the already existing branch
+// name.
+func NewBranchExistsError(name string) error {
+	return &BranchError{Name: name, Err: ErrBranchExists}
+}
+
+var (
+	// ErrSnapshotNotFound is matched by errors for a saved snapshot that
+	// doesn't exist.
+	ErrSnapshotNotFound = errors.New("snapshot does not exist")
+
+	// ErrSnapshotExists is matched by errors for a snapshot name that is
+	// already taken.
+	ErrSnapshotExists = errors.New("snapshot already exists")
+)
+
+// SnapshotError is an error about a named snapshot. It matches its Err
+// (ErrSnapshotNotFound or ErrSnapshotExists) with errors.Is.
+type SnapshotError struct {
+	Name string
+	Err  error
+}
+
+func (e *SnapshotError) Error() string {
+	switch e.Err {
+	case ErrSnapshotNotFound:
+		return fmt.Sprintf("snapshot '%s' does not exist", e.Name)
+	case ErrSnapshotExists:
+		return fmt.Sprintf("snapshot '%s' already exists", e.Name)
+	default:
+		return fmt.Sprintf("snapshot '%s': %v", e.Name, e.Err)
+	}
+}
+
+func (e *SnapshotError) Unwrap() error {
+	return e.Err
+}
+
+// NewSnapshotNotFoundError returns an error for the missing snapshot name.
+func NewSnapshotNotFoundError(name string) error {
+	return &SnapshotError{Name: name, Err: ErrSnapshotNotFound}
+}
+
+// NewSnapshotExistsError returns an error for the already existing snapshot
+// name.
+func NewSnapshotExistsError(name string) error {
+	return &SnapshotError{Name: name, Err: ErrSnapshotExists}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/le-vlad/pgbranch/pkg/config"
+)
+
+// MetadataLockFileName is the name of the file locked while the metadata
+// file is read or written, so concurrent pgbranch processes (for example the
+// git hook and an interactive command) don't overwrite each other's changes.
+const MetadataLockFileName = "metadata.lock"
+
+// metadataLock is an advisory lock on the metadata lock file. The lock is
+// held until Unlock is called or the process exits.
+type metadataLock struct {
+	file *os.File
+}
+
+// lockMetadata blocks until it holds the metadata lock. An exclusive lock
+// is needed to write the metadata; any number of processes can hold a
+// shared lock to read it.
+func lockMetadata(exclusive bool) (*metadataLock, error) {
+	rootDir, err := config.GetRootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(rootDir, MetadataLockFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata lock file: %w", err)
+	}
+
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock metadata: %w", err)
+	}
+
+	return &metadataLock{file: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *metadataLock) Unlock() {
+	unlockFile(l.file)
+	l.file.Close()
+}
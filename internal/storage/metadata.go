@@ -3,9 +3,12 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/le-vlad/pgbranch/pkg/config"
@@ -21,6 +24,56 @@ type Branch struct {
 	LastCheckoutAt time.Time `json:"last_checkout_at,omitempty"`
 	Parent         string    `json:"parent,omitempty"`
 	Snapshot       string    `json:"snapshot"`
+
+	// Description is a free-form note about what the branch is for.
+	Description string `json:"description,omitempty"`
+
+	// Labels are short tags for organizing branches, sorted.
+	Labels []string `json:"labels,omitempty"`
+
+	// Protected branches can't be deleted or pruned without force.
+	Protected bool `json:"protected,omitempty"`
+
+	// SchemaFingerprint is the hash of the snapshot's schema when it was last
+	// created or updated by pgbranch.
+	SchemaFingerprint string `json:"schema_fingerprint,omitempty"`
+
+	// UpdateHistory lists every update of the snapshot, oldest first.
+	UpdateHistory []UpdateEntry `json:"update_history,omitempty"`
+
+	// Source records the remote branch this branch was pulled from, if any.
+	Source *RemoteSource `json:"source,omitempty"`
+}
+
+// RemoteSource identifies the remote branch a local branch was created from.
+type RemoteSource struct {
+	Remote   string    `json:"remote"`
+	Branch   string    `json:"branch"`
+	PulledAt time.Time `json:"pulled_at"`
+
+	// UpdatedAt is the remote branch's modification time when it was
+	// pulled, or zero if the remote couldn't be listed.
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// UpdateEntry records a single update of a branch's snapshot.
+type UpdateEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message,omitempty"`
+}
+
+// KeepLabel is the label that protects a branch from being considered
+// stale, and so from being removed by prune.
+const KeepLabel = "keep"
+
+// HasLabel reports whether the branch has the given label.
+func (b *Branch) HasLabel(label string) bool {
+	for _, l := range b.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
 }
 
 // IsStale returns true if the branch hasn't been accessed in the specified
@@ -49,10 +102,37 @@ func (b *Branch) DaysSinceLastAccess() int {
 	return int(time.Since(lastAccess).Hours() / 24)
 }
 
+// RemoteBranchRef records a branch seen on a remote during the last fetch.
+type RemoteBranchRef struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// RemoteTracking holds the branches last fetched from a single remote.
+type RemoteTracking struct {
+	FetchedAt time.Time         `json:"fetched_at"`
+	Branches  []RemoteBranchRef `json:"branches"`
+}
+
+// Snapshot is a named copy of the working database saved with 'pgbranch
+// snapshot create'. Unlike a branch, it has no parent or history and is
+// never checked out; it can only be restored over the working database.
+type Snapshot struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Database  string    `json:"database"`
+	// Branch is the branch that was checked out when the snapshot was
+	// taken, if any.
+	Branch string `json:"branch,omitempty"`
+}
+
 // Metadata stores information about all branches and the current branch state.
 type Metadata struct {
-	CurrentBranch string             `json:"current_branch"`
-	Branches      map[string]*Branch `json:"branches"`
+	CurrentBranch  string                     `json:"current_branch"`
+	Branches       map[string]*Branch         `json:"branches"`
+	RemoteTracking map[string]*RemoteTracking `json:"remote_tracking,omitempty"`
+	Snapshots      map[string]*Snapshot       `json:"snapshots,omitempty"`
 }
 
 // NewMetadata creates a new empty Metadata instance.
@@ -75,6 +155,54 @@ func GetMetadataPath() (string, error) {
 // LoadMetadata reads and parses the metadata file. If the file doesn't exist,
 // returns a new empty Metadata instance.
 func LoadMetadata() (*Metadata, error) {
+	lock, err := lockMetadata(false)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return NewMetadata(), nil
+		}
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	return loadMetadata()
+}
+
+// Save writes the metadata to the metadata file. Save replaces whatever is
+// in the file; use LockedUpdate to change metadata that another process may
+// be changing at the same time.
+func (m *Metadata) Save() error {
+	lock, err := lockMetadata(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return m.save()
+}
+
+// LockedUpdate loads the metadata, applies fn to it and saves the result
+// while holding the metadata lock, so that no other pgbranch process can
+// change the metadata in between. If fn returns an error nothing is saved.
+func LockedUpdate(fn func(*Metadata) error) error {
+	lock, err := lockMetadata(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	meta, err := loadMetadata()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(meta); err != nil {
+		return err
+	}
+
+	return meta.save()
+}
+
+func loadMetadata() (*Metadata, error) {
 	metadataPath, err := GetMetadataPath()
 	if err != nil {
 		return nil, err
@@ -100,8 +228,9 @@ func LoadMetadata() (*Metadata, error) {
 	return &meta, nil
 }
 
-// Save writes the metadata to the metadata file.
-func (m *Metadata) Save() error {
+// save writes the metadata file atomically, so readers and a crash mid-write
+// never leave a partially written file.
+func (m *Metadata) save() error {
 	metadataPath, err := GetMetadataPath()
 	if err != nil {
 		return err
@@ -112,7 +241,7 @@ func (m *Metadata) Save() error {
 		return fmt.Errorf("failed to serialize metadata: %w", err)
 	}
 
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	if err := config.WriteFileAtomic(metadataPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write metadata file: %w", err)
 	}
 
@@ -140,7 +269,7 @@ func (m *Metadata) GetBranch(name string) (*Branch, bool) {
 // DeleteBranch removes a branch from the metadata.
 func (m *Metadata) DeleteBranch(name string) error {
 	if _, ok := m.Branches[name]; !ok {
-		return fmt.Errorf("branch '%s' does not exist", name)
+		return NewBranchNotFoundError(name)
 	}
 	delete(m.Branches, name)
 	return nil
@@ -164,7 +293,7 @@ func (m *Metadata) ListBranches() []string {
 // SetCurrentBranch sets the current branch to the given name.
 func (m *Metadata) SetCurrentBranch(name string) error {
 	if name != "" && !m.BranchExists(name) {
-		return fmt.Errorf("branch '%s' does not exist", name)
+		return NewBranchNotFoundError(name)
 	}
 	m.CurrentBranch = name
 	return nil
@@ -172,7 +301,8 @@ func (m *Metadata) SetCurrentBranch(name string) error {
 
 // GetStaleBranches returns all branches that haven't been accessed
 // in the specified number of days. Excludes root branches (branches with no parent)
-// as they represent the main base branch of the project.
+// as they represent the main base branch of the project, and branches
+// labeled KeepLabel.
 func (m *Metadata) GetStaleBranches(staleDays int) []*Branch {
 	var stale []*Branch
 	for _, branch := range m.Branches {
@@ -181,6 +311,9 @@ func (m *Metadata) GetStaleBranches(staleDays int) []*Branch {
 		if branch.Parent == "" {
 			continue
 		}
+		if branch.HasLabel(KeepLabel) {
+			continue
+		}
 		if branch.IsStale(staleDays) {
 			stale = append(stale, branch)
 		}
@@ -192,8 +325,175 @@ func (m *Metadata) GetStaleBranches(staleDays int) []*Branch {
 func (m *Metadata) UpdateLastCheckout(name string) error {
 	branch, ok := m.Branches[name]
 	if !ok {
-		return fmt.Errorf("branch '%s' does not exist", name)
+		return NewBranchNotFoundError(name)
 	}
 	branch.LastCheckoutAt = time.Now()
 	return nil
 }
+
+// RecordUpdate appends an entry with the given message to a branch's update
+// history.
+func (m *Metadata) RecordUpdate(name, message string) error {
+	branch, ok := m.Branches[name]
+	if !ok {
+		return NewBranchNotFoundError(name)
+	}
+	branch.UpdateHistory = append(branch.UpdateHistory, UpdateEntry{
+		Time:    time.Now(),
+		Message: message,
+	})
+	return nil
+}
+
+// SetDescription sets a branch's description. An empty description
+// removes it.
+func (m *Metadata) SetDescription(name, description string) error {
+	branch, ok := m.Branches[name]
+	if !ok {
+		return NewBranchNotFoundError(name)
+	}
+	branch.Description = description
+	return nil
+}
+
+// SetProtected protects a branch from deletion, or removes the protection.
+func (m *Metadata) SetProtected(name string, protected bool) error {
+	branch, ok := m.Branches[name]
+	if !ok {
+		return NewBranchNotFoundError(name)
+	}
+	branch.Protected = protected
+	return nil
+}
+
+// AddLabel adds label to a branch. Adding a label the branch already has
+// does nothing.
+func (m *Metadata) AddLabel(name, label string) error {
+	branch, ok := m.Branches[name]
+	if !ok {
+		return NewBranchNotFoundError(name)
+	}
+	if branch.HasLabel(label) {
+		return nil
+	}
+	branch.Labels = append(branch.Labels, label)
+	sort.Strings(branch.Labels)
+	return nil
+}
+
+// RemoveLabel removes label from a branch.
+func (m *Metadata) RemoveLabel(name, label string) error {
+	branch, ok := m.Branches[name]
+	if !ok {
+		return NewBranchNotFoundError(name)
+	}
+	for i, l := range branch.Labels {
+		if l == label {
+			branch.Labels = append(branch.Labels[:i], branch.Labels[i+1:]...)
+			if len(branch.Labels) == 0 {
+				branch.Labels = nil
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("branch '%s' has no label '%s'", name, label)
+}
+
+// SetRemoteBranches replaces the tracked branches for the given remote with
+// the result of a fetch.
+func (m *Metadata) SetRemoteBranches(remoteName string, branches []RemoteBranchRef) {
+	if m.RemoteTracking == nil {
+		m.RemoteTracking = make(map[string]*RemoteTracking)
+	}
+	m.RemoteTracking[remoteName] = &RemoteTracking{
+		FetchedAt: time.Now(),
+		Branches:  branches,
+	}
+}
+
+// GetRemoteBranches returns the tracked branches for the given remote, or
+// false if the remote has never been fetched.
+func (m *Metadata) GetRemoteBranches(remoteName string) (*RemoteTracking, bool) {
+	tracking, ok := m.RemoteTracking[remoteName]
+	return tracking, ok
+}
+
+// RemoteUpdate returns the remote branch that branch was pulled from, as
+// seen by the last fetch, if it was updated on the remote after the pull.
+func (m *Metadata) RemoteUpdate(branch *Branch) (RemoteBranchRef, bool) {
+	source := branch.Source
+	if source == nil {
+		return RemoteBranchRef{}, false
+	}
+
+	tracking, ok := m.RemoteTracking[source.Remote]
+	if !ok {
+		return RemoteBranchRef{}, false
+	}
+
+	// Branches pulled before UpdatedAt was recorded fall back to the time
+	// of the pull.
+	since := source.UpdatedAt
+	if since.IsZero() {
+		since = source.PulledAt
+	}
+
+	for _, ref := range tracking.Branches {
+		if ref.Name == source.Branch {
+			return ref, ref.ModTime.After(since)
+		}
+	}
+	return RemoteBranchRef{}, false
+}
+
+// RemoveRemoteTracking drops the tracked branches for the given remote.
+func (m *Metadata) RemoveRemoteTracking(remoteName string) {
+	delete(m.RemoteTracking, remoteName)
+}
+
+// AddSnapshot records a saved snapshot of the working database, stored in
+// the database dbName.
+func (m *Metadata) AddSnapshot(name, dbName string) *Snapshot {
+	if m.Snapshots == nil {
+		m.Snapshots = make(map[string]*Snapshot)
+	}
+	snapshot := &Snapshot{
+		Name:      name,
+		CreatedAt: time.Now(),
+		Database:  dbName,
+		Branch:    m.CurrentBranch,
+	}
+	m.Snapshots[name] = snapshot
+	return snapshot
+}
+
+// GetSnapshot returns the saved snapshot with the given name, or false if
+// not found.
+func (m *Metadata) GetSnapshot(name string) (*Snapshot, bool) {
+	snapshot, ok := m.Snapshots[name]
+	return snapshot, ok
+}
+
+// DeleteSnapshot removes a saved snapshot from the metadata.
+func (m *Metadata) DeleteSnapshot(name string) error {
+	if _, ok := m.Snapshots[name]; !ok {
+		return NewSnapshotNotFoundError(name)
+	}
+	delete(m.Snapshots, name)
+	return nil
+}
+
+// ListSnapshots returns all saved snapshots, oldest first.
+func (m *Metadata) ListSnapshots() []*Snapshot {
+	snapshots := make([]*Snapshot, 0, len(m.Snapshots))
+	for _, snapshot := range m.Snapshots {
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+		}
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots
+}
@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -92,7 +94,7 @@ func TestDeleteBranch(t *testing.T) {
 
 	err = meta.DeleteBranch("non-existent")
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "does not exist")
+	assert.ErrorIs(t, err, ErrBranchNotFound)
 }
 
 func TestBranchExists(t *testing.T) {
@@ -130,7 +132,7 @@ func TestSetCurrentBranch(t *testing.T) {
 
 	err = meta.SetCurrentBranch("non-existent")
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "does not exist")
+	assert.ErrorIs(t, err, ErrBranchNotFound)
 
 	err = meta.SetCurrentBranch("")
 	require.NoError(t, err)
@@ -226,6 +228,17 @@ func TestGetStaleBranchesExcludesRootBranch(t *testing.T) {
 	assert.Len(t, staleBranches, 0)
 }
 
+func TestGetStaleBranchesExcludesKeepLabel(t *testing.T) {
+	meta := NewMetadata()
+	meta.AddBranch("main", "", "main.dump")
+
+	kept := meta.AddBranch("demo", "main", "demo.dump")
+	kept.CreatedAt = kept.CreatedAt.AddDate(0, 0, -100)
+	require.NoError(t, meta.AddLabel("demo", KeepLabel))
+
+	assert.Len(t, meta.GetStaleBranches(7), 0)
+}
+
 func TestDaysSinceLastAccess(t *testing.T) {
 	t.Run("recent checkout returns 0", func(t *testing.T) {
 		b := &Branch{
@@ -275,6 +288,294 @@ func TestUpdateLastCheckout(t *testing.T) {
 
 		err := meta.UpdateLastCheckout("non-existent")
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "does not exist")
+		assert.ErrorIs(t, err, ErrBranchNotFound)
+	})
+}
+
+func TestRecordUpdate(t *testing.T) {
+	t.Run("appends entries in order", func(t *testing.T) {
+		meta := NewMetadata()
+		meta.AddBranch("feature-1", "", "feature-1.dump")
+
+		before := time.Now()
+		require.NoError(t, meta.RecordUpdate("feature-1", "add users table"))
+		require.NoError(t, meta.RecordUpdate("feature-1", ""))
+
+		branch, _ := meta.GetBranch("feature-1")
+		require.Len(t, branch.UpdateHistory, 2)
+		assert.Equal(t, "add users table", branch.UpdateHistory[0].Message)
+		assert.True(t, !branch.UpdateHistory[0].Time.Before(before))
+		assert.Empty(t, branch.UpdateHistory[1].Message)
+	})
+
+	t.Run("returns error for non-existent branch", func(t *testing.T) {
+		meta := NewMetadata()
+
+		err := meta.RecordUpdate("non-existent", "message")
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrBranchNotFound)
+	})
+}
+
+func TestSetDescription(t *testing.T) {
+	_, cleanup := setupMetadataTestDir(t)
+	defer cleanup()
+
+	meta := NewMetadata()
+	meta.AddBranch("feature-x", "", "snap_feature_x")
+
+	require.NoError(t, meta.SetDescription("feature-x", "Login rework"))
+	require.NoError(t, meta.Save())
+
+	loaded, err := LoadMetadata()
+	require.NoError(t, err)
+	branch, ok := loaded.GetBranch("feature-x")
+	require.True(t, ok)
+	assert.Equal(t, "Login rework", branch.Description)
+
+	require.NoError(t, loaded.SetDescription("feature-x", ""))
+	assert.Empty(t, branch.Description)
+
+	assert.ErrorIs(t, meta.SetDescription("missing", "x"), ErrBranchNotFound)
+}
+
+func TestLabels(t *testing.T) {
+	_, cleanup := setupMetadataTestDir(t)
+	defer cleanup()
+
+	meta := NewMetadata()
+	meta.AddBranch("feature-x", "", "snap_feature_x")
+
+	require.NoError(t, meta.AddLabel("feature-x", "wip"))
+	require.NoError(t, meta.AddLabel("feature-x", "demo"))
+	require.NoError(t, meta.AddLabel("feature-x", "wip"))
+	require.NoError(t, meta.Save())
+
+	loaded, err := LoadMetadata()
+	require.NoError(t, err)
+	branch, ok := loaded.GetBranch("feature-x")
+	require.True(t, ok)
+	assert.Equal(t, []string{"demo", "wip"}, branch.Labels)
+	assert.True(t, branch.HasLabel("wip"))
+	assert.False(t, branch.HasLabel("keep"))
+
+	require.NoError(t, loaded.RemoveLabel("feature-x", "wip"))
+	require.NoError(t, loaded.RemoveLabel("feature-x", "demo"))
+	assert.Nil(t, branch.Labels)
+
+	assert.EqualError(t, loaded.RemoveLabel("feature-x", "wip"), "branch 'feature-x' has no label 'wip'")
+	assert.ErrorIs(t, loaded.AddLabel("missing", "wip"), ErrBranchNotFound)
+}
+
+func TestRemoteTracking(t *testing.T) {
+	_, cleanup := setupMetadataTestDir(t)
+	defer cleanup()
+
+	meta := NewMetadata()
+
+	_, ok := meta.GetRemoteBranches("origin")
+	assert.False(t, ok)
+
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	meta.SetRemoteBranches("origin", []RemoteBranchRef{
+		{Name: "main", Size: 1024, ModTime: modTime},
+		{Name: "feature-x", Size: 2048, ModTime: modTime},
 	})
+	require.NoError(t, meta.Save())
+
+	loaded, err := LoadMetadata()
+	require.NoError(t, err)
+
+	tracking, ok := loaded.GetRemoteBranches("origin")
+	require.True(t, ok)
+	assert.False(t, tracking.FetchedAt.IsZero())
+	require.Len(t, tracking.Branches, 2)
+	assert.Equal(t, "main", tracking.Branches[0].Name)
+	assert.Equal(t, int64(1024), tracking.Branches[0].Size)
+	assert.True(t, modTime.Equal(tracking.Branches[0].ModTime))
+
+	loaded.RemoveRemoteTracking("origin")
+	_, ok = loaded.GetRemoteBranches("origin")
+	assert.False(t, ok)
+}
+
+func TestBranchSource(t *testing.T) {
+	_, cleanup := setupMetadataTestDir(t)
+	defer cleanup()
+
+	meta := NewMetadata()
+	pulledAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	meta.AddBranch("staging", "", "snap_staging").Source = &RemoteSource{
+		Remote:    "origin",
+		Branch:    "main",
+		PulledAt:  pulledAt,
+		UpdatedAt: pulledAt.Add(-time.Hour),
+	}
+	meta.AddBranch("local", "", "snap_local")
+	require.NoError(t, meta.Save())
+
+	loaded, err := LoadMetadata()
+	require.NoError(t, err)
+
+	staging, ok := loaded.GetBranch("staging")
+	require.True(t, ok)
+	require.NotNil(t, staging.Source)
+	assert.Equal(t, "origin", staging.Source.Remote)
+	assert.Equal(t, "main", staging.Source.Branch)
+	assert.True(t, pulledAt.Equal(staging.Source.PulledAt))
+	assert.True(t, pulledAt.Add(-time.Hour).Equal(staging.Source.UpdatedAt))
+
+	local, ok := loaded.GetBranch("local")
+	require.True(t, ok)
+	assert.Nil(t, local.Source)
+}
+
+func TestSnapshots(t *testing.T) {
+	_, cleanup := setupMetadataTestDir(t)
+	defer cleanup()
+
+	meta := NewMetadata()
+	meta.AddBranch("main", "", "snap_main")
+	require.NoError(t, meta.SetCurrentBranch("main"))
+
+	first := meta.AddSnapshot("pre-experiment", "snap__snapshot_pre_experiment")
+	assert.Equal(t, "main", first.Branch)
+	second := meta.AddSnapshot("before-migrate", "snap__snapshot_before_migrate")
+	second.CreatedAt = first.CreatedAt.Add(time.Minute)
+
+	assert.False(t, meta.BranchExists("pre-experiment"), "snapshots are not branches")
+	require.NoError(t, meta.Save())
+
+	loaded, err := LoadMetadata()
+	require.NoError(t, err)
+
+	snapshot, ok := loaded.GetSnapshot("pre-experiment")
+	require.True(t, ok)
+	assert.Equal(t, "snap__snapshot_pre_experiment", snapshot.Database)
+	assert.Equal(t, "main", snapshot.Branch)
+
+	list := loaded.ListSnapshots()
+	require.Len(t, list, 2)
+	assert.Equal(t, "pre-experiment", list[0].Name)
+	assert.Equal(t, "before-migrate", list[1].Name)
+
+	require.NoError(t, loaded.DeleteSnapshot("pre-experiment"))
+	_, ok = loaded.GetSnapshot("pre-experiment")
+	assert.False(t, ok)
+
+	err = loaded.DeleteSnapshot("pre-experiment")
+	assert.EqualError(t, err, "snapshot 'pre-experiment' does not exist")
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
+func TestRemoteUpdate(t *testing.T) {
+	pulledAt := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	updatedAt := pulledAt.Add(-time.Hour)
+
+	meta := NewMetadata()
+	pulled := meta.AddBranch("staging", "", "snap_staging")
+	pulled.Source = &RemoteSource{Remote: "origin", Branch: "main", PulledAt: pulledAt, UpdatedAt: updatedAt}
+	legacy := meta.AddBranch("legacy", "", "snap_legacy")
+	legacy.Source = &RemoteSource{Remote: "origin", Branch: "old", PulledAt: pulledAt}
+	local := meta.AddBranch("local", "", "snap_local")
+
+	_, ok := meta.RemoteUpdate(pulled)
+	assert.False(t, ok, "remote never fetched")
+
+	meta.SetRemoteBranches("origin", []RemoteBranchRef{
+		{Name: "main", ModTime: updatedAt},
+		{Name: "old", ModTime: pulledAt.Add(-time.Minute)},
+	})
+
+	_, ok = meta.RemoteUpdate(pulled)
+	assert.False(t, ok, "remote unchanged since pull")
+	_, ok = meta.RemoteUpdate(legacy)
+	assert.False(t, ok, "remote older than pull")
+	_, ok = meta.RemoteUpdate(local)
+	assert.False(t, ok, "not pulled")
+
+	meta.SetRemoteBranches("origin", []RemoteBranchRef{
+		{Name: "main", ModTime: updatedAt.Add(time.Minute)},
+		{Name: "old", ModTime: pulledAt.Add(time.Minute)},
+	})
+
+	ref, ok := meta.RemoteUpdate(pulled)
+	assert.True(t, ok)
+	assert.Equal(t, "main", ref.Name)
+	_, ok = meta.RemoteUpdate(legacy)
+	assert.True(t, ok)
+
+	meta.SetRemoteBranches("origin", nil)
+	_, ok = meta.RemoteUpdate(pulled)
+	assert.False(t, ok, "remote branch deleted")
+}
+
+func TestLockedUpdate(t *testing.T) {
+	_, cleanup := setupMetadataTestDir(t)
+	defer cleanup()
+
+	meta := NewMetadata()
+	meta.AddBranch("main", "", "main_db")
+	require.NoError(t, meta.Save())
+
+	t.Run("saves changes", func(t *testing.T) {
+		err := LockedUpdate(func(m *Metadata) error {
+			m.CurrentBranch = "main"
+			return nil
+		})
+		require.NoError(t, err)
+
+		loaded, err := LoadMetadata()
+		require.NoError(t, err)
+		assert.Equal(t, "main", loaded.CurrentBranch)
+		assert.True(t, loaded.BranchExists("main"))
+	})
+
+	t.Run("discards changes on error", func(t *testing.T) {
+		err := LockedUpdate(func(m *Metadata) error {
+			m.AddBranch("feature", "main", "feature_db")
+			return fmt.Errorf("boom")
+		})
+		require.Error(t, err)
+
+		loaded, err := LoadMetadata()
+		require.NoError(t, err)
+		assert.False(t, loaded.BranchExists("feature"))
+	})
+
+	t.Run("concurrent updates are all kept", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := LockedUpdate(func(m *Metadata) error {
+					name := fmt.Sprintf("branch-%d", i)
+					m.AddBranch(name, "main", name+"_db")
+					return nil
+				})
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+
+		loaded, err := LoadMetadata()
+		require.NoError(t, err)
+		assert.Len(t, loaded.Branches, 21)
+	})
+}
+
+func TestBranchError(t *testing.T) {
+	err := NewBranchNotFoundError("feature-1")
+	assert.EqualError(t, err, "branch 'feature-1' does not exist")
+	assert.ErrorIs(t, err, ErrBranchNotFound)
+	assert.NotErrorIs(t, err, ErrBranchExists)
+
+	err = fmt.Errorf("failed to checkout: %w", NewBranchExistsError("feature-1"))
+	assert.EqualError(t, err, "failed to checkout: branch 'feature-1' already exists")
+	assert.ErrorIs(t, err, ErrBranchExists)
+
+	var branchErr *BranchError
+	require.ErrorAs(t, err, &branchErr)
+	assert.Equal(t, "feature-1", branchErr.Name)
 }
@@ -2,14 +2,119 @@ package storage
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
+// MaxIdentifierLength is the maximum length of a PostgreSQL identifier in
+// bytes. Longer database names are silently truncated by the server.
+const MaxIdentifierLength = 63
+
+var branchNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// ValidateBranchName checks that a branch name is non-empty and only uses
+// characters that can be safely mapped to a snapshot database name.
+func ValidateBranchName(name string) error {
+	return validateName("branch name", name)
+}
+
+// ValidateSnapshotName checks that a saved snapshot name is non-empty and
+// follows the same rules as branch names.
+func ValidateSnapshotName(name string) error {
+	return validateName("snapshot name", name)
+}
+
+// ValidateLabel checks that a branch label is non-empty and follows the
+// same rules as branch names.
+func ValidateLabel(label string) error {
+	return validateName("label", label)
+}
+
+// validateName checks name against the branch name rules, naming it as kind
+// in the error.
+func validateName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s cannot be empty", kind)
+	}
+	if !branchNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid %s '%s': must start with a letter or digit and contain only letters, digits, '-', '_', '.' and '/'", kind, name)
+	}
+	return nil
+}
+
+// maxPrefixDatabaseLength is how much of the database name is kept in the
+// names of namespaced snapshots. The project ID tells projects apart, so the
+// database name only needs to be long enough to recognize.
+const maxPrefixDatabaseLength = 24
+
+var projectIDPattern = regexp.MustCompile(`^[0-9a-f]{8}_`)
+
+// SnapshotDBPrefix returns the prefix shared by the names of all snapshot and
+// temporary databases created for originalDB by the project with the given
+// ID. Projects initialized before project IDs existed have an empty ID and
+// use the legacy prefix {originalDB}_pgbranch_; otherwise the prefix is
+// {originalDB}_pgbranch_{projectID}_, with originalDB shortened to leave
+// room for the branch name.
+//
+// A non-empty snapshotPrefix, configured by the project, replaces the
+// default scheme with {snapshotPrefix}_{originalDB}_{projectID}_ (or
+// {snapshotPrefix}_{originalDB}_ without an ID), so all of the project's
+// databases start with it.
+func SnapshotDBPrefix(snapshotPrefix, originalDB, projectID string) string {
+	if snapshotPrefix != "" {
+		if projectID == "" {
+			return fmt.Sprintf("%s_%s_", snapshotPrefix, originalDB)
+		}
+		if len(originalDB) > maxPrefixDatabaseLength {
+			originalDB = originalDB[:maxPrefixDatabaseLength]
+		}
+		return fmt.Sprintf("%s_%s_%s_", snapshotPrefix, originalDB, projectID)
+	}
+	if projectID == "" {
+		return originalDB + "_pgbranch_"
+	}
+	if len(originalDB) > maxPrefixDatabaseLength {
+		originalDB = originalDB[:maxPrefixDatabaseLength]
+	}
+	return fmt.Sprintf("%s_pgbranch_%s_", originalDB, projectID)
+}
+
 // SnapshotDBName generates a database name for a snapshot.
-// Format: {originalDB}_pgbranch_{branchName}
-func SnapshotDBName(originalDB, branchName string) string {
-	sanitized := strings.ReplaceAll(branchName, "-", "_")
+// Format: {SnapshotDBPrefix}{branchName}
+func SnapshotDBName(snapshotPrefix, originalDB, projectID, branchName string) string {
+	return SnapshotDBPrefix(snapshotPrefix, originalDB, projectID) + sanitizeName(branchName)
+}
+
+// SavedSnapshotDBName generates a database name for a snapshot saved with
+// 'pgbranch snapshot create'. Like TempDBName, it starts with a double
+// underscore, so it never collides with a branch's snapshot.
+// Format: {SnapshotDBPrefix}_snapshot_{name}
+func SavedSnapshotDBName(snapshotPrefix, originalDB, projectID, name string) string {
+	return TempDBName(snapshotPrefix, originalDB, projectID, "snapshot_"+sanitizeName(name))
+}
+
+// sanitizeName maps a branch or snapshot name to characters that are valid
+// in an unquoted database name.
+func sanitizeName(name string) string {
+	sanitized := strings.ReplaceAll(name, "-", "_")
 	sanitized = strings.ReplaceAll(sanitized, "/", "_")
-	sanitized = strings.ReplaceAll(sanitized, ".", "_")
-	return fmt.Sprintf("%s_pgbranch_%s", originalDB, sanitized)
+	return strings.ReplaceAll(sanitized, ".", "_")
+}
+
+// TempDBName generates a name for a temporary database used while an
+// operation is in progress. The double underscore cannot be produced by
+// SnapshotDBName for a valid branch name, so it never collides with a snapshot.
+func TempDBName(snapshotPrefix, originalDB, projectID, purpose string) string {
+	return SnapshotDBPrefix(snapshotPrefix, originalDB, projectID) + "_" + purpose
+}
+
+// IsNamespacedDBName reports whether name looks like a snapshot or temporary
+// database of some project with an ID, given the legacy prefix for
+// originalDB. Legacy projects use it to leave other projects' databases alone.
+func IsNamespacedDBName(snapshotPrefix, originalDB, name string) bool {
+	legacy := SnapshotDBPrefix(snapshotPrefix, originalDB, "")
+	if !strings.HasPrefix(name, legacy) {
+		return false
+	}
+	return projectIDPattern.MatchString(strings.TrimPrefix(name, legacy))
 }
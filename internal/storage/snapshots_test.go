@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,8 +22,92 @@ func TestSnapshotDBName(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.branchName, func(t *testing.T) {
-			result := SnapshotDBName(tt.originalDB, tt.branchName)
+			result := SnapshotDBName("", tt.originalDB, "", tt.branchName)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
+
+func TestValidateBranchName(t *testing.T) {
+	tests := []struct {
+		name    string
+		branch  string
+		wantErr bool
+	}{
+		{name: "simple", branch: "main"},
+		{name: "with dash", branch: "feature-1"},
+		{name: "with slash", branch: "feature/login"},
+		{name: "with dot", branch: "release.1.0"},
+		{name: "uppercase", branch: "JIRA-123"},
+		{name: "empty", branch: "", wantErr: true},
+		{name: "with space", branch: "my branch", wantErr: true},
+		{name: "leading dash", branch: "-main", wantErr: true},
+		{name: "with quote", branch: `bad"name`, wantErr: true},
+		{name: "with semicolon", branch: "x;drop", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBranchName(tt.branch)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSnapshotDBNameWithProjectID(t *testing.T) {
+	assert.Equal(t, "mydb_pgbranch_0a1b2c3d_feature_1", SnapshotDBName("", "mydb", "0a1b2c3d", "feature-1"))
+	assert.NotEqual(t, SnapshotDBName("", "mydb", "0a1b2c3d", "main"), SnapshotDBName("", "mydb", "ffffffff", "main"))
+
+	long := strings.Repeat("d", 60)
+	name := SnapshotDBName("", long, "0a1b2c3d", "main")
+	assert.Equal(t, strings.Repeat("d", 24)+"_pgbranch_0a1b2c3d_main", name)
+	assert.LessOrEqual(t, len(name), MaxIdentifierLength)
+}
+
+func TestTempDBName(t *testing.T) {
+	assert.Equal(t, "mydb_pgbranch__checkout", TempDBName("", "mydb", "", "checkout"))
+	assert.Equal(t, "mydb_pgbranch_0a1b2c3d__checkout", TempDBName("", "mydb", "0a1b2c3d", "checkout"))
+	assert.NotEqual(t, SnapshotDBName("", "mydb", "", "checkout"), TempDBName("", "mydb", "", "checkout"))
+}
+
+func TestSavedSnapshotDBName(t *testing.T) {
+	assert.Equal(t, "mydb_pgbranch__snapshot_pre_experiment", SavedSnapshotDBName("", "mydb", "", "pre-experiment"))
+	assert.Equal(t, "mydb_pgbranch_0a1b2c3d__snapshot_v1_0", SavedSnapshotDBName("", "mydb", "0a1b2c3d", "v1.0"))
+	assert.NotEqual(t, SnapshotDBName("", "mydb", "", "snapshot_main"), SavedSnapshotDBName("", "mydb", "", "main"))
+}
+
+func TestSnapshotDBPrefix(t *testing.T) {
+	for _, id := range []string{"", "0a1b2c3d"} {
+		prefix := SnapshotDBPrefix("", "mydb", id)
+		assert.True(t, strings.HasPrefix(SnapshotDBName("", "mydb", id, "feature/x"), prefix))
+		assert.True(t, strings.HasPrefix(TempDBName("", "mydb", id, "checkout"), prefix))
+	}
+	assert.Equal(t, "mydb_pgbranch_", SnapshotDBPrefix("", "mydb", ""))
+}
+
+func TestSnapshotDBNameWithSnapshotPrefix(t *testing.T) {
+	assert.Equal(t, "devbranch_mydb_0a1b2c3d_feature_1", SnapshotDBName("devbranch", "mydb", "0a1b2c3d", "feature-1"))
+	assert.Equal(t, "devbranch_mydb_feature_1", SnapshotDBName("devbranch", "mydb", "", "feature-1"))
+	assert.Equal(t, "devbranch_mydb_0a1b2c3d__checkout", TempDBName("devbranch", "mydb", "0a1b2c3d", "checkout"))
+	assert.Equal(t, "devbranch_mydb_0a1b2c3d__snapshot_v1", SavedSnapshotDBName("devbranch", "mydb", "0a1b2c3d", "v1"))
+
+	long := strings.Repeat("d", 60)
+	name := SnapshotDBName("devbranch", long, "0a1b2c3d", "main")
+	assert.Equal(t, "devbranch_"+strings.Repeat("d", 24)+"_0a1b2c3d_main", name)
+	assert.LessOrEqual(t, len(name), MaxIdentifierLength)
+
+	assert.True(t, IsNamespacedDBName("devbranch", "mydb", "devbranch_mydb_0a1b2c3d_main"))
+	assert.False(t, IsNamespacedDBName("devbranch", "mydb", "mydb_pgbranch_0a1b2c3d_main"))
+}
+
+func TestIsNamespacedDBName(t *testing.T) {
+	assert.True(t, IsNamespacedDBName("", "mydb", "mydb_pgbranch_0a1b2c3d_main"))
+	assert.True(t, IsNamespacedDBName("", "mydb", "mydb_pgbranch_0a1b2c3d__checkout"))
+	assert.False(t, IsNamespacedDBName("", "mydb", "mydb_pgbranch_main"))
+	assert.False(t, IsNamespacedDBName("", "mydb", "mydb_pgbranch__checkout"))
+	assert.False(t, IsNamespacedDBName("", "mydb", "otherdb_pgbranch_0a1b2c3d_main"))
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParentProblem is an invalid parent reference found in the metadata.
+type ParentProblem struct {
+	// Branch is the branch whose Parent field is invalid. For a cycle this
+	// is the first branch of the cycle by name.
+	Branch string
+	Parent string
+
+	// Cycle lists the branches of a parent cycle, starting at Branch and
+	// following parents. It is empty when Parent refers to a branch that
+	// does not exist.
+	Cycle []string
+}
+
+func (p ParentProblem) String() string {
+	if len(p.Cycle) > 0 {
+		return fmt.Sprintf("parent cycle %s -> %s", strings.Join(p.Cycle, " -> "), p.Branch)
+	}
+	return fmt.Sprintf("branch '%s' has missing parent '%s'", p.Branch, p.Parent)
+}
+
+// CheckParents returns the dangling parent references and parent cycles in
+// the metadata, sorted by branch name.
+func (m *Metadata) CheckParents() []ParentProblem {
+	var problems []ParentProblem
+
+	names := m.ListBranches()
+	sort.Strings(names)
+
+	for _, name := range names {
+		parent := m.Branches[name].Parent
+		if parent != "" && !m.BranchExists(parent) {
+			problems = append(problems, ParentProblem{Branch: name, Parent: parent})
+		}
+	}
+
+	// Every branch has at most one parent, so following parents from any
+	// branch either ends or runs into exactly one cycle.
+	done := make(map[string]bool)
+	for _, start := range names {
+		var path []string
+		onPath := make(map[string]int)
+
+		for name := start; name != "" && !done[name] && m.BranchExists(name); name = m.Branches[name].Parent {
+			if i, ok := onPath[name]; ok {
+				problems = append(problems, cycleProblem(m, path[i:]))
+				break
+			}
+			onPath[name] = len(path)
+			path = append(path, name)
+		}
+
+		for _, name := range path {
+			done[name] = true
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Branch < problems[j].Branch
+	})
+
+	return problems
+}
+
+// cycleProblem reports a cycle starting at its first branch by name.
+func cycleProblem(m *Metadata, cycle []string) ParentProblem {
+	first := 0
+	for i, name := range cycle {
+		if name < cycle[first] {
+			first = i
+		}
+	}
+	ordered := append(append([]string{}, cycle[first:]...), cycle[:first]...)
+
+	return ParentProblem{
+		Branch: ordered[0],
+		Parent: m.Branches[ordered[0]].Parent,
+		Cycle:  ordered,
+	}
+}
+
+// Validate checks that every parent reference points to an existing branch
+// and that following parents never loops.
+func (m *Metadata) Validate() error {
+	problems := m.CheckParents()
+	if len(problems) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, len(problems))
+	for i, p := range problems {
+		descriptions[i] = p.String()
+	}
+	return fmt.Errorf("invalid branch metadata: %s", strings.Join(descriptions, "; "))
+}
+
+// RepairParents clears the Parent field of each branch reported by
+// CheckParents, turning it into a root branch, and returns the problems
+// that were fixed. The caller is responsible for saving metadata.
+func (m *Metadata) RepairParents() []ParentProblem {
+	problems := m.CheckParents()
+	for _, p := range problems {
+		m.Branches[p.Branch].Parent = ""
+	}
+	return problems
+}
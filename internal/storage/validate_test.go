@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cyclicMetadata returns metadata with a valid chain, a dangling parent, a
+// self-parent and a three-branch cycle.
+func cyclicMetadata() *Metadata {
+	meta := NewMetadata()
+	meta.AddBranch("main", "", "main.dump")
+	meta.AddBranch("feature", "main", "feature.dump")
+	meta.AddBranch("leftover", "deleted", "leftover.dump")
+	meta.AddBranch("self", "self", "self.dump")
+	meta.AddBranch("loop-c", "loop-a", "loop-c.dump")
+	meta.AddBranch("loop-a", "loop-b", "loop-a.dump")
+	meta.AddBranch("loop-b", "loop-c", "loop-b.dump")
+	meta.AddBranch("tail", "loop-b", "tail.dump")
+	return meta
+}
+
+func TestCheckParents(t *testing.T) {
+	t.Run("valid metadata", func(t *testing.T) {
+		meta := NewMetadata()
+		meta.AddBranch("main", "", "main.dump")
+		meta.AddBranch("feature", "main", "feature.dump")
+
+		assert.Empty(t, meta.CheckParents())
+		assert.NoError(t, meta.Validate())
+	})
+
+	t.Run("cycles and dangling parents", func(t *testing.T) {
+		meta := cyclicMetadata()
+
+		problems := meta.CheckParents()
+		require.Len(t, problems, 3)
+
+		assert.Equal(t, "leftover", problems[0].Branch)
+		assert.Equal(t, "deleted", problems[0].Parent)
+		assert.Empty(t, problems[0].Cycle)
+
+		assert.Equal(t, "loop-a", problems[1].Branch)
+		assert.Equal(t, "loop-b", problems[1].Parent)
+		assert.Equal(t, []string{"loop-a", "loop-b", "loop-c"}, problems[1].Cycle)
+		assert.Equal(t, "parent cycle loop-a -> loop-b -> loop-c -> loop-a", problems[1].String())
+
+		assert.Equal(t, "self", problems[2].Branch)
+		assert.Equal(t, []string{"self"}, problems[2].Cycle)
+
+		err := meta.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "branch 'leftover' has missing parent 'deleted'")
+		assert.Contains(t, err.Error(), "parent cycle self -> self")
+	})
+}
+
+func TestRepairParents(t *testing.T) {
+	meta := cyclicMetadata()
+
+	fixed := meta.RepairParents()
+	assert.Len(t, fixed, 3)
+	assert.NoError(t, meta.Validate())
+
+	assert.Empty(t, meta.Branches["leftover"].Parent)
+	assert.Empty(t, meta.Branches["loop-a"].Parent)
+	assert.Empty(t, meta.Branches["self"].Parent)
+	assert.Equal(t, "loop-c", meta.Branches["loop-b"].Parent)
+	assert.Equal(t, "loop-b", meta.Branches["tail"].Parent)
+	assert.Equal(t, "main", meta.Branches["feature"].Parent)
+}
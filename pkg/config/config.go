@@ -4,10 +4,18 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgpassfile"
 )
 
 const (
@@ -17,8 +25,18 @@ const (
 	ConfigFileName = "config.json"
 	// SnapshotsDir is the name of the directory containing snapshot metadata.
 	SnapshotsDir = "snapshots"
+	// DefaultSSLMode is used when no sslmode is configured, matching the
+	// behavior of configs written before the setting existed.
+	DefaultSSLMode = "disable"
 )
 
+// Keys lists the settings that can be read and changed with Get and Set, in
+// display order.
+var Keys = []string{"database", "host", "port", "user", "sslmode", "default_remote"}
+
+// ValidSSLModes lists the sslmode values accepted by PostgreSQL.
+var ValidSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
 // RemoteConfig holds configuration for a remote storage backend.
 type RemoteConfig struct {
 	// Name is the name of this remote (e.g., "origin")
@@ -43,20 +61,51 @@ type Config struct {
 	User     string `json:"user"`
 	Password string `json:"password,omitempty"`
 
+	// SSLMode is the libpq sslmode used for every connection. Empty means
+	// DefaultSSLMode.
+	SSLMode string `json:"sslmode,omitempty"`
+	// SSLRootCert is the path to the CA certificate used to verify the
+	// server with the verify-ca and verify-full modes.
+	SSLRootCert string `json:"sslrootcert,omitempty"`
+
+	// ProjectID is a short hash identifying this project, included in
+	// snapshot database names so that projects sharing a server and
+	// database name don't collide. Configs written before it existed leave
+	// it empty and keep the legacy names.
+	ProjectID string `json:"project_id,omitempty"`
+
+	// SnapshotPrefix, if set, starts the names of all snapshot databases
+	// instead of the default {database}_pgbranch_ scheme, so they can be
+	// told apart in pg_database listings. See ValidateSnapshotPrefix.
+	SnapshotPrefix string `json:"snapshot_prefix,omitempty"`
+
 	Remotes map[string]*RemoteConfig `json:"remotes,omitempty"`
 
 	DefaultRemote string `json:"default_remote,omitempty"`
+
+	// fromEnv holds the keys of the connection settings that Load took
+	// from the environment, which Save leaves out.
+	fromEnv map[string]bool
 }
 
 // DefaultConfig returns a new Config with default values for PostgreSQL connection.
 func DefaultConfig() *Config {
 	return &Config{
-		Host: "localhost",
-		Port: 5432,
-		User: "postgres",
+		Host:    "localhost",
+		Port:    5432,
+		User:    "postgres",
+		SSLMode: DefaultSSLMode,
 	}
 }
 
+// GenerateProjectID returns the project ID for a project whose pgbranch
+// directory is rootDir and whose working database is database: the first 8
+// hex characters of a SHA-256 hash of both.
+func GenerateProjectID(rootDir, database string) string {
+	sum := sha256.Sum256([]byte(rootDir + "\x00" + database))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
 // GetRootDir returns the absolute path to the pgbranch configuration directory.
 func GetRootDir() (string, error) {
 	cwd, err := os.Getwd()
@@ -95,6 +144,10 @@ func IsInitialized() bool {
 }
 
 // Load reads and parses the configuration file from the current directory.
+// Connection settings the file leaves empty are taken from DATABASE_URL and
+// the libpq environment variables, as in FromEnvironment, except that
+// values that can't be used are ignored. Save doesn't write the settings
+// taken from the environment.
 func Load() (*Config, error) {
 	configPath, err := GetConfigPath()
 	if err != nil {
@@ -111,6 +164,10 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// Settings missing from the file, for example in a hand-written
+	// config, fall back to the environment as they would for psql.
+	cfg.fillFromEnv()
+
 	return &cfg, nil
 }
 
@@ -121,12 +178,12 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := json.MarshalIndent(c.withoutEnv(), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to serialize config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	if err := WriteFileAtomic(configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -135,22 +192,116 @@ func (c *Config) Save() error {
 
 // ConnectionString returns a PostgreSQL connection string for the configured database.
 func (c *Config) ConnectionString() string {
-	connStr := fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=disable",
-		c.Host, c.Port, c.User, c.Database)
-	if c.Password != "" {
-		connStr += fmt.Sprintf(" password=%s", c.Password)
+	connStr := fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=%s",
+		c.Host, c.Port, c.User, c.Database, c.EffectiveSSLMode())
+	if c.SSLRootCert != "" {
+		connStr += fmt.Sprintf(" sslrootcert=%s", c.SSLRootCert)
+	}
+	if password := c.ResolvePassword(c.Database); password != "" {
+		connStr += fmt.Sprintf(" password=%s", password)
 	}
 	return connStr
 }
 
-// ConnectionURLForDB returns a PostgreSQL connection URL for the specified database name.
+// ConnectionURLForDB returns a PostgreSQL connection URL for the specified
+// database name. A host starting with '/' is a Unix socket directory, which
+// is passed in the query since it can't be written as the URL's host.
 func (c *Config) ConnectionURLForDB(dbName string) string {
+	query := "sslmode=" + url.QueryEscape(c.EffectiveSSLMode())
+	if c.SSLRootCert != "" {
+		query += "&sslrootcert=" + url.QueryEscape(c.SSLRootCert)
+	}
+
+	user := url.User(c.User)
+	if password := c.ResolvePassword(dbName); password != "" {
+		user = url.UserPassword(c.User, password)
+	}
+
+	if strings.HasPrefix(c.Host, "/") {
+		return fmt.Sprintf("postgres://%s@/%s?host=%s&port=%d&%s",
+			user.String(), dbName, url.QueryEscape(c.Host), c.Port, query)
+	}
+
+	return fmt.Sprintf("postgres://%s@%s:%d/%s?%s",
+		user.String(), c.Host, c.Port, dbName, query)
+}
+
+// ResolvePassword returns the password to use when connecting to dbName.
+// The password stored in the config takes precedence, followed by the
+// PGPASSWORD environment variable and then the first matching entry in the
+// password file named by PGPASSFILE, or ~/.pgpass. It returns an empty
+// string when none of them provide a password.
+func (c *Config) ResolvePassword(dbName string) string {
 	if c.Password != "" {
-		return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
-			c.User, c.Password, c.Host, c.Port, dbName)
+		return c.Password
 	}
-	return fmt.Sprintf("postgres://%s@%s:%d/%s?sslmode=disable",
-		c.User, c.Host, c.Port, dbName)
+
+	if password := os.Getenv("PGPASSWORD"); password != "" {
+		return password
+	}
+
+	path := os.Getenv("PGPASSFILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+
+	passfile, err := pgpassfile.ReadPassfile(path)
+	if err != nil {
+		return ""
+	}
+
+	return passfile.FindPassword(c.Host, strconv.Itoa(c.Port), dbName, c.User)
+}
+
+// EffectiveSSLMode returns the configured sslmode, or DefaultSSLMode when
+// none is set.
+func (c *Config) EffectiveSSLMode() string {
+	if c.SSLMode == "" {
+		return DefaultSSLMode
+	}
+	return c.SSLMode
+}
+
+// ValidateSSLMode checks that mode is an sslmode accepted by PostgreSQL. An
+// empty mode is valid and means DefaultSSLMode.
+func ValidateSSLMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, valid := range ValidSSLModes {
+		if mode == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sslmode %q: must be one of %v", mode, ValidSSLModes)
+}
+
+// MaxSnapshotPrefixLength is the longest snapshot prefix accepted, leaving
+// room in PostgreSQL's 63-byte identifiers for the database name, project
+// ID and branch name.
+const MaxSnapshotPrefixLength = 16
+
+var snapshotPrefixPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidateSnapshotPrefix checks that prefix can start an unquoted database
+// name: lowercase letters, digits and underscores, starting with a letter,
+// at most MaxSnapshotPrefixLength bytes. An empty prefix is valid and
+// selects the default naming scheme.
+func ValidateSnapshotPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if !snapshotPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid snapshot prefix '%s': must start with a lowercase letter and contain only lowercase letters, digits and '_'", prefix)
+	}
+	if len(prefix) > MaxSnapshotPrefixLength {
+		return fmt.Errorf("invalid snapshot prefix '%s': must be at most %d characters", prefix, MaxSnapshotPrefixLength)
+	}
+	return nil
 }
 
 // Validate checks that all required configuration fields are set.
@@ -167,6 +318,67 @@ func (c *Config) Validate() error {
 	if c.User == "" {
 		return fmt.Errorf("user is required")
 	}
+	if err := ValidateSSLMode(c.SSLMode); err != nil {
+		return err
+	}
+	if err := ValidateSnapshotPrefix(c.SnapshotPrefix); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get returns the value of the setting named key as a string. See Keys for
+// the supported names.
+func (c *Config) Get(key string) (string, error) {
+	switch key {
+	case "database":
+		return c.Database, nil
+	case "host":
+		return c.Host, nil
+	case "port":
+		return strconv.Itoa(c.Port), nil
+	case "user":
+		return c.User, nil
+	case "sslmode":
+		return c.EffectiveSSLMode(), nil
+	case "default_remote":
+		return c.DefaultRemote, nil
+	default:
+		return "", fmt.Errorf("unknown config key '%s': must be one of %v", key, Keys)
+	}
+}
+
+// Set changes the setting named key to value. Only the value's format is
+// checked; call Validate before saving to check the resulting config.
+func (c *Config) Set(key, value string) error {
+	switch key {
+	case "database":
+		c.Database = value
+	case "host":
+		c.Host = value
+	case "port":
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port '%s': must be a number between 1 and 65535", value)
+		}
+		c.Port = port
+	case "user":
+		c.User = value
+	case "sslmode":
+		c.SSLMode = value
+	case "default_remote":
+		if value == "" {
+			c.DefaultRemote = ""
+			return nil
+		}
+		return c.SetDefaultRemote(value)
+	default:
+		return fmt.Errorf("unknown config key '%s': must be one of %v", key, Keys)
+	}
+
+	// A setting the user sets is saved even if it was taken from the
+	// environment.
+	delete(c.fromEnv, key)
 	return nil
 }
 
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromDatabaseURL parses a PostgreSQL connection URL, such as the value of
+// DATABASE_URL, into a Config. The scheme must be postgres:// or
+// postgresql://; the sslmode and sslrootcert query parameters are read, as
+// are host and port when the URL has no host part, as in
+// postgres:///db?host=/var/run/postgresql. Other parameters are ignored. Parts the URL leaves out get the values of
+// DefaultConfig.
+func FromDatabaseURL(rawURL string) (*Config, error) {
+	cfg, err := parseDatabaseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg.fillDefaults()
+	return cfg, nil
+}
+
+// FromEnvironment returns a Config built from the DATABASE_URL environment
+// variable, if set, with the settings it leaves out taken from the libpq
+// variables PGHOST, PGPORT, PGUSER, PGDATABASE, PGSSLMODE and
+// PGSSLROOTCERT, and then from DefaultConfig. PGPASSWORD is not copied; it
+// is read at connection time by ResolvePassword.
+func FromEnvironment() (*Config, error) {
+	cfg, err := envConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.fillDefaults()
+	return cfg, nil
+}
+
+// envConfig returns the settings given by DATABASE_URL and the libpq
+// variables, leaving the others empty. Values that can't be used are left
+// out and reported in the returned error, which joins all such problems;
+// the settings are returned either way.
+func envConfig() (*Config, error) {
+	var errs []error
+	cfg := &Config{}
+	if rawURL := os.Getenv("DATABASE_URL"); rawURL != "" {
+		parsed, err := parseDatabaseURL(rawURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid DATABASE_URL: %w", err))
+		} else {
+			cfg = parsed
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+func parseDatabaseURL(rawURL string) (*Config, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return nil, fmt.Errorf("invalid database URL: scheme must be postgres:// or postgresql://, got '%s'", u.Scheme)
+	}
+
+	query := u.Query()
+
+	cfg := &Config{
+		Host:     u.Hostname(),
+		Database: strings.TrimPrefix(u.Path, "/"),
+	}
+
+	// A Unix socket directory can't be written as the URL's host, so it is
+	// given in the query instead.
+	port := u.Port()
+	if cfg.Host == "" {
+		cfg.Host = query.Get("host")
+		if port == "" {
+			port = query.Get("port")
+		}
+	}
+
+	if port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("invalid database URL: invalid port '%s'", port)
+		}
+		cfg.Port = p
+	}
+
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+
+	cfg.SSLMode = query.Get("sslmode")
+	cfg.SSLRootCert = query.Get("sslrootcert")
+	if err := ValidateSSLMode(cfg.SSLMode); err != nil {
+		return nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// applyEnv fills settings that are not set from the libpq environment
+// variables. Invalid values are skipped and reported in the returned error.
+func (c *Config) applyEnv() error {
+	var errs []error
+	if c.Host == "" {
+		c.Host = os.Getenv("PGHOST")
+	}
+	if c.Port == 0 {
+		if port := os.Getenv("PGPORT"); port != "" {
+			p, err := strconv.Atoi(port)
+			if err != nil || p <= 0 || p > 65535 {
+				errs = append(errs, fmt.Errorf("invalid PGPORT '%s': must be a number between 1 and 65535", port))
+			} else {
+				c.Port = p
+			}
+		}
+	}
+	if c.User == "" {
+		c.User = os.Getenv("PGUSER")
+	}
+	if c.Database == "" {
+		c.Database = os.Getenv("PGDATABASE")
+	}
+	if c.SSLMode == "" {
+		mode := os.Getenv("PGSSLMODE")
+		if err := ValidateSSLMode(mode); err != nil {
+			errs = append(errs, fmt.Errorf("invalid PGSSLMODE: %w", err))
+		} else {
+			c.SSLMode = mode
+		}
+	}
+	if c.SSLRootCert == "" {
+		c.SSLRootCert = os.Getenv("PGSSLROOTCERT")
+	}
+	return errors.Join(errs...)
+}
+
+// fillDefaults sets the connection settings that are still empty to the
+// values of DefaultConfig.
+func (c *Config) fillDefaults() {
+	defaults := DefaultConfig()
+	if c.Host == "" {
+		c.Host = defaults.Host
+	}
+	if c.Port == 0 {
+		c.Port = defaults.Port
+	}
+	if c.User == "" {
+		c.User = defaults.User
+	}
+	if c.SSLMode == "" {
+		c.SSLMode = defaults.SSLMode
+	}
+}
+
+// fillFromEnv sets the connection settings that are empty in c from
+// DATABASE_URL and the libpq variables, and records which ones it set so
+// that Save leaves them out. Values that can't be used, such as a
+// DATABASE_URL for another database system, are ignored, since they may be
+// meant for another program.
+func (c *Config) fillFromEnv() {
+	env, _ := envConfig()
+
+	taken := func(key string) {
+		if c.fromEnv == nil {
+			c.fromEnv = make(map[string]bool)
+		}
+		c.fromEnv[key] = true
+	}
+	if c.Database == "" && env.Database != "" {
+		c.Database = env.Database
+		taken("database")
+	}
+	if c.Host == "" && env.Host != "" {
+		c.Host = env.Host
+		taken("host")
+	}
+	if c.Port == 0 && env.Port != 0 {
+		c.Port = env.Port
+		taken("port")
+	}
+	if c.User == "" && env.User != "" {
+		c.User = env.User
+		taken("user")
+	}
+	if c.SSLMode == "" && env.SSLMode != "" {
+		c.SSLMode = env.SSLMode
+		taken("sslmode")
+	}
+	if c.SSLRootCert == "" && env.SSLRootCert != "" {
+		c.SSLRootCert = env.SSLRootCert
+		taken("sslrootcert")
+	}
+}
+
+// withoutEnv returns a copy of c without the settings that fillFromEnv
+// took from the environment.
+func (c *Config) withoutEnv() *Config {
+	saved := *c
+	saved.fromEnv = nil
+	for key := range c.fromEnv {
+		switch key {
+		case "database":
+			saved.Database = ""
+		case "host":
+			saved.Host = ""
+		case "port":
+			saved.Port = 0
+		case "user":
+			saved.User = ""
+		case "sslmode":
+			saved.SSLMode = ""
+		case "sslrootcert":
+			saved.SSLRootCert = ""
+		}
+	}
+	return &saved
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Run("replaces contents", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"old":true}`), 0644))
+
+		require.NoError(t, WriteFileAtomic(path, []byte(`{"new":true}`), 0600))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `{"new":true}`, string(data))
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temporary file should not be left behind")
+	})
+
+	t.Run("interrupted write leaves original intact", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"database":"app"}`), 0644))
+
+		// A process killed before the rename leaves a partial temporary
+		// file next to the target.
+		partial := filepath.Join(dir, "config.json.tmp-123")
+		require.NoError(t, os.WriteFile(partial, []byte(`{"datab`), 0644))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `{"database":"app"}`, string(data))
+
+		require.NoError(t, WriteFileAtomic(path, []byte(`{"database":"other"}`), 0644))
+
+		data, err = os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `{"database":"other"}`, string(data))
+	})
+
+	t.Run("failed rename leaves original intact", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "target")
+		require.NoError(t, os.MkdirAll(filepath.Join(path, "child"), 0755))
+
+		err := WriteFileAtomic(path, []byte("data"), 0644)
+		require.Error(t, err)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temporary file should be removed")
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "config.json")
+
+		err := WriteFileAtomic(path, []byte("data"), 0644)
+		assert.Error(t, err)
+	})
+}